@@ -0,0 +1,464 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/clock"
+	"github.com/Arend-melissant/simhospital/pkg/diagram"
+	"github.com/Arend-melissant/simhospital/pkg/dryrun"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/schema"
+	"github.com/Arend-melissant/simhospital/pkg/state/migrate"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistcrypt"
+)
+
+// Commands that can be run instead of running Simulated Hospital.
+const (
+	validateCommand    = "validate"
+	renderCommand      = "render"
+	schemaCommand      = "schema"
+	diagramCommand     = "diagram"
+	migrateCommand     = "migrate"
+	reencryptCommand   = "reencrypt"
+	printConfigCommand = "print-effective-config"
+)
+
+// Exit codes of the commands.
+const (
+	exitOK       = 0
+	exitProblems = 1
+	exitError    = 2
+)
+
+// commandConfig returns the hospital configuration for the commands.
+// The commands never send messages nor persist anything, so no sender is created; the pathways are
+// parsed by the commands themselves. If the command runs pathways, the resources they generate are
+// written to stdout, in the format in -resource_format, and never to -resource_output, so that the
+// command has no side effects; otherwise, no resource writer is created.
+func commandConfig(ctx context.Context, c clock.Clock, runsPathways bool) (hospital.Config, error) {
+	arguments := hospitalArguments()
+	arguments.Clock = c
+	arguments.PathwayArguments = nil
+	arguments.SenderArguments = nil
+	if runsPathways {
+		arguments.ResourceArguments = &hospital.ResourceArguments{Output: "stdout", Format: arguments.ResourceArguments.Format}
+	} else {
+		arguments.ResourceArguments = nil
+	}
+	config, err := hospital.DefaultConfig(ctx, arguments)
+	if err != nil {
+		return hospital.Config{}, errors.Wrap(err, "cannot create default hospital config")
+	}
+	return config, nil
+}
+
+// validate parses and validates all the pathways in -pathways_dir, and writes every problem found to w,
+// with the file and the line where it is.
+// It returns exitProblems if any pathway is not valid.
+func validate(ctx context.Context, w io.Writer) int {
+	config, err := commandConfig(ctx, &clock.RealTimeClock{}, false)
+	if err != nil {
+		log.WithError(err).Error("Cannot validate pathways")
+		return exitError
+	}
+	dir := addLocalPathIfNotSet(*pathwaysDir, "pathways_dir")
+	pathways, problems, err := config.PathwayParser.Lint(ctx, dir)
+	if err != nil {
+		log.WithError(err).Error("Cannot validate pathways")
+		return exitError
+	}
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	fmt.Fprintf(w, "%d valid pathways, %d problems found in %s\n", len(pathways), len(problems), dir)
+	if len(problems) > 0 {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// render runs the pathway named in args against a fake clock, and writes the messages it generates to w,
+// with the time at which they are sent. Delays in the pathway do not slow down the command.
+// args are the arguments of the command, i.e., the command line arguments after "render".
+func render(ctx context.Context, w io.Writer, args []string) int {
+	fs := flag.NewFlagSet(renderCommand, flag.ContinueOnError)
+	start := fs.String("start", "", "Time at which the pathway starts, in RFC 3339 format, e.g., 2020-02-12T10:00:00Z. If not set, the pathway starts at the current time")
+	maxDuration := fs.Duration("max_duration", 365*24*time.Hour, "Maximum amount of simulated time the pathway can run for")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: simulator [flags] %s [-start time] [-max_duration duration] <pathway_name>\n", renderCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	name := fs.Arg(0)
+
+	startTime := time.Now()
+	if *start != "" {
+		t, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			log.WithError(err).WithField("start", *start).Error("Cannot parse the start time")
+			return exitError
+		}
+		startTime = t
+	}
+	c := clock.NewManualClock(startTime)
+	config, err := commandConfig(ctx, c, true)
+	if err != nil {
+		log.WithError(err).Error("Cannot render pathway")
+		return exitError
+	}
+
+	dir := addLocalPathIfNotSet(*pathwaysDir, "pathways_dir")
+	pathways, problems, err := config.PathwayParser.Lint(ctx, dir)
+	if err != nil {
+		log.WithError(err).Error("Cannot parse pathways")
+		return exitError
+	}
+	if _, ok := pathways[name]; !ok {
+		for _, p := range problems {
+			if p.Pathway == name {
+				fmt.Fprintln(w, p)
+			}
+		}
+		log.WithField("pathway_name", name).Error("The pathway does not exist or is not valid")
+		return exitProblems
+	}
+
+	messages, err := dryrun.Run(ctx, dryrun.Config{Hospital: config, Clock: c, MaxDuration: *maxDuration}, pathways, name)
+	for _, m := range messages {
+		fmt.Fprintf(w, "--- %s ---\n%s\n\n", m.Time.Format(time.RFC3339), strings.ReplaceAll(strings.TrimRight(m.Message, "\r"), "\r", "\n"))
+	}
+	if err != nil {
+		log.WithError(err).WithField("pathway_name", name).Error("Cannot render pathway")
+		return exitProblems
+	}
+	return exitOK
+}
+
+// writeSchemas writes the JSON Schemas of pathways and other configuration files to the directory in
+// the -out argument.
+// args are the arguments of the command, i.e., the command line arguments after "schema".
+func writeSchemas(args []string) int {
+	fs := flag.NewFlagSet(schemaCommand, flag.ContinueOnError)
+	out := fs.String("out", "configs/schema", "Directory to write the schemas to")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if err := schema.WriteAll(*out); err != nil {
+		log.WithError(err).Error("Cannot write schemas")
+		return exitError
+	}
+	return exitOK
+}
+
+// drawDiagram writes the diagram of the pathway named in args to w.
+// args are the arguments of the command, i.e., the command line arguments after "diagram".
+func drawDiagram(ctx context.Context, w io.Writer, args []string) int {
+	fs := flag.NewFlagSet(diagramCommand, flag.ContinueOnError)
+	format := fs.String("format", string(diagram.Mermaid), fmt.Sprintf("Format of the diagram, either %q or %q", diagram.Mermaid, diagram.DOT))
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: simulator [flags] %s [-format format] <pathway_name>\n", diagramCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	name := fs.Arg(0)
+	f, err := diagram.ParseFormat(*format)
+	if err != nil {
+		log.WithError(err).Error("Cannot draw pathway")
+		return exitError
+	}
+
+	config, err := commandConfig(ctx, &clock.RealTimeClock{}, false)
+	if err != nil {
+		log.WithError(err).Error("Cannot draw pathway")
+		return exitError
+	}
+	dir := addLocalPathIfNotSet(*pathwaysDir, "pathways_dir")
+	pathways, problems, err := config.PathwayParser.Lint(ctx, dir)
+	if err != nil {
+		log.WithError(err).Error("Cannot parse pathways")
+		return exitError
+	}
+	p, ok := pathways[name]
+	if !ok {
+		for _, p := range problems {
+			if p.Pathway == name {
+				fmt.Fprintln(w, p)
+			}
+		}
+		log.WithField("pathway_name", name).Error("The pathway does not exist or is not valid")
+		return exitProblems
+	}
+	if err := diagram.Write(w, f, name, &p); err != nil {
+		log.WithError(err).WithField("pathway_name", name).Error("Cannot draw pathway")
+		return exitError
+	}
+	return exitOK
+}
+
+// migrateState copies the persisted state from the persistence backend in the -from argument to the
+// backend in the -to argument, verifies it, and writes the number of items of each type that were
+// migrated to w. Each backend is configured with the flags of the backend, e.g., -bolt_file or
+// -sql_dsn, which the -from_ and -to_ arguments of the command override for the source and the
+// destination respectively, e.g., -to_sql_dsn. The source and the destination can be the same kind
+// of backend, but not the same data, and the destination must be empty.
+// args are the arguments of the command, i.e., the command line arguments after "migrate".
+func migrateState(ctx context.Context, w io.Writer, args []string) int {
+	backends := fmt.Sprintf("[%s, %s, %s]", persistenceBolt, persistenceCosmos, persistenceSQL)
+	fs := flag.NewFlagSet(migrateCommand, flag.ContinueOnError)
+	from := addMigrateFlags(fs, "from", "source", backends)
+	to := addMigrateFlags(fs, "to", "destination", backends)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: simulator [flags] %s -from backend -to backend [-from_* setting] [-to_* setting]\n", migrateCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 0 || *from.backend == "" || *to.backend == "" {
+		fs.Usage()
+		return exitError
+	}
+	if *from.backend == persistenceNone || *to.backend == persistenceNone {
+		log.Errorf("Cannot migrate the state from or to %q; supported backends: %s", persistenceNone, backends)
+		return exitError
+	}
+
+	srcConfig, err := from.config()
+	if err != nil {
+		log.WithError(err).Error("Invalid source backend")
+		return exitError
+	}
+	dstConfig, err := to.config()
+	if err != nil {
+		log.WithError(err).Error("Invalid destination backend")
+		return exitError
+	}
+	if srcConfig.location() == dstConfig.location() {
+		log.WithField("backend", srcConfig.location()).
+			Error("Cannot migrate the state to the same data it is migrated from; set a different destination with the -to_ arguments")
+		return exitError
+	}
+
+	src, err := openBackend(ctx, srcConfig)
+	if err != nil {
+		log.WithError(err).Error("Cannot open the source backend")
+		return exitError
+	}
+	defer src.close()
+	dst, err := openBackend(ctx, dstConfig)
+	if err != nil {
+		log.WithError(err).Error("Cannot open the destination backend")
+		return exitError
+	}
+	defer dst.close()
+
+	result, err := migrate.Migrate(src.syncers, dst.syncers)
+	itemTypes := make([]string, 0, len(result))
+	for itemType := range result {
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Strings(itemTypes)
+	for _, itemType := range itemTypes {
+		fmt.Fprintf(w, "%s: %d items migrated and verified\n", itemType, result[itemType])
+	}
+	if err != nil {
+		log.WithError(err).WithField("from", *from.backend).WithField("to", *to.backend).Error("Cannot migrate the state")
+		return exitProblems
+	}
+	return exitOK
+}
+
+// migrateFlags are the arguments of the migrate command that configure the source or the
+// destination. The settings that are empty are taken from the flags of the backend.
+type migrateFlags struct {
+	backend           *string
+	boltFile          *string
+	cosmosEndpoint    *string
+	cosmosKey         *string
+	cosmosToken       *string
+	cosmosDatabase    *string
+	cosmosContainer   *string
+	sqlDriver         *string
+	sqlDSN            *string
+	encryptionKeyFile *string
+	plaintext         *bool
+}
+
+// addMigrateFlags adds to fs the arguments of the migrate command for the source or the
+// destination, named after the given prefix, e.g., -from and -from_bolt_file.
+func addMigrateFlags(fs *flag.FlagSet, prefix, side, backends string) *migrateFlags {
+	name := func(setting string) string { return prefix + "_" + setting }
+	overrides := func(flagName string) string {
+		return fmt.Sprintf("If empty, -%s is used", flagName)
+	}
+	return &migrateFlags{
+		backend:           fs.String(prefix, "", fmt.Sprintf("Persistence backend of the %s: %s", side, backends)),
+		boltFile:          fs.String(name("bolt_file"), "", fmt.Sprintf("Path to the BoltDB database file of the %s. %s", side, overrides("bolt_file"))),
+		cosmosEndpoint:    fs.String(name("cosmos_endpoint"), "", fmt.Sprintf("URL of the Cosmos DB account of the %s. %s", side, overrides("cosmos_endpoint"))),
+		cosmosKey:         fs.String(name("cosmos_key"), "", fmt.Sprintf("Key of the Cosmos DB account of the %s. Cannot be set together with -%s", side, name("cosmos_token"))),
+		cosmosToken:       fs.String(name("cosmos_token"), "", fmt.Sprintf("Azure Active Directory access token for the Cosmos DB account of the %s. Cannot be set together with -%s", side, name("cosmos_key"))),
+		cosmosDatabase:    fs.String(name("cosmos_database"), "", fmt.Sprintf("Name of the Cosmos DB database of the %s. %s", side, overrides("cosmos_database"))),
+		cosmosContainer:   fs.String(name("cosmos_container"), "", fmt.Sprintf("Name of the Cosmos DB container of the %s. %s", side, overrides("cosmos_container"))),
+		sqlDriver:         fs.String(name("sql_driver"), "", fmt.Sprintf("The SQL database of the %s: [sqlite, postgres]. %s", side, overrides("sql_driver"))),
+		sqlDSN:            fs.String(name("sql_dsn"), "", fmt.Sprintf("Data source name of the SQL database of the %s. %s", side, overrides("sql_dsn"))),
+		encryptionKeyFile: fs.String(name("encryption_key_file"), "", fmt.Sprintf("Path to a file with the keys that the %s is encrypted with. %s", side, overrides("encryption_key_file"))),
+		plaintext:         fs.Bool(name("plaintext"), false, fmt.Sprintf("Whether the %s is not encrypted, even if the encryption keys are set", side)),
+	}
+}
+
+// config returns the configuration of the backend, with its defaults set.
+func (f *migrateFlags) config() (backendConfig, error) {
+	c := flagBackendConfig(*f.backend)
+	override := func(setting *string, value string) {
+		if value != "" {
+			*setting = value
+		}
+	}
+	override(&c.boltFile, *f.boltFile)
+	override(&c.cosmos.Endpoint, *f.cosmosEndpoint)
+	if *f.cosmosKey != "" || *f.cosmosToken != "" {
+		c.cosmos.Key, c.cosmos.Token = *f.cosmosKey, *f.cosmosToken
+	}
+	override(&c.cosmos.Database, *f.cosmosDatabase)
+	override(&c.cosmos.Container, *f.cosmosContainer)
+	override(&c.sqlDriver, *f.sqlDriver)
+	override(&c.sqlDSN, *f.sqlDSN)
+	switch {
+	case *f.plaintext && *f.encryptionKeyFile != "":
+		return backendConfig{}, errors.New("the encryption keys cannot be set for a backend that is not encrypted")
+	case *f.plaintext:
+		c.keys = func() (*persistcrypt.Keyring, error) { return nil, nil }
+	case *f.encryptionKeyFile != "":
+		file := *f.encryptionKeyFile
+		c.keys = func() (*persistcrypt.Keyring, error) { return persistcrypt.LoadKeyring(file) }
+	}
+	return c.withDefaults()
+}
+
+// reencryptState encrypts again with the primary encryption key the items persisted in the backend
+// in -persistence that are encrypted with other keys or not encrypted at all, and writes the number
+// of items of each type that were encrypted again to w. After that, the other keys can be removed.
+// args are the arguments of the command, i.e., the command line arguments after "reencrypt".
+func reencryptState(ctx context.Context, w io.Writer, args []string) int {
+	if len(args) != 0 {
+		log.Errorf("Usage: simulator [flags] %s", reencryptCommand)
+		return exitError
+	}
+	if *persistence == persistenceNone {
+		log.Error("Cannot re-encrypt the state: -persistence is not set")
+		return exitError
+	}
+	c := flagBackendConfig(*persistence)
+	keys, err := c.keys()
+	if err != nil {
+		log.WithError(err).Error("Cannot load the encryption keys")
+		return exitError
+	}
+	if keys == nil {
+		log.Errorf("Cannot re-encrypt the state: set the encryption keys with -encryption_key_file or the %s environment variable", persistcrypt.KeysEnv)
+		return exitError
+	}
+	b, err := openBackend(ctx, c)
+	if err != nil {
+		log.WithError(err).Error("Cannot open the persistence backend")
+		return exitError
+	}
+	defer b.close()
+
+	itemTypes := make([]string, 0, len(b.syncers))
+	for itemType := range b.syncers {
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Strings(itemTypes)
+	for _, itemType := range itemTypes {
+		n, err := b.syncers[itemType].(*persistcrypt.ItemSyncer).Reencrypt()
+		fmt.Fprintf(w, "%s: %d items re-encrypted with key %q\n", itemType, n, keys.PrimaryKeyID())
+		if err != nil {
+			log.WithError(err).WithField("item_type", itemType).Error("Cannot re-encrypt the state")
+			return exitProblems
+		}
+	}
+	return exitOK
+}
+
+// backend is a persistence backend opened by a command.
+type backend struct {
+	name    string
+	syncers map[string]persist.ItemSyncer
+	closer  io.Closer
+}
+
+func openBackend(ctx context.Context, c backendConfig) (*backend, error) {
+	syncers, closer, err := backendItemSyncers(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return &backend{name: c.name, syncers: syncers, closer: closer}, nil
+}
+
+func (b *backend) close() {
+	if b.closer == nil {
+		return
+	}
+	if err := b.closer.Close(); err != nil {
+		log.WithError(err).WithField("backend", b.name).Error("Error when closing the persistence backend")
+	}
+}
+
+// runCommand runs the given command, and exits with the exit code of the command.
+func runCommand(ctx context.Context, command string, args []string) {
+	switch command {
+	case validateCommand:
+		os.Exit(validate(ctx, os.Stdout))
+	case renderCommand:
+		os.Exit(render(ctx, os.Stdout, args))
+	case schemaCommand:
+		os.Exit(writeSchemas(args))
+	case diagramCommand:
+		os.Exit(drawDiagram(ctx, os.Stdout, args))
+	case migrateCommand:
+		os.Exit(migrateState(ctx, os.Stdout, args))
+	case reencryptCommand:
+		os.Exit(reencryptState(ctx, os.Stdout, args))
+	case printConfigCommand:
+		os.Exit(printEffectiveConfig(os.Stdout, args))
+	default:
+		log.WithField("command", command).Fatalf("Unknown command; supported commands are %s, %s, %s, %s, %s, %s and %s",
+			validateCommand, renderCommand, schemaCommand, diagramCommand, migrateCommand, reencryptCommand, printConfigCommand)
+	}
+}
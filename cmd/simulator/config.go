@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+	"github.com/Arend-melissant/simhospital/pkg/flagconfig"
+)
+
+// envPrefix is the prefix of the environment variables that set the flags, e.g.,
+// SIMHOSPITAL_PATHWAYS_PER_HOUR sets -pathways_per_hour.
+const envPrefix = "SIMHOSPITAL_"
+
+// configOptions configure how the flags are set from the -config_file file and from the environment.
+var configOptions = flagconfig.Options{
+	EnvPrefix: envPrefix,
+	// The persistence backends read their environment variables themselves, only if none of the
+	// related flags are set.
+	NoEnv:     []string{"cosmos_endpoint", "cosmos_key", "cosmos_token", "sql_dsn"},
+	NotInFile: []string{"config_file"},
+	Secret:    []string{"api_key", "cosmos_key", "cosmos_token", "sql_dsn"},
+}
+
+// flagSources are where the values of the flags come from; see applyConfig.
+var flagSources flagconfig.Sources
+
+// applyConfig sets the flags that were not set in the command line from the environment variables
+// and from the -config_file file, if any, and returns where the values of the flags come from.
+func applyConfig(ctx context.Context) (flagconfig.Sources, error) {
+	fileName := *configFile
+	if !isSetInCommandLine("config_file") {
+		if v, ok := os.LookupEnv(configOptions.EnvName("config_file")); ok {
+			fileName = v
+		}
+	}
+	var data []byte
+	if fileName != "" {
+		var err error
+		if data, err = files.Read(ctx, fileName); err != nil {
+			return nil, errors.Wrapf(err, "cannot read configuration file %s", fileName)
+		}
+	}
+	return flagconfig.Apply(flag.CommandLine, data, fileName, configOptions)
+}
+
+// isSetInCommandLine returns whether the flag with the given name was set in the command line.
+// It must be called before applyConfig.
+func isSetInCommandLine(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// printEffectiveConfig writes the effective value of every flag to w, in the format of the
+// -config_file file, with where each value comes from. Secrets, e.g., -api_key, are redacted.
+// args are the arguments of the command, i.e., the command line arguments after "print-effective-config".
+func printEffectiveConfig(w io.Writer, args []string) int {
+	fs := flag.NewFlagSet(printConfigCommand, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: simulator [flags] %s\n", printConfigCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+	fmt.Fprintln(w, "# Effective configuration of Simulated Hospital. Each value is followed by where it comes from.")
+	if err := flagconfig.WriteEffective(w, flag.CommandLine, flagSources, configOptions); err != nil {
+		log.WithError(err).Error("Cannot print the effective configuration")
+		return exitError
+	}
+	return exitOK
+}
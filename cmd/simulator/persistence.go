@@ -0,0 +1,181 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistazure"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistcrypt"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistdb"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistsql"
+)
+
+// Persistence backends that can be set in -persistence.
+const (
+	persistenceNone   = "none"
+	persistenceBolt   = "bolt"
+	persistenceCosmos = "cosmos"
+	persistenceSQL    = "sql"
+)
+
+// defaultSQLiteFile is the SQLite database file used if -sql_dsn is not set.
+const defaultSQLiteFile = "simhosp.sqlite"
+
+// itemSyncers returns the syncers of the backend set in -persistence, keyed by item type, or nil if
+// the state is not persisted. If the backend needs to be closed when Simulated Hospital exits,
+// itemSyncers also returns its closer.
+func itemSyncers(ctx context.Context) (map[string]persist.ItemSyncer, io.Closer, error) {
+	return backendItemSyncers(ctx, flagBackendConfig(*persistence))
+}
+
+// backendConfig is the configuration of a persistence backend.
+type backendConfig struct {
+	// name is the backend, e.g., persistenceBolt.
+	name      string
+	boltFile  string
+	cosmos    persistazure.Config
+	sqlDriver string
+	sqlDSN    string
+	// keys returns the keys that the items are encrypted with, or nil if they are not encrypted.
+	keys func() (*persistcrypt.Keyring, error)
+}
+
+// flagBackendConfig returns the configuration of the given backend set in the flags of the backend,
+// e.g., -bolt_file or -sql_dsn, and in -encryption_key_file.
+func flagBackendConfig(name string) backendConfig {
+	return backendConfig{
+		name:     name,
+		boltFile: *boltFile,
+		cosmos: persistazure.Config{
+			Endpoint:  *cosmosEndpoint,
+			Key:       *cosmosKey,
+			Token:     *cosmosToken,
+			Database:  *cosmosDatabase,
+			Container: *cosmosContainer,
+		},
+		sqlDriver: *sqlDriver,
+		sqlDSN:    *sqlDataSourceName,
+		keys:      encryptionKeys,
+	}
+}
+
+// withDefaults returns a copy of the configuration where the settings of the backend that are not
+// set are read from the environment variables, or set to their defaults.
+func (c backendConfig) withDefaults() (backendConfig, error) {
+	switch c.name {
+	case persistenceCosmos:
+		c.cosmos = c.cosmos.WithEnvDefaults()
+	case persistenceSQL:
+		if c.sqlDSN == "" {
+			c.sqlDSN = os.Getenv(persistsql.DSNEnv)
+		}
+		if c.sqlDSN == "" {
+			if c.sqlDriver != persistsql.SQLite {
+				return backendConfig{}, errors.Errorf("the data source name of the %s database is not set; set it with -sql_dsn or the %s environment variable", c.sqlDriver, persistsql.DSNEnv)
+			}
+			c.sqlDSN = defaultSQLiteFile
+		}
+	}
+	return c, nil
+}
+
+// location returns where the backend stores the items, so that two configurations with the same
+// location refer to the same data. The configuration must have its defaults set.
+func (c backendConfig) location() string {
+	switch c.name {
+	case persistenceBolt:
+		if abs, err := filepath.Abs(c.boltFile); err == nil {
+			return c.name + ":" + abs
+		}
+		return c.name + ":" + c.boltFile
+	case persistenceCosmos:
+		return c.name + ":" + strings.TrimSuffix(c.cosmos.Endpoint, "/") + "/" + c.cosmos.Database + "/" + c.cosmos.Container
+	case persistenceSQL:
+		return c.name + ":" + c.sqlDriver + ":" + c.sqlDSN
+	default:
+		return c.name
+	}
+}
+
+// backendItemSyncers returns the syncers of the backend with the given configuration, and its
+// closer if it needs to be closed. If the configuration has encryption keys, the syncers encrypt the
+// items.
+func backendItemSyncers(ctx context.Context, c backendConfig) (map[string]persist.ItemSyncer, io.Closer, error) {
+	syncers, closer, err := plainItemSyncers(ctx, c)
+	if err != nil || syncers == nil {
+		return syncers, closer, err
+	}
+	keys, err := c.keys()
+	if err == nil && keys != nil {
+		syncers, err = persistcrypt.Wrap(syncers, keys)
+	}
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, errors.Wrap(err, "cannot encrypt the persisted state")
+	}
+	return syncers, closer, nil
+}
+
+// plainItemSyncers returns the syncers of the backend with the given configuration, which don't
+// encrypt the items.
+func plainItemSyncers(ctx context.Context, c backendConfig) (map[string]persist.ItemSyncer, io.Closer, error) {
+	c, err := c.withDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch c.name {
+	case persistenceNone:
+		return nil, nil, nil
+	case persistenceBolt:
+		db, err := persistdb.Open(c.boltFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot open BoltDB")
+		}
+		return persistdb.NewItemSyncers(db), db, nil
+	case persistenceCosmos:
+		store, err := persistazure.Open(ctx, c.cosmos)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot open Cosmos DB")
+		}
+		return persistazure.NewItemSyncers(store), nil, nil
+	case persistenceSQL:
+		db, err := persistsql.Open(c.sqlDriver, c.sqlDSN)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot open SQL database")
+		}
+		return persistsql.NewItemSyncers(db), db, nil
+	default:
+		return nil, nil, errors.Errorf("unsupported persistence backend %q; supported: [%s, %s, %s, %s]",
+			c.name, persistenceNone, persistenceBolt, persistenceCosmos, persistenceSQL)
+	}
+}
+
+// encryptionKeys returns the keys in -encryption_key_file if set, or in the persistcrypt.KeysEnv
+// environment variable otherwise, or nil if neither is set.
+func encryptionKeys() (*persistcrypt.Keyring, error) {
+	if *encryptionKeyFile != "" {
+		return persistcrypt.LoadKeyring(*encryptionKeyFile)
+	}
+	return persistcrypt.KeyringFromEnv()
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/hospital/runner"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/rate"
+	"github.com/Arend-melissant/simhospital/pkg/reload"
+)
+
+// configReloader reloads the locations, doctors, order profiles and pathways of the hospital, and
+// the pathways of the pathway groups, from the files set in the flags.
+// The definitions of the pathway groups themselves, e.g., their rates, are not reloaded.
+type configReloader struct {
+	hospital *hospital.Hospital
+	config   hospital.Config
+	groups   rate.Groups
+	// managers are the pathway managers of groups, in the same order.
+	managers []*pathway.ReloadableManager
+	// mu serializes the reloads, e.g., from the watcher and from the API.
+	mu sync.Mutex
+}
+
+// newConfigReloader returns a reloader of the configuration of the given hospital, created with the
+// given config, and of the given pathway groups, whose managers must be *pathway.ReloadableManagers.
+// groupDefs are the definitions of the groups, in the same order.
+func newConfigReloader(h *hospital.Hospital, config hospital.Config, groups []runner.PathwayGroup, groupDefs rate.Groups) *configReloader {
+	r := &configReloader{hospital: h, config: config, groups: groupDefs}
+	for _, g := range groups {
+		r.managers = append(r.managers, g.Manager.(*pathway.ReloadableManager))
+	}
+	return r
+}
+
+// Reload reloads the configuration. If the new configuration is not valid, nothing changes.
+func (r *configReloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rc, err := hospital.LoadReloadableConfig(ctx, hospitalArguments(), r.config)
+	if err != nil {
+		return errors.Wrap(err, "cannot load the configuration")
+	}
+	rc.GroupManagers = map[*pathway.ReloadableManager]pathway.Manager{}
+	for i, g := range r.groups {
+		m, err := pathway.NewDistributionManager(rc.Pathways, g.PathwayNames, g.ExcludePathwayNames, r.config.Rand)
+		if err != nil {
+			return errors.Wrapf(err, "cannot create pathway manager for group %q", g.Name)
+		}
+		rc.GroupManagers[r.managers[i]] = m
+	}
+	return errors.Wrap(r.hospital.Reload(rc), "cannot reload the hospital")
+}
+
+// watcher returns a watcher that reloads the configuration when its files change, checking them
+// with the given interval.
+func (r *configReloader) watcher(interval time.Duration) *reload.Watcher {
+	args := hospitalArguments()
+	return &reload.Watcher{
+		Reloader: r,
+		Files:    []string{*args.LocationsFile, *args.DoctorsFile, *args.OrderProfilesFile},
+		Dirs:     []string{args.PathwayArguments.Dir},
+		Interval: interval,
+	}
+}
@@ -13,12 +13,19 @@
 // limitations under the License.
 
 // Binary simulator creates and runs an open-source version of Simulated Hospital.
+//
+// It also supports the following commands, that run after the flags, e.g., "simulator -pathways_dir=dir validate":
+//   - validate: validates all pathways and reports every problem with its file and line.
+//   - render <pathway_name>: runs the pathway against a fake clock and prints the messages it generates.
+//   - schema: writes the JSON Schemas of pathways and other configuration files.
+//   - diagram <pathway_name>: prints a diagram of the pathway, in Mermaid or Graphviz DOT format.
+//   - migrate -from <backend> -to <backend>: copies the persisted state from one persistence backend to another.
+//   - reencrypt: encrypts the persisted state again with the primary encryption key, e.g., after rotating the keys.
 package main
 
 import (
 	"context"
 	"flag"
-	"math/rand"
 	"os"
 	"os/signal"
 	"path"
@@ -28,21 +35,34 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/Arend-melissant/simhospital/pkg/clock"
 	"github.com/Arend-melissant/simhospital/pkg/config"
-	"github.com/Arend-melissant/simhospital/pkg/state"
-	"github.com/Arend-melissant/simhospital/pkg/state/persist"
-	//"github.com/Arend-melissant/simhospital/pkg/state/persistdb"
-	"github.com/Arend-melissant/simhospital/pkg/state/persistazure"
+	"github.com/Arend-melissant/simhospital/pkg/diagram"
 	"github.com/Arend-melissant/simhospital/pkg/hl7"
 	"github.com/Arend-melissant/simhospital/pkg/hospital"
 	"github.com/Arend-melissant/simhospital/pkg/hospital/runner"
+	"github.com/Arend-melissant/simhospital/pkg/instances"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/rate"
+	"github.com/Arend-melissant/simhospital/pkg/reload"
 	"github.com/Arend-melissant/simhospital/pkg/starter"
+	"github.com/Arend-melissant/simhospital/pkg/state/archive"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistazure"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistcrypt"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistdb"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistsql"
+	"github.com/Arend-melissant/simhospital/pkg/trigger"
 )
 
 var (
 	log = logging.ForCallerPackage()
 
+	// Flags that control where the configuration is read from.
+	configFile = flag.String("config_file", "", "Path to a YAML file with the values of any of the other flags, named after them, e.g., \"pathways_per_hour: 10\". "+
+		"This file can be a local file or a GCS object. Flags set in the command line and in "+envPrefix+"<FLAG_NAME> environment variables take precedence over the file")
+
 	// Flags that control the data that is generated.
 	localPath              = flag.String("local_path", "", "Absolute path to the directory where Simulated Hospital is located. Set when running locally to use as a prefix to all default paths")
 	locationsFile          = flag.String("locations_file", "configs/hl7_messages/locations.yml", "Path to a YAML file with the definition of locations. This can be a local file or a GCS object.")
@@ -63,6 +83,7 @@ var (
 	patientClassFile       = flag.String("patient_class_file", "configs/hl7_messages/patient_class.csv", "Path to a CSV file with the patient classes and types and how often they occur. This file can be a local file or a GCS object.")
 	doctorsFile            = flag.String("doctors_file", "configs/hl7_messages/doctors.yml", "Path to a YAML file with the doctors. This file can be a local file or a GCS object.")
 	orderProfilesFile      = flag.String("order_profile_file", "configs/hl7_messages/order_profiles.yml", "Path to a YAML file with the definition of the order profiles. This file can be a local file or a GCS object.")
+	calendarFile           = flag.String("calendar_file", "configs/hl7_messages/calendar.yml", "Path to a YAML file with the holidays and the working hours of locations, used to apply time constraints to pathway steps. This file can be a local file or a GCS object.")
 
 	// Flags that control resource generation.
 	resourceOutput    = flag.String("resource_output", "stdout", "Where the generated resources will be written: [stdout, file, cloud]")
@@ -77,8 +98,43 @@ var (
 
 	// Flags that control the behaviour of Simulated Hospital.
 	sleepFor                 = flag.Duration("sleep_for", time.Second, "How long Simulated Hospital sleeps before checking if any new messages need to be generated")
+	eventWorkers             = flag.Int("event_workers", 1, "How many events can run concurrently. The events of the same patient always run in order. "+
+		"If greater than 1, runs with the same -seed are not reproducible; only relevant when running in real time")
 	deletePatientsFromMemory = flag.Bool("delete_patients_from_memory", false, "Whether Simulated Hospital deletes patients after their pathways finish. "+
 		"Deleting saves memory but means you can't reuse the patient in another pathway")
+	fullWardPolicy = flag.String("full_ward_policy", hospital.FullWardFail, "What happens to admissions to locations that have no beds available: [fail, wait, pending]. "+
+		"If wait or pending, the patient waits in the ED until a bed is freed; if pending, a pending admission message is also sent")
+
+	// Flags that control where the state of Simulated Hospital is persisted.
+	persistence = flag.String("persistence", persistenceNone, "Where the patients and the pending events and messages are persisted, so that they survive restarts: [none, bolt, cosmos, sql]. "+
+		"If none, the state is only kept in memory")
+	boltFile       = flag.String("bolt_file", persistdb.DefaultFile, "Path to the BoltDB database file, which is created if it doesn't exist; only relevant if -persistence=bolt")
+	cosmosEndpoint = flag.String("cosmos_endpoint", "", "URL of the Cosmos DB account, e.g., https://myaccount.documents.azure.com:443/. "+
+		"If empty, the "+persistazure.EndpointEnv+" environment variable is used; only relevant if -persistence=cosmos")
+	cosmosKey = flag.String("cosmos_key", "", "Key of the Cosmos DB account. Cannot be set together with -cosmos_token. If neither is set, the "+
+		persistazure.KeyEnv+" or "+persistazure.TokenEnv+" environment variables are used; only relevant if -persistence=cosmos")
+	cosmosToken = flag.String("cosmos_token", "", "Azure Active Directory access token for the Cosmos DB account. Cannot be set together with -cosmos_key. "+
+		"The token cannot be refreshed, so Simulated Hospital can't reach Cosmos DB once it expires: only use it for short runs; "+
+		"only relevant if -persistence=cosmos")
+	cosmosDatabase    = flag.String("cosmos_database", "simhospital", "Name of the Cosmos DB database, which is created if it doesn't exist; only relevant if -persistence=cosmos")
+	cosmosContainer   = flag.String("cosmos_container", "items", "Name of the Cosmos DB container, which is created if it doesn't exist; only relevant if -persistence=cosmos")
+	sqlDriver         = flag.String("sql_driver", persistsql.SQLite, "The SQL database: [sqlite, postgres]; only relevant if -persistence=sql")
+	sqlDataSourceName = flag.String("sql_dsn", "", "Data source name of the SQL database: the path of the database file for sqlite, or a connection string for postgres. "+
+		"If empty, the "+persistsql.DSNEnv+" environment variable is used, or "+defaultSQLiteFile+" for sqlite; only relevant if -persistence=sql")
+	encryptionKeyFile = flag.String("encryption_key_file", "", "Path to a file with the keys to encrypt the persisted state with, one \"id:base64 key\" per line; the first key encrypts, and all of them decrypt. "+
+		"If empty, the "+persistcrypt.KeysEnv+" environment variable is used; if that is not set either, the state is not encrypted")
+
+	// Flags that control how long patients and messages are kept.
+	patientRetention = flag.Duration("patient_retention", 0, "How long patients are kept after their last encounter ended, e.g., 720h for 30 days. "+
+		"Older patients are purged from memory and from the persistence backend, and cannot return to the hospital. If 0, patients are kept forever")
+	messageRetention = flag.Duration("message_retention", 0, "How long messages that haven't been sent are kept after their message time, e.g., messages persisted when Simulated Hospital stopped. "+
+		"Older messages are purged without being sent. If 0, messages are kept until they are sent")
+	retentionInterval = flag.Duration("retention_interval", time.Hour, "How often patients and messages are purged; only relevant if -patient_retention or -message_retention are set")
+	archiveFile       = flag.String("archive_file", "", "Path to a JSON Lines file to which patients and messages are appended before they are purged. If empty, they are not archived")
+
+	// Flags that control how the configuration is reloaded.
+	reloadInterval = flag.Duration("reload_interval", 0, "How often the locations, doctors, order profiles and pathways files are checked for changes; they are reloaded when they change. "+
+		"Running pathways are not affected. If 0, the files are not watched; they can still be reloaded with the authenticated API if -api_key is set")
 
 	// Flags that control logging and monitoring.
 	logLevel             = flag.String("log_level", "INFO", "The logging granularity. One of PANIC, FATAL, ERROR, WARN, INFO, DEBUG. Not case sensitive")
@@ -101,14 +157,39 @@ var (
 		"when pathway_manager_type=distribution. Pathways that match both -pathway_names and -exclude_pathway_names are excluded. Excluded pathways can still be run from the dashboard.")
 
 	pathwaysPerHour = flag.Float64("pathways_per_hour", 0, "Number of pathways that should start per hour")
-	maxPathways     = flag.Int("max_pathways", -1, "Number of pathways to run before stopping. Pathways run from the dashboard do not count towards this limit. "+
+	rateProfile     = flag.String("rate_profile", "", "Path to a YAML file with the profile of how the number of pathways that start per hour varies over time. If set, pathways_per_hour is the base rate that the profile's multipliers apply to")
+	rateGroups      = flag.String("rate_groups", "", "Path to a YAML file with groups of pathways that start at their own rate, in addition to the pathways that start at pathways_per_hour. "+
+		"Each group's rate can be changed and paused from the dashboard")
+	maxPathways = flag.Int("max_pathways", -1, "Number of pathways to run before stopping. Pathways run from the dashboard do not count towards this limit. "+
 		"If negative, Simulated Hospital will keep running pathways indefinitely")
+	backfillMonths          = flag.Int("backfill_months", 0, "Number of months of past activity to simulate before Simulated Hospital starts running. If 0, there is no backfill")
+	backfillPathwaysPerHour = flag.Float64("backfill_pathways_per_hour", 0, "Number of pathways that start per hour during the backfill. If 0, pathways_per_hour is used; only relevant if -backfill_months is set")
+	backfillOutput          = flag.String("backfill_output", "", "Where the HL7 messages generated during the backfill will be sent: [stdout, mllp, file]. "+
+		"If empty, they are sent to -output; only relevant if -backfill_months is set")
+	backfillOutputFile        = flag.String("backfill_output_file", "backfill_messages.out", "File path to write the messages generated during the backfill if -backfill_output=file")
+	backfillResourceOutputDir = flag.String("backfill_resource_output_dir", "", "Path to the output directory for the resource files generated during the backfill. "+
+		"If empty, they are written to -resource_output; only relevant if -backfill_months is set")
+	returningPatients = flag.Float64("returning_patients", 0, "Probability, between 0 and 1, that a pathway is for a patient whose previous pathways have finished, e.g., "+
+		"a patient created during the backfill, instead of for a new patient. Cannot be set if -delete_patients_from_memory=true")
+	seed = flag.Int64("seed", 0, "Seed of the source of randomness. Runs with the same seed, configuration and -fast_forward_start generate the same messages. "+
+		"If 0, a seed based on the current time is used; the seed in use is always logged")
+	fastForwardStart = flag.String("fast_forward_start", "", "Date or RFC 3339 timestamp at which the fast-forward run starts, e.g., 2020-02-12 or 2020-02-12T08:00:00Z. "+
+		"If empty, the run starts now; only relevant if -fast_forward_end is set")
+	fastForwardEnd = flag.String("fast_forward_end", "", "Date or RFC 3339 timestamp at which the fast-forward run ends. If set, Simulated Hospital runs in fast-forward mode: "+
+		"instead of waiting in real time, the clock jumps straight to the next pathway, event or message, and Simulated Hospital exits when the clock reaches this time")
+	snapshotFile = flag.String("snapshot_file", "", "Path to a snapshot file to restore when Simulated Hospital starts, so that it continues where the snapshotted run was. "+
+		"In fast-forward mode, if -fast_forward_start is not set, the run starts at the time of the snapshot. Cannot be set together with -backfill_months")
+	snapshotOutputFile = flag.String("snapshot_output_file", "", "Path to the file to write a snapshot of the complete state of Simulated Hospital to when it exits. If empty, no snapshot is written")
 
 	// Flags that control the dashboard.
 	dashboardURI     = flag.String("dashboard_uri", "simulated-hospital", "Base URI at which the dashboard and endpoints are available")
 	dashboardAddress = flag.String("dashboard_address", ":8000", "Address for the dashboard to control Simulated Hospital")
 	staticDir        = flag.String("static_dir", "web/static", "Directory for static assets")
 
+	// Flags that control the authenticated API.
+	apiAddress = flag.String("api_address", ":8001", "Address for the authenticated API endpoints; only relevant if -api_key is set")
+	apiKey     = flag.String("api_key", "", "Key that callers of the authenticated API must send in the Authorization header. If not set, the authenticated API is disabled")
+
 	// flagset tracks what flags have been set in the command line.
 	flagset = make(map[string]bool)
 )
@@ -119,6 +200,12 @@ func main() {
 	defer cancel()
 	onShutdown(cancel)
 
+	sources, err := applyConfig(ctx)
+	if err != nil {
+		logrus.WithError(err).Fatal("Cannot load the configuration of Simulated Hospital")
+	}
+	flagSources = sources
+
 	if err := logging.SetLogLevelFromString(*logLevel); err != nil {
 		logrus.WithError(err).
 			WithField("log_level", *logLevel).
@@ -130,10 +217,37 @@ func main() {
 			Fatal("Cannot configure HL7 timezone and location")
 	}
 
-	rand.Seed(time.Now().Unix())
+	if flag.NArg() > 0 {
+		runCommand(ctx, flag.Arg(0), flag.Args()[1:])
+		return
+	}
 
 	log.Info("Starting Simulated Hospital")
-	hr, err := createRunner(ctx)
+	syncers, closer, err := itemSyncers(ctx)
+	if err != nil {
+		log.WithError(err).Fatal("Cannot create the persistence backend")
+	}
+	if closer != nil {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.WithError(err).Error("Error when closing the persistence backend")
+			}
+		}()
+	}
+	var archiver hospital.Archiver
+	if *archiveFile != "" {
+		a, err := archive.OpenFile(*archiveFile)
+		if err != nil {
+			log.WithError(err).Fatal("Cannot open the archive")
+		}
+		defer func() {
+			if err := a.Close(); err != nil {
+				log.WithError(err).Error("Error when closing the archive")
+			}
+		}()
+		archiver = a
+	}
+	hr, err := createRunner(ctx, syncers, archiver)
 	if err != nil {
 		log.WithError(err).Fatal("Cannot create Hospital Runner")
 	}
@@ -157,7 +271,196 @@ func onShutdown(cancel context.CancelFunc) {
 	}()
 }
 
-func createRunner(ctx context.Context) (*runner.Hospital, error) {
+// createRunner creates the Hospital Runner configured in the flags, whose state is persisted with
+// the given syncers. Purged patients and messages are archived with the given archiver, if any.
+func createRunner(ctx context.Context, syncers map[string]persist.ItemSyncer, archiver hospital.Archiver) (*runner.Hospital, error) {
+	args := hospitalArguments()
+	args.Retention.Archiver = archiver
+	var snapshot *runner.Snapshot
+	if *snapshotFile != "" {
+		s, err := runner.ReadSnapshot(ctx, *snapshotFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read -snapshot_file")
+		}
+		if s.Hospital == nil {
+			return nil, errors.Errorf("invalid -snapshot_file %s: it doesn't have the state of the hospital", *snapshotFile)
+		}
+		snapshot = s
+	}
+	var fastForwardUntil time.Time
+	if *fastForwardEnd != "" {
+		start := time.Now()
+		if snapshot != nil {
+			start = snapshot.Hospital.Time
+		}
+		if *fastForwardStart != "" {
+			t, err := parseTime(*fastForwardStart)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid -fast_forward_start")
+			}
+			start = t
+		}
+		t, err := parseTime(*fastForwardEnd)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid -fast_forward_end")
+		}
+		fastForwardUntil = t
+		args.Clock = clock.NewManualClock(start)
+	} else if *backfillMonths > 0 {
+		args.Clock = clock.NewSwitchClock(&clock.RealTimeClock{})
+	}
+	config, err := hospital.DefaultConfig(ctx, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create default hospital config")
+	}
+	b, err := backfill(ctx, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create backfill")
+	}
+	config.AdditionalConfig.ItemSyncers = syncers
+	h, err := hospital.NewHospital(ctx, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot instantiate Hospital")
+	}
+	var apiEndpoints []runner.APIEndpointAndHandler
+	if *apiKey != "" {
+		apiEndpoints = append(apiEndpoints, runner.APIEndpointAndHandler{
+			EndpointAndHandler: runner.EndpointAndHandler{Endpoint: "trigger", Handler: trigger.NewController(h).ServeHTTP},
+			HTTPMethod:         "POST",
+		})
+		instancesController := instances.NewController(h)
+		for _, method := range []string{"GET", "POST"} {
+			apiEndpoints = append(apiEndpoints, runner.APIEndpointAndHandler{
+				EndpointAndHandler: runner.EndpointAndHandler{Endpoint: "pathwayInstances", Handler: instancesController.ServeHTTP},
+				HTTPMethod:         method,
+			})
+		}
+	}
+	var profile *rate.Profile
+	if *rateProfile != "" {
+		if profile, err = rate.LoadProfile(ctx, *rateProfile); err != nil {
+			return nil, errors.Wrap(err, "cannot load rate profile")
+		}
+	}
+	groups, groupDefs, err := pathwayGroups(ctx, config.PathwayParser)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create pathway groups")
+	}
+	reloader := newConfigReloader(h, config, groups, groupDefs)
+	if *apiKey != "" {
+		apiEndpoints = append(apiEndpoints, runner.APIEndpointAndHandler{
+			EndpointAndHandler: runner.EndpointAndHandler{Endpoint: "reload", Handler: reload.NewController(reloader).ServeHTTP},
+			HTTPMethod:         "POST",
+		})
+	}
+	var watcher *reload.Watcher
+	if *reloadInterval > 0 {
+		watcher = reloader.watcher(*reloadInterval)
+	}
+	var interval time.Duration
+	if config.Retention.IsSet() {
+		interval = *retentionInterval
+	}
+	return runner.New(h, runner.Config{
+		PathwayGroups:          groups,
+		PathwayStarter:         &starter.PathwayStarter{Hospital: h, Parser: config.PathwayParser, PathwayManager: config.PathwayManager, Sender: config.Sender},
+		PathwayDiagram:         diagram.NewController(config.PathwayManager),
+		PathwaysPerHour:        *pathwaysPerHour,
+		RateProfile:            profile,
+		Rand:                   config.Rand,
+		DashboardURI:           *dashboardURI,
+		DashboardAddress:       *dashboardAddress,
+		DashboardStaticDir:     addLocalPathIfNotSet(*staticDir, "static_dir"),
+		MetricsAddress:         *metricsListenAddress,
+		SleepFor:               *sleepFor,
+		Clock:                  config.Clock,
+		FastForwardUntil:       fastForwardUntil,
+		Backfill:               b,
+		Snapshot:               snapshot,
+		SnapshotOutputFile:     *snapshotOutputFile,
+		MaxPathways:            *maxPathways,
+		AuthenticatedAPIConfig: runner.APIConfig{APIPort: *apiAddress, APIKey: *apiKey},
+		AuthenticatedEndpoints: apiEndpoints,
+		RetentionInterval:      interval,
+		EventWorkers:           *eventWorkers,
+		ConfigWatcher:          watcher,
+	})
+}
+
+// backfill returns the backfill set in the -backfill_* flags, or nil if -backfill_months is not set.
+// The backfill runs until the current time of the clock in the given hospital config.
+func backfill(ctx context.Context, config hospital.Config) (*runner.Backfill, error) {
+	if *backfillMonths <= 0 {
+		return nil, nil
+	}
+	b := &runner.Backfill{
+		From:            config.Clock.Now().AddDate(0, -*backfillMonths, 0),
+		PathwaysPerHour: *backfillPathwaysPerHour,
+	}
+	if b.PathwaysPerHour == 0 {
+		b.PathwaysPerHour = *pathwaysPerHour
+	}
+	var err error
+	if *backfillOutput != "" {
+		if b.Sender, err = hospital.NewSender(hospital.SenderArguments{
+			Output:                *backfillOutput,
+			OutputFile:            *backfillOutputFile,
+			MllpDestination:       *mllpDestination,
+			MllpKeepAlive:         *mllpKeepAlive,
+			MllpKeepAliveInterval: mllpKeepAliveInterval,
+		}); err != nil {
+			return nil, errors.Wrap(err, "cannot create the backfill sender")
+		}
+	}
+	if *backfillResourceOutputDir != "" {
+		if b.ResourceWriter, err = hospital.NewResourceWriter(ctx, hospital.ResourceArguments{
+			Output:    "file",
+			OutputDir: *backfillResourceOutputDir,
+			Format:    *resourceFormat,
+		}, config.HL7Config, config.Rand); err != nil {
+			return nil, errors.Wrap(err, "cannot create the backfill resource writer")
+		}
+	}
+	return b, nil
+}
+
+// parseTime parses a date, e.g., 2020-02-12, or an RFC 3339 timestamp, e.g., 2020-02-12T08:00:00Z.
+// Dates are midnight UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// pathwayGroups returns the pathway groups in the -rate_groups file, or nil if the flag is not set.
+// The managers of the groups are *pathway.ReloadableManagers, so that the pathways can be reloaded.
+// The groups as defined in the file are also returned, in the same order.
+func pathwayGroups(ctx context.Context, p *pathway.Parser) ([]runner.PathwayGroup, rate.Groups, error) {
+	if *rateGroups == "" {
+		return nil, nil, nil
+	}
+	groups, err := rate.LoadGroups(ctx, *rateGroups)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot load rate groups")
+	}
+	pathways, err := p.ParsePathways(ctx, addLocalPathIfNotSet(*pathwaysDir, "pathways_dir"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot parse pathways")
+	}
+	var pg []runner.PathwayGroup
+	for _, g := range groups {
+		m, err := pathway.NewDistributionManager(pathways, g.PathwayNames, g.ExcludePathwayNames, p.Rand)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot create pathway manager for group %q", g.Name)
+		}
+		pg = append(pg, runner.PathwayGroup{Name: g.Name, Manager: pathway.NewReloadableManager(m), RateController: g.Controller(p.Rand)})
+	}
+	return pg, groups, nil
+}
+
+// hospitalArguments returns the arguments to create the hospital from the command line flags.
+func hospitalArguments() hospital.Arguments {
 	flag.Visit(func(f *flag.Flag) { flagset[f.Name] = true })
 
 	var include []string
@@ -165,14 +468,19 @@ func createRunner(ctx context.Context) (*runner.Hospital, error) {
 		include = strings.Split(*pathwayNames, ",")
 	}
 	exclude := strings.Split(*excludePathwayNames, ",")
-	arguments := hospital.Arguments{
+	return hospital.Arguments{
 		LocationsFile:            addLocalPathIfNotSetAndNotNil(locationsFile, "locations_file"),
 		HardcodedMessagesDir:     addLocalPathIfNotSetAndNotNil(hardcodedMessagesDir, "hardcoded_messages_dir"),
 		Hl7ConfigFile:            addLocalPathIfNotSetAndNotNil(hl7ConfigFile, "hl7_config_file"),
 		HeaderConfigFile:         addLocalPathIfNotSetAndNotNil(headerConfigFile, "header_config_file"),
 		DoctorsFile:              addLocalPathIfNotSetAndNotNil(doctorsFile, "doctors_file"),
 		OrderProfilesFile:        addLocalPathIfNotSetAndNotNil(orderProfilesFile, "order_profile_file"),
+		CalendarFile:             addLocalPathIfNotSetAndNotNil(calendarFile, "calendar_file"),
 		DeletePatientsFromMemory: *deletePatientsFromMemory,
+		FullWardPolicy:           *fullWardPolicy,
+		ReturningPatients:        *returningPatients,
+		Seed:                     *seed,
+		Retention:                hospital.RetentionPolicy{Patients: *patientRetention, Messages: *messageRetention},
 		PathwayArguments: &hospital.PathwayArguments{
 			Dir:          addLocalPathIfNotSet(*pathwaysDir, "pathways_dir"),
 			Type:         *pathwayManagerType,
@@ -210,38 +518,6 @@ func createRunner(ctx context.Context) (*runner.Hospital, error) {
 			ClinicalNoteTypes: addLocalPathIfNotSet(*clinicalNoteTypesFile, "clinical_note_types_file"),
 		},
 	}
-
-	config, err := hospital.DefaultConfig(ctx, arguments)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot create default hospital config")
-	}
-	ms := persistazure.NewItemSyncerWithDelete(persistazure.MessageSyncer, true)
-	es := persistazure.NewItemSyncerWithDelete(persistazure.EventSyncer, true)
-	ps := persistazure.NewItemSyncer(persistazure.PatientSyncer)
-
-	syncers := map[string]persist.ItemSyncer{
-		state.MessageItemType: ms,
-		state.EventItemType:   es,
-		state.PatientItemType: ps,
-	}
-
-	config.AdditionalConfig.ItemSyncers = syncers
-
-	h, err := hospital.NewHospital(ctx, config)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot instantiate Hospital")
-	}
-	return runner.New(h, runner.Config{
-		PathwayStarter:     &starter.PathwayStarter{Hospital: h, Parser: config.PathwayParser, PathwayManager: config.PathwayManager, Sender: config.Sender},
-		PathwaysPerHour:    *pathwaysPerHour,
-		DashboardURI:       *dashboardURI,
-		DashboardAddress:   *dashboardAddress,
-		DashboardStaticDir: addLocalPathIfNotSet(*staticDir, "static_dir"),
-		MetricsAddress:     *metricsListenAddress,
-		SleepFor:           *sleepFor,
-		Clock:              config.Clock,
-		MaxPathways:        *maxPathways,
-	})
 }
 
 func addLocalPathIfNotSetAndNotNil(f *string, n string) *string {
@@ -15,13 +15,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"flag"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Arend-melissant/simhospital/pkg/hl7"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/schema"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistcrypt"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistdb"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistsql"
 	"github.com/Arend-melissant/simhospital/pkg/test"
+	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
 )
 
 var (
@@ -38,19 +51,414 @@ func TestRunner(t *testing.T) {
 		t.Errorf("flag.Set(%v, %v) failed with %v", "output", "stdout", err)
 	}
 
-	if _, err := createRunner(ctx); err == nil {
-		t.Error("createRunner() got nil error, want non nil error")
+	// The default configuration files are not in an empty directory.
+	empty := testwrite.TempDir(t)
+	if err := flag.Set("local_path", empty); err != nil {
+		t.Errorf("flag.Set(%v, %v) failed with %v", "local_path", empty, err)
+	}
+	if _, err := createRunner(ctx, nil, nil); err == nil {
+		t.Errorf("createRunner(local_path=%v) got nil error, want non nil error", empty)
 	}
 
 	if err := flag.Set("local_path", base); err != nil {
 		t.Errorf("flag.Set(%v, %v) failed with %v", "local_path", base, err)
 	}
-	if _, err := createRunner(ctx); err != nil {
+	if _, err := createRunner(ctx, nil, nil); err != nil {
 		t.Errorf("createRunner(local_path=%v) failed with %v", base, err)
 	}
 }
 
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	valid := []byte(`valid:
+  pathway:
+    - admission:
+        loc: WardA01
+    - discharge: {}
+`)
+	invalid := []byte(`invalid:
+  pathway:
+    - admission:
+        loc: WardA01
+    - dischrge: {}
+`)
+	cases := []struct {
+		name         string
+		files        map[string][]byte
+		wantCode     int
+		wantProblems []string
+	}{{
+		name:     "valid",
+		files:    map[string][]byte{"valid.yml": valid},
+		wantCode: exitOK,
+	}, {
+		name:         "invalid",
+		files:        map[string][]byte{"valid.yml": valid, "invalid.yml": invalid},
+		wantCode:     exitProblems,
+		wantProblems: []string{"invalid.yml:5: field dischrge not found"},
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := testwrite.TempDir(t)
+			for name, content := range tc.files {
+				testwrite.BytesToFileInExistingDir(t, content, dir, name)
+			}
+			setFlag(t, "local_path", base)
+			setFlag(t, "pathways_dir", dir)
+
+			var out bytes.Buffer
+			if got := validate(ctx, &out); got != tc.wantCode {
+				t.Errorf("validate() got exit code %d, want %d; output:\n%s", got, tc.wantCode, out.String())
+			}
+			for _, want := range tc.wantProblems {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("validate() got output:\n%s\nwant it to contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	ctx := context.Background()
+	if err := hl7.TimezoneAndLocation("UTC"); err != nil {
+		t.Fatalf("hl7.TimezoneAndLocation(UTC) failed with %v", err)
+	}
+	dir := testwrite.BytesToDir(t, []byte(`pathway1:
+  pathway:
+    - admission:
+        loc: WardA01
+    - delay:
+        from: 48h
+        to: 48h
+    - discharge: {}
+`), "pathways.yml")
+	setFlag(t, "local_path", base)
+	setFlag(t, "pathways_dir", dir)
+
+	var out bytes.Buffer
+	if got := render(ctx, &out, []string{"-start", "2020-02-12T10:00:00Z", "pathway1"}); got != exitOK {
+		t.Fatalf("render(pathway1) got exit code %d, want %d; output:\n%s", got, exitOK, out.String())
+	}
+	for _, want := range []string{
+		"--- 2020-02-12T10:00:00Z ---\nMSH|",
+		"|ADT^A01|",
+		"--- 2020-02-14T10:00:00Z ---\nMSH|",
+		"|ADT^A03|",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("render(pathway1) got output:\n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+
+	if got := render(ctx, &out, []string{"unknown"}); got != exitProblems {
+		t.Errorf("render(unknown) got exit code %d, want %d", got, exitProblems)
+	}
+}
+
+func TestRender_DoesNotWriteResourcesToResourceOutput(t *testing.T) {
+	ctx := context.Background()
+	if err := hl7.TimezoneAndLocation("UTC"); err != nil {
+		t.Fatalf("hl7.TimezoneAndLocation(UTC) failed with %v", err)
+	}
+	dir := testwrite.BytesToDir(t, []byte(`pathway1:
+  pathway:
+    - admission:
+        loc: WardA01
+    - generate_resources: {}
+`), "pathways.yml")
+	resourceDir := path.Join(t.TempDir(), "resources")
+	setFlag(t, "local_path", base)
+	setFlag(t, "pathways_dir", dir)
+	setFlag(t, "resource_output", "file")
+	setFlag(t, "resource_output_dir", resourceDir)
+
+	var out bytes.Buffer
+	if got := render(ctx, &out, []string{"-start", "2020-02-12T10:00:00Z", "pathway1"}); got != exitOK {
+		t.Fatalf("render(pathway1) got exit code %d, want %d; output:\n%s", got, exitOK, out.String())
+	}
+	if _, err := os.Stat(resourceDir); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(%s) after render(pathway1) got err %v, want the directory not to exist", resourceDir, err)
+	}
+}
+
+func TestDrawDiagram(t *testing.T) {
+	ctx := context.Background()
+	dir := testwrite.BytesToDir(t, []byte(`pathway1:
+  pathway:
+    - admission:
+        loc: WardA01
+    - delay:
+        from: 1h
+        to: 2h
+    - discharge: {}
+`), "pathways.yml")
+	setFlag(t, "local_path", base)
+	setFlag(t, "pathways_dir", dir)
+
+	var out bytes.Buffer
+	if got := drawDiagram(ctx, &out, []string{"-format", "dot", "pathway1"}); got != exitOK {
+		t.Fatalf("drawDiagram(pathway1) got exit code %d, want %d; output:\n%s", got, exitOK, out.String())
+	}
+	for _, want := range []string{
+		`digraph "pathway1"`,
+		`label="admission\nloc: WardA01\nmessages: ADT^A01"`,
+		`label="delay 1h - 2h"`,
+		`label="discharge\nmessages: ADT^A03"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("drawDiagram(pathway1) got output:\n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+
+	if got := drawDiagram(ctx, &out, []string{"unknown"}); got != exitProblems {
+		t.Errorf("drawDiagram(unknown) got exit code %d, want %d", got, exitProblems)
+	}
+	if got := drawDiagram(ctx, &out, []string{"-format", "svg", "pathway1"}); got != exitError {
+		t.Errorf("drawDiagram(-format svg) got exit code %d, want %d", got, exitError)
+	}
+}
+
+func TestMigrateState(t *testing.T) {
+	ctx := context.Background()
+	dir := testwrite.TempDir(t)
+	boltPath := path.Join(dir, "test.db")
+	setFlag(t, "bolt_file", boltPath)
+	setFlag(t, "sql_driver", persistsql.SQLite)
+	setFlag(t, "sql_dsn", path.Join(dir, "test.sqlite"))
+
+	db, err := persistdb.Open(boltPath)
+	if err != nil {
+		t.Fatalf("persistdb.Open(%q) failed with %v", boltPath, err)
+	}
+	syncers := persistdb.NewItemSyncers(db)
+	now := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+	for itemType, item := range map[string]persist.MarshallableItem{
+		state.EventItemType:   state.Event{EventTime: now, PathwayName: "pathway1", PatientMRN: "1"},
+		state.PatientItemType: state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1"}}},
+	} {
+		if err := syncers[itemType].Write(item); err != nil {
+			t.Fatalf("Write(%v) failed with %v", item, err)
+		}
+	}
+	// Only one process can have the BoltDB file open.
+	db.Close()
+
+	var out bytes.Buffer
+	if got := migrateState(ctx, &out, []string{"-from", "bolt", "-to", "sql"}); got != exitOK {
+		t.Fatalf("migrateState(-from bolt -to sql) got exit code %d, want %d; output:\n%s", got, exitOK, out.String())
+	}
+	want := "event: 1 items migrated and verified\nmessage: 0 items migrated and verified\npatient: 1 items migrated and verified\n"
+	if got := out.String(); got != want {
+		t.Errorf("migrateState(-from bolt -to sql) got output:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The destination is not empty anymore.
+	if got := migrateState(ctx, &out, []string{"-from", "bolt", "-to", "sql"}); got != exitProblems {
+		t.Errorf("migrateState(-from bolt -to sql) again got exit code %d, want %d", got, exitProblems)
+	}
+	for _, args := range [][]string{
+		{"-from", "bolt"},
+		{"-from", "bolt", "-to", "bolt"},
+		{"-from", "none", "-to", "sql"},
+		{"-from", "unknown", "-to", "sql"},
+	} {
+		if got := migrateState(ctx, &out, args); got != exitError {
+			t.Errorf("migrateState(%v) got exit code %d, want %d", args, got, exitError)
+		}
+	}
+}
+
+func TestMigrateState_SameBackend(t *testing.T) {
+	ctx := context.Background()
+	dir := testwrite.TempDir(t)
+	fromPath := path.Join(dir, "from.db")
+	toPath := path.Join(dir, "to.db")
+	keyFile := path.Join(dir, "keys")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, persistcrypt.KeySize))
+	if err := os.WriteFile(keyFile, []byte("k1:"+key+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile(%q) failed with %v", keyFile, err)
+	}
+	setFlag(t, "bolt_file", fromPath)
+	setFlag(t, "encryption_key_file", keyFile)
+	defer setFlag(t, "encryption_key_file", "")
+
+	db, err := persistdb.Open(fromPath)
+	if err != nil {
+		t.Fatalf("persistdb.Open(%q) failed with %v", fromPath, err)
+	}
+	keys, err := persistcrypt.LoadKeyring(keyFile)
+	if err != nil {
+		t.Fatalf("LoadKeyring(%q) failed with %v", keyFile, err)
+	}
+	syncers, err := persistcrypt.Wrap(persistdb.NewItemSyncers(db), keys)
+	if err != nil {
+		t.Fatalf("persistcrypt.Wrap() failed with %v", err)
+	}
+	p := state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1"}}}
+	if err := syncers[state.PatientItemType].Write(p); err != nil {
+		t.Fatalf("Write(%v) failed with %v", p, err)
+	}
+	// Only one process can have the BoltDB file open.
+	db.Close()
+
+	var out bytes.Buffer
+	for _, args := range [][]string{
+		{"-from", "bolt", "-to", "bolt", "-to_bolt_file", path.Join(dir, ".", "from.db")},
+		{"-from", "bolt", "-to", "bolt", "-to_bolt_file", toPath, "-to_plaintext", "-to_encryption_key_file", keyFile},
+	} {
+		if got := migrateState(ctx, &out, args); got != exitError {
+			t.Errorf("migrateState(%v) got exit code %d, want %d", args, got, exitError)
+		}
+	}
+
+	// The source is decrypted with the keys, and the destination is not encrypted.
+	args := []string{"-from", "bolt", "-to", "bolt", "-to_bolt_file", toPath, "-to_plaintext"}
+	if got := migrateState(ctx, &out, args); got != exitOK {
+		t.Fatalf("migrateState(%v) got exit code %d, want %d; output:\n%s", args, got, exitOK, out.String())
+	}
+	db, err = persistdb.Open(toPath)
+	if err != nil {
+		t.Fatalf("persistdb.Open(%q) failed with %v", toPath, err)
+	}
+	defer db.Close()
+	got, err := persistdb.NewItemSyncers(db)[state.PatientItemType].LoadByID("1")
+	if err != nil {
+		t.Fatalf("LoadByID(%q) failed with %v", "1", err)
+	}
+	if got, ok := got.(state.Patient); !ok || got.PatientInfo == nil || got.PatientInfo.Person.MRN != "1" {
+		t.Errorf("LoadByID(%q) got %+v, want the unencrypted patient with MRN %q", "1", got, "1")
+	}
+}
+
+func TestReencryptState(t *testing.T) {
+	ctx := context.Background()
+	dir := testwrite.TempDir(t)
+	boltPath := path.Join(dir, "test.db")
+	setFlag(t, "bolt_file", boltPath)
+	setFlag(t, "persistence", persistenceBolt)
+	t.Cleanup(func() {
+		setFlag(t, "persistence", persistenceNone)
+		setFlag(t, "encryption_key_file", "")
+	})
+
+	db, err := persistdb.Open(boltPath)
+	if err != nil {
+		t.Fatalf("persistdb.Open(%q) failed with %v", boltPath, err)
+	}
+	p := state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1"}}}
+	if err := persistdb.NewItemSyncers(db)[state.PatientItemType].Write(p); err != nil {
+		t.Fatalf("Write(%v) failed with %v", p, err)
+	}
+	// Only one process can have the BoltDB file open.
+	db.Close()
+
+	var out bytes.Buffer
+	// There are no keys.
+	t.Setenv(persistcrypt.KeysEnv, "")
+	if got := reencryptState(ctx, &out, nil); got != exitError {
+		t.Errorf("reencryptState() without keys got exit code %d, want %d", got, exitError)
+	}
+
+	keyFile := path.Join(dir, "keys")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, persistcrypt.KeySize))
+	if err := os.WriteFile(keyFile, []byte("k1:"+key+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile(%q) failed with %v", keyFile, err)
+	}
+	setFlag(t, "encryption_key_file", keyFile)
+	if got := reencryptState(ctx, &out, []string{"extra"}); got != exitError {
+		t.Errorf("reencryptState(extra) got exit code %d, want %d", got, exitError)
+	}
+	if got := reencryptState(ctx, &out, nil); got != exitOK {
+		t.Fatalf("reencryptState() got exit code %d, want %d; output:\n%s", got, exitOK, out.String())
+	}
+	want := `event: 0 items re-encrypted with key "k1"
+message: 0 items re-encrypted with key "k1"
+patient: 1 items re-encrypted with key "k1"
+`
+	if got := out.String(); got != want {
+		t.Errorf("reencryptState() got output:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The patient can only be loaded with the key now.
+	syncers, closer, err := itemSyncers(ctx)
+	if err != nil {
+		t.Fatalf("itemSyncers() failed with %v", err)
+	}
+	defer closer.Close()
+	got, err := syncers[state.PatientItemType].LoadByID("1")
+	if err != nil {
+		t.Fatalf("LoadByID(%q) failed with %v", "1", err)
+	}
+	if got == nil {
+		t.Errorf("LoadByID(%q) got nil, want the patient", "1")
+	}
+}
+
+func TestWriteSchemas(t *testing.T) {
+	dir := testwrite.TempDir(t)
+	if got := writeSchemas([]string{"-out", dir}); got != exitOK {
+		t.Fatalf("writeSchemas(-out %s) got exit code %d, want %d", dir, got, exitOK)
+	}
+	for _, name := range []string{"pathways", "hardcoded_messages", "locations", "order_profiles", "rate_profile", "rate_groups"} {
+		fileName := path.Join(dir, name+schema.Extension)
+		if _, err := os.Stat(fileName); err != nil {
+			t.Errorf("os.Stat(%q) failed with %v", fileName, err)
+		}
+	}
+}
+
+func TestPrintEffectiveConfig(t *testing.T) {
+	setFlag(t, "api_key", "my-secret-key")
+	defer setFlag(t, "api_key", "")
+
+	var b bytes.Buffer
+	if got := printEffectiveConfig(&b, nil); got != exitOK {
+		t.Fatalf("printEffectiveConfig() got exit code %d, want %d", got, exitOK)
+	}
+	out := b.String()
+	for _, want := range []string{"pathways_per_hour: \"0\"  # default\n", "api_key: REDACTED  #", "config_file: \"\"  # default\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("printEffectiveConfig() got output:\n%s\nwant it to contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "my-secret-key") {
+		t.Errorf("printEffectiveConfig() got output:\n%s\nwant the API key redacted", out)
+	}
+}
+
+func setFlag(t *testing.T, name string, value string) {
+	t.Helper()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatalf("flag.Set(%v, %v) failed with %v", name, value, err)
+	}
+}
+
 func currentDir() string {
 	dir, _ := os.Getwd()
 	return dir
 }
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2020-02-12", want: time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)},
+		{in: "2020-02-12T08:30:00Z", want: time.Date(2020, 2, 12, 8, 30, 0, 0, time.UTC)},
+		{in: "2020-02-12T08:30:00+01:00", want: time.Date(2020, 2, 12, 7, 30, 0, 0, time.UTC)},
+		{in: "12/02/2020", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseTime(tc.in)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseTime(%q) got err %v, want err? %t", tc.in, err, tc.wantErr)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("parseTime(%q) got %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
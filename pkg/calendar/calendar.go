@@ -0,0 +1,257 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package calendar provides functionality to manage working hours and holidays, and to apply
+// time-of-day and calendar constraints to the times of events.
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// maxDaysAhead is the maximum number of days that an event can be moved forward to satisfy
+	// its constraints.
+	maxDaysAhead = 366
+)
+
+var log = logging.ForCallerPackage()
+
+// WorkingHours are the opening hours of a location.
+type WorkingHours struct {
+	// Open is the time of day at which the location opens, eg.: "09:00".
+	Open string
+	// Close is the time of day at which the location closes, eg.: "17:00".
+	// Use pathway.EndOfDay, "24:00", for locations that close at midnight.
+	Close string
+	// Days are the days of the week in which the location is open, eg.: "Monday".
+	// These are the clinic days of the location.
+	Days []string
+	// OpenOnHolidays indicates that the location is also open on holidays that fall on one of Days.
+	OpenOnHolidays bool `yaml:"open_on_holidays"`
+
+	open  int
+	close int
+	days  map[time.Weekday]bool
+}
+
+// Config is the configuration of a Calendar, as specified in the calendar file.
+type Config struct {
+	// Holidays are the dates, in the format YYYY-MM-DD, in which the hospital only provides emergency care.
+	Holidays []string
+	// DefaultWorkingHours are the working hours of the locations that don't have working hours
+	// specified in WorkingHours. If not set, locations are open from 09:00 to 17:00, Monday to Friday.
+	DefaultWorkingHours *WorkingHours `yaml:"default_working_hours"`
+	// WorkingHours are the working hours for each location, keyed by location name.
+	WorkingHours map[string]*WorkingHours `yaml:"working_hours"`
+}
+
+// Calendar contains the holidays and the working hours of the locations of the hospital.
+type Calendar struct {
+	holidays            map[string]bool
+	defaultWorkingHours *WorkingHours
+	workingHours        map[string]*WorkingHours
+}
+
+// Default returns a Calendar without holidays, where all locations are open from
+// 09:00 to 17:00, Monday to Friday.
+func Default() *Calendar {
+	c, err := New(Config{})
+	if err != nil {
+		// This should never happen, as the default working hours are valid.
+		log.WithError(err).Fatal("Cannot create the default calendar")
+	}
+	return c
+}
+
+// Load loads the Calendar from the given file.
+func Load(ctx context.Context, filename string) (*Calendar, error) {
+	data, err := files.Read(ctx, filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read calendar from file %q", filename)
+	}
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal calendar from file %q", filename)
+	}
+	cal, err := New(c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid calendar in file %q", filename)
+	}
+	return cal, nil
+}
+
+// New creates a Calendar from the given Config.
+// Returns an error if the Config is invalid.
+func New(c Config) (*Calendar, error) {
+	cal := &Calendar{
+		holidays:            map[string]bool{},
+		defaultWorkingHours: &WorkingHours{Open: "09:00", Close: "17:00", Days: []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}},
+		workingHours:        map[string]*WorkingHours{},
+	}
+	for _, h := range c.Holidays {
+		d, err := time.Parse(dateLayout, h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid holiday %q: must be in the format YYYY-MM-DD", h)
+		}
+		cal.holidays[d.Format(dateLayout)] = true
+	}
+	if c.DefaultWorkingHours != nil {
+		cal.defaultWorkingHours = c.DefaultWorkingHours
+	}
+	if err := cal.defaultWorkingHours.init(); err != nil {
+		return nil, errors.Wrap(err, "invalid default working hours")
+	}
+	for loc, wh := range c.WorkingHours {
+		if wh == nil {
+			return nil, fmt.Errorf("invalid working hours for location %q: working hours not provided", loc)
+		}
+		if err := wh.init(); err != nil {
+			return nil, errors.Wrapf(err, "invalid working hours for location %q", loc)
+		}
+		cal.workingHours[loc] = wh
+	}
+	return cal, nil
+}
+
+func (wh *WorkingHours) init() error {
+	var err error
+	if wh.open, err = pathway.ParseTimeOfDay(wh.Open); err != nil {
+		return errors.Wrap(err, "invalid open time")
+	}
+	if wh.close, err = pathway.ParseTimeOfDay(wh.Close); err != nil {
+		return errors.Wrap(err, "invalid close time")
+	}
+	if wh.close <= wh.open {
+		return fmt.Errorf("close time %q must be after open time %q", wh.Close, wh.Open)
+	}
+	if len(wh.Days) == 0 {
+		return errors.New("at least one day must be provided")
+	}
+	wh.days = map[time.Weekday]bool{}
+	for _, d := range wh.Days {
+		wd, err := parseWeekday(d)
+		if err != nil {
+			return err
+		}
+		wh.days[wd] = true
+	}
+	return nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), s) {
+			return d, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid day of the week %q", s)
+}
+
+// IsHoliday returns whether the day of the given time is a holiday.
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	return c.holidays[t.Format(dateLayout)]
+}
+
+// WorkingHours returns the working hours of the given location.
+func (c *Calendar) WorkingHours(loc string) *WorkingHours {
+	if wh, ok := c.workingHours[loc]; ok {
+		return wh
+	}
+	return c.defaultWorkingHours
+}
+
+func (c *Calendar) isWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday && !c.IsHoliday(t)
+}
+
+func (c *Calendar) isOpenDay(wh *WorkingHours, t time.Time) bool {
+	return wh.days[t.Weekday()] && (wh.OpenOnHolidays || !c.IsHoliday(t))
+}
+
+// Apply returns the earliest time at or after t that satisfies the given constraints.
+// Returns an error if no such time exists in the year following t.
+func (c *Calendar) Apply(t time.Time, tc *pathway.TimeConstraints) (time.Time, error) {
+	if tc == nil {
+		return t, nil
+	}
+	notBefore, notAfter := -1, -1
+	var err error
+	if tc.NotBefore != "" {
+		if notBefore, err = pathway.ParseTimeOfDay(tc.NotBefore); err != nil {
+			return time.Time{}, errors.Wrap(err, "invalid not_before")
+		}
+	}
+	if tc.NotAfter != "" {
+		if notAfter, err = pathway.ParseTimeOfDay(tc.NotAfter); err != nil {
+			return time.Time{}, errors.Wrap(err, "invalid not_after")
+		}
+	}
+
+	original := t
+	limit := t.AddDate(0, 0, maxDaysAhead)
+	if tc.NextClinicDay != "" {
+		wh := c.WorkingHours(tc.NextClinicDay)
+		t = nextDay(t)
+		for !c.isOpenDay(wh, t) && t.Before(limit) {
+			t = nextDay(t)
+		}
+		t = atTimeOfDay(t, wh.open)
+	}
+
+	var wh *WorkingHours
+	if tc.WorkingHoursOf != "" {
+		wh = c.WorkingHours(tc.WorkingHoursOf)
+	}
+	for t.Before(limit) {
+		switch {
+		case tc.WeekdaysOnly && !c.isWeekday(t):
+			t = nextDay(t)
+		case wh != nil && !c.isOpenDay(wh, t):
+			t = nextDay(t)
+		case wh != nil && t.Before(atTimeOfDay(t, wh.open)):
+			t = atTimeOfDay(t, wh.open)
+		case wh != nil && !t.Before(atTimeOfDay(t, wh.close)):
+			t = nextDay(t)
+		case notBefore >= 0 && t.Before(atTimeOfDay(t, notBefore)):
+			t = atTimeOfDay(t, notBefore)
+		case notAfter >= 0 && t.After(atTimeOfDay(t, notAfter)):
+			t = nextDay(t)
+		default:
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no time in the %d days after %v satisfies the constraints %+v", maxDaysAhead, original, *tc)
+}
+
+// nextDay returns midnight of the day after t.
+func nextDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+// atTimeOfDay returns the time in the same day as t, at the given number of minutes since midnight.
+func atTimeOfDay(t time.Time, minutes int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, minutes, 0, 0, t.Location())
+}
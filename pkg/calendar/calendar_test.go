@@ -0,0 +1,221 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calendar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/test"
+	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
+)
+
+const calendarYml = `
+holidays:
+  - "2018-02-19"
+default_working_hours:
+  open: "08:00"
+  close: "18:00"
+  days: [Monday, Tuesday, Wednesday, Thursday, Friday]
+working_hours:
+  Renal:
+    open: "09:30"
+    close: "12:00"
+    days: [tuesday, thursday]
+  ED:
+    open: "00:00"
+    close: "24:00"
+    days: [Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday]
+    open_on_holidays: true
+`
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{{
+		name:  "valid",
+		input: calendarYml,
+	}, {
+		name:  "empty",
+		input: "",
+	}, {
+		name:    "invalid holiday",
+		input:   `holidays: ["19/02/2018"]`,
+		wantErr: true,
+	}, {
+		name: "invalid day",
+		input: `
+working_hours:
+  Renal:
+    open: "09:00"
+    close: "12:00"
+    days: [Funday]`,
+		wantErr: true,
+	}, {
+		name: "no days",
+		input: `
+working_hours:
+  Renal:
+    open: "09:00"
+    close: "12:00"`,
+		wantErr: true,
+	}, {
+		name: "close before open",
+		input: `
+default_working_hours:
+  open: "12:00"
+  close: "09:00"
+  days: [Monday]`,
+		wantErr: true,
+	}, {
+		name: "invalid time",
+		input: `
+default_working_hours:
+  open: "9am"
+  close: "17:00"
+  days: [Monday]`,
+		wantErr: true,
+	}, {
+		name:    "unknown field",
+		input:   `bank_holidays: ["2018-02-19"]`,
+		wantErr: true,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fName := testwrite.BytesToFile(t, []byte(tc.input))
+			if _, err := Load(ctx, fName); (err != nil) != tc.wantErr {
+				t.Errorf("Load(%s) got err %v; want err: %t", tc.input, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_Prod(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Load(ctx, test.CalendarConfigProd); err != nil {
+		t.Errorf("Load(%s) failed with %v", test.CalendarConfigProd, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	c, err := Load(ctx, testwrite.BytesToFile(t, []byte(calendarYml)))
+	if err != nil {
+		t.Fatalf("Load() failed with %v", err)
+	}
+
+	// 2018-02-12 is a Monday, and 2018-02-19 is a holiday.
+	monday := time.Date(2018, 2, 12, 0, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return monday.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	cases := []struct {
+		name        string
+		t           time.Time
+		constraints *pathway.TimeConstraints
+		want        time.Time
+	}{{
+		name: "no constraints",
+		t:    at(0, 3, 0),
+		want: at(0, 3, 0),
+	}, {
+		name:        "not before - moved forward",
+		t:           at(0, 3, 0),
+		constraints: &pathway.TimeConstraints{NotBefore: "08:00"},
+		want:        at(0, 8, 0),
+	}, {
+		name:        "not before - already satisfied",
+		t:           at(0, 10, 0),
+		constraints: &pathway.TimeConstraints{NotBefore: "08:00"},
+		want:        at(0, 10, 0),
+	}, {
+		name:        "not after - moved to the next day",
+		t:           at(0, 19, 0),
+		constraints: &pathway.TimeConstraints{NotBefore: "08:00", NotAfter: "17:00"},
+		want:        at(1, 8, 0),
+	}, {
+		name:        "weekdays only - weekday",
+		t:           at(4, 10, 0),
+		constraints: &pathway.TimeConstraints{WeekdaysOnly: true},
+		want:        at(4, 10, 0),
+	}, {
+		name:        "weekdays only - skips weekends and holidays",
+		t:           at(5, 10, 0),
+		constraints: &pathway.TimeConstraints{WeekdaysOnly: true, NotBefore: "09:00"},
+		want:        at(8, 9, 0),
+	}, {
+		name:        "working hours - default",
+		t:           at(0, 18, 0),
+		constraints: &pathway.TimeConstraints{WorkingHoursOf: "WardA01"},
+		want:        at(1, 8, 0),
+	}, {
+		name:        "working hours - open on holidays",
+		t:           at(7, 23, 0),
+		constraints: &pathway.TimeConstraints{WorkingHoursOf: "ED"},
+		want:        at(7, 23, 0),
+	}, {
+		name:        "working hours - location",
+		t:           at(0, 10, 0),
+		constraints: &pathway.TimeConstraints{WorkingHoursOf: "Renal"},
+		want:        at(1, 9, 30),
+	}, {
+		name:        "working hours - within working hours",
+		t:           at(1, 11, 0),
+		constraints: &pathway.TimeConstraints{WorkingHoursOf: "Renal"},
+		want:        at(1, 11, 0),
+	}, {
+		name:        "working hours and not before",
+		t:           at(1, 8, 0),
+		constraints: &pathway.TimeConstraints{WorkingHoursOf: "Renal", NotBefore: "10:00"},
+		want:        at(1, 10, 0),
+	}, {
+		name:        "next clinic day",
+		t:           at(1, 10, 0),
+		constraints: &pathway.TimeConstraints{NextClinicDay: "Renal"},
+		want:        at(3, 9, 30),
+	}, {
+		name:        "next clinic day - skips holidays",
+		t:           at(4, 10, 0),
+		constraints: &pathway.TimeConstraints{NextClinicDay: "WardA01"},
+		want:        at(8, 8, 0),
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := c.Apply(tc.t, tc.constraints)
+			if err != nil {
+				t.Fatalf("Apply(%v, %+v) failed with %v", tc.t, tc.constraints, err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("Apply(%v, %+v) got %v, want %v", tc.t, tc.constraints, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApply_Unsatisfiable(t *testing.T) {
+	c := Default()
+	now := time.Date(2018, 2, 12, 0, 0, 0, 0, time.UTC)
+	// The default working hours finish at 17:00.
+	constraints := &pathway.TimeConstraints{WorkingHoursOf: "Renal", NotBefore: "18:00"}
+	if got, err := c.Apply(now, constraints); err == nil {
+		t.Errorf("Apply(%v, %+v) got %v, want error", now, constraints, got)
+	}
+}
@@ -15,7 +15,10 @@
 // Package clock provides convenient functionality to manage the time.
 package clock
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Clock provides functionality to manage the time.
 type Clock interface {
@@ -30,3 +33,65 @@ type RealTimeClock struct{}
 func (c *RealTimeClock) Now() time.Time {
 	return time.Now().UTC()
 }
+
+// ManualClock is a Clock whose time only changes when it is explicitly set or advanced.
+// It is useful to run pathways faster than in real time. It is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock returns a ManualClock set to the given time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now.UTC()}
+}
+
+// Now is the time the clock is set to, in UTC.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the clock to the given time.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t.UTC()
+}
+
+// Advance moves the clock forward the given duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SwitchClock is a Clock that tells the time of another clock, which can be switched at any time.
+// It is useful to simulate past activity with a ManualClock before switching to real time.
+// It is safe for concurrent use.
+type SwitchClock struct {
+	mu    sync.Mutex
+	clock Clock
+}
+
+// NewSwitchClock returns a SwitchClock that tells the time of the given clock.
+func NewSwitchClock(c Clock) *SwitchClock {
+	return &SwitchClock{clock: c}
+}
+
+// Now is the current time as seen by the current clock, in UTC.
+func (c *SwitchClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock.Now()
+}
+
+// Switch switches to the given clock, and returns the previous one.
+func (c *SwitchClock) Switch(to Clock) Clock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.clock
+	c.clock = to
+	return previous
+}
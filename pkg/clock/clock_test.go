@@ -38,3 +38,51 @@ func TestRealTimeClock(t *testing.T) {
 		t.Errorf("rtc.Now() got %v; want before or equal to %v", second, after)
 	}
 }
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, 2, 12, 10, 0, 0, 0, time.UTC)
+	c := NewManualClock(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("c.Now() got %v, want %v", got, start)
+	}
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Second invocation of c.Now() got %v, want %v", got, start)
+	}
+
+	c.Advance(time.Hour)
+	if got, want := c.Now(), start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("c.Now() after c.Advance(%v) got %v, want %v", time.Hour, got, want)
+	}
+
+	later := start.Add(48 * time.Hour)
+	c.Set(later)
+	if got := c.Now(); !got.Equal(later) {
+		t.Errorf("c.Now() after c.Set(%v) got %v, want %v", later, got, later)
+	}
+}
+
+func TestSwitchClock(t *testing.T) {
+	start := time.Date(2020, 2, 12, 10, 0, 0, 0, time.UTC)
+	manual := NewManualClock(start)
+	c := NewSwitchClock(&RealTimeClock{})
+	if got := c.Now(); got.Before(start) {
+		t.Errorf("c.Now() got %v; want after %v", got, start)
+	}
+
+	previous := c.Switch(manual)
+	if _, ok := previous.(*RealTimeClock); !ok {
+		t.Errorf("c.Switch(%v) got previous clock %T, want *RealTimeClock", manual, previous)
+	}
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("c.Now() after c.Switch(%v) got %v, want %v", manual, got, start)
+	}
+	manual.Advance(time.Hour)
+	if got, want := c.Now(), start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("c.Now() after manual.Advance(%v) got %v, want %v", time.Hour, got, want)
+	}
+
+	c.Switch(previous)
+	if got := c.Now(); got.Before(start.Add(time.Hour)) {
+		t.Errorf("c.Now() after switching back got %v; want after %v", got, start.Add(time.Hour))
+	}
+}
@@ -46,6 +46,8 @@ type HL7Config struct {
 
 	Gender Gender
 
+	Relationship Relationship
+
 	AbnormalFlags AbnormalFlags `yaml:"abnormal_flags"`
 
 	// PrimaryFacility is the patient's primary facility.
@@ -189,6 +191,15 @@ type Gender struct {
 	Unknown string
 }
 
+// Relationship are the values to set in the NK1.3 Relationship field of the persons related to a patient.
+// Values: https://hl7-definition.caristix.com/v2/HL7v2.5.1/Tables/0063
+type Relationship struct {
+	// Mother is the relationship of a newborn's mother to the newborn.
+	Mother string
+	// Child is the relationship of a newborn to their mother.
+	Child string
+}
+
 // AbnormalFlags are the abnormal flag values to set in the OBX.8 Abnormal Flags field.
 // Values: http://hl7-definition.caristix.com:9010/HL7%20v2.2/table/Default.aspx?version=HL7+v2.2&table=0078
 type AbnormalFlags struct {
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -90,6 +91,8 @@ func names(ctx context.Context, filename string) (*Names, error) {
 	for k := range allNames {
 		all = append(all, k)
 	}
+	// Sort the names so that runs with the same seed pick the same names.
+	sort.Strings(all)
 
 	return &Names{ByYear: namesByYear, All: all, MinYear: years[0], MaxYear: years[len(years)-1]}, nil
 }
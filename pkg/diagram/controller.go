@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagram
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+)
+
+var log = logging.ForCallerPackage()
+
+// Controller handles requests for diagrams of pathways.
+type Controller struct {
+	PathwayManager pathway.Manager
+}
+
+// NewController creates a new Controller.
+func NewController(m pathway.Manager) *Controller {
+	return &Controller{PathwayManager: m}
+}
+
+// ServeHTTP handles the requests for diagrams of pathways.
+// Use a GET request with the following query parameters:
+// * pathway: the name of the pathway. Required.
+// * format: the format of the diagram, either "mermaid" or "dot". Optional; the default is "mermaid".
+// The pathway is drawn as it is run, i.e., with the steps that AutoGenerate steps generate.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		c.handleGet(w, r)
+	case "POST", "PUT", "DELETE":
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown method: %q", r.Method), http.StatusInternalServerError)
+	}
+}
+
+func (c *Controller) handleGet(w http.ResponseWriter, r *http.Request) {
+	errStr := "Failed to draw pathway"
+	name := r.URL.Query().Get("pathway")
+	if name == "" {
+		http.Error(w, `Missing pathway: the request must be in the format "?pathway=X&format=Y"`, http.StatusBadRequest)
+		return
+	}
+	format := Mermaid
+	if f := r.URL.Query().Get("format"); f != "" {
+		var err error
+		if format, err = ParseFormat(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	p, err := c.PathwayManager.GetPathway(name)
+	if err != nil {
+		log.WithError(err).WithField("pathway_name", name).Warning(errStr)
+		http.Error(w, fmt.Sprintf("%s: %v", errStr, err), http.StatusNotFound)
+		return
+	}
+	var b bytes.Buffer
+	if err := Write(&b, format, name, p); err != nil {
+		log.WithError(err).WithField("pathway_name", name).Error(errStr)
+		http.Error(w, fmt.Sprintf("%s: %v", errStr, err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(b.Bytes())
+}
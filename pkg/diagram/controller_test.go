@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagram
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+)
+
+type fakeManager struct {
+	pathways map[string]*pathway.Pathway
+}
+
+func (m *fakeManager) GetPathway(name string) (*pathway.Pathway, error) {
+	p, ok := m.pathways[name]
+	if !ok {
+		return nil, fmt.Errorf("pathway %s does not exist", name)
+	}
+	return p, nil
+}
+
+func (m *fakeManager) NextPathway() (*pathway.Pathway, error) {
+	return nil, nil
+}
+
+func TestControllerGET(t *testing.T) {
+	c := NewController(&fakeManager{pathways: map[string]*pathway.Pathway{
+		"admit": {Pathway: []pathway.Step{{Admission: &pathway.Admission{Loc: "ED"}}}},
+	}})
+
+	cases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{{
+		name:       "default format",
+		query:      "pathway=admit",
+		wantStatus: http.StatusOK,
+		wantBody:   "flowchart TD",
+	}, {
+		name:       "dot",
+		query:      "pathway=admit&format=dot",
+		wantStatus: http.StatusOK,
+		wantBody:   `digraph "admit"`,
+	}, {
+		name:       "missing pathway",
+		query:      "format=dot",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "unknown format",
+		query:      "pathway=admit&format=svg",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "unknown pathway",
+		query:      "pathway=unknown",
+		wantStatus: http.StatusNotFound,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, httptest.NewRequest("GET", "/pathwayDiagram?"+tc.query, nil))
+			if got := w.Code; got != tc.wantStatus {
+				t.Errorf("ServeHTTP(%q) got status %d, want %d", tc.query, got, tc.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("ServeHTTP(%q) got body:\n%s\nwant it to contain %q", tc.query, w.Body.String(), tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestControllerPOST(t *testing.T) {
+	c := NewController(&fakeManager{})
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("POST", "/pathwayDiagram", nil))
+	if got, want := w.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("ServeHTTP() got status %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,376 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagram draws pathways as diagrams, in Graphviz DOT or Mermaid format.
+package diagram
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/constants"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+)
+
+// Format is the format of a diagram.
+type Format string
+
+const (
+	// DOT is the Graphviz DOT format.
+	DOT = Format("dot")
+	// Mermaid is the format of Mermaid flowcharts.
+	Mermaid = Format("mermaid")
+)
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case DOT, Mermaid:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown diagram format %q; supported formats are %q and %q", s, DOT, Mermaid)
+	}
+}
+
+// Write writes the diagram of pathway p, named name, to w in the given format.
+// Historical steps and the steps of the pathway are drawn in separate groups. Every step shows its
+// type, its main arguments and the types of the messages it produces, and delays show their range.
+// AutoGenerate steps are drawn as single steps; use the Runnable version of the pathway to draw the
+// steps that they generate instead.
+func Write(w io.Writer, f Format, name string, p *pathway.Pathway) error {
+	g := build(p)
+	var err error
+	switch f {
+	case DOT:
+		err = writeDOT(w, name, g)
+	case Mermaid:
+		err = writeMermaid(w, name, g)
+	default:
+		return fmt.Errorf("unknown diagram format %q", f)
+	}
+	return errors.Wrapf(err, "cannot write diagram of pathway %s", name)
+}
+
+type nodeKind int
+
+const (
+	stepNode nodeKind = iota
+	delayNode
+	// controlNode is a step that controls the flow of the pathway, e.g., Parallel.
+	controlNode
+	// joinNode is where the tracks of a Parallel step join.
+	joinNode
+)
+
+type node struct {
+	id    string
+	kind  nodeKind
+	lines []string
+}
+
+type edge struct {
+	from   string
+	to     string
+	label  string
+	dashed bool
+}
+
+// group is a group of nodes that is drawn as a box, e.g., the historical steps or a track of a Parallel step.
+type group struct {
+	id     string
+	label  string
+	nodes  []*node
+	groups []*group
+}
+
+type graph struct {
+	root  *group
+	edges []edge
+}
+
+// endpoint is a node that the next node needs to be connected to, and how.
+type endpoint struct {
+	id     string
+	label  string
+	dashed bool
+}
+
+type builder struct {
+	g      *graph
+	nextID int
+}
+
+func build(p *pathway.Pathway) *graph {
+	b := &builder{g: &graph{root: &group{}}}
+	var prev []endpoint
+	if len(p.History) > 0 {
+		prev = b.addSteps(b.addGroup(b.g.root, "History"), p.History, nil)
+		for i := range prev {
+			prev[i].label = "now"
+		}
+	}
+	b.addSteps(b.addGroup(b.g.root, "Pathway"), p.Pathway, prev)
+	return b.g
+}
+
+func (b *builder) id(prefix string) string {
+	b.nextID++
+	return fmt.Sprintf("%s%d", prefix, b.nextID)
+}
+
+func (b *builder) addGroup(parent *group, label string) *group {
+	g := &group{id: b.id("cluster_"), label: label}
+	parent.groups = append(parent.groups, g)
+	return g
+}
+
+// addNode adds a node to group g, and connects the endpoints in prev to it.
+func (b *builder) addNode(g *group, kind nodeKind, lines []string, prev []endpoint) string {
+	n := &node{id: b.id("n"), kind: kind, lines: lines}
+	g.nodes = append(g.nodes, n)
+	for _, e := range prev {
+		b.g.edges = append(b.g.edges, edge{from: e.id, to: n.id, label: e.label, dashed: e.dashed})
+	}
+	return n.id
+}
+
+// addSteps adds the given steps to group g, and connects the endpoints in prev to the first of them.
+// It returns the endpoints that the step after them needs to be connected to.
+func (b *builder) addSteps(g *group, steps []pathway.Step, prev []endpoint) []endpoint {
+	for i := range steps {
+		s := &steps[i]
+		switch s.StepType() {
+		case pathway.StepDelay:
+			id := b.addNode(g, delayNode, []string{"delay " + durationRange(s.Delay.From, s.Delay.To)}, prev)
+			prev = []endpoint{{id: id}}
+		case pathway.StepParallel:
+			id := b.addNode(g, controlNode, stepLines(s), prev)
+			var ends []endpoint
+			for j, t := range s.Parallel.Tracks {
+				label := t.Name
+				if label == "" {
+					label = fmt.Sprintf("track %d", j+1)
+				}
+				ends = append(ends, b.addSteps(b.addGroup(g, label), t.Steps, []endpoint{{id: id}})...)
+			}
+			prev = []endpoint{{id: b.addNode(g, joinNode, nil, ends)}}
+		case pathway.StepWaitForEvent:
+			id := b.addNode(g, controlNode, stepLines(s), prev)
+			if len(s.WaitForEvent.OnTimeout) > 0 {
+				b.addSteps(b.addGroup(g, "on timeout"), s.WaitForEvent.OnTimeout, []endpoint{{id: id, label: "timeout", dashed: true}})
+			}
+			prev = []endpoint{{id: id, label: s.WaitForEvent.Event}}
+		default:
+			id := b.addNode(g, stepNode, stepLines(s), prev)
+			prev = []endpoint{{id: id}}
+		}
+	}
+	return prev
+}
+
+// stepNames maps the step types to their names in the pathway files, e.g., "PreAdmission" to "pre_admission".
+var stepNames = func() map[string]string {
+	names := map[string]string{}
+	t := reflect.TypeOf(pathway.Step{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		names[f.Name] = name
+	}
+	return names
+}()
+
+// stepLines returns the lines of text that describe step s: its type and label, its main arguments,
+// its parameters that affect time, and the types of the messages it produces.
+func stepLines(s *pathway.Step) []string {
+	title := stepNames[s.StepType()]
+	if s.Label != "" {
+		title = fmt.Sprintf("%s (%s)", title, s.Label)
+	}
+	lines := []string{title}
+
+	switch s.StepType() {
+	case pathway.StepAdmission:
+		lines = append(lines, "loc: "+s.Admission.Loc)
+	case pathway.StepTransfer:
+		lines = append(lines, "loc: "+s.Transfer.Loc)
+	case pathway.StepOrder:
+		lines = append(lines, "order_profile: "+s.Order.OrderProfile)
+	case pathway.StepResults:
+		lines = append(lines, "order_profile: "+s.Result.OrderProfile)
+	case pathway.StepUsePatient:
+		lines = append(lines, "patient: "+string(s.UsePatient.Patient))
+	case pathway.StepBirth:
+		lines = append(lines, "baby: "+string(s.Birth.Baby))
+	case pathway.StepMerge:
+		var children []string
+		for _, c := range s.Merge.Children {
+			children = append(children, string(c))
+		}
+		lines = append(lines, fmt.Sprintf("parent: %s, children: %s", s.Merge.Parent, strings.Join(children, ", ")))
+	case pathway.StepBedSwap:
+		lines = append(lines, fmt.Sprintf("patients: %s, %s", s.BedSwap.Patient1, s.BedSwap.Patient2))
+	case pathway.StepHardcodedMessage:
+		lines = append(lines, "regex: "+s.HardcodedMessage.Regex)
+	case pathway.StepGeneric:
+		lines = append(lines, "name: "+s.Generic.Name)
+	case pathway.StepWaitForEvent:
+		lines = append(lines, "event: "+s.WaitForEvent.Event)
+		if s.WaitForEvent.Timeout != nil {
+			lines = append(lines, "timeout: "+formatDuration(*s.WaitForEvent.Timeout))
+		}
+	case pathway.StepAutoGenerate:
+		lines = append(lines, autoGenerateLines(s.AutoGenerate)...)
+	}
+
+	if p := s.Parameters; p != nil {
+		if p.TimeFromNow != nil {
+			lines = append(lines, "time_from_now: "+formatDuration(*p.TimeFromNow))
+		}
+		if p.DelayMessage != nil {
+			lines = append(lines, "delay_message: "+durationRange(p.DelayMessage.From, p.DelayMessage.To))
+		}
+	}
+	if m := messageTypes(s); len(m) > 0 {
+		lines = append(lines, "messages: "+strings.Join(m, ", "))
+	}
+	return lines
+}
+
+func autoGenerateLines(a *pathway.AutoGenerate) []string {
+	var lines []string
+	if a.Result != nil {
+		lines = append(lines, "result: "+a.Result.OrderProfile)
+	}
+	var every []string
+	if a.Every != nil {
+		every = append(every, "every "+formatDuration(*a.Every))
+	}
+	if a.From != nil {
+		every = append(every, "from "+formatDuration(*a.From))
+	}
+	if a.To != nil {
+		every = append(every, "to "+formatDuration(*a.To))
+	}
+	if len(every) > 0 {
+		lines = append(lines, strings.Join(every, " "))
+	}
+	return lines
+}
+
+// messageTypes returns the types of the messages that step s produces.
+// If the type depends on the state of the patient, all the possible types are returned.
+func messageTypes(s *pathway.Step) []string {
+	switch s.StepType() {
+	case pathway.StepAdmission:
+		return []string{"ADT^A01"}
+	case pathway.StepTransfer, pathway.StepTransferInError:
+		return []string{"ADT^A02"}
+	case pathway.StepDischarge, pathway.StepDischargeInError:
+		return []string{"ADT^A03"}
+	case pathway.StepRegistration:
+		return []string{"ADT^A04"}
+	case pathway.StepPreAdmission:
+		return []string{"ADT^A05"}
+	case pathway.StepUpdatePerson:
+		return []string{"ADT^A08 or ADT^A31"}
+	case pathway.StepTrackDeparture:
+		return []string{"ADT^A09"}
+	case pathway.StepTrackArrival:
+		return []string{"ADT^A10"}
+	case pathway.StepCancelVisit:
+		return []string{"ADT^A11"}
+	case pathway.StepCancelTransfer:
+		return []string{"ADT^A12"}
+	case pathway.StepCancelDischarge:
+		return []string{"ADT^A13"}
+	case pathway.StepPendingAdmission:
+		return []string{"ADT^A14"}
+	case pathway.StepPendingTransfer:
+		return []string{"ADT^A15"}
+	case pathway.StepPendingDischarge:
+		return []string{"ADT^A16"}
+	case pathway.StepBedSwap:
+		return []string{"ADT^A17"}
+	case pathway.StepDeleteVisit:
+		return []string{"ADT^A23"}
+	case pathway.StepCancelPendingDischarge:
+		return []string{"ADT^A25"}
+	case pathway.StepCancelPendingTransfer:
+		return []string{"ADT^A26"}
+	case pathway.StepCancelPendingAdmission:
+		return []string{"ADT^A27"}
+	case pathway.StepAddPerson, pathway.StepBirth:
+		return []string{"ADT^A28"}
+	case pathway.StepMerge:
+		if len(s.Merge.Children) == 1 && !s.Merge.ForceA40 {
+			return []string{"ADT^A34"}
+		}
+		return []string{"ADT^A40"}
+	case pathway.StepDocument:
+		return []string{"MDM^T02"}
+	case pathway.StepOrder:
+		if s.Order.NoAcknowledgementMessage {
+			return []string{"ORM^O01"}
+		}
+		return []string{"ORM^O01", "ORR^O02"}
+	case pathway.StepResults:
+		return []string{resultsMessageType(s.Result)}
+	case pathway.StepAutoGenerate:
+		if s.AutoGenerate.Result != nil {
+			return []string{resultsMessageType(s.AutoGenerate.Result)}
+		}
+	case pathway.StepClinicalNote:
+		return []string{"ORU^R01"}
+	case pathway.StepHardcodedMessage:
+		return []string{"hardcoded"}
+	}
+	return nil
+}
+
+// resultsMessageType returns the type of the messages for results r, based on their trigger event.
+func resultsMessageType(r *pathway.Results) string {
+	switch te := strings.ToUpper(r.TriggerEvent); te {
+	case constants.R03, constants.R32:
+		return "ORU^" + te
+	default:
+		return "ORU^" + constants.R01
+	}
+}
+
+func durationRange(from, to time.Duration) string {
+	if from == to {
+		return formatDuration(from)
+	}
+	return fmt.Sprintf("%s - %s", formatDuration(from), formatDuration(to))
+}
+
+// formatDuration formats d without the units that are zero at the end, e.g., "1h" instead of "1h0m0s".
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
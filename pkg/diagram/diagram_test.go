@@ -0,0 +1,202 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagram
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+)
+
+func duration(d time.Duration) *time.Duration {
+	return &d
+}
+
+// testPathway has historical steps, delays, a Parallel step with a WaitForEvent step, and an AutoGenerate step.
+var testPathway = &pathway.Pathway{
+	History: []pathway.Step{
+		{Result: &pathway.Results{OrderProfile: "UREA AND ELECTROLYTES"}, Parameters: &pathway.Parameters{TimeFromNow: duration(-48 * time.Hour)}},
+	},
+	Pathway: []pathway.Step{
+		{Admission: &pathway.Admission{Loc: "ED"}, Label: "admit"},
+		{Delay: &pathway.Delay{From: time.Hour, To: 150 * time.Minute}},
+		{Parallel: &pathway.Parallel{Tracks: []pathway.Track{
+			{Name: "labs", Steps: []pathway.Step{{Order: &pathway.Order{OrderProfile: "UREA AND ELECTROLYTES"}}}},
+			{Steps: []pathway.Step{{WaitForEvent: &pathway.WaitForEvent{
+				Event:     "callback",
+				Timeout:   duration(time.Hour),
+				OnTimeout: []pathway.Step{{Discharge: &pathway.Discharge{}}},
+			}}}},
+		}}},
+		{AutoGenerate: &pathway.AutoGenerate{
+			Result: &pathway.Results{OrderProfile: "UREA AND ELECTROLYTES", TriggerEvent: "r03"},
+			From:   duration(0),
+			To:     duration(24 * time.Hour),
+			Every:  duration(6 * time.Hour),
+		}},
+		{Discharge: &pathway.Discharge{}},
+	},
+}
+
+func TestWriteMermaid(t *testing.T) {
+	want := `flowchart TD
+  %% complex
+  subgraph cluster_1 ["History"]
+    n2["result<br/>order_profile: UREA AND ELECTROLYTES<br/>time_from_now: -48h<br/>messages: ORU^R01"]
+  end
+  subgraph cluster_3 ["Pathway"]
+    n4["admission (admit)<br/>loc: ED<br/>messages: ADT^A01"]
+    n5(["delay 1h - 2h30m"])
+    n6{{"parallel"}}
+    n13((" "))
+    n14["autogenerate<br/>result: UREA AND ELECTROLYTES<br/>every 6h from 0s to 24h<br/>messages: ORU^R03"]
+    n15["discharge<br/>messages: ADT^A03"]
+    subgraph cluster_7 ["labs"]
+      n8["order<br/>order_profile: UREA AND ELECTROLYTES<br/>messages: ORM^O01, ORR^O02"]
+    end
+    subgraph cluster_9 ["track 2"]
+      n10{{"wait_for_event<br/>event: callback<br/>timeout: 1h"}}
+      subgraph cluster_11 ["on timeout"]
+        n12["discharge<br/>messages: ADT^A03"]
+      end
+    end
+  end
+  n2 -->|"now"| n4
+  n4 --> n5
+  n5 --> n6
+  n6 --> n8
+  n6 --> n10
+  n10 -.->|"timeout"| n12
+  n8 --> n13
+  n10 -->|"callback"| n13
+  n13 --> n14
+  n14 --> n15
+`
+	var b bytes.Buffer
+	if err := Write(&b, Mermaid, "complex", testPathway); err != nil {
+		t.Fatalf("Write(%q) failed with %v", Mermaid, err)
+	}
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write(%q) -> diff (-want, +got):\n%s", Mermaid, diff)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	want := `digraph "complex" {
+  subgraph cluster_1 {
+    label="History";
+    n2 [shape=box, label="result\norder_profile: UREA AND ELECTROLYTES\ntime_from_now: -48h\nmessages: ORU^R01"];
+  }
+  subgraph cluster_3 {
+    label="Pathway";
+    n4 [shape=box, label="admission (admit)\nloc: ED\nmessages: ADT^A01"];
+    n5 [shape=ellipse, style=dashed, label="delay 1h - 2h30m"];
+    n6 [shape=hexagon, label="parallel"];
+    n13 [shape=point];
+    n14 [shape=box, label="autogenerate\nresult: UREA AND ELECTROLYTES\nevery 6h from 0s to 24h\nmessages: ORU^R03"];
+    n15 [shape=box, label="discharge\nmessages: ADT^A03"];
+    subgraph cluster_7 {
+      label="labs";
+      n8 [shape=box, label="order\norder_profile: UREA AND ELECTROLYTES\nmessages: ORM^O01, ORR^O02"];
+    }
+    subgraph cluster_9 {
+      label="track 2";
+      n10 [shape=hexagon, label="wait_for_event\nevent: callback\ntimeout: 1h"];
+      subgraph cluster_11 {
+        label="on timeout";
+        n12 [shape=box, label="discharge\nmessages: ADT^A03"];
+      }
+    }
+  }
+  n2 -> n4 [label="now"];
+  n4 -> n5;
+  n5 -> n6;
+  n6 -> n8;
+  n6 -> n10;
+  n10 -> n12 [label="timeout", style=dashed];
+  n8 -> n13;
+  n10 -> n13 [label="callback"];
+  n13 -> n14;
+  n14 -> n15;
+}
+`
+	var b bytes.Buffer
+	if err := Write(&b, DOT, "complex", testPathway); err != nil {
+		t.Fatalf("Write(%q) failed with %v", DOT, err)
+	}
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write(%q) -> diff (-want, +got):\n%s", DOT, diff)
+	}
+}
+
+func TestWriteEscapesLabels(t *testing.T) {
+	p := &pathway.Pathway{Pathway: []pathway.Step{{Generic: &pathway.Generic{Name: `say "hi" <now>`}}}}
+
+	cases := []struct {
+		format Format
+		want   string
+	}{
+		{format: DOT, want: `label="generic\nname: say \"hi\" <now>"`},
+		{format: Mermaid, want: `["generic<br/>name: say #quot;hi#quot; #lt;now#gt;"]`},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			var b bytes.Buffer
+			if err := Write(&b, tc.format, "generic", p); err != nil {
+				t.Fatalf("Write(%q) failed with %v", tc.format, err)
+			}
+			if !bytes.Contains(b.Bytes(), []byte(tc.want)) {
+				t.Errorf("Write(%q) got:\n%s\nwant it to contain %s", tc.format, b.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestMessageTypes(t *testing.T) {
+	cases := []struct {
+		name string
+		step pathway.Step
+		want []string
+	}{
+		{name: "delay", step: pathway.Step{Delay: &pathway.Delay{}}, want: nil},
+		{name: "update person", step: pathway.Step{UpdatePerson: &pathway.UpdatePerson{}}, want: []string{"ADT^A08 or ADT^A31"}},
+		{name: "merge one child", step: pathway.Step{Merge: &pathway.Merge{Children: []pathway.PatientID{"1"}}}, want: []string{"ADT^A34"}},
+		{name: "merge force A40", step: pathway.Step{Merge: &pathway.Merge{Children: []pathway.PatientID{"1"}, ForceA40: true}}, want: []string{"ADT^A40"}},
+		{name: "order without acknowledgement", step: pathway.Step{Order: &pathway.Order{NoAcknowledgementMessage: true}}, want: []string{"ORM^O01"}},
+		{name: "results R32", step: pathway.Step{Result: &pathway.Results{TriggerEvent: "R32"}}, want: []string{"ORU^R32"}},
+		{name: "birth", step: pathway.Step{Birth: &pathway.Birth{Baby: "baby"}}, want: []string{"ADT^A28"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, messageTypes(&tc.step)); diff != "" {
+				t.Errorf("messageTypes(%+v) -> diff (-want, +got):\n%s", tc.step, diff)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"dot", "DOT", "mermaid"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("ParseFormat(%q) failed with %v", s, err)
+		}
+	}
+	if _, err := ParseFormat("svg"); err == nil {
+		t.Error("ParseFormat(svg) got nil error, want non-nil error")
+	}
+}
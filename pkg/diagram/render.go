@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagram
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotAttributes are the Graphviz attributes of each kind of node.
+var dotAttributes = map[nodeKind]string{
+	stepNode:    "shape=box",
+	delayNode:   "shape=ellipse, style=dashed",
+	controlNode: "shape=hexagon",
+	joinNode:    "shape=point",
+}
+
+// writeDOT writes g to w in Graphviz DOT format.
+func writeDOT(w io.Writer, name string, g *graph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(name))
+	writeDOTGroup(bw, g.root, 1)
+	for _, e := range g.edges {
+		var attrs []string
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(bw, "  %s -> %s", e.from, e.to)
+		if len(attrs) > 0 {
+			fmt.Fprintf(bw, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(bw, ";")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func writeDOTGroup(w io.Writer, g *group, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, n := range g.nodes {
+		attrs := dotAttributes[n.kind]
+		if n.kind != joinNode {
+			attrs = fmt.Sprintf("%s, label=%s", attrs, dotQuote(strings.Join(n.lines, "\n")))
+		}
+		fmt.Fprintf(w, "%s%s [%s];\n", indent, n.id, attrs)
+	}
+	for _, sub := range g.groups {
+		fmt.Fprintf(w, "%ssubgraph %s {\n", indent, sub.id)
+		fmt.Fprintf(w, "%s  label=%s;\n", indent, dotQuote(sub.label))
+		writeDOTGroup(w, sub, depth+1)
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+}
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// mermaidShapes are the opening and closing delimiters of the shape of each kind of node.
+var mermaidShapes = map[nodeKind][2]string{
+	stepNode:    {"[", "]"},
+	delayNode:   {"([", "])"},
+	controlNode: {"{{", "}}"},
+	joinNode:    {"((", "))"},
+}
+
+// writeMermaid writes g to w as a Mermaid flowchart.
+func writeMermaid(w io.Writer, name string, g *graph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart TD")
+	fmt.Fprintf(bw, "  %%%% %s\n", strings.ReplaceAll(name, "\n", " "))
+	writeMermaidGroup(bw, g.root, 1)
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		if e.label != "" {
+			arrow = fmt.Sprintf("%s|%s|", arrow, mermaidQuote(e.label))
+		}
+		fmt.Fprintf(bw, "  %s %s %s\n", e.from, arrow, e.to)
+	}
+	return bw.Flush()
+}
+
+func writeMermaidGroup(w io.Writer, g *group, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, n := range g.nodes {
+		shape := mermaidShapes[n.kind]
+		label := mermaidQuote(strings.Join(n.lines, "\n"))
+		if n.kind == joinNode {
+			label = `" "`
+		}
+		fmt.Fprintf(w, "%s%s%s%s%s\n", indent, n.id, shape[0], label, shape[1])
+	}
+	for _, sub := range g.groups {
+		fmt.Fprintf(w, "%ssubgraph %s [%s]\n", indent, sub.id, mermaidQuote(sub.label))
+		writeMermaidGroup(w, sub, depth+1)
+		fmt.Fprintf(w, "%send\n", indent)
+	}
+}
+
+// mermaidQuote returns s as a quoted Mermaid label.
+// Mermaid labels cannot contain quotes, so they are replaced with entity codes.
+func mermaidQuote(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", "<br/>")
+	return `"` + r.Replace(s) + `"`
+}
@@ -20,20 +20,24 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"sync"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 	"github.com/Arend-melissant/simhospital/pkg/files"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 var log = logging.ForCallerPackage()
 
 // Doctors contains and manages a set of doctors.
+// The methods of Doctors are safe for concurrent use.
 type Doctors struct {
-	m map[string]*ir.Doctor
-	k []string
+	m  map[string]*ir.Doctor
+	k  []string
+	mu sync.RWMutex
 }
 
 // LoadDoctors loads the doctors from the given file.
@@ -82,6 +86,8 @@ func validate(d *ir.Doctor) error {
 // Add adds a doctor to the set of available doctors.
 // Returns an error if the doctor with the same ID already exists.
 func (d *Doctors) Add(doctor *ir.Doctor) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if _, ok := d.m[doctor.ID]; ok {
 		return fmt.Errorf("a consultant with this consultant ID %q already exists in the doctors map", doctor.ID)
 	}
@@ -90,14 +96,35 @@ func (d *Doctors) Add(doctor *ir.Doctor) error {
 	return nil
 }
 
+// Replace replaces the doctors with the doctors in other, e.g., to reload the doctors file while
+// Simulated Hospital runs. Doctors that were added with Add are replaced too.
+func (d *Doctors) Replace(other *Doctors) {
+	other.mu.RLock()
+	m := make(map[string]*ir.Doctor, len(other.m))
+	for id, doctor := range other.m {
+		m[id] = doctor
+	}
+	k := append([]string(nil), other.k...)
+	other.mu.RUnlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.m = m
+	d.k = k
+}
+
 // GetByID returns a doctor by ID or nil if no doctor is mapped to a given ID.
 func (d *Doctors) GetByID(id string) *ir.Doctor {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	return d.m[id]
 }
 
 // GetByName returns a doctor by firstName and surname.
 // Returns nil if no matching doctor found.
 func (d *Doctors) GetByName(firstName string, surname string) *ir.Doctor {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	for _, doctor := range d.m {
 		if doctor.FirstName == firstName && doctor.Surname == surname {
 			return doctor
@@ -106,13 +133,27 @@ func (d *Doctors) GetByName(firstName string, surname string) *ir.Doctor {
 	return nil
 }
 
-// GetRandomDoctor returns a random doctor.
+// GetRandomDoctor returns a random doctor, using rng as the source of randomness.
+// If rng is nil, the default source of the math/rand package is used.
 // Returns nil if no doctors are specified.
-func (d *Doctors) GetRandomDoctor() *ir.Doctor {
+func (d *Doctors) GetRandomDoctor(rng *rand.Rand) *ir.Doctor {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	if len(d.k) == 0 {
 		return nil
 	}
 
-	id := rand.Intn(len(d.k))
+	id := random.Or(rng).Intn(len(d.k))
 	return d.m[d.k[id]]
 }
+
+// All returns all the doctors, in the order they were loaded or added.
+func (d *Doctors) All() []*ir.Doctor {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	all := make([]*ir.Doctor, len(d.k))
+	for i, id := range d.k {
+		all[i] = d.m[id]
+	}
+	return all
+}
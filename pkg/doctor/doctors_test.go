@@ -177,6 +177,34 @@ func TestDoctorsAdd(t *testing.T) {
 	}
 }
 
+func TestDoctorsReplace(t *testing.T) {
+	ctx := context.Background()
+	d, err := LoadDoctors(ctx, testwrite.BytesToFile(t, []byte(twoDoctors)))
+	if err != nil {
+		t.Fatalf("LoadDoctors(%s) failed with %v", twoDoctors, err)
+	}
+	other, err := LoadDoctors(ctx, testwrite.BytesToFile(t, []byte(singleDoctor)))
+	if err != nil {
+		t.Fatalf("LoadDoctors(%s) failed with %v", singleDoctor, err)
+	}
+
+	d.Replace(other)
+	if got := d.GetByID("id-2"); got != nil {
+		t.Errorf(`GetByID("id-2") got %+v, want <nil>`, got)
+	}
+	want := other.All()
+	if diff := cmp.Diff(want, d.All()); diff != "" {
+		t.Errorf("All() got diff (-want, +got):\n%s", diff)
+	}
+	// Adding doctors after Replace doesn't change other.
+	if err := d.Add(&ir.Doctor{ID: "id-3"}); err != nil {
+		t.Fatalf("Add() failed with %v", err)
+	}
+	if got := other.GetByID("id-3"); got != nil {
+		t.Errorf(`other.GetByID("id-3") got %+v, want <nil>`, got)
+	}
+}
+
 func TestDoctorsGetRandomDoctor(t *testing.T) {
 	ctx := context.Background()
 	// Somewhat arbitrary number of doctors and runs, but chosen in a way that the probability that
@@ -192,7 +220,7 @@ func TestDoctorsGetRandomDoctor(t *testing.T) {
 
 	pickedIDs := map[string]int{}
 	for i := 0; i < runs; i++ {
-		randomDoctor := d.GetRandomDoctor()
+		randomDoctor := d.GetRandomDoctor(nil)
 		if randomDoctor == nil {
 			t.Error("GetRandomDoctor() got <nil>; want not nil")
 			continue
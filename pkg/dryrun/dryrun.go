@@ -0,0 +1,137 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dryrun runs pathways without sending the messages they generate anywhere and without
+// persisting any state, so that the messages can be inspected without running Simulated Hospital.
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/clock"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+)
+
+// Message is a message generated by a pathway.
+type Message struct {
+	// Time is the time at which the message was sent, as seen by the clock of the dry run.
+	Time time.Time
+	// Message is the HL7v2 message.
+	Message string
+}
+
+// Config is the configuration of a dry run.
+type Config struct {
+	// Hospital is the configuration of the hospital that runs the pathway.
+	// Its Sender, Clock, PathwayManager and item syncers are replaced: messages are collected
+	// instead of being sent, and nothing is persisted.
+	Hospital hospital.Config
+	// Clock is the clock of the hospital. It is moved forward to the time of the next event or message
+	// as soon as there is nothing else to run, so that pathways with long delays run instantly.
+	// The pathway parser in Hospital, if any, should use the same clock.
+	Clock *clock.ManualClock
+	// MaxDuration is the maximum amount of simulated time that the pathway can run for.
+	// Run stops and returns an error if there are events or messages due after that.
+	MaxDuration time.Duration
+}
+
+// collector is an hl7.Sender that collects the messages instead of sending them.
+type collector struct {
+	clock    clock.Clock
+	messages []Message
+}
+
+// Send collects the message.
+func (c *collector) Send(message []byte) error {
+	c.messages = append(c.messages, Message{Time: c.clock.Now(), Message: string(message)})
+	return nil
+}
+
+// Close is a no-op.
+func (c *collector) Close() error {
+	return nil
+}
+
+// Run runs the pathway with the given name until there are no more events nor messages, and returns
+// the messages it generated, in the order in which they were sent.
+// If the pathway runs for longer than c.MaxDuration, Run returns the messages generated up to that
+// point, and an error.
+func Run(ctx context.Context, c Config, pathways map[string]pathway.Pathway, name string) ([]Message, error) {
+	if _, ok := pathways[name]; !ok {
+		return nil, fmt.Errorf("unknown pathway %q", name)
+	}
+	pm, err := pathway.NewDeterministicManager(pathways, []string{name}, c.Hospital.Rand)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create pathway manager")
+	}
+	sender := &collector{clock: c.Clock}
+	hc := c.Hospital
+	hc.Clock = c.Clock
+	hc.Sender = sender
+	hc.PathwayManager = pm
+	hc.AdditionalConfig.ItemSyncers = nil
+	h, err := hospital.NewHospital(ctx, hc)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create hospital")
+	}
+	defer h.Close()
+
+	if err := h.StartNextPathway(); err != nil {
+		return nil, errors.Wrapf(err, "cannot start pathway %q", name)
+	}
+	deadline := c.Clock.Now().Add(c.MaxDuration)
+	for h.HasEvents() || h.HasMessages() {
+		ran, err := h.RunNextEventIfDue(ctx)
+		if err != nil {
+			return sender.messages, errors.Wrap(err, "cannot run event")
+		}
+		processed, err := h.ProcessNextMessageIfDue()
+		if err != nil {
+			return sender.messages, errors.Wrap(err, "cannot process message")
+		}
+		if ran || processed {
+			continue
+		}
+		next, ok := nextTime(h)
+		if !ok {
+			break
+		}
+		if next.After(deadline) {
+			return sender.messages, fmt.Errorf("pathway %q did not finish within %v: the next event or message is due at %v", name, c.MaxDuration, next)
+		}
+		c.Clock.Set(next)
+	}
+	return sender.messages, nil
+}
+
+// nextTime returns the earliest time at which an event or a message is due.
+// It returns false if there are no events nor messages.
+func nextTime(h *hospital.Hospital) (time.Time, bool) {
+	eventTime, hasEvent := h.NextEventTime()
+	msgTime, hasMessage := h.NextMessageTime()
+	switch {
+	case hasEvent && hasMessage && msgTime.Before(eventTime):
+		return msgTime, true
+	case hasEvent:
+		return eventTime, true
+	case hasMessage:
+		return msgTime, true
+	default:
+		return time.Time{}, false
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/Arend-melissant/simhospital/pkg/clock"
+	"github.com/Arend-melissant/simhospital/pkg/hl7"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhl7"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
+	"github.com/Arend-melissant/simhospital/pkg/test/testresource"
+)
+
+var start = time.Date(2020, 2, 12, 10, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	hl7.TimezoneAndLocation("Europe/London")
+	os.Exit(m.Run())
+}
+
+func newConfig(ctx context.Context, t *testing.T, maxDuration time.Duration) Config {
+	t.Helper()
+	c := clock.NewManualClock(start)
+	args := testhospital.Arguments
+	args.Clock = c
+	args.PathwayArguments = nil
+	hc, err := hospital.DefaultConfig(ctx, args)
+	if err != nil {
+		t.Fatalf("hospital.DefaultConfig(%+v) failed with %v", args, err)
+	}
+	hc.ResourceWriter = testresource.NewWriter()
+	return Config{Hospital: hc, Clock: c, MaxDuration: maxDuration}
+}
+
+func pathways(t *testing.T, delay time.Duration) map[string]pathway.Pathway {
+	t.Helper()
+	p := pathway.Pathway{
+		Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: "Renal"}},
+			{Delay: &pathway.Delay{From: delay, To: delay}},
+			{Discharge: &pathway.Discharge{}},
+		},
+	}
+	p.Init("test_pathway")
+	return map[string]pathway.Pathway{"test_pathway": p}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	twoDays := 48 * time.Hour
+	messages, err := Run(ctx, newConfig(ctx, t, 7*24*time.Hour), pathways(t, twoDays), "test_pathway")
+	if err != nil {
+		t.Fatalf("Run() failed with %v", err)
+	}
+
+	var gotTypes []string
+	var gotTimes []time.Time
+	for _, m := range messages {
+		gotTypes = append(gotTypes, testhl7.MessageType(t, m.Message))
+		gotTimes = append(gotTimes, m.Time)
+	}
+	if diff := cmp.Diff([]string{"ADT^A01", "ADT^A03"}, gotTypes); diff != "" {
+		t.Errorf("Run() got message types diff (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]time.Time{start, start.Add(twoDays)}, gotTimes); diff != "" {
+		t.Errorf("Run() got message times diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRun_MaxDurationExceeded(t *testing.T) {
+	ctx := context.Background()
+	messages, err := Run(ctx, newConfig(ctx, t, 24*time.Hour), pathways(t, 48*time.Hour), "test_pathway")
+	if err == nil {
+		t.Fatal("Run() got nil error, want non nil error")
+	}
+	if got, want := len(messages), 1; got != want {
+		t.Errorf("len(Run()) got %d messages, want %d", got, want)
+	}
+}
+
+func TestRun_UnknownPathway(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Run(ctx, newConfig(ctx, t, time.Hour), pathways(t, time.Hour), "unknown"); err == nil {
+		t.Error("Run(unknown) got nil error, want non nil error")
+	}
+}
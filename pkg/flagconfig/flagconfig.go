@@ -0,0 +1,290 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flagconfig sets command line flags from a YAML configuration file and from environment
+// variables, so that programs with many flags can be configured declaratively.
+//
+// The settings in the file are named after the flags, e.g., "pathways_per_hour: 10" sets the
+// -pathways_per_hour flag. Settings can be grouped in nested mappings, whose keys are joined with
+// "_": for instance, the "destination" setting in the "mllp" mapping sets the -mllp_destination
+// flag. Lists are joined with ",".
+// The value of a flag can also be set in an environment variable named after the flag in upper case,
+// with a prefix, e.g., PREFIX_MLLP_DESTINATION.
+// Flags set in the command line take precedence over environment variables, which take precedence
+// over the file.
+package flagconfig
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Sources of the values of the flags.
+const (
+	SourceDefault     = "default"
+	SourceFile        = "file"
+	SourceEnvironment = "environment"
+	SourceCommandLine = "command line"
+)
+
+// redacted replaces the values of secret flags in the effective configuration.
+const redacted = "REDACTED"
+
+// Options configure how the flags are set.
+type Options struct {
+	// EnvPrefix is the prefix of the environment variables that set the flags.
+	// Optional: if not set, the flags are not set from environment variables.
+	EnvPrefix string
+	// NoEnv are the names of the flags that are not set from environment variables, e.g., because
+	// the program reads their environment variables itself.
+	NoEnv []string
+	// NotInFile are the names of the flags that cannot be set in the file, e.g., the flag with the
+	// path of the file itself.
+	NotInFile []string
+	// Secret are the names of the flags whose values are not written by WriteEffective.
+	Secret []string
+	// LookupEnv returns the value of the given environment variable, and whether it is set.
+	// Optional: if not set, os.LookupEnv is used.
+	LookupEnv func(key string) (string, bool)
+}
+
+// EnvName returns the name of the environment variable that sets the given flag, or an empty string
+// if flags are not set from environment variables.
+func (o Options) EnvName(flagName string) string {
+	if o.EnvPrefix == "" || contains(o.NoEnv, flagName) {
+		return ""
+	}
+	return o.EnvPrefix + strings.ToUpper(flagName)
+}
+
+func (o Options) lookupEnv(key string) (string, bool) {
+	if o.LookupEnv != nil {
+		return o.LookupEnv(key)
+	}
+	return os.LookupEnv(key)
+}
+
+// Sources maps the names of the flags that were set to where their values come from: SourceFile,
+// SourceEnvironment or SourceCommandLine. Flags that are not in Sources have their default values.
+type Sources map[string]string
+
+// Source returns where the value of the given flag comes from.
+func (s Sources) Source(flagName string) string {
+	if src, ok := s[flagName]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+// setting is a setting in the configuration file.
+type setting struct {
+	name  string
+	value string
+}
+
+// Apply sets the flags in fs that were not set in the command line, i.e., that fs.Visit doesn't
+// visit, from the environment variables and from the YAML configuration in data, which can be empty.
+// fileName is the name of the file that data was read from, and is only used in errors.
+// All the problems found, e.g., settings that don't match any flag or values that are not valid,
+// are returned in the error; some flags may have been set even if an error is returned.
+func Apply(fs *flag.FlagSet, data []byte, fileName string, o Options) (Sources, error) {
+	sources := Sources{}
+	fs.Visit(func(f *flag.Flag) { sources[f.Name] = SourceCommandLine })
+
+	var problems []string
+	settings, err := parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse configuration file %s", fileName)
+	}
+	seen := map[string]bool{}
+	for _, s := range settings {
+		switch {
+		case seen[s.name]:
+			problems = append(problems, fmt.Sprintf("%s: setting %q is set more than once", fileName, s.name))
+			continue
+		case contains(o.NotInFile, s.name):
+			problems = append(problems, fmt.Sprintf("%s: setting %q cannot be set in the configuration file", fileName, s.name))
+			continue
+		case fs.Lookup(s.name) == nil:
+			problems = append(problems, fmt.Sprintf("%s: unknown setting %q%s", fileName, s.name, suggestion(fs, s.name)))
+			continue
+		}
+		seen[s.name] = true
+		if _, ok := sources[s.name]; ok {
+			continue
+		}
+		if err := fs.Set(s.name, s.value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid value %q for setting %q: %v", fileName, s.value, s.name, err))
+			continue
+		}
+		sources[s.name] = SourceFile
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		env := o.EnvName(f.Name)
+		if env == "" || sources.Source(f.Name) == SourceCommandLine {
+			return
+		}
+		v, ok := o.lookupEnv(env)
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, v); err != nil {
+			problems = append(problems, fmt.Sprintf("environment variable %s: invalid value %q for flag -%s: %v", env, v, f.Name, err))
+			return
+		}
+		sources[f.Name] = SourceEnvironment
+	})
+
+	if len(problems) > 0 {
+		return sources, errors.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return sources, nil
+}
+
+// parse returns the settings in the given YAML configuration, in the order they appear.
+func parse(data []byte) ([]setting, error) {
+	var m yaml.MapSlice
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return flatten("", m)
+}
+
+func flatten(prefix string, m yaml.MapSlice) ([]setting, error) {
+	var settings []setting
+	for _, item := range m {
+		name := prefix + fmt.Sprint(item.Key)
+		switch v := item.Value.(type) {
+		case yaml.MapSlice:
+			nested, err := flatten(name+"_", v)
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, nested...)
+		case []interface{}:
+			values := make([]string, len(v))
+			for i, e := range v {
+				s, err := scalar(name, e)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = s
+			}
+			settings = append(settings, setting{name: name, value: strings.Join(values, ",")})
+		default:
+			s, err := scalar(name, v)
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, setting{name: name, value: s})
+		}
+	}
+	return settings, nil
+}
+
+func scalar(name string, v interface{}) (string, error) {
+	switch v.(type) {
+	case nil:
+		return "", errors.Errorf("setting %q has no value", name)
+	case yaml.MapSlice, []interface{}:
+		return "", errors.Errorf("setting %q has a nested value; only lists of plain values are supported", name)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// suggestion returns a hint with the name of the flag in fs that is most similar to the given name,
+// or an empty string if no flag is similar enough.
+func suggestion(fs *flag.FlagSet, name string) string {
+	best, bestDistance := "", len(name)/3+1
+	fs.VisitAll(func(f *flag.Flag) {
+		if d := distance(name, f.Name); d <= bestDistance {
+			best, bestDistance = f.Name, d
+		}
+	})
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean %q?", best)
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// WriteEffective writes the effective values of all the flags in fs to w, sorted by name, as a YAML
+// configuration file. Each value is followed by a comment with where it comes from. The values of
+// the Secret flags are redacted, so the output can be shared.
+func WriteEffective(w io.Writer, fs *flag.FlagSet, sources Sources, o Options) error {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	sort.Strings(names)
+	for _, name := range names {
+		v := fs.Lookup(name).Value.String()
+		if v != "" && contains(o.Secret, name) {
+			v = redacted
+		}
+		b, err := yaml.Marshal(map[string]string{name: v})
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal flag %s", name)
+		}
+		if _, err := fmt.Fprintf(w, "%s  # %s\n", strings.TrimSuffix(string(b), "\n"), sources.Source(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(s []string, e string) bool {
+	for _, v := range s {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flagconfig
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newFlagSet returns a flag set with some flags, parsed from the given command line arguments.
+func newFlagSet(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Float64("pathways_per_hour", 1, "")
+	fs.String("mllp_destination", "", "")
+	fs.Bool("mllp_keep_alive", false, "")
+	fs.Duration("sleep_for", time.Second, "")
+	fs.String("pathway_names", "", "")
+	fs.String("api_key", "", "")
+	fs.String("config_file", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse(%v) failed with %v", args, err)
+	}
+	return fs
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name        string
+		args        []string
+		file        string
+		env         map[string]string
+		want        map[string]string
+		wantSources Sources
+	}{{
+		name: "no configuration",
+		want: map[string]string{"pathways_per_hour": "1", "mllp_destination": "", "sleep_for": "1s"},
+	}, {
+		name: "flat settings",
+		file: `
+pathways_per_hour: 3600
+mllp_destination: localhost:6661
+sleep_for: 2s
+`,
+		want:        map[string]string{"pathways_per_hour": "3600", "mllp_destination": "localhost:6661", "sleep_for": "2s"},
+		wantSources: Sources{"pathways_per_hour": SourceFile, "mllp_destination": SourceFile, "sleep_for": SourceFile},
+	}, {
+		name: "nested settings and lists",
+		file: `
+mllp:
+  destination: localhost:6661
+  keep_alive: true
+pathway_names: [a, b]
+`,
+		want:        map[string]string{"mllp_destination": "localhost:6661", "mllp_keep_alive": "true", "pathway_names": "a,b"},
+		wantSources: Sources{"mllp_destination": SourceFile, "mllp_keep_alive": SourceFile, "pathway_names": SourceFile},
+	}, {
+		name:        "environment takes precedence over the file",
+		file:        "pathways_per_hour: 3600\nsleep_for: 2s",
+		env:         map[string]string{"TEST_PATHWAYS_PER_HOUR": "10"},
+		want:        map[string]string{"pathways_per_hour": "10", "sleep_for": "2s"},
+		wantSources: Sources{"pathways_per_hour": SourceEnvironment, "sleep_for": SourceFile},
+	}, {
+		name:        "command line takes precedence over the environment and the file",
+		args:        []string{"-pathways_per_hour", "5"},
+		file:        "pathways_per_hour: 3600",
+		env:         map[string]string{"TEST_PATHWAYS_PER_HOUR": "10"},
+		want:        map[string]string{"pathways_per_hour": "5"},
+		wantSources: Sources{"pathways_per_hour": SourceCommandLine},
+	}, {
+		name:        "flags not set from the environment",
+		env:         map[string]string{"TEST_API_KEY": "secret"},
+		want:        map[string]string{"api_key": ""},
+		wantSources: Sources{},
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := newFlagSet(t, tc.args...)
+			o := Options{
+				EnvPrefix: "TEST_",
+				NoEnv:     []string{"api_key"},
+				LookupEnv: func(key string) (string, bool) {
+					v, ok := tc.env[key]
+					return v, ok
+				},
+			}
+			sources, err := Apply(fs, []byte(tc.file), "config.yml", o)
+			if err != nil {
+				t.Fatalf("Apply() failed with %v", err)
+			}
+			got := map[string]string{}
+			for name := range tc.want {
+				got[name] = fs.Lookup(name).Value.String()
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Apply() flag values -want, +got:\n%s", diff)
+			}
+			if tc.wantSources == nil {
+				tc.wantSources = Sources{}
+			}
+			if diff := cmp.Diff(tc.wantSources, sources); diff != "" {
+				t.Errorf("Apply() sources -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		file string
+		env  map[string]string
+		// wantProblems are substrings of the error, one per problem.
+		wantProblems []string
+	}{{
+		name:         "invalid YAML",
+		file:         "pathways_per_hour: [",
+		wantProblems: []string{"cannot parse configuration file config.yml"},
+	}, {
+		name:         "unknown setting with a suggestion",
+		file:         "pathway_per_hour: 10",
+		wantProblems: []string{`config.yml: unknown setting "pathway_per_hour"; did you mean "pathways_per_hour"?`},
+	}, {
+		name:         "unknown setting without a suggestion",
+		file:         "colour: blue",
+		wantProblems: []string{`config.yml: unknown setting "colour"`},
+	}, {
+		name:         "invalid value",
+		file:         "sleep_for: often",
+		wantProblems: []string{`config.yml: invalid value "often" for setting "sleep_for"`},
+	}, {
+		name:         "setting not allowed in the file",
+		file:         "config_file: other.yml",
+		wantProblems: []string{`config.yml: setting "config_file" cannot be set in the configuration file`},
+	}, {
+		name:         "setting set twice",
+		file:         "mllp_destination: a\nmllp:\n  destination: b",
+		wantProblems: []string{`config.yml: setting "mllp_destination" is set more than once`},
+	}, {
+		name:         "setting without value",
+		file:         "mllp_destination:",
+		wantProblems: []string{`setting "mllp_destination" has no value`},
+	}, {
+		name:         "invalid environment variable",
+		env:          map[string]string{"TEST_MLLP_KEEP_ALIVE": "maybe"},
+		wantProblems: []string{`environment variable TEST_MLLP_KEEP_ALIVE: invalid value "maybe" for flag -mllp_keep_alive`},
+	}, {
+		name: "all the problems are reported",
+		file: "pathway_per_hour: 10\nsleep_for: often",
+		wantProblems: []string{
+			`unknown setting "pathway_per_hour"`,
+			`invalid value "often" for setting "sleep_for"`,
+		},
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := Options{
+				EnvPrefix: "TEST_",
+				NotInFile: []string{"config_file"},
+				LookupEnv: func(key string) (string, bool) {
+					v, ok := tc.env[key]
+					return v, ok
+				},
+			}
+			_, err := Apply(newFlagSet(t), []byte(tc.file), "config.yml", o)
+			if err == nil {
+				t.Fatal("Apply() got nil error, want error")
+			}
+			for _, p := range tc.wantProblems {
+				if !strings.Contains(err.Error(), p) {
+					t.Errorf("Apply() got error %q, want it to contain %q", err, p)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteEffective(t *testing.T) {
+	fs := newFlagSet(t, "-api_key", "secret", "-pathways_per_hour", "5")
+	o := Options{Secret: []string{"api_key", "mllp_destination"}}
+	sources, err := Apply(fs, []byte("sleep_for: 2s"), "config.yml", o)
+	if err != nil {
+		t.Fatalf("Apply() failed with %v", err)
+	}
+	var b bytes.Buffer
+	if err := WriteEffective(&b, fs, sources, o); err != nil {
+		t.Fatalf("WriteEffective() failed with %v", err)
+	}
+	want := `api_key: REDACTED  # command line
+config_file: ""  # default
+mllp_destination: ""  # default
+mllp_keep_alive: "false"  # default
+pathway_names: ""  # default
+pathways_per_hour: "5"  # command line
+sleep_for: 2s  # file
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteEffective() -want, +got:\n%s", diff)
+	}
+
+	// The output is a valid configuration file that results in the same values.
+	roundTrip := newFlagSet(t)
+	out := strings.Replace(b.String(), "api_key: REDACTED", "api_key: secret", 1)
+	if _, err := Apply(roundTrip, []byte(out), "effective.yml", Options{}); err != nil {
+		t.Fatalf("Apply(effective configuration) failed with %v", err)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if got, want := roundTrip.Lookup(f.Name).Value.String(), f.Value.String(); got != want {
+			t.Errorf("flag %s after applying the effective configuration got %q, want %q", f.Name, got, want)
+		}
+	})
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 
 	cpb "github.com/google/fhir/go/proto/google/fhir/proto/r4/core/codes_go_proto"
 )
@@ -37,9 +38,10 @@ const (
 )
 
 // Random generates a random gender from the options of Male or Female
-// with equal probability.
-func Random() Internal {
-	switch rand.Intn(2) {
+// with equal probability, using rng as the source of randomness.
+// If rng is nil, the default source of the math/rand package is used.
+func Random(rng *rand.Rand) Internal {
+	switch random.Or(rng).Intn(2) {
 	case 0:
 		return Male
 	default:
@@ -111,7 +111,7 @@ func TestRandom(t *testing.T) {
 	runs := 1000
 
 	for i := 0; i < runs; i++ {
-		got := Random()
+		got := Random(nil)
 		if !contains(got, want) {
 			t.Errorf("Random()=%v; want one of %v", got, want)
 		}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // Generator is a generator of addresses.
@@ -29,6 +30,9 @@ type Generator struct {
 	Nouns             []string
 	Address           config.Address
 	PostcodeGenerator PostcodeGenerator
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // PostcodeGenerator is a generator of postcodes.
@@ -64,30 +68,31 @@ func (g *Generator) Random() *ir.Address {
 		Type:       "home",
 	}
 
-	if rand.Intn(2) == 0 {
+	r := random.Or(g.Rand)
+	if r.Intn(2) == 0 {
 		// 1 line address
-		a.FirstLine = fmt.Sprintf("%d %s %s", rand.Intn(200)+1, strings.Title(g.noun()), g.street())
+		a.FirstLine = fmt.Sprintf("%d %s %s", r.Intn(200)+1, strings.Title(g.noun()), g.street())
 	} else {
 		// 2 lines address
-		a.FirstLine = fmt.Sprintf("%d %s House", rand.Intn(100)+1, strings.Title(g.noun()))
+		a.FirstLine = fmt.Sprintf("%d %s House", r.Intn(100)+1, strings.Title(g.noun()))
 		a.SecondLine = fmt.Sprintf("%s %s", strings.Title(g.noun()), g.street())
 	}
 	return a
 }
 
 func (g *Generator) city() string {
-	return random(g.Address.Cities)
+	return g.random(g.Address.Cities)
 }
 
 func (g *Generator) street() string {
-	return random(g.Address.Streets)
+	return g.random(g.Address.Streets)
 }
 
 func (g *Generator) noun() string {
-	return random(g.Nouns)
+	return g.random(g.Nouns)
 }
 
 // random returns a random item from the given slice.
-func random(s []string) string {
-	return s[rand.Intn(len(s))]
+func (g *Generator) random(s []string) string {
+	return s[random.Or(g.Rand).Intn(len(s))]
 }
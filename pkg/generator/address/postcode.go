@@ -17,10 +17,16 @@ package address
 import (
 	"fmt"
 	"math/rand"
+
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // UKPostcode is a generator of UK postcodes.
-type UKPostcode struct{}
+type UKPostcode struct {
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
+}
 
 // Random returns a random string that matches the format of a UK post code:
 // XX1 1XX or XX11 1XX
@@ -31,15 +37,20 @@ type UKPostcode struct{}
 //
 // The returned postcode might exist or not.
 func (g *UKPostcode) Random() string {
-	return fmt.Sprintf("%s%s%d %d%s%s", randomLetter(), randomLetter(), rand.Intn(99)+1, rand.Intn(9)+1, randomLetter(), randomLetter())
+	r := random.Or(g.Rand)
+	return fmt.Sprintf("%s%s%d %d%s%s", randomLetter(r), randomLetter(r), r.Intn(99)+1, r.Intn(9)+1, randomLetter(r), randomLetter(r))
 }
 
-func randomLetter() string {
-	return string(rand.Intn(int('Z')-int('A')) + int('A'))
+func randomLetter(r *rand.Rand) string {
+	return string(rune(r.Intn(int('Z')-int('A')) + int('A')))
 }
 
 // USPostcode is a generator of US zipcodes.
-type USPostcode struct{}
+type USPostcode struct {
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
+}
 
 // Random returns a random string that matches the format of a US zipcode:
 // 11111
@@ -52,7 +63,7 @@ func (g *USPostcode) Random() string {
 	chars := []rune("1234567890")
 	udn := make([]rune, 5)
 	for i := range udn {
-		udn[i] = chars[rand.Intn(len(chars))]
+		udn[i] = chars[random.Or(g.Rand).Intn(len(chars))]
 	}
 	return string(udn)
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 
 	cpb "github.com/google/fhir/go/proto/google/fhir/proto/r4/core/codes_go_proto"
 )
@@ -53,12 +54,12 @@ func (g *AllergyGenerator) DeriveCodingSystem(config config.HL7Allergy, a pathwa
 
 // randomSeverity returns a random severity value, where each value has an equal probability to be selected.
 func (g *AllergyGenerator) randomSeverity() string {
-	return g.severities[rand.Intn(len(g.severities))]
+	return g.severities[random.Or(g.Rand).Intn(len(g.severities))]
 }
 
 // randomReaction returns a random reaction value, where each value has an equal probability to be selected.
 func (g *AllergyGenerator) randomReaction() string {
-	return g.reactions[rand.Intn(len(g.reactions))]
+	return g.reactions[random.Or(g.Rand).Intn(len(g.reactions))]
 }
 
 // randomIdentificationDateTime returns a random identification datetime.
@@ -71,11 +72,12 @@ func (g *AllergyGenerator) randomIdentificationDateTime() ir.NullTime {
 // After that, the final number of items is picked randomly between 1 to maxAllergies (both inclusive).
 func (g *AllergyGenerator) GenerateRandomDistinctAllergies() []*ir.Allergy {
 	var generatedAllergies []*ir.Allergy
-	ra := rand.Intn(100)
+	r := random.Or(g.Rand)
+	ra := r.Intn(100)
 	if ra >= g.percentage {
 		return generatedAllergies
 	}
-	allergyCount := rand.Intn(g.maxAllergies) + 1
+	allergyCount := r.Intn(g.maxAllergies) + 1
 	selectedCodes := map[string]bool{}
 	for len(generatedAllergies) < allergyCount {
 		a := g.Random()
@@ -95,9 +97,10 @@ func (g *AllergyGenerator) GenerateRandomDistinctAllergies() []*ir.Allergy {
 }
 
 // NewAllergyGenerator creates a new Generator with the allergies from the given configurations.
-func NewAllergyGenerator(hc *config.HL7Config, d *config.Data, c clock.Clock, dg DateGenerator) *AllergyGenerator {
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func NewAllergyGenerator(hc *config.HL7Config, d *config.Data, c clock.Clock, dg DateGenerator, rng *rand.Rand) *AllergyGenerator {
 	return &AllergyGenerator{
-		Generator:    newGenerator(d.Allergies, hc.Allergy.Types, c, dg),
+		Generator:    newGenerator(d.Allergies, hc.Allergy.Types, c, dg, rng),
 		severities:   hc.Allergy.Severities,
 		reactions:    d.Allergy.Reactions,
 		percentage:   d.Allergy.Percentage,
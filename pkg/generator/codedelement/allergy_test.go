@@ -50,7 +50,7 @@ func TestAllergyGenerator_RandomNoItems(t *testing.T) {
 	if err != nil {
 		t.Fatalf("LoadData(%+v, %+v) failed with %v", f, configHL7, err)
 	}
-	g := NewAllergyGenerator(configHL7, data, testclock.New(defaultDate), &testdate.Generator{})
+	g := NewAllergyGenerator(configHL7, data, testclock.New(defaultDate), &testdate.Generator{}, nil)
 	if r := g.Random(); r != nil {
 		t.Errorf("NewAllergyGenerator().Random() = %v, want <nil>", r)
 	}
@@ -78,7 +78,7 @@ T78.1,Allergy4,23
 	if err != nil {
 		t.Fatalf("LoadData(%+v, %+v) failed with %v", f, configHL7, err)
 	}
-	g := NewAllergyGenerator(configHL7, data, testclock.New(defaultDate), &testdate.Generator{})
+	g := NewAllergyGenerator(configHL7, data, testclock.New(defaultDate), &testdate.Generator{}, nil)
 	if len(g.WeightedValues) != 4 {
 		t.Fatalf("len(allergies.WeightedValues) = %d, want %d", len(g.WeightedValues), 4)
 	}
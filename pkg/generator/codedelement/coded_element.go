@@ -23,6 +23,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 	"github.com/Arend-melissant/simhospital/pkg/sample"
 )
 
@@ -91,7 +92,7 @@ func (g *Generator) Random() *ir.CodedElement {
 
 // RandomType returns a random type value, where each value has an equal probability to be selected.
 func (g *Generator) RandomType() string {
-	return g.types[rand.Intn(len(g.types))]
+	return g.types[random.Or(g.Rand).Intn(len(g.types))]
 }
 
 // DeriveCodeAndDescription returns underlying CodeDescriptionMapping.
@@ -112,7 +113,8 @@ func (g *Generator) nullTimeOrRandom(dt *pathway.DateTime) ir.NullTime {
 }
 
 // newGenerator creates a Generator with the given coded elements and types.
-func newGenerator(wrappedVals []config.MappableWeightedValue, types []string, c clock.Clock, dg DateGenerator) *Generator {
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func newGenerator(wrappedVals []config.MappableWeightedValue, types []string, c clock.Clock, dg DateGenerator, rng *rand.Rand) *Generator {
 	weightVals := make([]sample.WeightedValue, 0, len(wrappedVals))
 	mapping := NewCodeDescriptionMapping()
 	for _, wv := range wrappedVals {
@@ -123,6 +125,7 @@ func newGenerator(wrappedVals []config.MappableWeightedValue, types []string, c
 	return &Generator{
 		DiscreteDistribution: &sample.DiscreteDistribution{
 			WeightedValues: weightVals,
+			Rand:           rng,
 		},
 		mapping:       mapping,
 		types:         types,
@@ -138,11 +141,15 @@ type DateGenerator interface {
 }
 
 // SimpleDateGenerator is a generator of random dates.
-type SimpleDateGenerator struct{}
+type SimpleDateGenerator struct {
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
+}
 
 // Random returns a random time, up to a year ago based on the given time.
 func (s SimpleDateGenerator) Random(now time.Time) ir.NullTime {
-	days := rand.Int63n(364) + 1
+	days := random.Or(s.Rand).Int63n(364) + 1
 	timeFromNow := -time.Duration(days) * 24 * time.Hour
 	return ir.NewValidTime(now.Add(timeFromNow))
 }
@@ -15,6 +15,8 @@
 package codedelement
 
 import (
+	"math/rand"
+
 	"github.com/Arend-melissant/simhospital/pkg/clock"
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/constants"
@@ -28,13 +30,15 @@ type DiagOrProcGenerator struct {
 }
 
 // NewDiagnosisGenerator creates a new generator of Diagnoses.
-func NewDiagnosisGenerator(hc *config.HL7Config, d *config.Data, c clock.Clock, dg DateGenerator) *DiagOrProcGenerator {
-	return &DiagOrProcGenerator{Generator: newGenerator(d.Diagnoses, hc.Diagnosis.Types, c, dg)}
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func NewDiagnosisGenerator(hc *config.HL7Config, d *config.Data, c clock.Clock, dg DateGenerator, rng *rand.Rand) *DiagOrProcGenerator {
+	return &DiagOrProcGenerator{Generator: newGenerator(d.Diagnoses, hc.Diagnosis.Types, c, dg, rng)}
 }
 
 // NewProcedureGenerator creates a new generator of Procedures.
-func NewProcedureGenerator(hc *config.HL7Config, d *config.Data, c clock.Clock, dg DateGenerator) *DiagOrProcGenerator {
-	return &DiagOrProcGenerator{Generator: newGenerator(d.Procedures, hc.Procedure.Types, c, dg)}
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func NewProcedureGenerator(hc *config.HL7Config, d *config.Data, c clock.Clock, dg DateGenerator, rng *rand.Rand) *DiagOrProcGenerator {
+	return &DiagOrProcGenerator{Generator: newGenerator(d.Procedures, hc.Procedure.Types, c, dg, rng)}
 }
 
 // RandomOrFromPathway returns a random ir.DiagnosisOrProcedure or one based on the pathway
@@ -75,7 +75,7 @@ P04.1,Procedure4,1
 		want             *ir.DiagnosisOrProcedure
 	}{{
 		name: "Diagnosis from pathway",
-		g:    NewDiagnosisGenerator(c, data, tclock, dg),
+		g:    NewDiagnosisGenerator(c, data, tclock, dg, nil),
 		input: &pathway.DiagnosisOrProcedure{
 			Type:        "some-type",
 			Description: "description",
@@ -89,7 +89,7 @@ P04.1,Procedure4,1
 		wantTypes: c.Diagnosis.Types,
 	}, {
 		name: "Random Diagnosis",
-		g:    NewDiagnosisGenerator(c, data, tclock, dg),
+		g:    NewDiagnosisGenerator(c, data, tclock, dg, nil),
 		input: &pathway.DiagnosisOrProcedure{
 			Description: "RANDOM",
 		},
@@ -98,7 +98,7 @@ P04.1,Procedure4,1
 		wantCodingSystem: c.Diagnosis.CodingSystem,
 	}, {
 		name: "Procedure from pathway",
-		g:    NewProcedureGenerator(c, data, tclock, dg),
+		g:    NewProcedureGenerator(c, data, tclock, dg, nil),
 		input: &pathway.DiagnosisOrProcedure{
 			Type:        "some-type",
 			Description: "description",
@@ -112,7 +112,7 @@ P04.1,Procedure4,1
 		wantTypes: c.Procedure.Types,
 	}, {
 		name: "Random Procedure",
-		g:    NewProcedureGenerator(c, data, tclock, dg),
+		g:    NewProcedureGenerator(c, data, tclock, dg, nil),
 		input: &pathway.DiagnosisOrProcedure{
 			Description: "RANDOM",
 		},
@@ -204,14 +204,14 @@ func TestDiagOrProcGenerator_Random_EmptyFile_NoDate(t *testing.T) {
 		wantTypes []string
 	}{{
 		name: "Random Diagnosis but empty file",
-		g:    NewDiagnosisGenerator(c, data, tclock, dg),
+		g:    NewDiagnosisGenerator(c, data, tclock, dg, nil),
 		input: &pathway.DiagnosisOrProcedure{
 			Description: "RANDOM",
 		},
 		wantTypes: c.Diagnosis.Types,
 	}, {
 		name: "Random Procedure but empty file",
-		g:    NewProcedureGenerator(c, data, tclock, dg),
+		g:    NewProcedureGenerator(c, data, tclock, dg, nil),
 		input: &pathway.DiagnosisOrProcedure{
 			Description: "RANDOM",
 		},
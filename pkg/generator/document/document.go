@@ -24,6 +24,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/generator/text"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 const (
@@ -48,6 +49,9 @@ var chars = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 type Generator struct {
 	DocumentConfig *config.HL7Document
 	TextGenerator  text.Generator
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // Document returns a Document from the given configuration.
@@ -63,7 +67,7 @@ func (g *Generator) Document(eventTime time.Time, d *pathway.Document) *ir.Docum
 	cs := obsCS
 
 	if docType == "" {
-		docType = g.DocumentConfig.Types[rand.Intn(len(g.DocumentConfig.Types))]
+		docType = g.DocumentConfig.Types[random.Or(g.Rand).Intn(len(g.DocumentConfig.Types))]
 	}
 	if status == "" {
 		status = completionStatusDocumented
@@ -87,7 +91,7 @@ func (g *Generator) Document(eventTime time.Time, d *pathway.Document) *ir.Docum
 			Text:         text,
 			CodingSystem: cs,
 		},
-		UniqueDocumentNumber: randomUniqueDocumentNumber(),
+		UniqueDocumentNumber: g.randomUniqueDocumentNumber(),
 		ContentLine:          g.content(d),
 	}
 }
@@ -113,7 +117,7 @@ func (g *Generator) content(d *pathway.Document) []string {
 	if d.NumRandomContentLines == nil {
 		randLen = g.defaultRandomNumContentLines()
 	} else {
-		randLen = d.NumRandomContentLines.Random()
+		randLen = d.NumRandomContentLines.Random(g.Rand)
 	}
 	var contentLine []string
 	if len(d.HeaderContentLines) != 0 {
@@ -128,15 +132,15 @@ func (g *Generator) content(d *pathway.Document) []string {
 	return contentLine
 }
 
-func randomUniqueDocumentNumber() string {
+func (g *Generator) randomUniqueDocumentNumber() string {
 	udn := make([]rune, udnLength)
 	for i := range udn {
-		udn[i] = chars[rand.Intn(len(chars))]
+		udn[i] = chars[random.Or(g.Rand).Intn(len(chars))]
 	}
 	return string(udn)
 }
 
 func (g Generator) defaultRandomNumContentLines() int {
 	i := &pathway.Interval{To: maxLines, From: minLines}
-	return i.Random()
+	return i.Random(g.Rand)
 }
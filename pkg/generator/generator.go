@@ -48,15 +48,18 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/message"
 	"github.com/Arend-melissant/simhospital/pkg/orderprofile"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 	"github.com/Arend-melissant/simhospital/pkg/state"
 )
 
 var log = logging.ForCallerPackage()
 
-type randomIDGenerator struct{}
+type randomIDGenerator struct {
+	rand *rand.Rand
+}
 
 func (g *randomIDGenerator) NewID() string {
-	return fmt.Sprintf("%d", rand.Uint32())
+	return fmt.Sprintf("%d", random.Or(g.rand).Uint32())
 }
 
 // Generator implements functionality to generate various patient related information based on the information provided
@@ -72,6 +75,7 @@ type Generator struct {
 	headerGenerator       *header.Generator
 	orderGenerator        *order.Generator
 	documentGenerator     *document.Generator
+	rand                  *rand.Rand
 }
 
 type diagnosisOrProcedureGenerator interface {
@@ -136,7 +140,7 @@ func (g Generator) NewPatient(person *ir.Person, doctor *ir.Doctor) *state.Patie
 // messageConfig.HospitalService.
 func (g Generator) NewDoctor(c *pathway.Consultant) *ir.Doctor {
 	if c == nil {
-		return g.doctors.GetRandomDoctor()
+		return g.doctors.GetRandomDoctor(g.rand)
 	}
 	if doctor := g.doctors.GetByID(*c.ID); doctor != nil {
 		return doctor
@@ -272,7 +276,7 @@ func (g Generator) SetResults(o *ir.Order, r *pathway.Results, eventTime time.Ti
 
 // NewVisitID generates a new visit identifier.
 func (g Generator) NewVisitID() uint64 {
-	return rand.Uint64()
+	return random.Or(g.rand).Uint64()
 }
 
 // NewHeader returns a new header for the given step.
@@ -306,52 +310,57 @@ type Config struct {
 	Doctors          *doctor.Doctors
 	MsgCtrlGenerator *header.MessageControlGenerator
 	OrderProfiles    *orderprofile.OrderProfiles
+	// Rand is the source of randomness of all the generators that are not explicitly set.
+	// Given the same Rand seed, Clock and configuration, the generators return the same values.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // NewGenerator creates a new Generator.
 func NewGenerator(cfg Config) *Generator {
 	ag := cfg.AddressGenerator
 	if ag == nil {
-		ag = &address.Generator{Nouns: cfg.Data.Nouns, Address: cfg.Data.Address, PostcodeGenerator: &address.UKPostcode{}}
+		ag = &address.Generator{Nouns: cfg.Data.Nouns, Address: cfg.Data.Address, PostcodeGenerator: &address.UKPostcode{Rand: cfg.Rand}, Rand: cfg.Rand}
 	}
 
 	mrnGenerator := cfg.MRNGenerator
 	if mrnGenerator == nil {
-		mrnGenerator = &randomIDGenerator{}
+		mrnGenerator = &randomIDGenerator{rand: cfg.Rand}
 	}
 
 	placerGenerator := cfg.PlacerGenerator
 	if placerGenerator == nil {
-		placerGenerator = &randomIDGenerator{}
+		placerGenerator = &randomIDGenerator{rand: cfg.Rand}
 	}
 
 	fillerGenerator := cfg.FillerGenerator
 	if fillerGenerator == nil {
-		fillerGenerator = &randomIDGenerator{}
+		fillerGenerator = &randomIDGenerator{rand: cfg.Rand}
 	}
 
 	tg := cfg.textGenerator
 	if tg == nil {
-		tg = &text.NounGenerator{Nouns: cfg.Data.Nouns}
+		tg = &text.NounGenerator{Nouns: cfg.Data.Nouns, Rand: cfg.Rand}
 	}
 
 	ng := cfg.NotesGenerator
 	if ng == nil {
-		ng = notes.NewGenerator(cfg.Data, tg)
+		ng = notes.NewGenerator(cfg.Data, tg, cfg.Rand)
 	}
 
 	dg := cfg.DateGenerator
 	if dg == nil {
-		dg = &codedelement.SimpleDateGenerator{}
+		dg = &codedelement.SimpleDateGenerator{Rand: cfg.Rand}
 	}
 
 	personGenerator := &person.Generator{
 		Clock:              cfg.Clock,
-		NameGenerator:      &names.Generator{Data: cfg.Data},
+		NameGenerator:      &names.Generator{Data: cfg.Data, Rand: cfg.Rand},
 		GenderConvertor:    gender.NewConvertor(cfg.HL7Config),
-		EthnicityGenerator: person.NewEthnicityGenerator(cfg.Data),
+		EthnicityGenerator: person.NewEthnicityGenerator(cfg.Data, cfg.Rand),
 		AddressGenerator:   ag,
 		MRNGenerator:       mrnGenerator,
+		Rand:               cfg.Rand,
 	}
 
 	orderGenerator := &order.Generator{
@@ -362,18 +371,20 @@ func NewGenerator(cfg Config) *Generator {
 		FillerGenerator:       fillerGenerator,
 		AbnormalFlagConvertor: order.NewAbnormalFlagConvertor(cfg.HL7Config),
 		Doctors:               cfg.Doctors,
+		Rand:                  cfg.Rand,
 	}
 
 	return &Generator{
 		personGenerator:       personGenerator,
-		patientClassGenerator: newPatientClassAndTypeGenerator(cfg.Data),
+		patientClassGenerator: newPatientClassAndTypeGenerator(cfg.Data, cfg.Rand),
 		messageConfig:         cfg.HL7Config,
 		doctors:               cfg.Doctors,
-		allergyGenerator:      codedelement.NewAllergyGenerator(cfg.HL7Config, cfg.Data, cfg.Clock, dg),
-		diagnosisGenerator:    codedelement.NewDiagnosisGenerator(cfg.HL7Config, cfg.Data, cfg.Clock, dg),
-		procedureGenerator:    codedelement.NewProcedureGenerator(cfg.HL7Config, cfg.Data, cfg.Clock, dg),
+		allergyGenerator:      codedelement.NewAllergyGenerator(cfg.HL7Config, cfg.Data, cfg.Clock, dg, cfg.Rand),
+		diagnosisGenerator:    codedelement.NewDiagnosisGenerator(cfg.HL7Config, cfg.Data, cfg.Clock, dg, cfg.Rand),
+		procedureGenerator:    codedelement.NewProcedureGenerator(cfg.HL7Config, cfg.Data, cfg.Clock, dg, cfg.Rand),
 		headerGenerator:       &header.Generator{Header: cfg.Header, MsgCtrlGen: cfg.MsgCtrlGenerator},
 		orderGenerator:        orderGenerator,
-		documentGenerator:     &document.Generator{DocumentConfig: &cfg.HL7Config.Document, TextGenerator: tg},
+		documentGenerator:     &document.Generator{DocumentConfig: &cfg.HL7Config.Document, TextGenerator: tg, Rand: cfg.Rand},
+		rand:                  cfg.Rand,
 	}
 }
@@ -34,3 +34,18 @@ func (g *MessageControlGenerator) NewMessageControlID() string {
 	g.nextID++
 	return strconv.FormatUint(g.nextID, 10)
 }
+
+// LastID returns the last Message Control ID returned by NewMessageControlID, or 0 if none was returned.
+func (g *MessageControlGenerator) LastID() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.nextID
+}
+
+// SetLastID sets the last Message Control ID returned, so that the next call to NewMessageControlID
+// returns id+1, e.g., to restore a snapshot of a hospital.
+func (g *MessageControlGenerator) SetLastID(id uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nextID = id
+}
@@ -15,7 +15,13 @@
 // Package id provides the functionality to generate identifiers.
 package id
 
-import "github.com/google/uuid"
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/google/uuid"
+)
 
 // Generator is an interface to generate identifiers.
 type Generator interface {
@@ -23,9 +29,20 @@ type Generator interface {
 }
 
 // UUIDGenerator is a wrapper for github.com/google/uuid that implements the Generator interface.
-type UUIDGenerator struct{}
+type UUIDGenerator struct {
+	// Rand is the source of randomness of the UUIDs.
+	// Optional: if nil, the UUIDs are generated from a cryptographically secure source.
+	Rand *rand.Rand
+}
 
 // NewID returns a new random UUID.
-func (*UUIDGenerator) NewID() string {
-	return uuid.New().String()
+func (g *UUIDGenerator) NewID() string {
+	if g.Rand == nil {
+		return uuid.New().String()
+	}
+	// rand.Rand.Read is not safe for concurrent use, so the bytes are taken from Uint64 instead.
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b, g.Rand.Uint64())
+	binary.LittleEndian.PutUint64(b[8:], g.Rand.Uint64())
+	return uuid.Must(uuid.NewRandomFromReader(bytes.NewReader(b))).String()
 }
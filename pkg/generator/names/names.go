@@ -20,20 +20,24 @@ import (
 
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/gender"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // Generator is a generator of names.
 type Generator struct {
 	Data *config.Data
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // Prefix returns a random prefix based on the given gender.
 func (g Generator) Prefix(gen gender.Internal) string {
 	switch gen {
 	case gender.Male:
-		return random(g.Data.PatientName.MalePrefixes)
+		return g.random(g.Data.PatientName.MalePrefixes)
 	case gender.Female:
-		return random(g.Data.PatientName.FemalePrefixes)
+		return g.random(g.Data.PatientName.FemalePrefixes)
 	default:
 		return ""
 	}
@@ -43,9 +47,9 @@ func (g Generator) Prefix(gen gender.Internal) string {
 func (g Generator) FirstName(gen gender.Internal, year int) string {
 	switch gen {
 	case gender.Male:
-		return randomByYear(g.Data.FirstNames.Boys, year)
+		return g.randomByYear(g.Data.FirstNames.Boys, year)
 	case gender.Female:
-		return randomByYear(g.Data.FirstNames.Girls, year)
+		return g.randomByYear(g.Data.FirstNames.Girls, year)
 	default:
 		return ""
 	}
@@ -53,12 +57,12 @@ func (g Generator) FirstName(gen gender.Internal, year int) string {
 
 // MiddleName returns a random middle name based on the given gender.
 func (g Generator) MiddleName(gen gender.Internal) string {
-	if rand.Intn(100) < g.Data.PatientName.MiddlenamePercentage {
+	if random.Or(g.Rand).Intn(100) < g.Data.PatientName.MiddlenamePercentage {
 		switch gen {
 		case gender.Male:
-			return randomName(g.Data.FirstNames.Boys)
+			return g.randomName(g.Data.FirstNames.Boys)
 		case gender.Female:
-			return randomName(g.Data.FirstNames.Girls)
+			return g.randomName(g.Data.FirstNames.Girls)
 		}
 	}
 	return ""
@@ -66,36 +70,36 @@ func (g Generator) MiddleName(gen gender.Internal) string {
 
 // Suffix returns a random suffix.
 func (g Generator) Suffix() string {
-	return randomWithProb(g.Data.PatientName.Suffixes, g.Data.PatientName.SuffixPercentage)
+	return g.randomWithProb(g.Data.PatientName.Suffixes, g.Data.PatientName.SuffixPercentage)
 }
 
 // Degree returns a random degree.
 func (g Generator) Degree() string {
-	return randomWithProb(g.Data.PatientName.Degrees, g.Data.PatientName.DegreePercentage)
+	return g.randomWithProb(g.Data.PatientName.Degrees, g.Data.PatientName.DegreePercentage)
 }
 
 // Surname returns a random surname.
 func (g Generator) Surname() string {
-	return random(g.Data.Surnames)
+	return g.random(g.Data.Surnames)
 }
 
 // randomWithProb returns a random item from the slice with the probability p/100, where p is an int between [0, 100),
 // or an empty string otherwise.
-func randomWithProb(s []string, p int) string {
-	if rand.Intn(100) < p {
-		return random(s)
+func (g Generator) randomWithProb(s []string, p int) string {
+	if random.Or(g.Rand).Intn(100) < p {
+		return g.random(s)
 	}
 	return ""
 }
 
 // random returns a random item from the slice.
-func random(s []string) string {
-	return s[rand.Intn(len(s))]
+func (g Generator) random(s []string) string {
+	return s[random.Or(g.Rand).Intn(len(s))]
 }
 
 // randomByYear returns a random name from the set of Names which were popular among people born in a given year.
 // Every name from the given by-year set is equally probable.
-func randomByYear(n *config.Names, year int) string {
+func (g Generator) randomByYear(n *config.Names, year int) string {
 	if year > n.MaxYear {
 		year = n.MaxYear
 	}
@@ -110,10 +114,10 @@ func randomByYear(n *config.Names, year int) string {
 			break
 		}
 	}
-	return n.ByYear[censusYear][rand.Intn(len(n.ByYear[censusYear]))]
+	return n.ByYear[censusYear][random.Or(g.Rand).Intn(len(n.ByYear[censusYear]))]
 }
 
 // randomName returns a random name. Each name has the same probability to be returned.
-func randomName(n *config.Names) string {
-	return n.All[rand.Intn(len(n.All))]
+func (g Generator) randomName(n *config.Names) string {
+	return n.All[random.Or(g.Rand).Intn(len(n.All))]
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/generator/text"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 const (
@@ -50,15 +51,18 @@ type Generator struct {
 	types         []string
 	textGenerator text.Generator
 	numSentences  int
+	rand          *rand.Rand
 }
 
 // NewGenerator returns a new Generator struct.
-func NewGenerator(d *config.Data, t text.Generator) *Generator {
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func NewGenerator(d *config.Data, t text.Generator, rng *rand.Rand) *Generator {
 	return &Generator{
 		config:        d.NotesConfig,
 		types:         d.ClinicalNoteTypes,
 		textGenerator: t,
 		numSentences:  defaultNumSentences,
+		rand:          rng,
 	}
 }
 
@@ -132,7 +136,7 @@ func (g *Generator) contentAndEncoding(ctx context.Context, contentType string,
 // 0.1 - 2 notes
 // Each note has between 1 - 10 random words.
 func (g *Generator) RandomNotesForResult() []string {
-	switch r := rand.Intn(10); {
+	switch r := random.Or(g.rand).Intn(10); {
 	case r < 4:
 		return nil
 	case r < 9:
@@ -147,7 +151,7 @@ func (g *Generator) note(ctx context.Context, contentType string) ([]byte, error
 	if !ok || len(notes) == 0 {
 		return nil, fmt.Errorf("no sample Notes found for %s ContentType: ContentType not supported", contentType)
 	}
-	clinicalNote := notes[rand.Intn(len(notes))]
+	clinicalNote := notes[random.Or(g.rand).Intn(len(notes))]
 	return files.Read(ctx, clinicalNote.Path)
 }
 
@@ -158,7 +162,7 @@ func (g *Generator) id(currID string) string {
 	}
 	var buffer bytes.Buffer
 	for i := 0; i < 10; i++ {
-		buffer.WriteString(strconv.Itoa(rand.Intn(10)))
+		buffer.WriteString(strconv.Itoa(random.Or(g.rand).Intn(10)))
 	}
 	return fmt.Sprintf("random-%v", buffer.String())
 }
@@ -169,5 +173,5 @@ func (g *Generator) documentType(currType string) string {
 	if currType != "" {
 		return currType
 	}
-	return g.types[rand.Intn(len(g.types))]
+	return g.types[random.Or(g.rand).Intn(len(g.types))]
 }
@@ -18,6 +18,7 @@ package order
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"time"
 
 	cpb "github.com/google/fhir/go/proto/google/fhir/proto/r4/core/codes_go_proto"
@@ -52,6 +53,9 @@ type Generator struct {
 	FillerGenerator       id.Generator
 	AbnormalFlagConvertor AbnormalFlagConvertor
 	Doctors               *doctor.Doctors
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // NewOrder returns a new order based on order information from the pathway and eventTime.
@@ -61,7 +65,7 @@ func (g Generator) NewOrder(o *pathway.Order, eventTime time.Time) *ir.Order {
 		orderStatus = g.MessageConfig.OrderStatus.InProcess
 	}
 	return &ir.Order{
-		OrderProfile:  g.OrderProfiles.Generate(o.OrderProfile),
+		OrderProfile:  g.OrderProfiles.Generate(g.Rand, o.OrderProfile),
 		Placer:        g.PlacerGenerator.NewID(),
 		OrderDateTime: ir.NewValidTime(eventTime),
 		OrderControl:  g.MessageConfig.OrderControl.New,
@@ -92,7 +96,7 @@ func (g Generator) OrderWithClinicalNote(ctx context.Context, order *ir.Order, n
 	if order == nil {
 		order = &ir.Order{
 			ResultsStatus:    g.MessageConfig.DocumentStatus.Authenticated,
-			OrderingProvider: g.Doctors.GetRandomDoctor(),
+			OrderingProvider: g.Doctors.GetRandomDoctor(g.Rand),
 			DiagnosticServID: message.DiagnosticServIDMDOC,
 		}
 	}
@@ -176,13 +180,13 @@ func (g Generator) setOrderDates(o *ir.Order, r *pathway.Results, eventTime time
 		//    - get the difference between order and report time
 		//    - select random delay from it.
 		orderToCollectedDelay := pathway.Delay{From: 0, To: eventTime.Sub(o.OrderDateTime.Time)}
-		o.CollectedDateTime = ir.NewValidTime(o.OrderDateTime.Add(orderToCollectedDelay.Random()))
+		o.CollectedDateTime = ir.NewValidTime(o.OrderDateTime.Add(orderToCollectedDelay.Random(g.Rand)))
 
 		// 2) To calculate received in lab time:
 		//    - get the difference between collected and reported time
 		//    - select random delay from it.
 		collectedToReceivedInLabDelay := pathway.Delay{From: 0, To: eventTime.Sub(o.CollectedDateTime.Time)}
-		o.ReceivedInLabDateTime = ir.NewValidTime(o.CollectedDateTime.Time.Add(collectedToReceivedInLabDelay.Random()))
+		o.ReceivedInLabDateTime = ir.NewValidTime(o.CollectedDateTime.Time.Add(collectedToReceivedInLabDelay.Random(g.Rand)))
 	}
 
 	// Override dates if specified in the pathway.
@@ -376,7 +380,7 @@ func (g Generator) setRandomValueBasedOnCustomReferenceRange(result *ir.Result,
 		// This should never happen if the pathway is valid.
 		return errors.Wrapf(err, "cannot create value generator for reference range %q", pathwayResult.ReferenceRange)
 	}
-	result.Value, _ = vg.Random(rt)
+	result.Value, _ = vg.Random(g.Rand, rt)
 	result.Unit = pathwayResult.Unit
 	result.Range = pathwayResult.ReferenceRange
 	result.AbnormalFlag = g.AbnormalFlagConvertor.ToHL7(constants.FromRandomType(rt))
@@ -388,7 +392,7 @@ func (g Generator) setRandomValueBasedOnOrderProfileReferenceRange(result *ir.Re
 	if err != nil {
 		return errors.Wrap(err, "cannot get random type for result")
 	}
-	v, af, err := tt.RandomisedValueWithFlag(rt)
+	v, af, err := tt.RandomisedValueWithFlag(g.Rand, rt)
 	if err != nil {
 		return errors.Wrap(err, "cannot generate random result with abnormal flag")
 	}
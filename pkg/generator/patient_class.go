@@ -15,6 +15,8 @@
 package generator
 
 import (
+	"math/rand"
+
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/sample"
 )
@@ -23,8 +25,8 @@ type patientClassGenerator struct {
 	*sample.DiscreteDistribution
 }
 
-func newPatientClassAndTypeGenerator(d *config.Data) patientClassGenerator {
-	return patientClassGenerator{DiscreteDistribution: &sample.DiscreteDistribution{WeightedValues: d.PatientClass}}
+func newPatientClassAndTypeGenerator(d *config.Data, rng *rand.Rand) patientClassGenerator {
+	return patientClassGenerator{DiscreteDistribution: &sample.DiscreteDistribution{WeightedValues: d.PatientClass, Rand: rng}}
 }
 
 func (eg patientClassGenerator) Random() *config.PatientClassAndType {
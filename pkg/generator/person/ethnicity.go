@@ -15,6 +15,8 @@
 package person
 
 import (
+	"math/rand"
+
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/sample"
@@ -26,8 +28,9 @@ type EthnicityGenerator struct {
 }
 
 // NewEthnicityGenerator returns new EthnicityGenerator based on data provided.
-func NewEthnicityGenerator(d *config.Data) EthnicityGenerator {
-	return EthnicityGenerator{DiscreteDistribution: &sample.DiscreteDistribution{WeightedValues: d.Ethnicities}}
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func NewEthnicityGenerator(d *config.Data, rng *rand.Rand) EthnicityGenerator {
+	return EthnicityGenerator{DiscreteDistribution: &sample.DiscreteDistribution{WeightedValues: d.Ethnicities, Rand: rng}}
 }
 
 // Random returns a random ethnicity, which can be nil.
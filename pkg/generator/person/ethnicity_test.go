@@ -59,7 +59,7 @@ nil,nil,3
 
 	gotPerKey := map[string]int{}
 
-	eg := NewEthnicityGenerator(dataConfig)
+	eg := NewEthnicityGenerator(dataConfig, nil)
 
 	runs := 1000
 	for i := 0; i < runs; i++ {
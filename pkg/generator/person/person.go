@@ -29,6 +29,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/generator/names"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // AddressGenerator is an interface to generate addresses.
@@ -44,6 +45,9 @@ type Generator struct {
 	EthnicityGenerator EthnicityGenerator
 	AddressGenerator   AddressGenerator
 	MRNGenerator       id.Generator
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // NewPerson returns a new person based on pathway.Person.
@@ -80,9 +84,9 @@ func (g Generator) UpdatePersonFromPathway(person *ir.Person, pathwayPerson *pat
 	case pathwayPerson.DateOfBirth != nil:
 		person.Birth = ir.NewValidTime(*pathwayPerson.DateOfBirth)
 	case pathwayPerson.Age != nil:
-		person.Birth = ir.NewValidTime(pathwayPerson.Age.Birthdate(g.Clock))
+		person.Birth = ir.NewValidTime(pathwayPerson.Age.Birthdate(g.Clock, g.Rand))
 	case !person.Birth.Valid:
-		person.Birth = ir.NewValidTime(pathway.RandomBirthdate(g.Clock))
+		person.Birth = ir.NewValidTime(pathway.RandomBirthdate(g.Clock, g.Rand))
 	}
 
 	// For gender, if there is no gender set in the pathway then we need to randomly generate one
@@ -107,7 +111,7 @@ func (g Generator) UpdatePersonFromPathway(person *ir.Person, pathwayPerson *pat
 	person.Surname = chooseOptionalValue(pathwayPerson.Surname, g.NameGenerator.Surname(),
 		person.Surname)
 	person.Address = g.mergeAddressFromPathway(pathwayPerson.Address, person.Address)
-	person.NHS = chooseValue(pathwayPerson.NHS, newNHSNumber(), person.NHS)
+	person.NHS = chooseValue(pathwayPerson.NHS, g.newNHSNumber(), person.NHS)
 	person.MRN = chooseValueLazy(pathwayPerson.MRN, func() string { return g.MRNGenerator.NewID() }, person.MRN)
 }
 
@@ -190,24 +194,25 @@ func (g Generator) chooseGenderValue(pathwayGender pathway.Gender, originalHL7 s
 	case originalHL7 != "":
 		return originalHL7
 	default:
-		return g.GenderConvertor.InternalToHL7(gender.Random())
+		return g.GenderConvertor.InternalToHL7(gender.Random(g.Rand))
 	}
 }
 
 func (g Generator) phoneNumber() string {
-	if rand.Intn(2) == 0 {
+	r := random.Or(g.Rand)
+	if r.Intn(2) == 0 {
 		// London home phone number
-		return fmt.Sprintf("020 %04d %04d", rand.Intn(10000), rand.Intn(10000))
+		return fmt.Sprintf("020 %04d %04d", r.Intn(10000), r.Intn(10000))
 	}
 	// UK mobile number
-	return fmt.Sprintf("07%d %04d %04d", rand.Intn(10), rand.Intn(10000), rand.Intn(10000))
+	return fmt.Sprintf("07%d %04d %04d", r.Intn(10), r.Intn(10000), r.Intn(10000))
 }
 
 // Return a newly minted NHS number that will pass validation rules. See:
 // http://www.datadictionary.nhs.uk/version2/data_dictionary/data_field_notes/n/nhs_number_de.asp?shownav=0
-func newNHSNumber() string {
+func (g Generator) newNHSNumber() string {
 	for {
-		n := rand.Intn(1000000000) * 10
+		n := random.Or(g.Rand).Intn(1000000000) * 10
 		a := n / 10
 		check := 0
 		for i := 0; i < 9; i++ {
@@ -633,7 +633,7 @@ patient_name:
 		Clock:              testclock.New(date),
 		NameGenerator:      &names.Generator{Data: dataCFG},
 		GenderConvertor:    gender.NewConvertor(hl7Config),
-		EthnicityGenerator: NewEthnicityGenerator(dataCFG),
+		EthnicityGenerator: NewEthnicityGenerator(dataCFG, nil),
 		AddressGenerator: &fakeAddressGenerator{
 			want: defaultAddress,
 		},
@@ -657,7 +657,7 @@ func testGenerator(ctx context.Context, t *testing.T, date time.Time) (*Generato
 		Clock:              testclock.New(date),
 		NameGenerator:      &names.Generator{Data: dataCFG},
 		GenderConvertor:    gender.NewConvertor(hl7Config),
-		EthnicityGenerator: NewEthnicityGenerator(dataCFG),
+		EthnicityGenerator: NewEthnicityGenerator(dataCFG, nil),
 		AddressGenerator:   &fakeAddressGenerator{},
 		MRNGenerator:       &testid.Generator{},
 	}, hl7Config, dataCFG
@@ -682,7 +682,7 @@ func testGeneratorWithCustomHL7Gender(ctx context.Context, t *testing.T, date ti
 		Clock:              testclock.New(date),
 		NameGenerator:      &names.Generator{Data: dataCFG},
 		GenderConvertor:    gender.NewConvertor(hl7Config),
-		EthnicityGenerator: NewEthnicityGenerator(dataCFG),
+		EthnicityGenerator: NewEthnicityGenerator(dataCFG, nil),
 		AddressGenerator:   &fakeAddressGenerator{},
 		MRNGenerator:       &testid.Generator{},
 	}, hl7Config, dataCFG
@@ -18,6 +18,8 @@ package text
 import (
 	"math/rand"
 	"strings"
+
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // Generator is a generator of text.
@@ -32,13 +34,16 @@ const maxWords = 10
 // NounGenerator generates text by concatenating nouns.
 type NounGenerator struct {
 	Nouns []string
+	// Rand is the source of randomness.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 // randomSentence returns a random sentence consisting of between [1, max] random nouns,
 // separated by an empty space.
 // The first word starts with a capital letter.
 func (g *NounGenerator) randomSentence(max int) string {
-	r := rand.Intn(max)
+	r := random.Or(g.Rand).Intn(max)
 	w := make([]string, 0)
 	for i := 0; i <= r; i++ {
 		w = append(w, g.randomNoun())
@@ -48,7 +53,7 @@ func (g *NounGenerator) randomSentence(max int) string {
 }
 
 func (g *NounGenerator) randomNoun() string {
-	return g.Nouns[rand.Intn(len(g.Nouns))]
+	return g.Nouns[random.Or(g.Rand).Intn(len(g.Nouns))]
 }
 
 // Sentences returns an array of n random sentences.
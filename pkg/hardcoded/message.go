@@ -26,6 +26,7 @@ import (
 	"math/rand"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/hl7"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 	"github.com/Arend-melissant/simhospital/pkg/message"
 )
 
@@ -54,14 +56,27 @@ type Manager struct {
 	messages map[string]string
 	// generator produces unique message IDs.
 	generator *header.MessageControlGenerator
+	// rand is the source of randomness used to pick messages.
+	rand *rand.Rand
 }
 
+// hardcodedMessage is a message in a hardcoded messages file.
 type hardcodedMessage struct {
+	// Segments are the segments of the message, one per item.
+	// The segment PID_SEGMENT_PLACEHOLDER is replaced with the PID segment of the patient.
 	Segments []string
 }
 
+// FileFormat returns an empty value of the type that hardcoded messages files are unmarshalled into.
+// It is used to generate the JSON Schema of the files.
+func FileFormat() interface{} {
+	return map[string]hardcodedMessage{}
+}
+
 // NewManager returns a Manager for the messages contained in the given directory.
-func NewManager(ctx context.Context, messageDir string, headerGenerator *header.MessageControlGenerator) (*Manager, error) {
+// rng is the source of randomness used to pick messages; if nil, the default source of the math/rand
+// package is used.
+func NewManager(ctx context.Context, messageDir string, headerGenerator *header.MessageControlGenerator, rng *rand.Rand) (*Manager, error) {
 	files, err := files.List(ctx, messageDir)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot read hardcoded messages directory: %s", messageDir)
@@ -99,6 +114,7 @@ func NewManager(ctx context.Context, messageDir string, headerGenerator *header.
 	return &Manager{
 		messages:  messages,
 		generator: headerGenerator,
+		rand:      rng,
 	}, nil
 }
 
@@ -133,7 +149,7 @@ func (m Manager) Message(toIncludeRegex string, p *ir.Person, t time.Time) (*mes
 	}
 	log.Debugf("Selected %d hardcoded messages based on the regex %q: %v", len(filtered), toIncludeRegex, filtered)
 
-	name := filtered[rand.Intn(len(filtered))]
+	name := filtered[random.Or(m.rand).Intn(len(filtered))]
 	log.Infof("Hardcoded message with name %s chosen at random", name)
 
 	msg := m.messages[name]
@@ -173,6 +189,8 @@ func (m Manager) filterMessages(toIncludeRegex string) []string {
 			}
 		}
 	}
+	// Sorted so that the message picked only depends on the source of randomness.
+	sort.Strings(filtered)
 
 	return filtered
 }
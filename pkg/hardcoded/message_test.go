@@ -117,7 +117,7 @@ func TestNewManager(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			dir := writeYmlToFile(t, tc.yml)
 			mcg := &header.MessageControlGenerator{}
-			if _, err := NewManager(ctx, dir, mcg); (err != nil) != tc.wantErr {
+			if _, err := NewManager(ctx, dir, mcg, nil); (err != nil) != tc.wantErr {
 				t.Errorf("NewManager(%q, %v) got err %v, want err? %t", tc.yml, mcg, err, tc.wantErr)
 			}
 		})
@@ -146,7 +146,7 @@ func TestFileExtensionValidation(t *testing.T) {
 			dir := testwrite.BytesToDir(t, []byte(nhsYML), tc.name)
 
 			mcg := &header.MessageControlGenerator{}
-			mgr, err := NewManager(ctx, dir, mcg)
+			mgr, err := NewManager(ctx, dir, mcg, nil)
 
 			// If a filename is valid, we expect it to be parsed into messages.
 			// Otherwise, we expect an error because no files were parsed.
@@ -219,7 +219,7 @@ func TestMessage(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			dir := writeYmlToFile(t, tc.yml)
 			mcg := &header.MessageControlGenerator{}
-			mgr, err := NewManager(ctx, dir, mcg)
+			mgr, err := NewManager(ctx, dir, mcg, nil)
 			if err != nil {
 				t.Fatalf("NewManager(%s, %v) failed with %v", tc.yml, mcg, err)
 			}
@@ -239,7 +239,7 @@ func TestMessageReturnsUniqueMessages(t *testing.T) {
 	ctx := context.Background()
 	dir := writeYmlToFile(t, missingPIDYml)
 	mcg := &header.MessageControlGenerator{}
-	mgr, err := NewManager(ctx, dir, mcg)
+	mgr, err := NewManager(ctx, dir, mcg, nil)
 	if err != nil {
 		t.Fatalf("NewManager(%s, %v) failed with %v", missingPIDYml, mcg, err)
 	}
@@ -294,7 +294,7 @@ func TestMessageErrors(t *testing.T) {
 		t.Run(fmt.Sprintf("NewManager(%q)", tc.description), func(t *testing.T) {
 			dir := writeYmlToFile(t, tc.yml)
 			mcg := &header.MessageControlGenerator{}
-			mgr, err := NewManager(ctx, dir, mcg)
+			mgr, err := NewManager(ctx, dir, mcg, nil)
 			if err != nil {
 				t.Fatalf("NewManager(%s, %v) failed with %v", tc.yml, mcg, err)
 			}
@@ -24,7 +24,7 @@ import (
 
 func TestValidateProdConfig(t *testing.T) {
 	ctx := context.Background()
-	_, err := NewManager(ctx, test.HardcodedMessagesDirProd, &header.MessageControlGenerator{})
+	_, err := NewManager(ctx, test.HardcodedMessagesDirProd, &header.MessageControlGenerator{}, nil)
 	if err != nil {
 		t.Fatalf("NewManager(path=%s) failed with %v", test.HardcodedMessagesDirProd, err)
 	}
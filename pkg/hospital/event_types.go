@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -50,13 +51,17 @@ func (h *Hospital) processAdmission(e *state.Event, logLocal *logging.SimulatedH
 		patientInfo.Location = patientInfo.PendingLocation
 		logLocal.Debugf("Entered reserved bed %s", patientInfo.PendingLocation)
 	} else {
-		patientInfo.AdmissionDate = ir.NewValidTime(e.EventTime)
 		loc, err := h.occupyBed(e.Step.Admission.Loc, e.Step.Admission.Bed)
+		if errors.Is(err, location.ErrNoBedAvailable) && h.fullWardPolicy != FullWardFail {
+			return h.waitForBed(e, logLocal, patientInfo)
+		}
 		if err != nil {
 			return errors.Wrap(err, locationError)
 		}
+		patientInfo.AdmissionDate = ir.NewValidTime(e.EventTime)
 		patientInfo.Location = loc
 	}
+	h.stopWaitingForBed(e, logLocal)
 
 	if ec := patientInfo.LatestEncounter(); ec != nil && ec.IsPending {
 		ec.UpdateStatus(patientInfo.AdmissionDate, constants.EncounterStatusArrived)
@@ -82,6 +87,56 @@ func (h *Hospital) processAdmission(e *state.Event, logLocal *logging.SimulatedH
 	return h.queueMessage(logLocal, msg, e)
 }
 
+// waitForBed makes the given Admission event wait for a bed, as there are none available in its location.
+// The event is queued again once it finishes running, and it is resumed when a bed is freed.
+// The first time the event starts waiting, the patient boards in the ED if they aren't in any other
+// location, and if the policy is FullWardPending, a pending admission (ADT^A14) message is sent.
+func (h *Hospital) waitForBed(e *state.Event, logLocal *logging.SimulatedHospitalLogger, patientInfo *ir.PatientInfo) error {
+	loc := e.Step.Admission.Loc
+	if e.WaitingForBed != "" {
+		logLocal.Infof("Still no bed available in %s, admission keeps waiting", loc)
+		return nil
+	}
+	e.WaitingForBed = loc
+	e.WaitingSince = e.EventTime
+	counters.SimulatedHospital.WaitingForBed.With(prometheus.Labels{"poc": loc}).Inc()
+	logLocal.Infof("No bed available in %s, admission waiting for a bed", loc)
+	if patientInfo.Location == nil {
+		patientInfo.Location = h.locationManager.GetAAndELocation()
+		logLocal.Info("Patient boarding in the ED while waiting for a bed")
+	}
+	if h.fullWardPolicy != FullWardPending {
+		return nil
+	}
+
+	pendingLocation, err := h.locationManager.Location(loc)
+	if err != nil {
+		return errors.Wrap(err, locationError)
+	}
+	patientInfo.AccountStatus = h.messageConfig.PatientAccountStatus.Planned
+	patientInfo.PendingLocation = pendingLocation
+	ec := patientInfo.AddEncounter(ir.NewValidTime(e.EventTime), constants.EncounterStatusPlanned, nil)
+	ec.IsPending = true
+
+	msg, err := message.BuildPendingAdmissionADTA14(h.generator.NewHeader(&e.Step), patientInfo, e.EventTime, e.MessageTime)
+	if err != nil {
+		return errors.Wrap(err, "cannot build ADT^A14 message")
+	}
+	return h.queueMessage(logLocal, msg, e)
+}
+
+// stopWaitingForBed marks the given Admission event as no longer waiting for a bed, if it was.
+func (h *Hospital) stopWaitingForBed(e *state.Event, logLocal *logging.SimulatedHospitalLogger) {
+	if e.WaitingForBed == "" {
+		return
+	}
+	labels := prometheus.Labels{"poc": e.WaitingForBed}
+	counters.SimulatedHospital.WaitingForBed.With(labels).Dec()
+	counters.SimulatedHospital.BedWaitMinutes.With(labels).Observe(e.EventTime.Sub(e.WaitingSince).Minutes())
+	logLocal.Infof("Bed available after waiting for %v", e.EventTime.Sub(e.WaitingSince))
+	e.WaitingForBed = ""
+}
+
 func (h *Hospital) processOrder(e *state.Event, logLocal *logging.SimulatedHospitalLogger, now time.Time) error {
 	msgHeader := h.generator.NewHeader(&e.Step)
 	patient := h.patients.Get(e.PatientMRN)
@@ -112,7 +167,7 @@ func (h *Hospital) processOrder(e *state.Event, logLocal *logging.SimulatedHospi
 	}
 	msgHeader = h.generator.NewHeader(&e.Step)
 	o.OrderControl = h.messageConfig.OrderControl.OK
-	delay := h.orderAckDelay.Random()
+	delay := h.orderAckDelay.Random(h.rand)
 	orderAckMessageTime := e.MessageTime.Add(delay)
 	msg, err = message.BuildPathologyORRO02(msgHeader, patientInfo, o, orderAckMessageTime)
 	if err != nil {
@@ -576,6 +631,45 @@ func (h *Hospital) addPerson(e *state.Event, logLocal *logging.SimulatedHospital
 	return h.queueMessage(logLocal, msg, e)
 }
 
+func (h *Hospital) birth(e *state.Event, logLocal *logging.SimulatedHospitalLogger, now time.Time) error {
+	mother := h.patients.Get(e.PatientMRN).PatientInfo
+	person := e.Step.Birth.Person
+	if person == nil {
+		person = &pathway.Person{}
+	}
+	newborn := h.generator.NewPerson(person)
+	newborn.Birth = ir.NewValidTime(e.EventTime)
+	if !person.Surname.IsSet() {
+		newborn.Surname = mother.Person.Surname
+	}
+	if person.Address == nil && mother.Person.Address != nil {
+		address := *mother.Person.Address
+		newborn.Address = &address
+	}
+	baby := h.generator.NewPatient(newborn, mother.AttendingDoctor)
+	addAssociatedParty(baby.PatientInfo, mother.Person, &ir.CodedElement{ID: h.messageConfig.Relationship.Mother})
+	addAssociatedParty(mother, newborn, &ir.CodedElement{ID: h.messageConfig.Relationship.Child})
+	h.patients.Put(baby)
+
+	// Subsequent events can refer to the newborn by its ID, e.g., in UsePatient steps.
+	// All the events of the pathway share the same map.
+	if e.PatientIDs == nil {
+		e.PatientIDs = map[pathway.PatientID]string{}
+	}
+	e.PatientIDs[e.Step.Birth.Baby] = newborn.MRN
+	*logLocal = *logLocal.WithField("newborn_mrn", newborn.MRN)
+	logLocal.Infof("Newborn %v: %s %s %s", e.Step.Birth.Baby, newborn.FirstName, newborn.Surname, newborn.MRN)
+
+	msgHeader := h.generator.NewHeader(&e.Step)
+	msg, err := message.BuildAddPersonADTA28(msgHeader, baby.PatientInfo, e.EventTime, e.MessageTime)
+	if err != nil {
+		return errors.Wrap(err, "cannot build ADT^A28 message")
+	}
+	babyEvent := *e
+	babyEvent.PatientMRN = newborn.MRN
+	return h.queueMessage(logLocal, msg, &babyEvent)
+}
+
 func (h *Hospital) updatePerson(e *state.Event, logLocal *logging.SimulatedHospitalLogger, now time.Time) error {
 	msgHeader := h.generator.NewHeader(&e.Step)
 	patientInfo := h.patients.Get(e.PatientMRN).PatientInfo
@@ -786,9 +880,24 @@ func (h *Hospital) hardcodedMessage(e *state.Event, logLocal *logging.SimulatedH
 func (h *Hospital) generateResources(e *state.Event, logLocal *logging.SimulatedHospitalLogger) error {
 	patientInfo := h.patients.Get(e.PatientMRN).PatientInfo
 	logLocal.Info("Generating resources")
+	h.resourceMu.Lock()
+	defer h.resourceMu.Unlock()
 	return h.resourceWriter.Generate(patientInfo)
 }
 
+// waitForEvent processes WaitForEvent events. These events only run when the external event they are
+// waiting for arrives, or when the timeout elapses. In the latter case, the rest of the pathway is replaced
+// with the steps to run on timeout.
+func (h *Hospital) waitForEvent(e *state.Event, logLocal *logging.SimulatedHospitalLogger) error {
+	if e.Triggered {
+		logLocal.Infof("External event %q arrived", e.Step.WaitForEvent.Event)
+		return nil
+	}
+	logLocal.Infof("Timed out waiting for external event %q", e.Step.WaitForEvent.Event)
+	e.Pathway = e.Step.WaitForEvent.OnTimeout
+	return nil
+}
+
 // processEventType processes the given event type.
 // Most events create HL7 messages that are added to the message queue.
 func (h *Hospital) processEventType(ctx context.Context, e *state.Event, logLocal *logging.SimulatedHospitalLogger, now time.Time) error {
@@ -849,6 +958,8 @@ func (h *Hospital) processEventType(ctx context.Context, e *state.Event, logLoca
 		return h.addPerson(e, logLocal, now)
 	case pathway.StepUpdatePerson:
 		return h.updatePerson(e, logLocal, now)
+	case pathway.StepBirth:
+		return h.birth(e, logLocal, now)
 	case pathway.StepCancelPendingAdmission:
 		return h.cancelPendingAdmission(e, logLocal, now)
 	case pathway.StepCancelPendingTransfer:
@@ -871,6 +982,11 @@ func (h *Hospital) processEventType(ctx context.Context, e *state.Event, logLoca
 		return errors.New("missing_processor_of_generic_event")
 	case pathway.StepGenerateResources:
 		return h.generateResources(e, logLocal)
+	case pathway.StepWaitForEvent:
+		return h.waitForEvent(e, logLocal)
+	case pathway.StepParallel:
+		// no-op as Parallel events don't have messages. The tracks are queued when the event runs, and
+		// the join event only runs once all of them have finished.
 	default:
 		return fmt.Errorf("unknown_event_type_%s", e.Step.StepType())
 	}
@@ -908,7 +1024,9 @@ func (h *Hospital) freeSpecificLocation(logLocal *logging.SimulatedHospitalLogge
 			"reason":       err.Error(),
 		}).Inc()
 		logLocal.WithError(err).Warning("cannot free patient bed")
+		return
 	}
+	atomic.AddInt64(&h.bedsFreed, 1)
 }
 
 // resetPatient clears a patient's state. This is usually needed after a discharge or a cancel
@@ -16,9 +16,14 @@ package hospital
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/Arend-melissant/simhospital/pkg/hl7"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
@@ -31,46 +36,84 @@ func (h *Hospital) HasEvents() bool {
 	return !h.eventQ.Empty()
 }
 
-// runNextEvent consumes the next event from the Events queue and runs it.
-// runNextEvent returns an error if the queue is empty or there was any problem running the event.
-func (h *Hospital) runNextEvent(ctx context.Context) error {
-	if !h.HasEvents() {
-		return errors.New("runNextEvent() was invoked on an empty Event queue")
+// NextEventTime returns the time at which the next event in the Event queue is due.
+// It returns false if the Event queue is empty.
+func (h *Hospital) NextEventTime() (time.Time, bool) {
+	i := h.eventQ.Peek()
+	if i == nil {
+		return time.Time{}, false
 	}
-	// The item retrieved by pq.Get() might not be exactly the same one as the pq.Peek() above (as the items are
-	// submitted to the queue concurrently by another thread). This is OK, because we want to process the item
-	// which due date is the earliest first.
+	e, ok := i.(state.Event)
+	if !ok {
+		log.Fatalf("Unknown item type %v, want state.Event", i)
+	}
+	return e.EventTime, true
+}
+
+// DueEvent is an event that has been removed from the Event queue because it is due, and that must be
+// run with RunEvent.
+type DueEvent struct {
+	state.Event
+	// consistentBefore is whether the Event queue was consistent before the event was removed from it.
+	consistentBefore bool
+}
+
+// NextDueEvent removes the next event from the Event queue and returns it, if it is due.
+// It returns nil if the queue is empty or the next event is not due yet.
+// Snapshots are not taken or restored between removing an event and running it, so the event
+// returned must always be run with RunEvent, which can happen in another goroutine.
+// Events can run concurrently with each other, as long as the events of the same patients run one
+// after the other, in the order in which NextDueEvent returned them. See DueEvent.Patients.
+func (h *Hospital) NextDueEvent() (*DueEvent, error) {
+	h.snapshotMu.RLock()
 	consistentBefore := h.eventQ.IsConsistent()
-	i, err := h.eventQ.Get()
+	i, err := h.eventQ.GetIf(func(i state.MarshallableQueueItem) bool {
+		e, ok := i.(state.Event)
+		if !ok {
+			log.Fatalf("Unknown item type %v, want state.Event", i)
+		}
+		return h.isTimeDue(e.EventTime)
+	})
 	if err != nil {
+		h.snapshotMu.RUnlock()
 		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
 			"pathway_name": unknown,
 			"reason":       "event_queue_get",
 		}).Inc()
-		return errors.Wrap(err, "failed to get event from queue")
+		return nil, errors.Wrap(err, "failed to get event from queue")
 	}
-	item := *i
-	event, ok := item.(state.Event)
-	if !ok {
-		log.WithError(err).Errorf("Unknown item consumed from the event queue: %v", item)
-		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
-			"pathway_name": unknown,
-			"reason":       "event_cast",
-		}).Inc()
-		// An item of the wrong type will never be able to be processed. The item has been
-		// removed from the queue already, so there's no point in signaling this further.
-		return nil
+	if i == nil {
+		h.snapshotMu.RUnlock()
+		return nil, nil
 	}
+	return &DueEvent{Event: i.(state.Event), consistentBefore: consistentBefore}, nil
+}
 
-	h.runEvent(ctx, event)
+// Patients returns the MRNs of the patients that the event refers to, sorted. Two events can only run
+// concurrently if they don't have any patients in common.
+func (e *DueEvent) Patients() []string {
+	seen := map[string]bool{e.PatientMRN: true}
+	mrns := []string{e.PatientMRN}
+	for _, mrn := range e.PatientIDs {
+		if !seen[mrn] {
+			seen[mrn] = true
+			mrns = append(mrns, mrn)
+		}
+	}
+	sort.Strings(mrns)
+	return mrns
+}
 
-	if consistentAfter := h.eventQ.IsConsistent(); !consistentAfter && consistentBefore {
+// RunEvent runs the given event, which was returned by NextDueEvent.
+func (h *Hospital) RunEvent(ctx context.Context, e *DueEvent) {
+	defer h.snapshotMu.RUnlock()
+	h.runEvent(ctx, e.Event)
+	if consistentAfter := h.eventQ.IsConsistent(); !consistentAfter && e.consistentBefore {
 		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
-			"pathway_name": event.PathwayName,
+			"pathway_name": e.PathwayName,
 			"reason":       inconsistentQueueError,
 		}).Inc()
 	}
-	return nil
 }
 
 func (h *Hospital) queueFirstEvent(p pathway.Pathway, patientIDs map[pathway.PatientID]string, patients ...*state.Patient) error {
@@ -90,7 +133,7 @@ func (h *Hospital) queueFirstEvent(p pathway.Pathway, patientIDs map[pathway.Pat
 
 	now := h.clock.Now()
 
-	eventTime, msgTime := calculateTimes(now, first.Parameters)
+	eventTime, msgTime := h.calculateTimes(now, first)
 
 	consistentBefore := h.eventQ.IsConsistent()
 	event := state.Event{
@@ -150,9 +193,12 @@ func (h *Hospital) runEvent(ctx context.Context, e state.Event) {
 	}
 
 	if e.Step.StepType() == pathway.StepDelay {
-		now = now.Add(e.Step.Delay.Random())
+		now = now.Add(e.Step.Delay.Random(h.rand))
 	}
 
+	// Admissions waiting for a bed can be resumed if this event frees any beds, even if it fails halfway.
+	defer h.resumeWaitingAdmissions(now)
+
 	if _, err := h.runEventProcessors(logLocal, &e, patientInfo, h.processors.EventPre); err != nil {
 		logLocal.WithError(err).Error("event pre processing failed")
 		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
@@ -199,10 +245,22 @@ func (h *Hospital) runEvent(ctx context.Context, e state.Event) {
 	// Event processing might have changed the patient's MRN.
 	mrn = e.PatientMRN
 
+	if e.WaitingForBed != "" {
+		// The rest of the pathway is queued once the admission gets a bed.
+		h.queueWaitingAdmission(logLocal, e)
+		return
+	}
+
+	if e.Step.StepType() == pathway.StepParallel && e.JoinFor == "" {
+		// The rest of the pathway is queued by the join event once all the tracks have finished.
+		h.queueParallelTracks(logLocal, e, now)
+		return
+	}
+
 	// Queue the next event, if any.
 	first, history, pathwaySteps := getNextEvents(e.History, e.Pathway)
 	if first != nil {
-		eventTime, msgTime := calculateTimes(now, first.Parameters)
+		eventTime, msgTime := h.calculateTimes(now, first)
 
 		logLocal = logLocal.
 			WithField(keyNextEventType, first.StepType()).
@@ -224,6 +282,7 @@ func (h *Hospital) runEvent(ctx context.Context, e state.Event) {
 			IsHistorical:   len(e.History) > 0,
 			Index:          e.Index + 1,
 			PatientIDs:     e.PatientIDs,
+			Tracks:         e.Tracks,
 		}
 		if err := h.eventQ.Put(event); err != nil {
 			logLocal.WithError(err).Error("Failed to put the next event on the priority queue")
@@ -238,12 +297,16 @@ func (h *Hospital) runEvent(ctx context.Context, e state.Event) {
 				"reason":       inconsistentQueueError,
 			}).Inc()
 		}
+	} else if len(e.Tracks) > 0 {
+		// Only the parallel track has finished; the pathway continues with the join event.
+		h.finishParallelTrack(logLocal, e, now)
 	} else {
 		// The pathway has finished!
 		// We assume the pathway steps are sorted in chronological order, so the time of the last event is
 		// the time the pathway finishes.
 		logLocal.Info("Pathway finished!")
 		h.patients.Delete(mrn)
+		h.returningPatients.add(mrn)
 		if len(e.Pathway) == 0 && !e.IsHistorical {
 			// The last step is a Pathway step, as opposed to a historical step.
 			// Note we don't export the metric if the pathway has historical steps only, as there's no
@@ -257,6 +320,221 @@ func (h *Hospital) runEvent(ctx context.Context, e state.Event) {
 	}
 }
 
+// queueParallelTracks queues the first event of every track of the given Parallel event, plus the join
+// event that carries the rest of the pathway. The join event only becomes due once all the tracks have finished.
+func (h *Hospital) queueParallelTracks(logLocal *logging.SimulatedHospitalLogger, e state.Event, now time.Time) {
+	forkID, err := e.ID()
+	if err != nil {
+		logLocal.WithError(err).Error("Cannot get the ID of the parallel event")
+		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
+			"pathway_name": e.PathwayName,
+			"reason":       "parallel_event_id",
+		}).Inc()
+		return
+	}
+	tracks := e.Step.Parallel.Tracks
+	events := []state.Event{{
+		EventTime:      joinPendingTime,
+		MessageTime:    joinPendingTime,
+		PathwayName:    e.PathwayName,
+		PatientMRN:     e.PatientMRN,
+		Step:           e.Step,
+		Pathway:        e.Pathway,
+		History:        e.History,
+		PathwayStarted: e.PathwayStarted,
+		Index:          e.Index,
+		PatientIDs:     e.PatientIDs,
+		Tracks:         e.Tracks,
+		JoinFor:        forkID,
+		PendingTracks:  len(tracks),
+	}}
+	for i, t := range tracks {
+		first, _, steps := getNextEvents(nil, t.Steps)
+		eventTime, msgTime := h.calculateTimes(now, first)
+		logLocal.WithField(keyNextEventType, first.StepType()).
+			WithField(keyExpectedNextEventTime, eventTime.UTC().Format(datetimeLayout)).
+			Infof("Queuing first event of parallel track %d %q", i, t.Name)
+		events = append(events, state.Event{
+			EventTime:      eventTime,
+			MessageTime:    msgTime,
+			PathwayName:    e.PathwayName,
+			PatientMRN:     e.PatientMRN,
+			Step:           *first,
+			Pathway:        steps,
+			PathwayStarted: e.PathwayStarted,
+			Index:          e.Index + 1,
+			PatientIDs:     e.PatientIDs,
+			Tracks:         append(append([]state.ParallelTrack{}, e.Tracks...), state.ParallelTrack{ForkID: forkID, Index: i}),
+		})
+	}
+
+	consistentBefore := h.eventQ.IsConsistent()
+	for _, event := range events {
+		if err := h.eventQ.Put(event); err != nil {
+			logLocal.WithError(err).Error("Failed to put the parallel track event on the priority queue")
+			counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
+				"pathway_name": e.PathwayName,
+				"reason":       "Failed to put the parallel track event on the priority queue",
+			}).Inc()
+		}
+	}
+	if consistentAfter := h.eventQ.IsConsistent(); !consistentAfter && consistentBefore {
+		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
+			"pathway_name": e.PathwayName,
+			"reason":       inconsistentQueueError,
+		}).Inc()
+	}
+}
+
+// finishParallelTrack notifies the join event of the innermost track the given event runs in that the track
+// has finished. When all the tracks have finished, the join event becomes due at the given time.
+func (h *Hospital) finishParallelTrack(logLocal *logging.SimulatedHospitalLogger, e state.Event, now time.Time) {
+	track := e.Tracks[len(e.Tracks)-1]
+	i, err := h.eventQ.Remove(func(i state.MarshallableQueueItem) bool {
+		join, ok := i.(state.Event)
+		return ok && join.JoinFor == track.ForkID
+	})
+	if err != nil || i == nil {
+		logLocal.WithError(err).Errorf("Cannot find the join event for parallel track %d", track.Index)
+		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
+			"pathway_name": e.PathwayName,
+			"reason":       "parallel_join_not_found",
+		}).Inc()
+		return
+	}
+	join := i.(state.Event)
+	join.PendingTracks--
+	logLocal.Infof("Parallel track %d finished, %d track(s) pending", track.Index, join.PendingTracks)
+	if join.PendingTracks <= 0 {
+		setDue(&join, now)
+	}
+	if err := h.eventQ.Put(join); err != nil {
+		logLocal.WithError(err).Error("Failed to put the join event on the priority queue")
+		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
+			"pathway_name": e.PathwayName,
+			"reason":       "Failed to put the join event on the priority queue",
+		}).Inc()
+	}
+}
+
+// queueWaitingAdmission queues the given Admission event, which is waiting for a bed. The event only becomes
+// due once a bed is freed in its location, or in any of its overflow locations.
+func (h *Hospital) queueWaitingAdmission(logLocal *logging.SimulatedHospitalLogger, e state.Event) {
+	e.EventTime = joinPendingTime
+	e.MessageTime = joinPendingTime
+	if err := h.eventQ.Put(e); err != nil {
+		logLocal.WithError(err).Error("Failed to put the waiting admission on the priority queue")
+		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
+			"pathway_name": e.PathwayName,
+			"reason":       "Failed to put the waiting admission on the priority queue",
+		}).Inc()
+	}
+}
+
+// resumeWaitingAdmissions makes one waiting admission due at the given time for every bed freed since
+// admissions were last resumed. Admissions are resumed in the order they started waiting.
+func (h *Hospital) resumeWaitingAdmissions(now time.Time) {
+	h.waitingMu.Lock()
+	defer h.waitingMu.Unlock()
+	for n := atomic.SwapInt64(&h.bedsFreed, 0); n > 0; n-- {
+		var first *state.Event
+		for _, i := range h.eventQ.Find(func(i state.MarshallableQueueItem) bool {
+			e, ok := i.(state.Event)
+			return ok && e.WaitingForBed != "" && !e.Paused && e.EventTime.Equal(joinPendingTime) && h.locationManager.HasAvailableBed(e.WaitingForBed)
+		}) {
+			e := i.(state.Event)
+			if first == nil || e.WaitingSince.Before(first.WaitingSince) {
+				first = &e
+			}
+		}
+		if first == nil {
+			return
+		}
+		id, err := first.ID()
+		if err != nil {
+			log.WithError(err).Error("Cannot get the ID of the waiting admission")
+			return
+		}
+		i, err := h.eventQ.Remove(func(i state.MarshallableQueueItem) bool {
+			e, ok := i.(state.Event)
+			if !ok {
+				return false
+			}
+			eID, err := e.ID()
+			return err == nil && eID == id
+		})
+		if err != nil || i == nil {
+			log.WithError(err).Error("Cannot remove the waiting admission from the queue")
+			return
+		}
+		e := i.(state.Event)
+		e.EventTime = now
+		e.MessageTime = now
+		log.WithField(keyPathwayName, e.PathwayName).
+			WithField(keyPatientID, e.PatientMRN).
+			Infof("Bed freed, resuming admission waiting for a bed in %s", e.WaitingForBed)
+		if err := h.eventQ.Put(e); err != nil {
+			log.WithError(err).Error("Failed to put the resumed admission on the priority queue")
+			return
+		}
+	}
+}
+
+// TriggerEvent notifies the hospital that the external event with the given name has arrived for the
+// patient with the given MRN. The pathway of that patient that is waiting for such event in a WaitForEvent
+// step resumes immediately, instead of waiting for the step's timeout to elapse. If several pathways are
+// waiting for the same event, the one with the earliest timeout resumes.
+// TriggerEvent returns an error if no pathway of the patient is waiting for the event.
+func (h *Hospital) TriggerEvent(mrn string, eventName string) error {
+	h.snapshotMu.RLock()
+	defer h.snapshotMu.RUnlock()
+	i, err := h.eventQ.Remove(func(i state.MarshallableQueueItem) bool {
+		e, ok := i.(state.Event)
+		return ok && e.IsWaitingFor(mrn, eventName)
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot remove waiting event from the queue")
+	}
+	if i == nil {
+		return fmt.Errorf("no pathway is waiting for event %q for patient %s", eventName, mrn)
+	}
+	e := i.(state.Event)
+	e.Triggered = true
+	setDue(&e, h.clock.Now())
+
+	log.WithField(keyPathwayName, e.PathwayName).
+		WithField(keyPatientID, mrn).
+		Infof("External event %q received, resuming pathway", eventName)
+	return h.eventQ.Put(e)
+}
+
+// TriggerEventFromMessage triggers the external event represented by the given inbound HL7 message.
+// The name of the event is the message type and trigger event of the message, e.g., "ORU^R01", and the
+// patient is identified by the first identifier in the PID-3 Patient Identifier List field.
+// See TriggerEvent for more details.
+func (h *Hospital) TriggerEventFromMessage(b []byte) error {
+	m, err := hl7.ParseMessage(b)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse message")
+	}
+	msh, err := m.MSH()
+	if err != nil {
+		return errors.Wrap(err, "cannot get MSH segment from parsed message")
+	}
+	if msh == nil || msh.MessageType == nil {
+		return errors.New("MSH.MessageType is not present in parsed message")
+	}
+	pid, err := m.PID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get PID segment from parsed message")
+	}
+	if pid == nil || len(pid.PatientIdentifierList) == 0 || pid.PatientIdentifierList[0].IDNumber == nil {
+		return errors.New("PID.PatientIdentifierList is not present in parsed message")
+	}
+	eventName := fmt.Sprintf("%s^%s", msh.MessageType.MessageCode.String(), msh.MessageType.TriggerEvent.String())
+	return h.TriggerEvent(pid.PatientIdentifierList[0].IDNumber.String(), eventName)
+}
+
 // getNextEvents gets the first event to be run, either from the historical steps or the pathway (if
 // there are no historical steps), and returns the updated lists of historical and pathway steps.
 func getNextEvents(historicalSteps []pathway.Step, pathwaySteps []pathway.Step) (first *pathway.Step, history []pathway.Step, steps []pathway.Step) {
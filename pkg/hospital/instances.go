@@ -0,0 +1,246 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+)
+
+var (
+	// ErrPathwayInstanceNotFound is returned when there is no pathway instance with the given ID, e.g.,
+	// because the pathway has finished.
+	ErrPathwayInstanceNotFound = errors.New("pathway instance not found")
+	// ErrPathwayInstancePaused is returned when a paused pathway instance cannot be changed, e.g., to
+	// run its next step, until it is resumed.
+	ErrPathwayInstancePaused = errors.New("pathway instance is paused")
+)
+
+// PathwayInstance is a pathway that has started for a patient and hasn't finished yet, i.e., that
+// has events in the event queue.
+type PathwayInstance struct {
+	// ID identifies the pathway instance; see state.Event.InstanceID.
+	ID          string    `json:"id"`
+	PathwayName string    `json:"pathwayName"`
+	PatientMRN  string    `json:"patientMrn"`
+	Started     time.Time `json:"started"`
+	// StepIndex is the index of the next step of the pathway that runs, counting the historical steps.
+	StepIndex int `json:"stepIndex"`
+	// NextStep is the type of the next step of the pathway that runs, e.g., "Admission".
+	NextStep string `json:"nextStep"`
+	// NextEventTime is the time at which the next step runs. It is not set if the next step waits
+	// for something else, e.g., a free bed or parallel tracks to finish.
+	NextEventTime *time.Time `json:"nextEventTime,omitempty"`
+	// WaitingForBed is the location where the next step waits for a free bed, if it does.
+	WaitingForBed string `json:"waitingForBed,omitempty"`
+	// WaitingForEvent is the external event that the next step waits for, if it does.
+	WaitingForEvent string `json:"waitingForEvent,omitempty"`
+	Paused          bool   `json:"paused"`
+	// Events is the number of events of the pathway instance in the event queue, which is greater
+	// than 1 if the pathway runs parallel tracks.
+	Events int `json:"events"`
+}
+
+// PathwayInstances returns the pathway instances that are running, sorted by the time they started.
+// Events that are running at the time PathwayInstances is called are not taken into account.
+func (h *Hospital) PathwayInstances() ([]PathwayInstance, error) {
+	h.snapshotMu.RLock()
+	defer h.snapshotMu.RUnlock()
+	items, err := h.eventQ.Items()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get the events in the queue")
+	}
+	byID := map[string][]state.Event{}
+	for _, i := range items {
+		e, ok := i.(state.Event)
+		if !ok {
+			continue
+		}
+		byID[e.InstanceID()] = append(byID[e.InstanceID()], e)
+	}
+	instances := make([]PathwayInstance, 0, len(byID))
+	for id, events := range byID {
+		next := nextInstanceEvent(events)
+		instance := PathwayInstance{
+			ID:          id,
+			PathwayName: next.PathwayName,
+			PatientMRN:  next.PatientMRN,
+			Started:     next.PathwayStarted,
+			StepIndex:   next.Index,
+			NextStep:    next.Step.StepType(),
+			Paused:      next.Paused,
+			Events:      len(events),
+		}
+		if t := dueTime(next); !t.Equal(joinPendingTime) {
+			instance.NextEventTime = &t
+		}
+		if next.WaitingForBed != "" {
+			instance.WaitingForBed = next.WaitingForBed
+		}
+		if next.Step.WaitForEvent != nil && !next.Triggered {
+			instance.WaitingForEvent = next.Step.WaitForEvent.Event
+		}
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if !instances[i].Started.Equal(instances[j].Started) {
+			return instances[i].Started.Before(instances[j].Started)
+		}
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
+
+// PausePathwayInstance pauses the pathway instance with the given ID: none of its steps run until it
+// is resumed with ResumePathwayInstance. External events that the instance waits for can still
+// arrive while it is paused, and they are taken into account when it is resumed.
+// Pausing an instance that is already paused does nothing.
+func (h *Hospital) PausePathwayInstance(id string) error {
+	return h.updatePathwayInstance(id, func(events []state.Event) ([]state.Event, error) {
+		for i := range events {
+			if events[i].Paused {
+				continue
+			}
+			events[i].Paused = true
+			events[i].PausedEventTime = events[i].EventTime
+			events[i].EventTime = joinPendingTime
+		}
+		return events, nil
+	})
+}
+
+// ResumePathwayInstance resumes the pathway instance with the given ID, which was paused with
+// PausePathwayInstance. The steps that became due while the instance was paused run straight away.
+// Resuming an instance that isn't paused does nothing.
+func (h *Hospital) ResumePathwayInstance(id string) error {
+	now := h.clock.Now()
+	return h.updatePathwayInstance(id, func(events []state.Event) ([]state.Event, error) {
+		for i := range events {
+			if !events[i].Paused {
+				continue
+			}
+			events[i].Paused = false
+			events[i].EventTime = events[i].PausedEventTime
+			events[i].PausedEventTime = time.Time{}
+			// Beds freed while the instance was paused didn't resume its waiting admissions.
+			if events[i].WaitingForBed != "" && events[i].EventTime.Equal(joinPendingTime) && h.locationManager.HasAvailableBed(events[i].WaitingForBed) {
+				setDue(&events[i], now)
+			}
+		}
+		return events, nil
+	})
+}
+
+// CancelPathwayInstance cancels the pathway instance with the given ID: the rest of its steps never
+// run. The patient is left as they were after the last step that ran, e.g., still admitted.
+func (h *Hospital) CancelPathwayInstance(id string) error {
+	return h.updatePathwayInstance(id, func([]state.Event) ([]state.Event, error) {
+		return nil, nil
+	})
+}
+
+// RunNextStepNow makes the next step of the pathway instance with the given ID due now, instead of
+// when it was due, so that it runs straight away. If the next step waits for an external event, it
+// times out. If it waits for a free bed, the admission is tried again.
+// Returns ErrPathwayInstancePaused if the instance is paused.
+func (h *Hospital) RunNextStepNow(id string) error {
+	now := h.clock.Now()
+	return h.updatePathwayInstance(id, func(events []state.Event) ([]state.Event, error) {
+		next := nextInstanceEvent(events)
+		if next.Paused {
+			return nil, errors.Wrapf(ErrPathwayInstancePaused, "cannot run the next step of pathway instance %s", id)
+		}
+		if next.JoinFor != "" && next.PendingTracks > 0 {
+			return nil, errors.Errorf("cannot run the next step of pathway instance %s: %d parallel track(s) haven't finished yet", id, next.PendingTracks)
+		}
+		// next points into events.
+		setDue(next, now)
+		return events, nil
+	})
+}
+
+// updatePathwayInstance replaces the events of the pathway instance with the given ID in the event
+// queue with the events returned by update, atomically.
+// Returns ErrPathwayInstanceNotFound if the instance doesn't have any events in the queue.
+func (h *Hospital) updatePathwayInstance(id string, update func([]state.Event) ([]state.Event, error)) error {
+	h.snapshotMu.RLock()
+	defer h.snapshotMu.RUnlock()
+	_, err := h.eventQ.Update(func(i state.MarshallableQueueItem) bool {
+		e, ok := i.(state.Event)
+		return ok && e.InstanceID() == id
+	}, func(items []state.MarshallableQueueItem) ([]state.MarshallableQueueItem, error) {
+		if len(items) == 0 {
+			return nil, errors.Wrapf(ErrPathwayInstanceNotFound, "no pathway instance with ID %q", id)
+		}
+		events := make([]state.Event, len(items))
+		for i, item := range items {
+			events[i] = item.(state.Event)
+		}
+		events, err := update(events)
+		if err != nil {
+			return nil, err
+		}
+		updated := make([]state.MarshallableQueueItem, len(events))
+		for i, e := range events {
+			updated[i] = e
+		}
+		return updated, nil
+	})
+	return err
+}
+
+// nextInstanceEvent returns the event of a pathway instance that runs next, out of all its events:
+// the one that is due the earliest, not counting join events waiting for their parallel tracks.
+func nextInstanceEvent(events []state.Event) *state.Event {
+	var next *state.Event
+	for i := range events {
+		e := &events[i]
+		switch {
+		case next == nil:
+			next = e
+		case isPendingJoin(next) && !isPendingJoin(e):
+			next = e
+		case isPendingJoin(next) == isPendingJoin(e) && dueTime(e).Before(dueTime(next)):
+			next = e
+		}
+	}
+	return next
+}
+
+func isPendingJoin(e *state.Event) bool {
+	return e.JoinFor != "" && e.PendingTracks > 0
+}
+
+// dueTime returns the time at which the given event is due, or would be due if it wasn't paused.
+func dueTime(e *state.Event) time.Time {
+	if e.Paused {
+		return e.PausedEventTime
+	}
+	return e.EventTime
+}
+
+// setDue makes the given event due at the given time or, if it is paused, at the given time once its
+// pathway instance is resumed.
+func setDue(e *state.Event, t time.Time) {
+	e.MessageTime = t
+	if e.Paused {
+		e.PausedEventTime = t
+		return
+	}
+	e.EventTime = t
+}
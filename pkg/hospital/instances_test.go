@@ -0,0 +1,259 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	. "github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhl7"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
+)
+
+// onlyInstance returns the only pathway instance that is running in the hospital.
+func onlyInstance(t *testing.T, h *testhospital.Hospital) PathwayInstance {
+	t.Helper()
+	instances, err := h.PathwayInstances()
+	if err != nil {
+		t.Fatalf("PathwayInstances() failed with %v", err)
+	}
+	if len(instances) != 1 {
+		t.Fatalf("PathwayInstances() got %d instances, want 1: %+v", len(instances), instances)
+	}
+	return instances[0]
+}
+
+// runDueEvents runs the events that are due, and returns the number of events that ran.
+func runDueEvents(ctx context.Context, t *testing.T, h *testhospital.Hospital) int {
+	t.Helper()
+	var n int
+	for {
+		ran, err := h.RunNextEventIfDue(ctx)
+		if err != nil {
+			t.Fatalf("RunNextEventIfDue() failed with %v", err)
+		}
+		if !ran {
+			return n
+		}
+		n++
+	}
+}
+
+func TestPathwayInstances(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Hour, To: time.Hour}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	h := newHospital(ctx, t, Config{}, pathways)
+	defer h.Close()
+
+	if got, err := h.PathwayInstances(); err != nil || len(got) != 0 {
+		t.Errorf("PathwayInstances() got (%v, %v), want no instances", got, err)
+	}
+
+	mrn := startPathwayMRN(t, h, testPathwayName)
+	got := onlyInstance(t, h)
+	if got.PathwayName != testPathwayName || got.PatientMRN != mrn || got.NextStep != pathway.StepAdmission || got.Paused || got.NextEventTime == nil {
+		t.Errorf("PathwayInstances() got %+v, want an unpaused instance of %s for patient %s with a due admission", got, testPathwayName, mrn)
+	}
+	runDueEvents(ctx, t, h)
+
+	// The ID of an instance doesn't change as its steps run.
+	next := onlyInstance(t, h)
+	if next.ID != got.ID {
+		t.Errorf("PathwayInstances() got ID %q, want %q", next.ID, got.ID)
+	}
+	if next.StepIndex <= got.StepIndex {
+		t.Errorf("PathwayInstances() got StepIndex %d, want greater than %d", next.StepIndex, got.StepIndex)
+	}
+}
+
+func TestPathwayInstances_PauseResume(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Hour, To: time.Hour}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	h := newHospital(ctx, t, Config{}, pathways)
+	defer h.Close()
+
+	startPathway(t, h, testPathwayName)
+	id := onlyInstance(t, h).ID
+	if err := h.PausePathwayInstance(id); err != nil {
+		t.Fatalf("PausePathwayInstance(%q) failed with %v", id, err)
+	}
+	// Pausing twice does nothing.
+	if err := h.PausePathwayInstance(id); err != nil {
+		t.Fatalf("PausePathwayInstance(%q) failed with %v", id, err)
+	}
+	got := onlyInstance(t, h)
+	if !got.Paused || got.NextEventTime == nil {
+		t.Errorf("PathwayInstances() got %+v, want a paused instance with a next event time", got)
+	}
+
+	// None of the steps run while the instance is paused.
+	h.AdvanceClock(2 * time.Hour)
+	if n := runDueEvents(ctx, t, h); n != 0 {
+		t.Errorf("runDueEvents() ran %d events while paused, want 0", n)
+	}
+
+	if err := h.ResumePathwayInstance(id); err != nil {
+		t.Fatalf("ResumePathwayInstance(%q) failed with %v", id, err)
+	}
+	if got := onlyInstance(t, h); got.Paused {
+		t.Errorf("PathwayInstances() got %+v, want an instance that isn't paused", got)
+	}
+	_, messages := h.ConsumeQueues(ctx, t)
+	want := []string{"ADT^A01", "ADT^A03"}
+	if diff := cmp.Diff(want, testhl7.Fields(t, messages, testhl7.MessageType)); diff != "" {
+		t.Errorf("message types diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestPathwayInstances_PauseWaitForEvent(t *testing.T) {
+	ctx := context.Background()
+	timeout := 10 * time.Minute
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{WaitForEvent: &pathway.WaitForEvent{
+				Event:     "ORU^R01",
+				Timeout:   &timeout,
+				OnTimeout: []pathway.Step{{CancelVisit: &pathway.CancelVisit{}}},
+			}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	h := newHospital(ctx, t, Config{}, pathways)
+	defer h.Close()
+
+	mrn := startPathwayMRN(t, h, testPathwayName)
+	runDueEvents(ctx, t, h)
+	got := onlyInstance(t, h)
+	if got.WaitingForEvent != "ORU^R01" {
+		t.Errorf("PathwayInstances() got WaitingForEvent %q, want %q", got.WaitingForEvent, "ORU^R01")
+	}
+	if err := h.PausePathwayInstance(got.ID); err != nil {
+		t.Fatalf("PausePathwayInstance(%q) failed with %v", got.ID, err)
+	}
+
+	// The external event arrives while the instance is paused, but it doesn't resume the pathway.
+	if err := h.TriggerEvent(mrn, "ORU^R01"); err != nil {
+		t.Fatalf("TriggerEvent(%s, ORU^R01) failed with %v", mrn, err)
+	}
+	if n := runDueEvents(ctx, t, h); n != 0 {
+		t.Errorf("runDueEvents() ran %d events while paused, want 0", n)
+	}
+
+	// Once resumed, the pathway continues as if the event had arrived, and doesn't time out.
+	if err := h.ResumePathwayInstance(got.ID); err != nil {
+		t.Fatalf("ResumePathwayInstance(%q) failed with %v", got.ID, err)
+	}
+	_, messages := h.ConsumeQueues(ctx, t)
+	want := []string{"ADT^A01", "ADT^A03"}
+	if diff := cmp.Diff(want, testhl7.Fields(t, messages, testhl7.MessageType)); diff != "" {
+		t.Errorf("message types diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestPathwayInstances_Cancel(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Hour, To: time.Hour}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	h := newHospital(ctx, t, Config{}, pathways)
+	defer h.Close()
+
+	startPathway(t, h, testPathwayName)
+	runDueEvents(ctx, t, h)
+	id := onlyInstance(t, h).ID
+	if err := h.CancelPathwayInstance(id); err != nil {
+		t.Fatalf("CancelPathwayInstance(%q) failed with %v", id, err)
+	}
+	if h.HasEvents() {
+		t.Error("HasEvents() after CancelPathwayInstance() got true, want false")
+	}
+	// The patient stays admitted.
+	_, messages := h.ConsumeQueues(ctx, t)
+	want := []string{"ADT^A01"}
+	if diff := cmp.Diff(want, testhl7.Fields(t, messages, testhl7.MessageType)); diff != "" {
+		t.Errorf("message types diff (-want, +got):\n%s", diff)
+	}
+
+	for name, f := range map[string]func(string) error{
+		"PausePathwayInstance":  h.PausePathwayInstance,
+		"ResumePathwayInstance": h.ResumePathwayInstance,
+		"CancelPathwayInstance": h.CancelPathwayInstance,
+		"RunNextStepNow":        h.RunNextStepNow,
+	} {
+		if err := f(id); errors.Cause(err) != ErrPathwayInstanceNotFound {
+			t.Errorf("%s(%q) got error %v, want %v", name, id, err, ErrPathwayInstanceNotFound)
+		}
+	}
+}
+
+func TestPathwayInstances_RunNextStepNow(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: 24 * time.Hour, To: 24 * time.Hour}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	h := newHospital(ctx, t, Config{}, pathways)
+	defer h.Close()
+
+	startPathway(t, h, testPathwayName)
+	runDueEvents(ctx, t, h)
+	id := onlyInstance(t, h).ID
+
+	if err := h.PausePathwayInstance(id); err != nil {
+		t.Fatalf("PausePathwayInstance(%q) failed with %v", id, err)
+	}
+	if err := h.RunNextStepNow(id); errors.Cause(err) != ErrPathwayInstancePaused {
+		t.Errorf("RunNextStepNow(%q) of a paused instance got error %v, want %v", id, err, ErrPathwayInstancePaused)
+	}
+	if err := h.ResumePathwayInstance(id); err != nil {
+		t.Fatalf("ResumePathwayInstance(%q) failed with %v", id, err)
+	}
+
+	if err := h.RunNextStepNow(id); err != nil {
+		t.Fatalf("RunNextStepNow(%q) failed with %v", id, err)
+	}
+	// The discharge runs without advancing the clock.
+	if n := runDueEvents(ctx, t, h); n == 0 {
+		t.Error("runDueEvents() after RunNextStepNow() ran no events, want at least 1")
+	}
+	if h.HasEvents() {
+		t.Error("HasEvents() after running the last step got true, want false")
+	}
+}
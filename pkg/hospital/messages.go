@@ -17,6 +17,7 @@ package hospital
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,6 +31,20 @@ func (h *Hospital) HasMessages() bool {
 	return !h.messageQ.Empty()
 }
 
+// NextMessageTime returns the time at which the next message in the Message queue is due.
+// It returns false if the Message queue is empty.
+func (h *Hospital) NextMessageTime() (time.Time, bool) {
+	i := h.messageQ.Peek()
+	if i == nil {
+		return time.Time{}, false
+	}
+	m, ok := i.(state.HL7Message)
+	if !ok {
+		log.Fatalf("Unknown item type %v, want state.HL7Message", i)
+	}
+	return m.MessageTime, true
+}
+
 // processNextMessage consumes the next message from the Message queue and processes it.
 // processNextMessage returns an error if the queue is empty or there was any problem processing the message.
 func (h *Hospital) processNextMessage() error {
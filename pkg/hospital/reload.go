@@ -0,0 +1,162 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/doctor"
+	"github.com/Arend-melissant/simhospital/pkg/location"
+	"github.com/Arend-melissant/simhospital/pkg/orderprofile"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+)
+
+// ReloadableConfig is the part of the configuration of a hospital that can be reloaded while the
+// hospital runs; see Hospital.Reload.
+type ReloadableConfig struct {
+	LocationManager *location.Manager
+	Doctors         *doctor.Doctors
+	OrderProfiles   *orderprofile.OrderProfiles
+	// Pathways are all the pathways that were loaded, keyed by name, e.g., to create pathway managers
+	// for groups of pathways.
+	Pathways       map[string]pathway.Pathway
+	PathwayManager pathway.Manager
+	// GroupManagers are the new managers of other reloadable pathway managers, e.g., the ones of the
+	// groups of pathways, keyed by the manager they replace. They are replaced together with the
+	// pathway manager of the hospital. Optional.
+	GroupManagers map[*pathway.ReloadableManager]pathway.Manager
+}
+
+// LoadReloadableConfig loads the locations, doctors, order profiles and pathways from the files in the
+// given arguments, which must all be set. The pathways are validated against the locations, doctors
+// and order profiles that are loaded, not the ones that the hospital uses.
+// c is the configuration of the hospital, whose HL7Config, Clock and Rand are used.
+func LoadReloadableConfig(ctx context.Context, arguments Arguments, c Config) (*ReloadableConfig, error) {
+	switch {
+	case arguments.LocationsFile == nil:
+		return nil, errors.New("Arguments.LocationsFile not provided; this is required")
+	case arguments.DoctorsFile == nil:
+		return nil, errors.New("Arguments.DoctorsFile not provided; this is required")
+	case arguments.OrderProfilesFile == nil:
+		return nil, errors.New("Arguments.OrderProfilesFile not provided; this is required")
+	case arguments.PathwayArguments == nil:
+		return nil, errors.New("Arguments.PathwayArguments not provided; this is required")
+	}
+	var rc ReloadableConfig
+	var err error
+	if rc.LocationManager, err = location.NewManager(ctx, *arguments.LocationsFile); err != nil {
+		return nil, errors.Wrap(err, "cannot create Location Manager")
+	}
+	if rc.Doctors, err = doctor.LoadDoctors(ctx, *arguments.DoctorsFile); err != nil {
+		return nil, errors.Wrap(err, "cannot load the doctors configuration")
+	}
+	if rc.OrderProfiles, err = orderprofile.Load(ctx, *arguments.OrderProfilesFile, c.HL7Config); err != nil {
+		return nil, errors.Wrap(err, "cannot load the order profiles")
+	}
+	p := &pathway.Parser{Clock: c.Clock, OrderProfiles: rc.OrderProfiles, Doctors: rc.Doctors, LocationManager: rc.LocationManager, Rand: c.Rand}
+	if rc.Pathways, err = p.ParsePathways(ctx, arguments.PathwayArguments.Dir); err != nil {
+		return nil, errors.Wrap(err, "failed to parse pathways for Pathway Manager")
+	}
+	if rc.PathwayManager, err = pathwayManager(rc.Pathways, *arguments.PathwayArguments, c.Rand); err != nil {
+		return nil, errors.Wrap(err, "cannot create pathway manager")
+	}
+	return &rc, nil
+}
+
+// Reload replaces the locations, doctors, order profiles and pathways of the hospital, and the
+// pathway managers in GroupManagers, with the ones in the given config, e.g., after they changed in
+// their files. Events, messages and pathways don't
+// run while the config is replaced. The pathways that are running keep going with the steps they
+// started with; the pathways that start afterwards are picked from the new pathways.
+// Occupied beds stay occupied. Returns an error if a location with occupied beds or with admissions
+// waiting for a bed doesn't exist in the new config, if any of the steps that the running pathways
+// have yet to run would fail with the new config, e.g., because they use a location or an order
+// profile that was removed, or if the pathway manager of the hospital is not a
+// *pathway.ReloadableManager; in these cases, nothing is replaced.
+// The given config must not be used after calling Reload.
+func (h *Hospital) Reload(c *ReloadableConfig) error {
+	m, ok := h.pathwayManager.(*pathway.ReloadableManager)
+	if !ok {
+		return errors.Errorf("the pathway manager %T cannot be reloaded; it must be a *pathway.ReloadableManager", h.pathwayManager)
+	}
+	h.snapshotMu.Lock()
+	defer h.snapshotMu.Unlock()
+
+	items, err := h.eventQ.Items()
+	if err != nil {
+		return errors.Wrap(err, "cannot get the events in the queue")
+	}
+	now := h.clock.Now()
+	for _, i := range items {
+		e, ok := i.(state.Event)
+		if !ok {
+			continue
+		}
+		if e.WaitingForBed != "" {
+			if _, err := c.LocationManager.Location(e.WaitingForBed); err != nil {
+				return errors.Errorf("location %s has admissions waiting for a bed, so it cannot be removed", e.WaitingForBed)
+			}
+		}
+		if err := pathway.ValidRemainingSteps(remainingSteps(e), now, c.OrderProfiles, c.Doctors, c.LocationManager, h.placedOrders(e)); err != nil {
+			return errors.Wrapf(err, "pathway %s of patient %s cannot continue with the new config", e.PathwayName, e.PatientMRN)
+		}
+	}
+	if err := h.locationManager.Replace(c.LocationManager); err != nil {
+		return errors.Wrap(err, "cannot reload the locations")
+	}
+	h.doctors.Replace(c.Doctors)
+	h.orderProfiles.Replace(c.OrderProfiles)
+	m.Replace(c.PathwayManager)
+	for rm, gm := range c.GroupManagers {
+		rm.Replace(gm)
+	}
+	log.WithField("pathways", len(c.Pathways)).Info("Reloaded the locations, doctors, order profiles and pathways")
+	return nil
+}
+
+// remainingSteps returns the steps of the pathway of the given event that haven't run yet, starting
+// with the step of the event itself. The step of a join event is the Parallel step whose tracks are
+// queued as events of their own, so it is not included.
+func remainingSteps(e state.Event) []pathway.Step {
+	var steps []pathway.Step
+	if e.JoinFor == "" {
+		steps = append(steps, e.Step)
+	}
+	return append(append(steps, e.History...), e.Pathway...)
+}
+
+// placedOrders returns the names of the order profiles of the orders that the patients of the
+// pathway of the given event have, keyed by order ID.
+func (h *Hospital) placedOrders(e state.Event) map[string]string {
+	mrns := []string{e.PatientMRN}
+	for _, mrn := range e.PatientIDs {
+		mrns = append(mrns, mrn)
+	}
+	orders := map[string]string{}
+	for _, mrn := range mrns {
+		p := h.patients.Get(mrn)
+		if p == nil {
+			continue
+		}
+		for id, o := range p.Orders {
+			if o != nil && o.OrderProfile != nil {
+				orders[id] = o.OrderProfile.Text
+			}
+		}
+	}
+	return orders
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	. "github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/test"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhl7"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
+	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
+)
+
+const (
+	reloadLocations = `
+ED:
+  poc: ED
+  facility: Simulated Hospital
+  type: ED
+Renal:
+  poc: RenalWard
+  facility: Simulated Hospital
+`
+	reloadPathways = `
+before_reload:
+  percentage_of_patients: 1
+  pathway:
+    - admission:
+        loc: Renal
+    - delay:
+        from: 1h
+        to: 1h
+    - discharge: {}
+`
+)
+
+// reloadArguments returns the arguments to create a hospital with the given locations and pathways.
+func reloadArguments(t *testing.T, locations string, pathways string) Arguments {
+	t.Helper()
+	args := testhospital.Arguments
+	locationsFile := testwrite.BytesToFile(t, []byte(locations))
+	args.LocationsFile = &locationsFile
+	pathwayArgs := *args.PathwayArguments
+	pathwayArgs.Dir = testwrite.BytesToDir(t, []byte(pathways), "pathways.yml")
+	args.PathwayArguments = &pathwayArgs
+	return args
+}
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	h := testhospital.New(ctx, t, testhospital.Config{Arguments: reloadArguments(t, reloadLocations, reloadPathways)})
+	defer h.Close()
+	hospitalConfig := Config{HL7Config: h.MessageConfig, Clock: h.Parser.Clock, Rand: h.Parser.Rand}
+
+	// The manager of a group of pathways, which is reloaded with the hospital.
+	group := pathway.NewReloadableManager(h.PathwayManager)
+
+	startPathwayMRN(t, h, "before_reload")
+	// The patient is admitted in Renal.
+	runDueEvents(ctx, t, h)
+
+	cases := []struct {
+		name      string
+		locations string
+		pathways  string
+	}{{
+		name: "location with occupied beds removed",
+		locations: `
+ED:
+  poc: ED
+  facility: Simulated Hospital
+  type: ED
+`,
+		pathways: `
+after_reload:
+  percentage_of_patients: 1
+  pathway:
+    - admission:
+        loc: ED
+`,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, err := LoadReloadableConfig(ctx, reloadArguments(t, tc.locations, tc.pathways), hospitalConfig)
+			if err != nil {
+				t.Fatalf("LoadReloadableConfig() failed with %v", err)
+			}
+			rc.GroupManagers = map[*pathway.ReloadableManager]pathway.Manager{group: rc.PathwayManager}
+			if err := h.Reload(rc); err == nil {
+				t.Fatal("Reload() got nil error, want error")
+			}
+			// Nothing changed.
+			if _, err := h.GetPathway("before_reload"); err != nil {
+				t.Errorf("GetPathway(before_reload) after a failed Reload() failed with %v", err)
+			}
+			if _, err := group.GetPathway("before_reload"); err != nil {
+				t.Errorf("group.GetPathway(before_reload) after a failed Reload() failed with %v", err)
+			}
+		})
+	}
+
+	// The pathways must be valid with the new locations.
+	invalid := reloadArguments(t, reloadLocations, `
+after_reload:
+  percentage_of_patients: 1
+  pathway:
+    - admission:
+        loc: NewWard
+`)
+	if _, err := LoadReloadableConfig(ctx, invalid, hospitalConfig); err == nil {
+		t.Error("LoadReloadableConfig() with a pathway in an unknown location got nil error, want error")
+	}
+
+	rc, err := LoadReloadableConfig(ctx, reloadArguments(t, reloadLocations+`
+NewWard:
+  poc: NewWard
+  facility: Simulated Hospital
+`, `
+after_reload:
+  percentage_of_patients: 1
+  pathway:
+    - admission:
+        loc: NewWard
+`), hospitalConfig)
+	if err != nil {
+		t.Fatalf("LoadReloadableConfig() failed with %v", err)
+	}
+	rc.GroupManagers = map[*pathway.ReloadableManager]pathway.Manager{group: rc.PathwayManager}
+	if err := h.Reload(rc); err != nil {
+		t.Fatalf("Reload() failed with %v", err)
+	}
+	if _, err := h.GetPathway("before_reload"); err == nil {
+		t.Error("GetPathway(before_reload) after Reload() got nil error, want error")
+	}
+	if _, err := group.GetPathway("after_reload"); err != nil {
+		t.Errorf("group.GetPathway(after_reload) after Reload() failed with %v", err)
+	}
+	if got, want := h.LocationManager.Names(), []string{"ED", "NewWard", "Renal"}; !cmp.Equal(got, want) {
+		t.Errorf("LocationManager.Names() got %v, want %v", got, want)
+	}
+
+	// The pathway that was running keeps going, and the new pathways start.
+	if err := h.StartNextPathway(); err != nil {
+		t.Fatalf("StartNextPathway() failed with %v", err)
+	}
+	_, messages := h.ConsumeQueues(ctx, t)
+	gotTypes := testhl7.Fields(t, messages, testhl7.MessageType)
+	gotLocations := testhl7.Fields(t, messages, testhl7.PointOfCare)
+	var got []string
+	for i := range gotTypes {
+		got = append(got, gotTypes[i]+" "+gotLocations[i])
+	}
+	sort.Strings(got)
+	want := []string{"ADT^A01 NewWard", "ADT^A01 RenalWard", "ADT^A03 RenalWard"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ConsumeQueues() messages (type and point of care) -want, +got:\n%s", diff)
+	}
+}
+
+func TestReload_RunningPathwaysMustContinue(t *testing.T) {
+	ctx := context.Background()
+	locations := reloadLocations + `
+ICU:
+  poc: ICU
+  facility: Simulated Hospital
+`
+	args := reloadArguments(t, locations, `
+running:
+  percentage_of_patients: 1
+  pathway:
+    - admission:
+        loc: Renal
+    - delay:
+        from: 1h
+        to: 1h
+    - result:
+        order_profile: UREA AND ELECTROLYTES
+        results:
+          - test_name: Creatinine
+            value: NORMAL
+    - transfer:
+        loc: ICU
+    - discharge: {}
+`)
+	h := testhospital.New(ctx, t, testhospital.Config{Arguments: args})
+	defer h.Close()
+	hospitalConfig := Config{HL7Config: h.MessageConfig, Clock: h.Parser.Clock, Rand: h.Parser.Rand}
+
+	startPathwayMRN(t, h, "running")
+	// The patient is admitted in Renal; the result and the transfer to ICU are still to run.
+	runDueEvents(ctx, t, h)
+
+	otherOrderProfiles := testwrite.BytesToFile(t, []byte(`
+LIPIDS:
+  universal_service_id: lpdc-1
+  test_types:
+    Cholesterol:
+      id: lpdc-2
+      value_type: NM
+      value: 4
+      unit: MMOLL
+      ref_range: 0 - 5
+`))
+	cases := []struct {
+		name          string
+		locations     string
+		orderProfiles string
+		wantErr       bool
+	}{{
+		name:          "location of a remaining step removed",
+		locations:     reloadLocations,
+		orderProfiles: test.OrderProfilesConfigTest,
+		wantErr:       true,
+	}, {
+		name:          "order profile of a remaining step removed",
+		locations:     locations,
+		orderProfiles: otherOrderProfiles,
+		wantErr:       true,
+	}, {
+		name:          "everything the remaining steps use is kept",
+		locations:     locations,
+		orderProfiles: test.OrderProfilesConfigTest,
+		wantErr:       false,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := reloadArguments(t, tc.locations, reloadPathways)
+			args.OrderProfilesFile = &tc.orderProfiles
+			rc, err := LoadReloadableConfig(ctx, args, hospitalConfig)
+			if err != nil {
+				t.Fatalf("LoadReloadableConfig() failed with %v", err)
+			}
+			err = h.Reload(rc)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Reload() got err %v, want error: %t", err, tc.wantErr)
+			}
+			// The pathways are only replaced if the reload succeeds.
+			_, err = h.GetPathway("running")
+			if gotErr := err != nil; gotErr != !tc.wantErr {
+				t.Errorf("GetPathway(running) after Reload() got err %v, want error: %t", err, !tc.wantErr)
+			}
+		})
+	}
+}
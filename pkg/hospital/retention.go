@@ -0,0 +1,167 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+)
+
+// RetentionPolicy is how long the hospital keeps patients and messages, so that the state of
+// long-running hospitals doesn't grow without bound. Items are purged both from memory and from the
+// ItemSyncers, if any, which must implement persist.Purger to purge patients.
+type RetentionPolicy struct {
+	// Patients is how long patients are kept after their last encounter ended, as returned by
+	// state.Patient.End. Patients with pending events, or whose last encounter hasn't ended, are kept.
+	// Optional. If 0, patients are kept forever.
+	Patients time.Duration
+
+	// Messages is how long messages that haven't been sent are kept after their message time, e.g.,
+	// messages that were persisted when Simulated Hospital stopped. Messages older than that are
+	// not sent.
+	// Optional. If 0, messages are kept until they are sent.
+	Messages time.Duration
+
+	// Archiver archives the patients and messages before they are purged. Items that cannot be
+	// archived are not purged.
+	// Optional. If not set, the items are purged without being archived.
+	Archiver Archiver
+}
+
+func (p RetentionPolicy) validate() error {
+	if p.Patients < 0 || p.Messages < 0 {
+		return errors.Errorf("the retention periods of patients %v and messages %v cannot be negative", p.Patients, p.Messages)
+	}
+	return nil
+}
+
+// IsSet returns whether the policy purges any items.
+func (p RetentionPolicy) IsSet() bool {
+	return p.Patients > 0 || p.Messages > 0
+}
+
+// Archiver archives the items purged by a RetentionPolicy.
+type Archiver interface {
+	// Archive archives an item of the given item type, e.g., state.PatientItemType.
+	Archive(itemType string, item persist.MarshallableItem) error
+}
+
+// RetentionResult is the number of items purged by Hospital.ApplyRetention, keyed by item type.
+type RetentionResult map[string]int
+
+// ApplyRetention purges the patients and the messages that are older than allowed by the retention
+// policy of the hospital, i.e., Config.Retention, archiving them first if the policy has an
+// Archiver. Purged patients cannot return to the hospital.
+// It blocks the processing of events and messages while it runs.
+func (h *Hospital) ApplyRetention() (RetentionResult, error) {
+	h.snapshotMu.Lock()
+	defer h.snapshotMu.Unlock()
+	now := h.clock.Now()
+	result := RetentionResult{}
+	if h.retention.Messages > 0 {
+		n, err := h.purgeMessages(now.Add(-h.retention.Messages))
+		result[state.MessageItemType] = n
+		if err != nil {
+			return result, errors.Wrap(err, "cannot purge messages")
+		}
+	}
+	if h.retention.Patients > 0 {
+		n, err := h.purgePatients(now.Add(-h.retention.Patients))
+		result[state.PatientItemType] = n
+		if err != nil {
+			return result, errors.Wrap(err, "cannot purge patients")
+		}
+	}
+	for itemType, n := range result {
+		if n > 0 {
+			log.WithField("item_type", itemType).WithField("count", n).Info("Purged items older than the retention period")
+		}
+	}
+	return result, nil
+}
+
+// purgeMessages purges the messages whose message time is before the given time, and returns how
+// many were purged.
+func (h *Hospital) purgeMessages(before time.Time) (int, error) {
+	expired := h.messageQ.Find(func(i state.MarshallableQueueItem) bool {
+		return i.End().Before(before)
+	})
+	purge := map[string]bool{}
+	for _, m := range expired {
+		id, err := h.archive(state.MessageItemType, m)
+		if err != nil {
+			log.WithError(err).WithField("item_id", id).Error("Cannot archive message; it is not purged")
+			continue
+		}
+		purge[id] = true
+	}
+	removed, err := h.messageQ.RemoveAll(func(i state.MarshallableQueueItem) bool {
+		id, err := i.ID()
+		return err == nil && purge[id]
+	})
+	return len(removed), err
+}
+
+// purgePatients purges the patients whose last encounter ended before the given time and that don't
+// have pending events, and returns how many were purged.
+func (h *Hospital) purgePatients(before time.Time) (int, error) {
+	patients, err := h.patients.All()
+	if err != nil {
+		return 0, err
+	}
+	active := map[string]bool{}
+	for _, i := range h.eventQ.Find(func(state.MarshallableQueueItem) bool { return true }) {
+		e := i.(state.Event)
+		active[e.PatientMRN] = true
+		for _, mrn := range e.PatientIDs {
+			active[mrn] = true
+		}
+	}
+	purged := map[string]bool{}
+	defer func() { h.returningPatients.remove(purged) }()
+	for _, p := range patients {
+		if p.PatientInfo == nil || !p.End().Before(before) {
+			continue
+		}
+		id, err := p.ID()
+		if err != nil || active[id] {
+			continue
+		}
+		if _, err := h.archive(state.PatientItemType, *p); err != nil {
+			log.WithError(err).WithField(keyPatientID, id).Error("Cannot archive patient; it is not purged")
+			continue
+		}
+		if err := h.patients.Purge(p); err != nil {
+			return len(purged), err
+		}
+		purged[id] = true
+	}
+	return len(purged), nil
+}
+
+// archive archives the item with the Archiver of the retention policy, if set, and returns its ID.
+func (h *Hospital) archive(itemType string, item persist.MarshallableItem) (string, error) {
+	id, err := item.ID()
+	if err != nil {
+		return "", errors.Wrap(err, "cannot get ID")
+	}
+	if h.retention.Archiver == nil {
+		return id, nil
+	}
+	return id, h.retention.Archiver.Archive(itemType, item)
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	. "github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
+	"github.com/Arend-melissant/simhospital/pkg/test/teststate"
+)
+
+// fakeArchiver records the IDs of the archived items, keyed by item type.
+type fakeArchiver struct {
+	archived map[string][]string
+	fail     bool
+}
+
+func (a *fakeArchiver) Archive(itemType string, item persist.MarshallableItem) error {
+	if a.fail {
+		return errors.New("archive failed")
+	}
+	id, err := item.ID()
+	if err != nil {
+		return err
+	}
+	if a.archived == nil {
+		a.archived = map[string][]string{}
+	}
+	a.archived[itemType] = append(a.archived[itemType], id)
+	return nil
+}
+
+func startPathwayMRN(t *testing.T, h *testhospital.Hospital, pathwayName string) string {
+	t.Helper()
+	p, err := h.PathwayManager.GetPathway(pathwayName)
+	if err != nil {
+		t.Fatalf("GetPathway(%s) failed with %v", pathwayName, err)
+	}
+	persons, err := h.StartPathway(p)
+	if err != nil {
+		t.Fatalf("StartPathway(%v) failed with %v", pathwayName, err)
+	}
+	return persons[0].MRN
+}
+
+func TestApplyRetention_Patients(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		"discharged": {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Minute, To: 2 * time.Minute}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+		"admitted": {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+		}},
+	}
+	for _, tc := range []struct {
+		name        string
+		archiver    *fakeArchiver
+		wantPurged  bool
+		wantArchive map[string][]string
+	}{{
+		name:       "no archiver",
+		wantPurged: true,
+	}, {
+		name:       "archiver",
+		archiver:   &fakeArchiver{},
+		wantPurged: true,
+	}, {
+		name:     "archiver fails",
+		archiver: &fakeArchiver{fail: true},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			retention := RetentionPolicy{Patients: 24 * time.Hour}
+			if tc.archiver != nil {
+				retention.Archiver = tc.archiver
+			}
+			// The syncer doesn't delete patients, but they are purged anyway.
+			syncer := teststate.NewItemSyncerWithDelete(false)
+			h := hospitalWithPatientSyncer(ctx, t, Config{Retention: retention}, pathways, syncer)
+			defer h.Close()
+
+			discharged := startPathwayMRN(t, h, "discharged")
+			admitted := startPathwayMRN(t, h, "admitted")
+			h.ConsumeQueues(ctx, t)
+
+			// The discharge was too recent.
+			if got, err := h.ApplyRetention(); err != nil || got[state.PatientItemType] != 0 {
+				t.Errorf("ApplyRetention() got (%v, %v), want no patients purged", got, err)
+			}
+
+			h.AdvanceClock(25 * time.Hour)
+			got, err := h.ApplyRetention()
+			if err != nil {
+				t.Fatalf("ApplyRetention() failed with %v", err)
+			}
+			wantPurged := 0
+			if tc.wantPurged {
+				wantPurged = 1
+			}
+			if got[state.PatientItemType] != wantPurged {
+				t.Errorf("ApplyRetention() got %v, want %d patients purged", got, wantPurged)
+			}
+			if got := h.PatientExists(discharged); got == tc.wantPurged {
+				t.Errorf("PatientExists(discharged) got %t, want %t", got, !tc.wantPurged)
+			}
+			if inSyncer, _ := syncer.LoadByID(discharged); (inSyncer == nil) != tc.wantPurged {
+				t.Errorf("syncer LoadByID(discharged) got %v, want purged? %t", inSyncer, tc.wantPurged)
+			}
+			// The patient that is still admitted is never purged.
+			if !h.PatientExists(admitted) {
+				t.Error("PatientExists(admitted) got false, want true")
+			}
+			if tc.archiver != nil && !tc.archiver.fail {
+				want := map[string][]string{state.PatientItemType: {discharged}}
+				if diff := cmp.Diff(want, tc.archiver.archived); diff != "" {
+					t.Errorf("archived items diff (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRetention_Messages(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+		}},
+	}
+	archiver := &fakeArchiver{}
+	h := newHospital(ctx, t, Config{Retention: RetentionPolicy{Messages: time.Hour, Archiver: archiver}}, pathways)
+	defer h.Close()
+
+	startPathway(t, h, testPathwayName)
+	// Run the event, but don't send the message it generates.
+	if ran, err := h.RunNextEventIfDue(ctx); err != nil || !ran {
+		t.Fatalf("RunNextEventIfDue() got (%t, %v), want (true, nil)", ran, err)
+	}
+	if !h.HasMessages() {
+		t.Fatal("HasMessages() got false, want true")
+	}
+
+	h.AdvanceClock(30 * time.Minute)
+	if got, err := h.ApplyRetention(); err != nil || got[state.MessageItemType] != 0 {
+		t.Errorf("ApplyRetention() got (%v, %v), want no messages purged", got, err)
+	}
+
+	h.AdvanceClock(time.Hour)
+	got, err := h.ApplyRetention()
+	if err != nil {
+		t.Fatalf("ApplyRetention() failed with %v", err)
+	}
+	if diff := cmp.Diff(RetentionResult{state.MessageItemType: 1}, got); diff != "" {
+		t.Errorf("ApplyRetention() diff (-want, +got):\n%s", diff)
+	}
+	if h.HasMessages() {
+		t.Error("HasMessages() after ApplyRetention() got true, want false")
+	}
+	if got := len(archiver.archived[state.MessageItemType]); got != 1 {
+		t.Errorf("archived messages got %d, want 1", got)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/Arend-melissant/simhospital/pkg/random"
+)
+
+// returningPatients keeps the MRNs of the patients whose pathways have finished, so that they can return
+// to the hospital in new pathways. It is safe for concurrent use.
+type returningPatients struct {
+	mu sync.Mutex
+	// probability is the probability that a new pathway is for a returning patient.
+	probability float64
+	mrns        []string
+	// rand is the source of randomness.
+	rand *rand.Rand
+}
+
+// add adds the patient with the given MRN to the patients that can return.
+func (r *returningPatients) add(mrn string) {
+	if r.probability == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mrns = append(r.mrns, mrn)
+}
+
+// pick returns the MRN of a patient picked at random to return to the hospital, and removes it from the
+// patients that can return. It returns false, with the probability 1-r.probability, or if there are no
+// patients that can return.
+func (r *returningPatients) pick() (string, bool) {
+	if r.probability == 0 || random.Or(r.rand).Float64() >= r.probability {
+		return "", false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.mrns) == 0 {
+		return "", false
+	}
+	i := random.Or(r.rand).Intn(len(r.mrns))
+	mrn := r.mrns[i]
+	r.mrns[i] = r.mrns[len(r.mrns)-1]
+	r.mrns = r.mrns[:len(r.mrns)-1]
+	return mrn, true
+}
+
+// all returns the MRNs of the patients that can return.
+func (r *returningPatients) all() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.mrns...)
+}
+
+// set replaces the MRNs of the patients that can return.
+func (r *returningPatients) set(mrns []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mrns = append([]string(nil), mrns...)
+}
+
+// remove removes the patients with the given MRNs from the patients that can return.
+func (r *returningPatients) remove(mrns map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.mrns[:0]
+	for _, mrn := range r.mrns {
+		if !mrns[mrn] {
+			kept = append(kept, mrn)
+		}
+	}
+	r.mrns = kept
+}
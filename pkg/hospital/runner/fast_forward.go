@@ -0,0 +1,195 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/Arend-melissant/simhospital/pkg/clock"
+	"github.com/Arend-melissant/simhospital/pkg/rate"
+)
+
+// pathwaySource starts pathways at the rate of a rate Controller in fast-forward mode.
+type pathwaySource struct {
+	group      string
+	controller *rate.Controller
+	start      func() error
+	// next is the time at which the next pathway starts.
+	next time.Time
+}
+
+// runFastForward runs Simulated Hospital in fast-forward mode until h.fastForwardUntil.
+// See fastForward for details.
+//
+// Returns an error if the context is Done.
+func (h *Hospital) runFastForward(ctx context.Context) error {
+	c := h.clock.(*clock.ManualClock)
+	sources := []*pathwaySource{{controller: h.pathwayRateController, start: h.hospital.StartNextPathway}}
+	for _, g := range h.pathwayGroups {
+		g := g
+		start := func() error { return h.hospital.StartNextPathwayFrom(g.Manager) }
+		sources = append(sources, &pathwaySource{group: g.Name, controller: g.RateController, start: start})
+	}
+	return h.fastForward(ctx, c, h.fastForwardUntil, sources, h.maxPathways)
+}
+
+// runBackfill simulates the activity of the hospital between h.backfill.From and the current time,
+// starting pathways at the rate of the backfill, before Simulated Hospital runs in real time.
+// The messages and resources generated during the backfill go to the backfill's Sender and
+// ResourceWriter, if set, which are closed when the backfill finishes. The patients created during the
+// backfill remain in the hospital, and so do the events of the pathways that haven't finished yet, which
+// run in real time after the backfill.
+//
+// Returns an error if the context is Done.
+func (h *Hospital) runBackfill(ctx context.Context) error {
+	var mc *clock.ManualClock
+	var until time.Time
+	switch c := h.clock.(type) {
+	case *clock.ManualClock:
+		mc, until = c, c.Now()
+		c.Set(h.backfill.From)
+	case *clock.SwitchClock:
+		until = c.Now()
+		mc = clock.NewManualClock(h.backfill.From)
+		// Switch to the manual clock now, and back to the previous clock when the backfill finishes.
+		defer c.Switch(c.Switch(mc))
+	}
+
+	restore := h.hospital.SetOutput(h.backfill.Sender, h.backfill.ResourceWriter)
+	defer func() {
+		restore()
+		if h.backfill.Sender != nil {
+			if err := h.backfill.Sender.Close(); err != nil {
+				log.WithError(err).Error("Error when closing the backfill sender")
+			}
+		}
+		if h.backfill.ResourceWriter != nil {
+			if err := h.backfill.ResourceWriter.Close(); err != nil {
+				log.WithError(err).Error("Error when closing the backfill resource writer")
+			}
+		}
+	}()
+
+	log.WithContext(ctx).Infof("Backfilling from %v until %v", h.backfill.From, until)
+	sources := []*pathwaySource{{
+		group:      "backfill",
+		controller: rate.NewController(h.backfill.PathwaysPerHour, time.Hour),
+		start:      h.hospital.StartNextPathway,
+	}}
+	return h.fastForward(ctx, mc, until, sources, -1)
+}
+
+// fastForward runs Simulated Hospital until the given time, with the given manual clock.
+// Instead of sleeping until pathways, events and messages are due, the clock jumps straight to the
+// time the next of them is due, and then starts, runs or processes everything that is due at that time.
+// Pathways start at the same rates as in real time, so the generated data is consistent with a real
+// run between the time the clock is set to when fastForward is called and the given time.
+// fastForward returns when the clock reaches the given time, or earlier if maxPathways is not negative
+// and all the pathways finished.
+//
+// Returns an error if the context is Done.
+func (h *Hospital) fastForward(ctx context.Context, c *clock.ManualClock, until time.Time, sources []*pathwaySource, maxPathways int) error {
+	logLocal := log.WithContext(ctx)
+	logLocal.Infof("Running in fast-forward mode from %v until %v", c.Now(), until)
+
+	now := c.Now()
+	for _, s := range sources {
+		if next, ok := s.controller.TakeRestoredNext(); ok {
+			s.next = next
+		} else {
+			s.next = now.Add(s.controller.Delay(now, s.controller.InitialElapsed()))
+		}
+		s.controller.SetNext(s.next)
+	}
+	creatingPathways := maxPathways != 0
+	counter := &pathwayCounter{max: maxPathways, reached: func() { creatingPathways = false }}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now = c.Now()
+		for _, s := range sources {
+			if !creatingPathways || s.next.After(now) {
+				continue
+			}
+			if !counter.next() {
+				creatingPathways = false
+				break
+			}
+			if err := s.start(); err != nil {
+				logLocal.WithError(err).WithField("pathway_group", s.group).Error("cannot start new pathway")
+			}
+			s.next = now.Add(s.controller.Delay(now, 0))
+			s.controller.SetNext(s.next)
+		}
+		h.runDueItems(ctx)
+
+		next, ok := h.nextItemTime()
+		if creatingPathways {
+			for _, s := range sources {
+				if !ok || s.next.Before(next) {
+					next, ok = s.next, true
+				}
+			}
+		}
+		if !ok {
+			logLocal.Infof("Fast-forward run finished at %v: all the pathways finished", now)
+			return nil
+		}
+		if next.After(until) {
+			c.Set(until)
+			logLocal.Infof("Fast-forward run finished at %v", until)
+			return nil
+		}
+		c.Set(next)
+	}
+}
+
+// runDueItems runs all the events and processes all the messages that are due, including the ones
+// that are created while doing so.
+func (h *Hospital) runDueItems(ctx context.Context) {
+	for {
+		ran, err := h.hospital.RunNextEventIfDue(ctx)
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Error("Failed to run the due event")
+		}
+		processed, err := h.hospital.ProcessNextMessageIfDue()
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Error("Failed to process the due message")
+		}
+		if !ran && !processed {
+			return
+		}
+	}
+}
+
+// nextItemTime returns the earliest time at which an event or a message is due.
+// It returns false if there are no events nor messages.
+func (h *Hospital) nextItemTime() (time.Time, bool) {
+	eventTime, hasEvent := h.hospital.NextEventTime()
+	msgTime, hasMessage := h.hospital.NextMessageTime()
+	switch {
+	case hasEvent && hasMessage && msgTime.Before(eventTime):
+		return msgTime, true
+	case hasEvent:
+		return eventTime, true
+	case hasMessage:
+		return msgTime, true
+	default:
+		return time.Time{}, false
+	}
+}
@@ -17,22 +17,28 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 	"github.com/gorilla/mux"
 	"github.com/Arend-melissant/simhospital/pkg/clock"
+	"github.com/Arend-melissant/simhospital/pkg/diagram"
+	"github.com/Arend-melissant/simhospital/pkg/hl7"
 	"github.com/Arend-melissant/simhospital/pkg/hospital"
 	"github.com/Arend-melissant/simhospital/pkg/hospital/runner/authentication"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
 	"github.com/Arend-melissant/simhospital/pkg/monitoring"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
 	"github.com/Arend-melissant/simhospital/pkg/rate"
 	"github.com/Arend-melissant/simhospital/pkg/read"
+	"github.com/Arend-melissant/simhospital/pkg/reload"
 	"github.com/Arend-melissant/simhospital/pkg/starter"
 )
 
@@ -55,12 +61,46 @@ type APIEndpointAndHandler struct {
 	HTTPMethod string
 }
 
+// PathwayGroup is a group of pathways that start at their own rate, independently of the pathways started
+// at the rate set by Config.PathwaysPerHour and of other groups.
+type PathwayGroup struct {
+	// Name identifies the group in the dashboard endpoints.
+	Name string
+	// Manager picks the next pathway of the group to start.
+	Manager pathway.Manager
+	// RateController controls the rate at which the pathways of the group start.
+	RateController *rate.Controller
+}
+
+// Backfill simulates the past activity of the hospital before Simulated Hospital starts running.
+type Backfill struct {
+	// From is the time at which the simulated activity starts. It runs until the time at which Run is called.
+	From time.Time
+	// PathwaysPerHour is the rate at which pathways start during the backfill.
+	PathwaysPerHour float64
+	// Sender sends the HL7 messages generated during the backfill. It is closed when the backfill finishes.
+	// Optional: if nil, the messages are sent by the hospital's Sender.
+	Sender hl7.Sender
+	// ResourceWriter writes the resources generated during the backfill. It is closed when the backfill finishes.
+	// Optional: if nil, the resources are written by the hospital's ResourceWriter.
+	ResourceWriter hospital.ResourceWriter
+}
+
+// groupStatus is the status of a pathway group, as shown in the dashboard.
+type groupStatus struct {
+	Name   string  `json:"name"`
+	Rate   float64 `json:"rate"`
+	Paused bool    `json:"paused"`
+}
+
 // Hospital wraps the hospital.Hospital and implements the run functionality.
 type Hospital struct {
 	hospital                     *hospital.Hospital
 	readIdController             *read.Controller
 	pathwayRateController        *rate.Controller
 	pathwayStarter               *starter.PathwayStarter
+	pathwayDiagram               *diagram.Controller
+	pathwayGroups                []PathwayGroup
 	additionalDashboardEndpoints []EndpointAndHandler
 	authenticatedEndpoints       []APIEndpointAndHandler
 	authenticatedAPIConfig       APIConfig
@@ -70,7 +110,13 @@ type Hospital struct {
 	metricsAddress               string
 	sleepFor                     time.Duration
 	clock                        clock.Clock
+	fastForwardUntil             time.Time
+	backfill                     *Backfill
 	maxPathways                  int
+	snapshotOutputFile           string
+	retentionInterval            time.Duration
+	eventWorkers                 int
+	configWatcher                *reload.Watcher
 	creatingPathways             chan bool
 	processingEvents             chan bool
 	processingMessages           chan bool
@@ -88,15 +134,31 @@ type Config struct {
 	// AdditionalDashboardEndpoints is a slice of endpoints and their handlers.
 	// The root path for these endpoints will be the Simulated Hospital dashboard address.
 	AdditionalDashboardEndpoints []EndpointAndHandler
-	// AuthenticatedAPIConfig is the API config for authenticated endpoints.
+	// AuthenticatedAPIConfig is the API config for authenticated endpoints. If it is set, the
+	// "snapshot" endpoint to take and restore snapshots is available in the authenticated API.
 	AuthenticatedAPIConfig APIConfig
 	// AuthenticatedEndpoints is a slice of API endpoints and their handlers.
 	// The root path for these endpoints will be the API root path.
 	AuthenticatedEndpoints []APIEndpointAndHandler
 	// PathwayStarter is a starter of pathways through an endpoint.
 	PathwayStarter *starter.PathwayStarter
+	// PathwayDiagram draws diagrams of pathways through an endpoint.
+	// Optional: if not set, the endpoint is not set up.
+	PathwayDiagram *diagram.Controller
 	// PathwaysPerHour indicates how often new pathways are generated.
+	// If RateProfile is set, this is the base rate that the multipliers in the profile apply to.
 	PathwaysPerHour float64
+	// RateProfile describes how the rate at which pathways are generated varies over time.
+	// Optional: if not set, pathways are generated at a constant rate of PathwaysPerHour.
+	RateProfile *rate.Profile
+	// Rand is the source of randomness of the times at which pathways are generated if RateProfile is set.
+	// It should be the same as the hospital's, so that runs with the same seed are reproducible.
+	// Required.
+	Rand *rand.Rand
+	// PathwayGroups are groups of pathways that start at their own rate, in addition to the pathways
+	// generated at the rate set by PathwaysPerHour.
+	// Optional.
+	PathwayGroups []PathwayGroup
 	// MaxPathways is the number of pathways to run before stopping.
 	// If negative, Simulated Hospital will keep running pathways indefinitely.
 	MaxPathways int
@@ -115,6 +177,42 @@ type Config struct {
 	SleepFor time.Duration
 	// Clock is the clock for the hospital.
 	Clock clock.Clock
+	// FastForwardUntil makes Simulated Hospital run in fast-forward mode until the given time.
+	// In fast-forward mode, instead of sleeping until things are due, Clock jumps straight to the time
+	// the next pathway starts or the next event or message is due. The dashboard and the other servers
+	// are not started, and Run returns when Clock reaches FastForwardUntil.
+	// Clock must be a *clock.ManualClock set to the time at which the run starts.
+	// Optional: if not set, Simulated Hospital runs in real time.
+	FastForwardUntil time.Time
+	// Backfill simulates the past activity of the hospital before Simulated Hospital starts running,
+	// in real time or in fast-forward mode. Clock must be a *clock.SwitchClock, or a *clock.ManualClock
+	// in fast-forward mode. The patients created during the backfill can return in later pathways;
+	// see hospital.Config.ReturningPatients.
+	// Optional: if not set, there is no backfill.
+	Backfill *Backfill
+	// Snapshot is restored when the Runner is created, so that Simulated Hospital continues where the
+	// hospital the snapshot was taken from was. It cannot be set together with Backfill.
+	// Optional: if not set, Simulated Hospital starts with the state it was created with.
+	Snapshot *Snapshot
+	// SnapshotOutputFile is the file that a snapshot of the state of Simulated Hospital is written to,
+	// in JSON format, when Run returns.
+	// Optional: if not set, no snapshot is written.
+	SnapshotOutputFile string
+	// RetentionInterval is how often the retention policy of the hospital is applied while Simulated
+	// Hospital runs in real time; see hospital.Hospital.ApplyRetention. The policy is also applied
+	// when Run starts.
+	// Optional: if not set, the retention policy is never applied.
+	RetentionInterval time.Duration
+	// EventWorkers is the number of events that can run concurrently while Simulated Hospital runs in
+	// real time. The events of the same patient always run one after the other, in order.
+	// If greater than 1, custom event processors of the hospital must be safe for concurrent use, and
+	// runs with the same seed are not reproducible.
+	// Optional: if zero or not set, the events run one at a time.
+	EventWorkers int
+	// ConfigWatcher reloads the configuration when its files change, while Simulated Hospital runs
+	// in real time.
+	// Optional: if not set, the configuration is not reloaded when its files change.
+	ConfigWatcher *reload.Watcher
 }
 
 func (c Config) isValid() error {
@@ -127,9 +225,62 @@ func (c Config) isValid() error {
 	if c.DashboardStaticDir == "" {
 		return errors.New("must provide a valid directory path for serving static assets")
 	}
+	if c.Rand == nil {
+		return errors.New("must provide a source of randomness")
+	}
 	if len(c.AuthenticatedEndpoints) != 0 && (c.AuthenticatedAPIConfig.APIKey == "" || c.AuthenticatedAPIConfig.APIPort == "") {
 		return errors.New("must provide API key and port if API endpoints are configured")
 	}
+	names := map[string]bool{}
+	for _, g := range c.PathwayGroups {
+		switch {
+		case g.Name == "":
+			return errors.New("must provide a name for every pathway group")
+		case names[g.Name]:
+			return errors.Errorf("duplicate pathway group name %q", g.Name)
+		case g.Manager == nil || g.RateController == nil:
+			return errors.Errorf("must provide a pathway manager and a rate controller for pathway group %q", g.Name)
+		}
+		names[g.Name] = true
+	}
+	if c.RetentionInterval < 0 {
+		return errors.Errorf("invalid retention interval %v; it must not be negative", c.RetentionInterval)
+	}
+	if c.EventWorkers < 0 {
+		return errors.Errorf("invalid number of event workers %d; it must not be negative", c.EventWorkers)
+	}
+	if c.ConfigWatcher != nil && (c.ConfigWatcher.Reloader == nil || c.ConfigWatcher.Interval <= 0) {
+		return errors.New("must provide a reloader and a positive interval for the config watcher")
+	}
+	if !c.FastForwardUntil.IsZero() {
+		mc, ok := c.Clock.(*clock.ManualClock)
+		if !ok {
+			return errors.New("must provide a manual clock to run in fast-forward mode")
+		}
+		if !c.FastForwardUntil.After(mc.Now()) {
+			return errors.Errorf("the end of the fast-forward run %v must be after the start %v", c.FastForwardUntil, mc.Now())
+		}
+	}
+	if c.Backfill != nil {
+		var now time.Time
+		switch bc := c.Clock.(type) {
+		case *clock.SwitchClock:
+			now = bc.Now()
+		case *clock.ManualClock:
+			now = bc.Now()
+		default:
+			return errors.New("must provide a switch clock, or a manual clock, to backfill")
+		}
+		if !c.Backfill.From.Before(now) {
+			return errors.Errorf("the start of the backfill %v must be before the current time %v", c.Backfill.From, now)
+		}
+		if c.Backfill.PathwaysPerHour < 0 {
+			return errors.Errorf("invalid backfill rate %v; it must not be negative", c.Backfill.PathwaysPerHour)
+		}
+		if c.Snapshot != nil {
+			return errors.New("cannot backfill and restore a snapshot")
+		}
+	}
 	return nil
 }
 
@@ -139,12 +290,13 @@ func New(h *hospital.Hospital, config Config) (*Hospital, error) {
 		return nil, err
 	}
 
-	rand.Seed(time.Now().Unix())
-	return &Hospital{
+	hr := &Hospital{
 		hospital:                     h,
-		pathwayRateController:        rate.NewController(config.PathwaysPerHour, time.Hour),
+		pathwayRateController:        rate.NewControllerWithProfile(config.PathwaysPerHour, time.Hour, config.RateProfile, config.Rand),
 		readIdController:        	  read.NewController(h),
 		pathwayStarter:               config.PathwayStarter,
+		pathwayDiagram:               config.PathwayDiagram,
+		pathwayGroups:                config.PathwayGroups,
 		additionalDashboardEndpoints: config.AdditionalDashboardEndpoints,
 		authenticatedEndpoints:       config.AuthenticatedEndpoints,
 		authenticatedAPIConfig:       config.AuthenticatedAPIConfig,
@@ -154,8 +306,20 @@ func New(h *hospital.Hospital, config Config) (*Hospital, error) {
 		metricsAddress:               config.MetricsAddress,
 		sleepFor:                     config.SleepFor,
 		clock:                        config.Clock,
+		fastForwardUntil:             config.FastForwardUntil,
+		backfill:                     config.Backfill,
 		maxPathways:                  config.MaxPathways,
-	}, nil
+		snapshotOutputFile:           config.SnapshotOutputFile,
+		retentionInterval:            config.RetentionInterval,
+		eventWorkers:                 config.EventWorkers,
+		configWatcher:                config.ConfigWatcher,
+	}
+	if config.Snapshot != nil {
+		if err := hr.Restore(config.Snapshot); err != nil {
+			return nil, errors.Wrap(err, "cannot restore snapshot")
+		}
+	}
+	return hr, nil
 }
 
 // Run starts the Simulated Hospital.
@@ -170,7 +334,28 @@ func New(h *hospital.Hospital, config Config) (*Hospital, error) {
 // are processed. When processing events finishes, we can stop processing messages after all our current
 // messages are processed.
 // If this happens, all servers are stopped and this method returns.
+// If Config.FastForwardUntil was set, Run runs in fast-forward mode instead, without starting any servers.
+// If Config.Backfill was set, the backfill runs first.
+// If Config.SnapshotOutputFile was set, a snapshot is written to it when Run returns.
 func (h *Hospital) Run(ctx context.Context) {
+	if h.snapshotOutputFile != "" {
+		defer h.writeSnapshot(ctx)
+	}
+	if h.backfill != nil {
+		if err := h.runBackfill(ctx); err != nil {
+			log.WithContext(ctx).WithError(err).Error("Simulated Hospital exited with errors during the backfill")
+			return
+		}
+	}
+	if !h.fastForwardUntil.IsZero() {
+		if err := h.runFastForward(ctx); err != nil {
+			log.WithContext(ctx).WithError(err).Error("Simulated Hospital exited with errors")
+			return
+		}
+		log.WithContext(ctx).Info("Simulated Hospital exited")
+		return
+	}
+
 	ctxCancel, cancel := context.WithCancel(ctx)
 	defer cancel()
 	// The groupCtx context is cancelled when:
@@ -227,6 +412,20 @@ func (h *Hospital) Run(ctx context.Context) {
 		}()
 	}
 
+	if h.retentionInterval > 0 {
+		eg.Go(func() error {
+			h.applyRetention(groupCtx)
+			return nil
+		})
+	}
+
+	if h.configWatcher != nil {
+		eg.Go(func() error {
+			h.configWatcher.Run(groupCtx)
+			return nil
+		})
+	}
+
 	// 1. Start the pathways that create the events.
 	eg.Go(func() error {
 		return h.startPathways(groupCtx)
@@ -249,6 +448,36 @@ func (h *Hospital) Run(ctx context.Context) {
 	logLocal.Info("Simulated Hospital exited")
 }
 
+// applyRetention applies the retention policy of the hospital every h.retentionInterval, starting
+// now, until the context is done.
+func (h *Hospital) applyRetention(ctx context.Context) {
+	for {
+		if _, err := h.hospital.ApplyRetention(); err != nil {
+			log.WithContext(ctx).WithError(err).Error("Cannot apply the retention policy")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.retentionInterval):
+		}
+	}
+}
+
+// writeSnapshot writes a snapshot of the current state to h.snapshotOutputFile.
+func (h *Hospital) writeSnapshot(ctx context.Context) {
+	logLocal := log.WithContext(ctx).WithField("file", h.snapshotOutputFile)
+	s, err := h.Snapshot()
+	if err != nil {
+		logLocal.WithError(err).Error("Cannot take snapshot")
+		return
+	}
+	if err := WriteSnapshot(h.snapshotOutputFile, s); err != nil {
+		logLocal.WithError(err).Error("Cannot write snapshot")
+		return
+	}
+	logLocal.Info("Snapshot written")
+}
+
 // Close closes resources held by the Hospital.
 // Should be called if the Hospital is no longer needed or at the program exit.
 func (h *Hospital) Close() error {
@@ -271,14 +500,57 @@ func createHTTPServer(ctx context.Context, addr string, handler http.Handler) *h
 }
 
 // startPathways starts pathways that create events.
+// Pathways are started at the rate of h.pathwayRateController by the hospital's pathway manager, and
+// at the rate of each pathway group by the group's pathway manager, independently of each other.
 // If h.maxPathways is negative, it runs indefinitely. Otherwise, it stops when
-// the number of created pathways is equal to h.maxPathways.
+// the number of pathways created by all groups is equal to h.maxPathways.
 // When startPathways stops, it writes "false" in the h.creatingPathways channel and closes the channel.
+//
+// Returns an error if the context is Done.
+func (h *Hospital) startPathways(ctx context.Context) error {
+	logLocal := log.WithContext(ctx)
+	if h.maxPathways >= 0 {
+		logLocal.Infof("Number of pathways to run: %d (excluding pathways run from the Dashboard)", h.maxPathways)
+	}
+
+	// groupsCtx is cancelled when the maximum number of pathways is reached.
+	groupsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	counter := &pathwayCounter{max: h.maxPathways, reached: cancel}
+	if h.maxPathways == 0 {
+		cancel()
+	}
+	eg, egCtx := errgroup.WithContext(groupsCtx)
+	eg.Go(func() error {
+		return h.startGroupPathways(egCtx, "", h.pathwayRateController, h.hospital.StartNextPathway, counter)
+	})
+	for _, g := range h.pathwayGroups {
+		g := g
+		eg.Go(func() error {
+			start := func() error { return h.hospital.StartNextPathwayFrom(g.Manager) }
+			return h.startGroupPathways(egCtx, g.Name, g.RateController, start, counter)
+		})
+	}
+	eg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if h.creatingPathways != nil {
+		h.creatingPathways <- false
+		close(h.creatingPathways)
+	}
+	logLocal.Info("Pathway generation finished")
+	return nil
+}
+
+// startGroupPathways starts the pathways of a group, calling start at the rate of the given rate Controller,
+// until the context is Done.
 // The delay between running consecutive pathways is derived by the rate Controller,
 // based on the rate.
 // If the rate is initially set to value != 0, then the first pathway
 // is started immediately.
-// If the rate changes, startPathways respects the new value immediately,
+// If the rate changes, startGroupPathways respects the new value immediately,
 // by taking into account the time that has already elapsed since
 // the last pathway run.
 // Eg:
@@ -291,46 +563,86 @@ func createHTTPServer(ctx context.Context, addr string, handler http.Handler) *h
 // - the rate was initially set to 0 pathway / hour (so no pathway was started initially)
 //   and was changed to 1 pathway / hour -> the next pathway will start after 1h elapses
 //   since the beginning of SH running.
+// If the rate controller has a rate profile, the start times follow a Poisson process instead,
+// and every change of the rate or the profile draws a new start time for the next pathway.
+// While the rate controller is paused, no pathways are started.
+// If a snapshot is restored, the next pathway starts at the time it was scheduled to start in the snapshot.
 //
 // Returns an error if the context is Done.
-func (h *Hospital) startPathways(ctx context.Context) error {
-	elapsed := h.pathwayRateController.InitialElapsed()
-	nCreated := 0
+func (h *Hospital) startGroupPathways(ctx context.Context, group string, c *rate.Controller, start func() error, counter *pathwayCounter) error {
+	elapsed := c.InitialElapsed()
 	logLocal := log.WithContext(ctx)
-	if h.maxPathways >= 0 {
-		logLocal.Infof("Number of pathways to run: %d (excluding pathways run from the Dashboard)", h.maxPathways)
+	if group != "" {
+		logLocal = logLocal.WithField("pathway_group", group)
 	}
 
-	for h.maxPathways < 0 || nCreated < h.maxPathways {
-		start := h.clock.Now()
-		delay := h.pathwayRateController.Heartbeat() - elapsed
+	for {
+		now := h.clock.Now()
+		delay := c.Delay(now, elapsed)
+		if next, ok := c.TakeRestoredNext(); ok {
+			// A snapshot was restored: start the next pathway when it was scheduled in the snapshot.
+			delay = next.Sub(now)
+		}
+		c.SetNext(now.Add(delay))
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-h.pathwayRateController.RateChanged():
+		case <-c.RateChanged():
 			// The rate was changed; we might need to generate a new pathway sooner.
-			elapsed += h.clock.Now().Sub(start)
+			elapsed += h.clock.Now().Sub(now)
 			continue
 		case <-time.After(delay):
 			elapsed = time.Duration(0)
-			nCreated++
-			if err := h.hospital.StartNextPathway(); err != nil {
+			if !counter.next() {
+				return nil
+			}
+			if err := start(); err != nil {
 				logLocal.WithError(err).Error("cannot start new pathway")
 			}
 		}
 	}
-	if h.creatingPathways != nil {
-		h.creatingPathways <- false
-		close(h.creatingPathways)
+}
+
+// pathwayCounter counts the pathways started by all the groups.
+type pathwayCounter struct {
+	mu sync.Mutex
+	n  int
+	// max is the maximum number of pathways to start. If negative, there is no maximum.
+	max int
+	// reached is called when the maximum number of pathways is reached.
+	reached func()
+}
+
+// next counts a new pathway, and returns whether it can be started.
+func (c *pathwayCounter) next() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.max >= 0 && c.n >= c.max {
+		return false
 	}
-	logLocal.Info("Pathway generation finished")
-	return nil
+	c.n++
+	if c.n == c.max {
+		c.reached()
+	}
+	return true
 }
 
 // RunEvents runs the events as they are due.
+// If Config.EventWorkers is greater than 1, the events run concurrently in that many workers, and
+// RunEvents waits for the running events to finish before returning.
 // Returns an error if the context is Done.
 func (h *Hospital) RunEvents(ctx context.Context) error {
-	err := h.processItems(ctx, h.hospital.RunNextEventIfDue, h.hospital.HasEvents, h.creatingPathways, h.processingEvents, "Failed to run the due event")
+	runNext, hasEvents := h.hospital.RunNextEventIfDue, h.hospital.HasEvents
+	if h.eventWorkers > 1 {
+		w := startEventWorkers(ctx, h.hospital, h.eventWorkers)
+		defer w.stop()
+		runNext = w.runNextEventIfDue
+		hasEvents = func() bool {
+			// The running events can still queue more events, so they are checked first.
+			return w.running() || h.hospital.HasEvents()
+		}
+	}
+	err := h.processItems(ctx, runNext, hasEvents, h.creatingPathways, h.processingEvents, "Failed to run the due event")
 	if err != nil {
 		return err
 	}
@@ -391,8 +703,9 @@ func (h *Hospital) processItems(ctx context.Context, f func(context.Context) (bo
 	return nil
 }
 
-// setupEndpoints sets up the regular endpoints (pathway rate and pathway starter)
-// plus any additional endpoints in additionalDashboardEndpoints, and returns the http.ServeMux.
+// setupEndpoints sets up the regular endpoints (pathway rate and profile, pathway starter, pathway groups and,
+// if set, pathway diagram) plus any additional endpoints in additionalDashboardEndpoints, and returns the http.ServeMux.
+// Every pathway group has its own endpoints to get and change its rate, profile and whether it is paused.
 // This method always returns a non-nil item.
 func (h *Hospital) setupEndpoints() *http.ServeMux {
 	m := http.NewServeMux()
@@ -400,8 +713,19 @@ func (h *Hospital) setupEndpoints() *http.ServeMux {
 	endpoints := append([]EndpointAndHandler{
 		{Endpoint: "readId", Handler: h.readIdController.ServeHTTP},
 		{Endpoint: "pathwayRate", Handler: h.pathwayRateController.ServeHTTP},
+		{Endpoint: "pathwayRateProfile", Handler: h.pathwayRateController.ServeProfileHTTP},
 		{Endpoint: "pathwayStarter", Handler: h.pathwayStarter.ServeHTTP},
+		{Endpoint: "pathwayGroups", Handler: h.servePathwayGroups},
 	}, h.additionalDashboardEndpoints...)
+	for _, g := range h.pathwayGroups {
+		endpoints = append(endpoints,
+			EndpointAndHandler{Endpoint: fmt.Sprintf("pathwayGroups/%s/rate", g.Name), Handler: g.RateController.ServeHTTP},
+			EndpointAndHandler{Endpoint: fmt.Sprintf("pathwayGroups/%s/profile", g.Name), Handler: g.RateController.ServeProfileHTTP},
+			EndpointAndHandler{Endpoint: fmt.Sprintf("pathwayGroups/%s/paused", g.Name), Handler: g.RateController.ServePauseHTTP})
+	}
+	if h.pathwayDiagram != nil {
+		endpoints = append(endpoints, EndpointAndHandler{Endpoint: "pathwayDiagram", Handler: h.pathwayDiagram.ServeHTTP})
+	}
 	for _, e := range endpoints {
 		log.WithField("root_path", h.dashboardURI).WithField("endpoint", e.Endpoint).Info("Setting up endpoint")
 		m.HandleFunc(fmt.Sprintf("/%s/%s", h.dashboardURI, e.Endpoint), e.Handler)
@@ -409,15 +733,37 @@ func (h *Hospital) setupEndpoints() *http.ServeMux {
 	return m
 }
 
-// setupAuthenticatedEndpoints sets up the authenticated endpoints and returns the mux.Router.
-// If there are no authenticated endpoints, this method returns nil.
+// servePathwayGroups handles the requests to list the pathway groups, with their rates and whether they are
+// paused, in JSON format.
+func (h *Hospital) servePathwayGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+		return
+	}
+	groups := []groupStatus{}
+	for _, g := range h.pathwayGroups {
+		groups = append(groups, groupStatus{Name: g.Name, Rate: g.RateController.Rate(), Paused: g.RateController.Paused()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		log.WithError(err).Error("Failed to write the pathway groups")
+	}
+}
+
+// setupAuthenticatedEndpoints sets up the snapshot endpoint, which exposes and replaces the whole
+// state, plus the endpoints in authenticatedEndpoints, and returns the mux.Router.
+// If the API key or port are not set, this method returns nil.
 func (h *Hospital) setupAuthenticatedEndpoints() *mux.Router {
-	if len(h.authenticatedEndpoints) == 0 {
+	if h.authenticatedAPIConfig.APIKey == "" || h.authenticatedAPIConfig.APIPort == "" {
 		log.Info("No authenticated endpoints to set up")
 		return nil
 	}
+	endpoints := append([]APIEndpointAndHandler{
+		{EndpointAndHandler: EndpointAndHandler{Endpoint: "snapshot", Handler: h.serveSnapshot}, HTTPMethod: "GET"},
+		{EndpointAndHandler: EndpointAndHandler{Endpoint: "snapshot", Handler: h.serveSnapshot}, HTTPMethod: "POST"},
+	}, h.authenticatedEndpoints...)
 	r := mux.NewRouter()
-	for _, e := range h.authenticatedEndpoints {
+	for _, e := range endpoints {
 		log.WithField("root_path", h.apiRootPath()).
 			WithField("endpoint", e.Endpoint).
 			WithField("key_is_set", h.authenticatedAPIConfig.APIKey != "").
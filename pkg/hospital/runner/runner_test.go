@@ -17,15 +17,24 @@ package runner_test
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/Arend-melissant/simhospital/pkg/clock"
 	"github.com/Arend-melissant/simhospital/pkg/hl7"
 	"github.com/Arend-melissant/simhospital/pkg/hospital"
 	. "github.com/Arend-melissant/simhospital/pkg/hospital/runner"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/rate"
+	"github.com/Arend-melissant/simhospital/pkg/reload"
 	"github.com/Arend-melissant/simhospital/pkg/test/testclock"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhl7"
 	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
+	"github.com/Arend-melissant/simhospital/pkg/test/testresource"
 	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
 )
 
@@ -34,6 +43,34 @@ const (
 	validDashboardAddress = ":8000"
 )
 
+// testPathway is an arbitrary pathway that sends 4 (arbitrary number) messages.
+// This pathway refers to values in the files in the pkg/test/data package,
+// more specifically the order profiles and the locations.
+// The tests that use it will fail if those values change.
+const testPathway = `
+test_pathway:
+  historical_data:
+    - result:
+        order_profile: UREA AND ELECTROLYTES
+        results:
+          - test_name: Creatinine
+            value: 126.00
+            unit: UMOLL
+            abnormal_flag: HIGH
+      parameters:
+        time_from_now: -48h
+  pathway:
+    - admission:
+        loc: Renal
+    - result:
+        order_profile: UREA AND ELECTROLYTES
+        results:
+          - test_name: Creatinine
+            value: 153.00
+            unit: UMOLL
+            abnormal_flag: HIGH
+    - discharge: {}`
+
 var (
 	testAPIHandler            = func(_ http.ResponseWriter, _ *http.Request) {}
 	testEndpointAndHandler    = EndpointAndHandler{Endpoint: "testEndpoint", Handler: testAPIHandler}
@@ -51,6 +88,7 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:           nonEmptyString,
 			DashboardAddress:       validDashboardAddress,
 			DashboardStaticDir:     nonEmptyString,
+			Rand:                   rand.New(rand.NewSource(1)),
 			AuthenticatedEndpoints: []APIEndpointAndHandler{testAPIEndpointAndHandler},
 			AuthenticatedAPIConfig: APIConfig{APIPort: nonEmptyString, APIKey: nonEmptyString},
 		},
@@ -61,6 +99,7 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:           nonEmptyString,
 			DashboardAddress:       validDashboardAddress,
 			DashboardStaticDir:     nonEmptyString,
+			Rand:                   rand.New(rand.NewSource(1)),
 			AuthenticatedEndpoints: []APIEndpointAndHandler{},
 			AuthenticatedAPIConfig: APIConfig{APIPort: nonEmptyString, APIKey: nonEmptyString},
 		},
@@ -71,6 +110,7 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:           nonEmptyString,
 			DashboardAddress:       validDashboardAddress,
 			DashboardStaticDir:     nonEmptyString,
+			Rand:                   rand.New(rand.NewSource(1)),
 			AuthenticatedEndpoints: []APIEndpointAndHandler{testAPIEndpointAndHandler},
 			AuthenticatedAPIConfig: APIConfig{},
 		},
@@ -81,6 +121,7 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:           nonEmptyString,
 			DashboardAddress:       validDashboardAddress,
 			DashboardStaticDir:     nonEmptyString,
+			Rand:                   rand.New(rand.NewSource(1)),
 			AuthenticatedEndpoints: []APIEndpointAndHandler{testAPIEndpointAndHandler},
 			AuthenticatedAPIConfig: APIConfig{APIKey: nonEmptyString},
 		},
@@ -91,6 +132,7 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:           nonEmptyString,
 			DashboardAddress:       validDashboardAddress,
 			DashboardStaticDir:     nonEmptyString,
+			Rand:                   rand.New(rand.NewSource(1)),
 			AuthenticatedEndpoints: []APIEndpointAndHandler{testAPIEndpointAndHandler},
 			AuthenticatedAPIConfig: APIConfig{APIPort: nonEmptyString},
 		},
@@ -101,6 +143,7 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:       nonEmptyString,
 			DashboardAddress:   validDashboardAddress,
 			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
 		},
 		wantErr: false,
 	}, {
@@ -108,6 +151,7 @@ func TestNewRunner(t *testing.T) {
 		config: Config{
 			DashboardAddress:   validDashboardAddress,
 			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
 		},
 		wantErr: true,
 	}, {
@@ -115,6 +159,7 @@ func TestNewRunner(t *testing.T) {
 		config: Config{
 			DashboardURI:       nonEmptyString,
 			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
 		},
 		wantErr: true,
 	}, {
@@ -123,6 +168,161 @@ func TestNewRunner(t *testing.T) {
 			DashboardURI:       nonEmptyString,
 			DashboardAddress:   nonEmptyString,
 			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+		},
+		wantErr: true,
+	}, {
+		name: "valid pathway groups",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			PathwayGroups: []PathwayGroup{
+				{Name: "group1", Manager: &pathway.DeterministicManager{}, RateController: rate.NewController(1, time.Hour)},
+				{Name: "group2", Manager: &pathway.DeterministicManager{}, RateController: rate.NewController(1, time.Hour)},
+			},
+		},
+		wantErr: false,
+	}, {
+		name: "pathway group without name",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			PathwayGroups: []PathwayGroup{
+				{Manager: &pathway.DeterministicManager{}, RateController: rate.NewController(1, time.Hour)},
+			},
+		},
+		wantErr: true,
+	}, {
+		name: "duplicate pathway group names",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			PathwayGroups: []PathwayGroup{
+				{Name: "group1", Manager: &pathway.DeterministicManager{}, RateController: rate.NewController(1, time.Hour)},
+				{Name: "group1", Manager: &pathway.DeterministicManager{}, RateController: rate.NewController(1, time.Hour)},
+			},
+		},
+		wantErr: true,
+	}, {
+		name: "pathway group without rate controller",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			PathwayGroups: []PathwayGroup{
+				{Name: "group1", Manager: &pathway.DeterministicManager{}},
+			},
+		},
+		wantErr: true,
+	}, {
+		name: "valid fast-forward run",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			Clock:              clock.NewManualClock(time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)),
+			FastForwardUntil:   time.Date(2020, 3, 12, 0, 0, 0, 0, time.UTC),
+		},
+		wantErr: false,
+	}, {
+		name: "fast-forward run without a manual clock",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			Clock:              testclock.New(time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)),
+			FastForwardUntil:   time.Date(2020, 3, 12, 0, 0, 0, 0, time.UTC),
+		},
+		wantErr: true,
+	}, {
+		name: "fast-forward run that ends before it starts",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			Clock:              clock.NewManualClock(time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)),
+			FastForwardUntil:   time.Date(2020, 1, 12, 0, 0, 0, 0, time.UTC),
+		},
+		wantErr: true,
+	}, {
+		name: "valid backfill",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			Clock:              clock.NewSwitchClock(&clock.RealTimeClock{}),
+			Backfill:           &Backfill{From: time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC), PathwaysPerHour: 1},
+		},
+		wantErr: false,
+	}, {
+		name: "backfill without a switch clock",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			Clock:              &clock.RealTimeClock{},
+			Backfill:           &Backfill{From: time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC), PathwaysPerHour: 1},
+		},
+		wantErr: true,
+	}, {
+		name: "backfill from the future",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			Clock:              clock.NewManualClock(time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)),
+			Backfill:           &Backfill{From: time.Date(2020, 3, 12, 0, 0, 0, 0, time.UTC), PathwaysPerHour: 1},
+		},
+		wantErr: true,
+	}, {
+		name: "negative retention interval",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			RetentionInterval:  -time.Hour,
+		},
+		wantErr: true,
+	}, {
+		name: "negative event workers",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			EventWorkers:       -1,
+		},
+		wantErr: true,
+	}, {
+		name: "config watcher without reloader and interval",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			ConfigWatcher:      &reload.Watcher{},
+		},
+		wantErr: true,
+	}, {
+		name: "missing Rand",
+		config: Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   validDashboardAddress,
+			DashboardStaticDir: nonEmptyString,
 		},
 		wantErr: true,
 	}, {
@@ -147,33 +347,7 @@ func TestNewRunner(t *testing.T) {
 // This test only covers the case when Run() stops.
 func TestRunner_Run(t *testing.T) {
 	ctx := context.Background()
-	// test_pathway is an arbitrary pathway that sends 4 (arbitrary number) messages.
-	// This pathway refers to values in the files in the pkg/test/data package,
-	// more specifically the order profiles and the locations.
-	// This test will fail if those values change.
-	b := []byte(`
-test_pathway:
-  historical_data:
-    - result:
-        order_profile: UREA AND ELECTROLYTES
-        results:
-          - test_name: Creatinine
-            value: 126.00
-            unit: UMOLL
-            abnormal_flag: HIGH
-      parameters:
-        time_from_now: -48h
-  pathway:
-    - admission:
-        loc: Renal
-    - result:
-        order_profile: UREA AND ELECTROLYTES
-        results:
-          - test_name: Creatinine
-            value: 153.00
-            unit: UMOLL
-            abnormal_flag: HIGH
-    - discharge: {}`)
+	b := []byte(testPathway)
 	mainDir := testwrite.BytesToDir(t, b, "pathway.yml")
 
 	hl7.TimezoneAndLocation("Europe/London")
@@ -207,9 +381,155 @@ test_pathway:
 				DashboardURI:       nonEmptyString,
 				DashboardAddress:   ":0000",
 				DashboardStaticDir: nonEmptyString,
+				Rand:               rand.New(rand.NewSource(1)),
+				MaxPathways:        tc.maxPathways,
+				PathwaysPerHour:    3600, // Create the pathways quickly.
+				Clock:              clock,
+				// Applying the retention policy doesn't prevent Run from returning.
+				RetentionInterval: time.Hour,
+			}
+
+			runner, err := New(h.Hospital, config)
+			if err != nil {
+				t.Fatalf("New(%+v) failed with %v", config, err)
+			}
+			runner.Run(context.Background())
+			messages := h.Sender.GetSentMessages()
+			if got, want := len(messages), tc.wantMessages; got != want {
+				t.Errorf("h.Sender.GetSentMessages() got %d messages, want %v", got, want)
+			}
+		})
+	}
+}
+
+// This test only covers the case when Run() stops.
+func TestRunner_RunEventWorkers(t *testing.T) {
+	ctx := context.Background()
+	mainDir := testwrite.BytesToDir(t, []byte(testPathway), "pathway.yml")
+
+	hl7.TimezoneAndLocation("Europe/London")
+	// now is an arbitrary date in the past.
+	now := time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)
+
+	args := testhospital.Arguments
+	args.PathwayArguments.Dir = mainDir
+	args.PathwayArguments.Names = []string{"test_pathway"}
+
+	tests := []struct {
+		workers     int
+		maxPathways int
+	}{
+		{workers: 1, maxPathways: 3},
+		{workers: 4, maxPathways: 3},
+		{workers: 4, maxPathways: 5},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d workers, %d pathways", tc.workers, tc.maxPathways), func(t *testing.T) {
+			clock := testclock.WithTick(now, time.Second)
+
+			h := testhospital.New(ctx, t, testhospital.Config{
+				Config:    hospital.Config{Clock: clock},
+				Arguments: args,
+			})
+			defer h.Close()
+
+			config := Config{
+				DashboardURI:       nonEmptyString,
+				DashboardAddress:   ":0000",
+				DashboardStaticDir: nonEmptyString,
+				Rand:               rand.New(rand.NewSource(1)),
 				MaxPathways:        tc.maxPathways,
 				PathwaysPerHour:    3600, // Create the pathways quickly.
 				Clock:              clock,
+				EventWorkers:       tc.workers,
+			}
+
+			runner, err := New(h.Hospital, config)
+			if err != nil {
+				t.Fatalf("New(%+v) failed with %v", config, err)
+			}
+			runner.Run(context.Background())
+			messages := h.Sender.GetSentMessages()
+			if got, want := len(messages), 4*tc.maxPathways; got != want {
+				t.Errorf("h.Sender.GetSentMessages() got %d messages, want %v", got, want)
+			}
+
+			// The messages of every patient are sent in the order of the steps of the pathway.
+			got := map[string][]string{}
+			for _, m := range messages {
+				mrn := testhl7.PID(t, m).PatientIdentifierList[0].IDNumber.String()
+				msh := testhl7.MSH(t, m)
+				got[mrn] = append(got[mrn], fmt.Sprintf("%s^%s", msh.MessageType.MessageCode, msh.MessageType.TriggerEvent))
+			}
+			if got, want := len(got), tc.maxPathways; got != want {
+				t.Errorf("got messages for %d patients, want %d", got, want)
+			}
+			want := []string{"ORU^R01", "ADT^A01", "ORU^R01", "ADT^A03"}
+			for mrn, types := range got {
+				if diff := cmp.Diff(want, types); diff != "" {
+					t.Errorf("message types of patient %s -want, +got:\n%s", mrn, diff)
+				}
+			}
+		})
+	}
+}
+
+// This test only covers the case when Run() stops.
+func TestRunner_RunPathwayGroups(t *testing.T) {
+	ctx := context.Background()
+	mainDir := testwrite.BytesToDir(t, []byte(testPathway), "pathway.yml")
+
+	hl7.TimezoneAndLocation("Europe/London")
+	// now is an arbitrary date in the past.
+	now := time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)
+
+	args := testhospital.Arguments
+	args.PathwayArguments.Dir = mainDir
+	args.PathwayArguments.Names = []string{"test_pathway"}
+
+	tests := []struct {
+		maxPathways  int
+		wantMessages int
+	}{
+		{maxPathways: 0, wantMessages: 0},
+		{maxPathways: 1, wantMessages: 4},
+		{maxPathways: 3, wantMessages: 12},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d", tc.maxPathways), func(t *testing.T) {
+			clock := testclock.WithTick(now, time.Second)
+
+			h := testhospital.New(ctx, t, testhospital.Config{
+				Config:    hospital.Config{Clock: clock},
+				Arguments: args,
+			})
+			defer h.Close()
+
+			pathways, err := h.Parser.ParsePathways(ctx, mainDir)
+			if err != nil {
+				t.Fatalf("ParsePathways(%q) failed with %v", mainDir, err)
+			}
+			m, err := pathway.NewDistributionManager(pathways, nil, nil, rand.New(rand.NewSource(1)))
+			if err != nil {
+				t.Fatalf("NewDistributionManager(%v, nil, nil) failed with %v", pathways, err)
+			}
+
+			config := Config{
+				DashboardURI:       nonEmptyString,
+				DashboardAddress:   ":0000",
+				DashboardStaticDir: nonEmptyString,
+				Rand:               rand.New(rand.NewSource(1)),
+				MaxPathways:        tc.maxPathways,
+				// Only the pathway groups start pathways.
+				PathwaysPerHour: 0,
+				PathwayGroups: []PathwayGroup{
+					// Create the pathways quickly.
+					{Name: "running", Manager: m, RateController: rate.NewController(3600, time.Hour)},
+					{Name: "paused", Manager: m, RateController: rate.Group{PathwaysPerHour: 3600, Paused: true}.Controller(nil)},
+				},
+				Clock: clock,
 			}
 
 			runner, err := New(h.Hospital, config)
@@ -224,3 +544,206 @@ test_pathway:
 		})
 	}
 }
+
+func TestRunner_RunFastForward(t *testing.T) {
+	ctx := context.Background()
+	mainDir := testwrite.BytesToDir(t, []byte(testPathway), "pathway.yml")
+
+	hl7.TimezoneAndLocation("Europe/London")
+	// now is an arbitrary date in the past.
+	now := time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)
+	until := now.Add(10 * time.Hour)
+
+	tests := []struct {
+		name         string
+		maxPathways  int
+		wantMessages int
+		wantEnd      time.Time
+	}{{
+		name:        "until the end",
+		maxPathways: -1,
+		// One pathway every hour, both at the start and at the end of the run.
+		wantMessages: 44,
+		wantEnd:      until,
+	}, {
+		name:         "until the pathways finish",
+		maxPathways:  2,
+		wantMessages: 8,
+		wantEnd:      now.Add(time.Hour),
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := clock.NewManualClock(now)
+			h, sender := manualClockHospital(ctx, t, c, mainDir, 0, 0)
+			defer h.Close()
+
+			config := Config{
+				DashboardURI:       nonEmptyString,
+				DashboardAddress:   ":0000",
+				DashboardStaticDir: nonEmptyString,
+				Rand:               rand.New(rand.NewSource(1)),
+				MaxPathways:        tc.maxPathways,
+				PathwaysPerHour:    1,
+				Clock:              c,
+				FastForwardUntil:   until,
+			}
+			runner, err := New(h, config)
+			if err != nil {
+				t.Fatalf("New(%+v) failed with %v", config, err)
+			}
+			runner.Run(ctx)
+
+			if got, want := len(sender.GetSentMessages()), tc.wantMessages; got != want {
+				t.Errorf("sender.GetSentMessages() got %d messages, want %v", got, want)
+			}
+			if got := c.Now(); !got.Equal(tc.wantEnd) {
+				t.Errorf("c.Now() after Run() got %v, want %v", got, tc.wantEnd)
+			}
+		})
+	}
+}
+
+func TestRunner_RunBackfill(t *testing.T) {
+	ctx := context.Background()
+	mainDir := testwrite.BytesToDir(t, []byte(testPathway), "pathway.yml")
+
+	hl7.TimezoneAndLocation("Europe/London")
+	// now is an arbitrary date in the past.
+	now := time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)
+
+	c := clock.NewManualClock(now)
+	h, sender := manualClockHospital(ctx, t, c, mainDir, 1, 0)
+	defer h.Close()
+	backfillSender := &testhl7.Sender{}
+
+	config := Config{
+		DashboardURI:       nonEmptyString,
+		DashboardAddress:   ":0000",
+		DashboardStaticDir: nonEmptyString,
+		Rand:               rand.New(rand.NewSource(1)),
+		MaxPathways:        1,
+		PathwaysPerHour:    1,
+		Clock:              c,
+		FastForwardUntil:   now.Add(time.Hour),
+		Backfill: &Backfill{
+			From:            now.Add(-10 * time.Hour),
+			PathwaysPerHour: 1,
+			Sender:          backfillSender,
+		},
+	}
+	runner, err := New(h, config)
+	if err != nil {
+		t.Fatalf("New(%+v) failed with %v", config, err)
+	}
+	runner.Run(ctx)
+
+	// One pathway every hour, both at the start and at the end of the backfill.
+	if got, want := len(backfillSender.GetSentMessages()), 44; got != want {
+		t.Errorf("backfillSender.GetSentMessages() got %d messages, want %v", got, want)
+	}
+	liveMessages := sender.GetSentMessages()
+	if got, want := len(liveMessages), 4; got != want {
+		t.Fatalf("sender.GetSentMessages() got %d messages, want %v", got, want)
+	}
+	// The live pathway is for a patient created during the backfill.
+	mrn := testhl7.PID(t, liveMessages[0]).PatientIdentifierList[0].IDNumber.String()
+	var found bool
+	for _, m := range backfillSender.GetSentMessages() {
+		if testhl7.PID(t, m).PatientIdentifierList[0].IDNumber.String() == mrn {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("the live pathway is for patient %s, want a patient created during the backfill", mrn)
+	}
+}
+
+func TestRunner_RunFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	mainDir := testwrite.BytesToDir(t, []byte(testPathway), "pathway.yml")
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+
+	hl7.TimezoneAndLocation("Europe/London")
+	// now is an arbitrary date in the past.
+	now := time.Date(2020, 2, 12, 0, 0, 0, 0, time.UTC)
+	middle := now.Add(5*time.Hour + 30*time.Minute)
+	until := now.Add(10 * time.Hour)
+	const seed = 1
+
+	run := func(h *hospital.Hospital, c *clock.ManualClock, until time.Time, snapshot *Snapshot, snapshotOutputFile string) {
+		t.Helper()
+		config := Config{
+			DashboardURI:       nonEmptyString,
+			DashboardAddress:   ":0000",
+			DashboardStaticDir: nonEmptyString,
+			Rand:               rand.New(rand.NewSource(1)),
+			MaxPathways:        -1,
+			PathwaysPerHour:    1,
+			Clock:              c,
+			FastForwardUntil:   until,
+			Snapshot:           snapshot,
+			SnapshotOutputFile: snapshotOutputFile,
+		}
+		runner, err := New(h, config)
+		if err != nil {
+			t.Fatalf("New(%+v) failed with %v", config, err)
+		}
+		runner.Run(ctx)
+	}
+
+	// A run from now until the end.
+	c := clock.NewManualClock(now)
+	h, sender := manualClockHospital(ctx, t, c, mainDir, 0, seed)
+	defer h.Close()
+	run(h, c, until, nil, "")
+	want := sender.GetSentMessages()
+
+	// The same run, stopped in the middle and continued from a snapshot in a hospital with a different seed.
+	c = clock.NewManualClock(now)
+	h, sender = manualClockHospital(ctx, t, c, mainDir, 0, seed)
+	defer h.Close()
+	run(h, c, middle, nil, snapshotFile)
+	got := sender.GetSentMessages()
+
+	snapshot, err := ReadSnapshot(ctx, snapshotFile)
+	if err != nil {
+		t.Fatalf("ReadSnapshot(%q) failed with %v", snapshotFile, err)
+	}
+	if got := snapshot.Hospital.Time; !got.Equal(middle) {
+		t.Errorf("snapshot.Hospital.Time got %v, want %v", got, middle)
+	}
+	c = clock.NewManualClock(snapshot.Hospital.Time)
+	h, sender = manualClockHospital(ctx, t, c, mainDir, 0, seed+1)
+	defer h.Close()
+	run(h, c, until, snapshot, "")
+	got = append(got, sender.GetSentMessages()...)
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("messages of the run continued from a snapshot diff (-want, +got):\n%s", diff)
+	}
+}
+
+// manualClockHospital creates a hospital that runs the pathways in the given directory with the given clock
+// and seed, and returns it along with its sender.
+func manualClockHospital(ctx context.Context, t *testing.T, c *clock.ManualClock, dir string, returningPatients float64, seed int64) (*hospital.Hospital, *testhl7.Sender) {
+	t.Helper()
+	args := testhospital.Arguments
+	args.Seed = seed
+	args.PathwayArguments = &hospital.PathwayArguments{Dir: dir, Type: "distribution", Names: []string{"test_pathway"}}
+	args.Clock = c
+	args.ReturningPatients = returningPatients
+	hc, err := hospital.DefaultConfig(ctx, args)
+	if err != nil {
+		t.Fatalf("hospital.DefaultConfig(%+v) failed with %v", args, err)
+	}
+	sender := &testhl7.Sender{}
+	hc.Sender = sender
+	hc.ResourceWriter = testresource.NewWriter()
+	h, err := hospital.NewHospital(ctx, hc)
+	if err != nil {
+		t.Fatalf("hospital.NewHospital() failed with %v", err)
+	}
+	return h, sender
+}
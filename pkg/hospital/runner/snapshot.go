@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/rate"
+)
+
+// Snapshot is the complete state of a running Simulated Hospital: the state of the hospital, and the
+// rates at which pathways start, which can be changed from the dashboard.
+type Snapshot struct {
+	// Hospital is the state of the hospital.
+	Hospital *hospital.Snapshot
+	// PathwayRate is the state of the controller of the rate set by Config.PathwaysPerHour.
+	PathwayRate rate.State
+	// PathwayGroups are the states of the rate controllers of the pathway groups, indexed by group name.
+	PathwayGroups map[string]rate.State
+}
+
+// ReadSnapshot reads a snapshot from the given file, in JSON format.
+func ReadSnapshot(ctx context.Context, fileName string) (*Snapshot, error) {
+	b, err := files.Read(ctx, fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read snapshot file %s", fileName)
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal snapshot file %s", fileName)
+	}
+	return s, nil
+}
+
+// WriteSnapshot writes the given snapshot to the given file, in JSON format.
+func WriteSnapshot(fileName string, s *Snapshot) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal snapshot")
+	}
+	if err := ioutil.WriteFile(fileName, b, 0644); err != nil {
+		return errors.Wrapf(err, "cannot write snapshot file %s", fileName)
+	}
+	return nil
+}
+
+// Snapshot returns the current state of Simulated Hospital.
+func (h *Hospital) Snapshot() (*Snapshot, error) {
+	hs, err := h.hospital.Snapshot()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot take snapshot of the hospital")
+	}
+	s := &Snapshot{Hospital: hs, PathwayGroups: map[string]rate.State{}}
+	if s.PathwayRate, err = h.pathwayRateController.State(); err != nil {
+		return nil, errors.Wrap(err, "cannot get the state of the pathway rate")
+	}
+	for _, g := range h.pathwayGroups {
+		if s.PathwayGroups[g.Name], err = g.RateController.State(); err != nil {
+			return nil, errors.Wrapf(err, "cannot get the state of the rate of pathway group %q", g.Name)
+		}
+	}
+	return s, nil
+}
+
+// Restore replaces the state of Simulated Hospital with the given snapshot.
+// See hospital.Hospital.Restore for details.
+// Restore returns an error if the snapshot has pathway groups that Simulated Hospital doesn't have,
+// or if any of its parts is not valid; in this case, the state doesn't change.
+// Pathway groups that are not in the snapshot keep their state.
+func (h *Hospital) Restore(s *Snapshot) error {
+	if s.Hospital == nil {
+		return errors.New("the snapshot doesn't have the state of the hospital")
+	}
+	if err := s.PathwayRate.Validate(); err != nil {
+		return errors.Wrap(err, "cannot restore the pathway rate")
+	}
+	groups := map[string]*rate.Controller{}
+	for _, g := range h.pathwayGroups {
+		groups[g.Name] = g.RateController
+	}
+	for name, st := range s.PathwayGroups {
+		if _, ok := groups[name]; !ok {
+			return errors.Errorf("unknown pathway group %q in the snapshot", name)
+		}
+		if err := st.Validate(); err != nil {
+			return errors.Wrapf(err, "cannot restore the rate of pathway group %q", name)
+		}
+	}
+	// The hospital validates its own state before changing anything, and the rates can't fail
+	// once they are validated.
+	if err := h.hospital.Restore(s.Hospital); err != nil {
+		return errors.Wrap(err, "cannot restore the hospital")
+	}
+	if err := h.pathwayRateController.Restore(s.PathwayRate); err != nil {
+		return errors.Wrap(err, "cannot restore the pathway rate")
+	}
+	for name, st := range s.PathwayGroups {
+		if err := groups[name].Restore(st); err != nil {
+			return errors.Wrapf(err, "cannot restore the rate of pathway group %q", name)
+		}
+	}
+	return nil
+}
+
+// serveSnapshot handles the requests to take and restore snapshots from the authenticated API.
+// GET requests return a snapshot of the current state in JSON format.
+// POST requests restore the snapshot in the body, in JSON format.
+func (h *Hospital) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s, err := h.Snapshot()
+		if err != nil {
+			log.WithError(err).Error("Failed to take a snapshot")
+			http.Error(w, fmt.Sprintf("Error taking snapshot: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="snapshot.json"`)
+		if err := json.NewEncoder(w).Encode(s); err != nil {
+			log.WithError(err).Error("Failed to write the snapshot")
+		}
+	case "POST":
+		defer r.Body.Close()
+		s := &Snapshot{}
+		if err := json.NewDecoder(r.Body).Decode(s); err != nil {
+			log.WithError(err).Warning("Failed to restore snapshot")
+			http.Error(w, fmt.Sprintf("Error unmarshalling snapshot: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := h.Restore(s); err != nil {
+			log.WithError(err).Warning("Failed to restore snapshot")
+			http.Error(w, fmt.Sprintf("Error restoring snapshot: %v", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+)
+
+// eventWorkers runs events in a pool of goroutines. The events of the same patient run one after the
+// other, in the order in which they were dispatched, while the events of different patients run
+// concurrently.
+type eventWorkers struct {
+	hospital *hospital.Hospital
+	events   chan *dispatchedEvent
+	wg       sync.WaitGroup
+	// pending is the number of events that have been dispatched but haven't finished running.
+	// It is accessed atomically.
+	pending int64
+	// mu guards last.
+	mu sync.Mutex
+	// last maps the MRN of every patient with events that haven't finished running to the last of
+	// those events.
+	last map[string]*dispatchedEvent
+}
+
+// dispatchedEvent is an event that has been dispatched to the workers.
+type dispatchedEvent struct {
+	*hospital.DueEvent
+	patients []string
+	// after are the done channels of the events of the same patients dispatched earlier, which must
+	// finish before this one runs.
+	after []chan struct{}
+	// done is closed when the event finishes running.
+	done chan struct{}
+}
+
+// startEventWorkers starts n workers that run the events of the given hospital with the given context.
+// The workers must be stopped with stop.
+func startEventWorkers(ctx context.Context, h *hospital.Hospital, n int) *eventWorkers {
+	w := &eventWorkers{
+		hospital: h,
+		events:   make(chan *dispatchedEvent),
+		last:     map[string]*dispatchedEvent{},
+	}
+	w.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer w.wg.Done()
+			for e := range w.events {
+				w.run(ctx, e)
+			}
+		}()
+	}
+	return w
+}
+
+// runNextEventIfDue dispatches the next event to the workers, if it is due.
+// Returns true if there was an event to dispatch, false otherwise.
+func (w *eventWorkers) runNextEventIfDue(_ context.Context) (bool, error) {
+	e, err := w.hospital.NextDueEvent()
+	if e == nil || err != nil {
+		return false, err
+	}
+	d := &dispatchedEvent{DueEvent: e, patients: e.Patients(), done: make(chan struct{})}
+	w.mu.Lock()
+	for _, mrn := range d.patients {
+		if prev, ok := w.last[mrn]; ok {
+			d.after = append(d.after, prev.done)
+		}
+		w.last[mrn] = d
+	}
+	w.mu.Unlock()
+	atomic.AddInt64(&w.pending, 1)
+	// The events are taken by the workers in the order they are dispatched, so the events that this
+	// one waits for have always been taken by other workers already, and the workers cannot deadlock.
+	w.events <- d
+	return true, nil
+}
+
+// run runs the given event once the events it has to wait for have finished.
+func (w *eventWorkers) run(ctx context.Context, e *dispatchedEvent) {
+	for _, done := range e.after {
+		<-done
+	}
+	w.hospital.RunEvent(ctx, e.DueEvent)
+	w.mu.Lock()
+	for _, mrn := range e.patients {
+		if w.last[mrn] == e {
+			delete(w.last, mrn)
+		}
+	}
+	w.mu.Unlock()
+	close(e.done)
+	atomic.AddInt64(&w.pending, -1)
+}
+
+// running returns whether there are events that have been dispatched but haven't finished running.
+// The events that are queued by those events are in the hospital's event queue once running returns false.
+func (w *eventWorkers) running() bool {
+	return atomic.LoadInt64(&w.pending) > 0
+}
+
+// stop waits for all the dispatched events to finish running and stops the workers.
+func (w *eventWorkers) stop() {
+	close(w.events)
+	w.wg.Wait()
+}
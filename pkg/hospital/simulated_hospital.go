@@ -17,11 +17,14 @@ package hospital
 
 import (
 	"context"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/protobuf/encoding/prototext"
+	"github.com/Arend-melissant/simhospital/pkg/calendar"
 	"github.com/Arend-melissant/simhospital/pkg/clock"
 	"github.com/Arend-melissant/simhospital/pkg/config"
 	"github.com/Arend-melissant/simhospital/pkg/doctor"
@@ -39,6 +42,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/orderprofile"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
 	"github.com/Arend-melissant/simhospital/pkg/processor"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 	"github.com/Arend-melissant/simhospital/pkg/resource/cloud"
 	"github.com/Arend-melissant/simhospital/pkg/resource"
 	"github.com/Arend-melissant/simhospital/pkg/state/persist"
@@ -69,6 +73,18 @@ const (
 	unknown = "unknown"
 )
 
+// Policies for admissions to locations that are full, i.e., whose beds, and the beds in their overflow
+// locations, are all occupied.
+const (
+	// FullWardFail makes the admission fail, which stops the pathway. This is the default.
+	FullWardFail = "fail"
+	// FullWardWait makes the admission wait until a bed is freed. Meanwhile, the patient boards in the ED.
+	FullWardWait = "wait"
+	// FullWardPending is like FullWardWait, and also sends a pending admission (ADT^A14) message
+	// when the admission starts waiting.
+	FullWardPending = "pending"
+)
+
 var (
 	log = logging.ForCallerPackage()
 
@@ -85,11 +101,14 @@ var (
 			PathwayDurationMinutes   *prometheus.HistogramVec `help:"Duration (minutes) of the generated pathway, by pathway name" labels:"pathway_name" buckets:"1,5,10,30,60,180,720,1440,2880"`
 			AdmissionDurationMinutes *prometheus.HistogramVec `help:"Duration (minutes) of the admissions in the generated pathways, by pathway name" labels:"pathway_name" buckets:"1,5,10,30,60,180,720,1440,2880"`
 			MessageDelaySeconds      prometheus.Histogram     `help:"Difference, in seconds, between the time a message was expected to be sent, and the time when it was really sent" buckets:"1,5,10,30,60,180"`
+			WaitingForBed            *prometheus.GaugeVec     `help:"Number of admissions waiting for a bed, by location" labels:"poc"`
+			BedWaitMinutes           *prometheus.HistogramVec `help:"Time (minutes) that admissions waited for a bed, by location" labels:"poc" buckets:"10,30,60,180,360,720,1440,2880"`
 		}
 	}
 )
 
 // EventProcessor defines a processor of events that is run before, instead of or after the standard event processing logic.
+// If events run concurrently, see NextDueEvent, the processors must be safe for concurrent use.
 type EventProcessor interface {
 	// Process processes the given event and returns any HL7 messages that must be sent as the result of the processing, if any.
 	Process(*state.Event, *ir.PatientInfo, *processor.Config) ([]*message.HL7Message, error)
@@ -136,6 +155,21 @@ type Arguments struct {
 	// Also required to create Config.PathwayParser and Config.PathwayManager.
 	OrderProfilesFile *string
 
+	// CalendarFile to create Config.Calendar.
+	CalendarFile *string
+
+	// FullWardPolicy to set as Config.FullWardPolicy.
+	FullWardPolicy string
+
+	// ReturningPatients to set as Config.ReturningPatients.
+	ReturningPatients float64
+
+	// Retention to set as Config.Retention.
+	Retention RetentionPolicy
+
+	// Seed to create Config.Rand. If 0, a seed based on the current time is used.
+	Seed int64
+
 	// ResourceArguments to create ResourceWriter.
 	ResourceArguments *ResourceArguments
 
@@ -238,10 +272,15 @@ type Config struct {
 	// OrderProfiles are the order profiles to be used in pathways.
 	OrderProfiles *orderprofile.OrderProfiles
 
+	// Calendar contains the holidays and working hours used to apply time constraints to steps.
+	// Optional. If not set, a calendar without holidays is used.
+	Calendar *calendar.Calendar
+
 	// PathwayParser is used to parse pathways.
 	PathwayParser *pathway.Parser
 
 	// PathwayManager is the pathway manager to use for the hospital.
+	// The pathways can only be reloaded with Hospital.Reload if it is a *pathway.ReloadableManager.
 	PathwayManager pathway.Manager
 
 	// Clock is the clock for the hospital.
@@ -254,9 +293,35 @@ type Config struct {
 	// Deleting patients saves memory, but patients cannot be reused for other pathways.
 	DeletePatientsFromMemory bool
 
+	// FullWardPolicy is what happens to admissions to locations that are full: one of FullWardFail,
+	// FullWardWait or FullWardPending. Locations are only full if they have a capacity.
+	// Optional. If not set, FullWardFail is used.
+	FullWardPolicy string
+
+	// ReturningPatients is the probability, between 0 and 1, that a person in a pathway that doesn't
+	// have an MRN is a patient whose pathways have finished, instead of a new patient, e.g., a patient
+	// created during a backfill. It cannot be set if DeletePatientsFromMemory is true.
+	// Optional. If not set, patients never return.
+	ReturningPatients float64
+
+	// Retention is how long patients and messages are kept; see Hospital.ApplyRetention.
+	// Optional. If not set, they are kept forever.
+	Retention RetentionPolicy
+
 	// ResourceWriter is used to write resources.
 	ResourceWriter ResourceWriter
 
+	// Rand is the source of randomness of the hospital. Given the same Rand seed, configuration and
+	// Clock, the hospital generates the same messages.
+	// Required.
+	Rand *rand.Rand
+
+	// RandSource is the source of Rand. It lets snapshots save and restore the state of Rand; see
+	// Hospital.Snapshot.
+	// Optional. If not set, snapshots don't include the state of Rand, and hospitals restored from
+	// them generate different values than the original ones.
+	RandSource *random.Source
+
 	// Additional configuration.
 	// Optional.
 	AdditionalConfig AdditionalConfig
@@ -304,7 +369,18 @@ func DefaultConfig(ctx context.Context, arguments Arguments) (Config, error) {
 		MessageControlGenerator:  &header.MessageControlGenerator{},
 		Clock:                    &clock.RealTimeClock{},
 		DeletePatientsFromMemory: arguments.DeletePatientsFromMemory,
+		FullWardPolicy:           arguments.FullWardPolicy,
+		ReturningPatients:        arguments.ReturningPatients,
+		Retention:                arguments.Retention,
+	}
+
+	seed := arguments.Seed
+	if seed == 0 {
+		seed = random.NewSeed()
 	}
+	log.Infof("Using random seed %d", seed)
+	c.RandSource = random.NewSource(seed)
+	c.Rand = rand.New(c.RandSource)
 
 	if arguments.MessageControlGenerator != nil {
 		c.MessageControlGenerator = arguments.MessageControlGenerator
@@ -322,7 +398,7 @@ func DefaultConfig(ctx context.Context, arguments Arguments) (Config, error) {
 	}
 
 	if arguments.HardcodedMessagesDir != nil {
-		if c.MessagesManager, err = hardcoded.NewManager(ctx, *arguments.HardcodedMessagesDir, c.MessageControlGenerator); err != nil {
+		if c.MessagesManager, err = hardcoded.NewManager(ctx, *arguments.HardcodedMessagesDir, c.MessageControlGenerator, c.Rand); err != nil {
 			return Config{}, errors.Wrap(err, "cannot create Hardcoded Messages Manager")
 		}
 	}
@@ -351,25 +427,37 @@ func DefaultConfig(ctx context.Context, arguments Arguments) (Config, error) {
 		}
 	}
 
+	if arguments.CalendarFile != nil {
+		if c.Calendar, err = calendar.Load(ctx, *arguments.CalendarFile); err != nil {
+			return Config{}, errors.Wrap(err, "cannot load the calendar")
+		}
+	}
+
 	if arguments.SenderArguments != nil {
-		if c.Sender, err = hl7Sender(*arguments.SenderArguments); err != nil {
+		if c.Sender, err = NewSender(*arguments.SenderArguments); err != nil {
 			return Config{}, errors.Wrap(err, "cannot create the sender")
 		}
 	}
 
 	if arguments.ResourceArguments != nil && c.HL7Config != nil {
-		if c.ResourceWriter, err = resourceWriter(ctx, *arguments.ResourceArguments, c.HL7Config); err != nil {
+		if c.ResourceWriter, err = NewResourceWriter(ctx, *arguments.ResourceArguments, c.HL7Config, c.Rand); err != nil {
 			return Config{}, errors.Wrap(err, "cannot create the resource writer")
 		}
 	}
 
 	if c.OrderProfiles != nil && c.Doctors != nil && c.LocationManager != nil {
-		c.PathwayParser = &pathway.Parser{Clock: c.Clock, OrderProfiles: c.OrderProfiles, Doctors: c.Doctors, LocationManager: c.LocationManager}
+		c.PathwayParser = &pathway.Parser{Clock: c.Clock, OrderProfiles: c.OrderProfiles, Doctors: c.Doctors, LocationManager: c.LocationManager, Rand: c.Rand}
 
 		if arguments.PathwayArguments != nil {
-			if c.PathwayManager, err = pathwayManager(ctx, c.PathwayParser, *arguments.PathwayArguments); err != nil {
+			pathways, err := c.PathwayParser.ParsePathways(ctx, arguments.PathwayArguments.Dir)
+			if err != nil {
+				return Config{}, errors.Wrap(err, "failed to parse pathways for Pathway Manager")
+			}
+			m, err := pathwayManager(pathways, *arguments.PathwayArguments, c.Rand)
+			if err != nil {
 				return Config{}, errors.Wrap(err, "cannot create pathway manager")
 			}
+			c.PathwayManager = pathway.NewReloadableManager(m)
 		}
 	}
 
@@ -380,7 +468,9 @@ func DefaultConfig(ctx context.Context, arguments Arguments) (Config, error) {
 	return c, nil
 }
 
-func resourceWriter(ctx context.Context, arguments ResourceArguments, hl7Config *config.HL7Config) (ResourceWriter, error) {
+// NewResourceWriter creates a ResourceWriter from the given arguments.
+// rng is the source of randomness of the IDs of the resources; if nil, the IDs are random UUIDs.
+func NewResourceWriter(ctx context.Context, arguments ResourceArguments, hl7Config *config.HL7Config, rng *rand.Rand) (ResourceWriter, error) {
 	output, err := resourceOutput(ctx, arguments)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create resource output")
@@ -392,7 +482,7 @@ func resourceWriter(ctx context.Context, arguments ResourceArguments, hl7Config
 
 	cfg := resource.GeneratorConfig{
 		HL7Config:   hl7Config,
-		IDGenerator: &id.UUIDGenerator{},
+		IDGenerator: &id.UUIDGenerator{Rand: rng},
 		Output:      output,
 		Marshaller:  marshaller,
 	}
@@ -424,7 +514,8 @@ func resourceMarshaller(arguments ResourceArguments) (resource.Marshaller, error
 	}
 }
 
-func hl7Sender(arguments SenderArguments) (hl7.Sender, error) {
+// NewSender creates a Sender from the given arguments.
+func NewSender(arguments SenderArguments) (hl7.Sender, error) {
 	switch arguments.Output {
 	case "stdout":
 		return hl7.NewStdoutSender(), nil
@@ -437,16 +528,12 @@ func hl7Sender(arguments SenderArguments) (hl7.Sender, error) {
 	}
 }
 
-func pathwayManager(ctx context.Context, p *pathway.Parser, arguments PathwayArguments) (pathway.Manager, error) {
-	pathways, err := p.ParsePathways(ctx, arguments.Dir)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse pathways for Pathway Manager")
-	}
+func pathwayManager(pathways map[string]pathway.Pathway, arguments PathwayArguments, rng *rand.Rand) (pathway.Manager, error) {
 	switch arguments.Type {
 	case "distribution":
-		return pathway.NewDistributionManager(pathways, arguments.Names, arguments.ExcludeNames)
+		return pathway.NewDistributionManager(pathways, arguments.Names, arguments.ExcludeNames, rng)
 	case "deterministic":
-		return pathway.NewDeterministicManager(pathways, arguments.Names)
+		return pathway.NewDeterministicManager(pathways, arguments.Names, rng)
 	default:
 		return nil, errors.Errorf("unsupported pathway manager type %q", arguments.Type)
 	}
@@ -486,6 +573,7 @@ type Hospital struct {
 	sender                  hl7.Sender
 	generator               *generator.Generator
 	locationManager         *location.Manager
+	calendar                *calendar.Calendar
 	messageQ                *state.WrappedQueue
 	eventQ                  *state.WrappedQueue
 	pathwayManager          pathway.Manager
@@ -495,8 +583,32 @@ type Hospital struct {
 	resourceWriter          ResourceWriter
 	messageConfig           *config.HL7Config
 	orderAckDelay           *pathway.Delay
+	fullWardPolicy          string
+	returningPatients       *returningPatients
+	retention               RetentionPolicy
+	rand                    *rand.Rand
+	randSource              *random.Source
+	doctors                 *doctor.Doctors
+	orderProfiles           *orderprofile.OrderProfiles
+	messageControlGenerator *header.MessageControlGenerator
+	// snapshotMu is held for reading while events run, messages are processed and pathways start,
+	// and for writing while a snapshot is taken or restored, so that snapshots are consistent.
+	snapshotMu *sync.RWMutex
+	// resourceMu serializes the generation of resources, as events can run concurrently.
+	resourceMu *sync.Mutex
+	// waitingMu serializes the resumption of admissions waiting for a bed.
+	waitingMu *sync.Mutex
+	// bedsFreed is the number of beds freed by the events that have run since admissions waiting for
+	// a bed were last resumed. After each event runs, as many admissions waiting for a bed are resumed.
+	// It is accessed atomically.
+	bedsFreed int64
 }
 
+// joinPendingTime is the event time of join events whose parallel tracks haven't all finished yet,
+// and of admissions waiting for a bed.
+// It is far enough in the future for such events never to be due.
+var joinPendingTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
 func init() {
 	if err := monitoring.CreateAndRegisterMetricsFromStruct(&counters); err != nil {
 		log.WithError(err).Fatal("Cannot register metrics from the 'hospital' package")
@@ -507,17 +619,20 @@ func init() {
 // and if so, it runs the next event.
 // Returns true it there was an event for processing and the event ran successfully, false otherwise.
 func (h *Hospital) RunNextEventIfDue(ctx context.Context) (bool, error) {
-	if !h.hasDueEvent() {
-		return false, nil
+	e, err := h.NextDueEvent()
+	if e == nil || err != nil {
+		return false, err
 	}
-	err := h.runNextEvent(ctx)
-	return err == nil, err
+	h.RunEvent(ctx, e)
+	return true, nil
 }
 
 // ProcessNextMessageIfDue checks if there is a message available on the message queue and if it is due,
 // and if so, it processes the next message.
 // Returns true if there was a message for processing and the processing was successful, false otherwise.
 func (h *Hospital) ProcessNextMessageIfDue() (bool, error) {
+	h.snapshotMu.RLock()
+	defer h.snapshotMu.RUnlock()
 	if !h.hasDueMessage() {
 		return false, nil
 	}
@@ -525,19 +640,7 @@ func (h *Hospital) ProcessNextMessageIfDue() (bool, error) {
 	return err == nil, err
 }
 
-func (h Hospital) hasDueEvent() bool {
-	i := h.eventQ.Peek()
-	if i == nil {
-		return false
-	}
-	e, ok := i.(state.Event)
-	if !ok {
-		log.Fatalf("Unknown item type %v, want state.Event", i)
-	}
-	return h.isTimeDue(e.EventTime)
-}
-
-func (h Hospital) hasDueMessage() bool {
+func (h *Hospital) hasDueMessage() bool {
 	i := h.messageQ.Peek()
 	if i == nil {
 		return false
@@ -549,7 +652,7 @@ func (h Hospital) hasDueMessage() bool {
 	return h.isTimeDue(m.MessageTime)
 }
 
-func (h Hospital) isTimeDue(t time.Time) bool {
+func (h *Hospital) isTimeDue(t time.Time) bool {
 	return t.Unix() <= h.clock.Now().Unix()
 }
 
@@ -560,7 +663,17 @@ func (h *Hospital) GetPathway(pathwayName string) (*pathway.Pathway, error) {
 
 // StartNextPathway starts the next pathway.
 func (h *Hospital) StartNextPathway() error {
-	p, err := h.pathwayManager.NextPathway()
+	return h.StartNextPathwayFrom(h.pathwayManager)
+}
+
+// StartNextPathwayFrom starts the next pathway picked by the given pathway manager instead of the
+// hospital's one, e.g., the manager of a group of pathways that start at their own rate.
+// The pathway is picked and started while the config cannot be reloaded, so that it always starts
+// with the config it was picked with.
+func (h *Hospital) StartNextPathwayFrom(m pathway.Manager) error {
+	h.snapshotMu.RLock()
+	defer h.snapshotMu.RUnlock()
+	p, err := m.NextPathway()
 	if err != nil {
 		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
 			"pathway_name": "unknown",
@@ -568,7 +681,7 @@ func (h *Hospital) StartNextPathway() error {
 		}).Inc()
 		return errors.Wrap(err, "cannot get next pathway")
 	}
-	if _, err := h.StartPathway(p); err != nil {
+	if _, err := h.startPathway(p); err != nil {
 		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
 			"pathway_name": p.Name(),
 			"reason":       "pathway_start_failure",
@@ -584,6 +697,13 @@ func (h *Hospital) StartNextPathway() error {
 //   - the list of persons that were generated as a result of running this pathway.
 //   - an error if something unexpected happened.
 func (h *Hospital) StartPathway(p *pathway.Pathway) ([]*ir.Person, error) {
+	h.snapshotMu.RLock()
+	defer h.snapshotMu.RUnlock()
+	return h.startPathway(p)
+}
+
+// startPathway starts the given pathway; h.snapshotMu must be held.
+func (h *Hospital) startPathway(p *pathway.Pathway) ([]*ir.Person, error) {
 	logLocal := log.WithField(keyPathwayName, p.Name())
 
 	if p.Persons == nil || len(*p.Persons) == 0 {
@@ -595,20 +715,26 @@ func (h *Hospital) StartPathway(p *pathway.Pathway) ([]*ir.Person, error) {
 	}
 
 	idsToMRN := map[pathway.PatientID]string{}
+	idsToPatient := map[pathway.PatientID]*state.Patient{}
 	var mbPersons []*ir.Person
 	var patients []*state.Patient
 
 	persons := *p.Persons
 	i := 1
-	for id, person := range persons {
+	// The persons are generated in a fixed order, so that runs with the same seed generate the same
+	// persons.
+	for _, id := range persons.SortedIDs() {
+		person := persons[id]
 		newPerson, p := h.newOrExistingPatient(&person, p.Consultant)
 		patients = append(patients, p)
 		mbPersons = append(mbPersons, newPerson)
 		logLocal.Infof("Starting pathway, person %v: %s %s %s",
 			id, newPerson.FirstName, newPerson.Surname, newPerson.MRN)
 		idsToMRN[id] = newPerson.MRN
+		idsToPatient[id] = p
 		i++
 	}
+	linkRelatedPersons(persons, idsToPatient)
 	if err := h.queueFirstEvent(*p, idsToMRN, patients...); err != nil {
 		counters.SimulatedHospital.ErrorsTotal.With(prometheus.Labels{
 			"pathway_name": p.Name(),
@@ -629,7 +755,18 @@ func (h *Hospital) StartPathway(p *pathway.Pathway) ([]*ir.Person, error) {
 // If it is not set, or if an existing patient isn't found, it generates a new person and patient.
 // If the patient already exists, this method updates the patient with information contained in the
 // pathway.
-func (h Hospital) newOrExistingPatient(person *pathway.Person, consultant *pathway.Consultant) (*ir.Person, *state.Patient) {
+// If the MRN is not set, the patient might be a returning patient whose pathways have finished; see
+// Config.ReturningPatients.
+func (h *Hospital) newOrExistingPatient(person *pathway.Person, consultant *pathway.Consultant) (*ir.Person, *state.Patient) {
+	if person.MRN == "" {
+		if mrn, ok := h.returningPatients.pick(); ok {
+			if p := h.patients.Get(mrn); p != nil {
+				log.WithField(keyPatientID, mrn).Info("Returning patient")
+				h.generator.UpdateFromPathway(p.PatientInfo, &pathway.UpdatePerson{Person: person})
+				return p.PatientInfo.Person, p
+			}
+		}
+	}
 	if person.MRN != "" {
 		if p := h.patients.Get(person.MRN); p != nil {
 			// After we load the patient, update the information with the information in the pathway.
@@ -641,22 +778,71 @@ func (h Hospital) newOrExistingPatient(person *pathway.Person, consultant *pathw
 }
 
 // newPatient returns a new person and patient.
-func (h Hospital) newPatient(person *pathway.Person, consultant *pathway.Consultant) (*ir.Person, *state.Patient) {
+func (h *Hospital) newPatient(person *pathway.Person, consultant *pathway.Consultant) (*ir.Person, *state.Patient) {
 	newPerson := h.generator.NewPerson(person)
 	newConsultant := h.generator.NewDoctor(consultant)
 	return newPerson, h.generator.NewPatient(newPerson, newConsultant)
 }
 
-// calculateTimes calculates the time in which the event should take place, and the message should
-// be sent, based on the current time and the specified delays (if any).
-func calculateTimes(now time.Time, params *pathway.Parameters) (eventTime time.Time, msgTime time.Time) {
+// linkRelatedPersons adds the related persons declared in the Persons section of a pathway to the
+// associated parties of each patient. Persons that share an address with a related person take their address.
+func linkRelatedPersons(persons pathway.Persons, idsToPatient map[pathway.PatientID]*state.Patient) {
+	for _, id := range persons.SortedIDs() {
+		person := persons[id]
+		patientInfo := idsToPatient[id].PatientInfo
+		for _, r := range person.Relationships {
+			related := idsToPatient[r.Person].PatientInfo.Person
+			addAssociatedParty(patientInfo, related, &ir.CodedElement{ID: r.Code, Text: r.Description})
+			if r.SharesAddress && related.Address != nil {
+				address := *related.Address
+				patientInfo.Person.Address = &address
+			}
+		}
+	}
+}
+
+// addAssociatedParty adds the given person to the associated parties of the patient with the given
+// relationship. If the person is already an associated party of the patient, their relationship is updated.
+func addAssociatedParty(patientInfo *ir.PatientInfo, person *ir.Person, relationship *ir.CodedElement) {
+	for _, ap := range patientInfo.AssociatedParties {
+		if ap.Person != nil && ap.Person.MRN == person.MRN {
+			ap.Person = person
+			ap.Relationship = relationship
+			return
+		}
+	}
+	patientInfo.AssociatedParties = append(patientInfo.AssociatedParties, &ir.AssociatedParty{
+		Person:       person,
+		Relationship: relationship,
+	})
+}
+
+// calculateTimes calculates the time in which the event for the given step should take place, and the
+// message should be sent, based on the current time and the specified delays (if any).
+// WaitForEvent steps take place when their timeout elapses, unless the external event arrives before.
+// If the step has time constraints, the event is moved forward to the earliest time that satisfies them
+// according to the hospital's calendar.
+func (h *Hospital) calculateTimes(now time.Time, step *pathway.Step) (eventTime time.Time, msgTime time.Time) {
 	eventTime = now
 	msgTime = now
-	if params != nil {
+	if step.WaitForEvent != nil && step.WaitForEvent.Timeout != nil {
+		eventTime = eventTime.Add(*step.WaitForEvent.Timeout)
+		msgTime = eventTime
+	}
+	if params := step.Parameters; params != nil {
 		if params.TimeFromNow != nil {
 			eventTime = eventTime.Add(*params.TimeFromNow)
 		}
-		msgTime = eventTime.Add(params.DelayMessage.Random())
+		if params.Constraints != nil {
+			constrained, err := h.calendar.Apply(eventTime, params.Constraints)
+			if err != nil {
+				log.WithError(err).WithField(keyEventType, step.StepType()).
+					Warning("Cannot apply the time constraints of the step; ignoring them")
+			} else {
+				eventTime = constrained
+			}
+		}
+		msgTime = eventTime.Add(params.DelayMessage.Random(h.rand))
 	}
 	return
 }
@@ -675,6 +861,9 @@ func NewHospital(ctx context.Context, c Config) (*Hospital, error) {
 	if c.HL7Config == nil {
 		return nil, errors.New("Config.HL7Config not provided; this is required")
 	}
+	if c.Rand == nil {
+		return nil, errors.New("Config.Rand not provided; this is required")
+	}
 	if c.Header == nil {
 		return nil, errors.New("Config.Header not provided; this is required")
 	}
@@ -696,6 +885,22 @@ func NewHospital(ctx context.Context, c Config) (*Hospital, error) {
 	if c.Clock == nil {
 		return nil, errors.New("Config.Clock not provided; this is required")
 	}
+	switch c.FullWardPolicy {
+	case "":
+		c.FullWardPolicy = FullWardFail
+	case FullWardFail, FullWardWait, FullWardPending:
+	default:
+		return nil, errors.Errorf("unsupported Config.FullWardPolicy %q; supported: [%s, %s, %s]", c.FullWardPolicy, FullWardFail, FullWardWait, FullWardPending)
+	}
+	if c.ReturningPatients < 0 || c.ReturningPatients > 1 {
+		return nil, errors.Errorf("invalid Config.ReturningPatients %v; it must be between 0 and 1", c.ReturningPatients)
+	}
+	if c.ReturningPatients > 0 && c.DeletePatientsFromMemory {
+		return nil, errors.New("Config.ReturningPatients cannot be set if Config.DeletePatientsFromMemory is true")
+	}
+	if err := c.Retention.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid Config.Retention")
+	}
 	ac := c.AdditionalConfig
 
 	dataConfig, err := config.LoadData(ctx, c.DataFiles, c.HL7Config)
@@ -711,6 +916,7 @@ func NewHospital(ctx context.Context, c Config) (*Hospital, error) {
 		Doctors:          c.Doctors,
 		MsgCtrlGenerator: c.MessageControlGenerator,
 		OrderProfiles:    c.OrderProfiles,
+		Rand:             c.Rand,
 		AddressGenerator: ac.AddressGenerator,
 		MRNGenerator:     ac.MRNGenerator,
 		PlacerGenerator:  ac.PlacerGenerator,
@@ -724,11 +930,16 @@ func NewHospital(ctx context.Context, c Config) (*Hospital, error) {
 	if ac.OrderAckDelay == nil {
 		ac.OrderAckDelay = defaultOrderAckDelay
 	}
+	cal := c.Calendar
+	if cal == nil {
+		cal = calendar.Default()
+	}
 	return &Hospital{
 		clock:                   c.Clock,
 		sender:                  c.Sender,
 		generator:               generator.NewGenerator(genConfig),
 		locationManager:         c.LocationManager,
+		calendar:                cal,
 		messageQ:                messageQ,
 		eventQ:                  eventQ,
 		pathwayManager:          c.PathwayManager,
@@ -738,9 +949,37 @@ func NewHospital(ctx context.Context, c Config) (*Hospital, error) {
 		resourceWriter:          c.ResourceWriter,
 		messageConfig:           c.HL7Config,
 		orderAckDelay:           ac.OrderAckDelay,
+		fullWardPolicy:          c.FullWardPolicy,
+		returningPatients:       &returningPatients{probability: c.ReturningPatients, rand: c.Rand},
+		retention:               c.Retention,
+		rand:                    c.Rand,
+		randSource:              c.RandSource,
+		doctors:                 c.Doctors,
+		orderProfiles:           c.OrderProfiles,
+		messageControlGenerator: c.MessageControlGenerator,
+		snapshotMu:              &sync.RWMutex{},
+		resourceMu:              &sync.Mutex{},
+		waitingMu:               &sync.Mutex{},
 	}, nil
 }
 
+// SetOutput sets the Sender that sends the HL7 messages and the ResourceWriter that writes the resources,
+// e.g., to send the messages generated by a backfill somewhere else. Nil values keep the current ones.
+// SetOutput returns a function that restores the previous Sender and ResourceWriter.
+// SetOutput must not be called while messages are being processed.
+func (h *Hospital) SetOutput(sender hl7.Sender, resourceWriter ResourceWriter) (restore func()) {
+	previousSender, previousResourceWriter := h.sender, h.resourceWriter
+	if sender != nil {
+		h.sender = sender
+	}
+	if resourceWriter != nil {
+		h.resourceWriter = resourceWriter
+	}
+	return func() {
+		h.sender, h.resourceWriter = previousSender, previousResourceWriter
+	}
+}
+
 // Close closes resources held by the Hospital.
 // Should be called if the Hospital is no longer needed or at the program exit.
 func (h *Hospital) Close() error {
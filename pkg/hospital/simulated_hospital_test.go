@@ -29,11 +29,13 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/testing/protocmp"
+	"github.com/Arend-melissant/simhospital/pkg/calendar"
 	"github.com/Arend-melissant/simhospital/pkg/generator/header"
 	"github.com/Arend-melissant/simhospital/pkg/hardcoded"
 	"github.com/Arend-melissant/simhospital/pkg/hl7"
 	. "github.com/Arend-melissant/simhospital/pkg/hospital"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/location"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
 	"github.com/Arend-melissant/simhospital/pkg/message"
 	"github.com/Arend-melissant/simhospital/pkg/pathway"
@@ -41,6 +43,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/state/persist"
 	"github.com/Arend-melissant/simhospital/pkg/state"
 	"github.com/Arend-melissant/simhospital/pkg/test"
+	"github.com/Arend-melissant/simhospital/pkg/test/testaddress"
 	"github.com/Arend-melissant/simhospital/pkg/test/testhl7"
 	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
 	"github.com/Arend-melissant/simhospital/pkg/test/testlocation"
@@ -2439,6 +2442,97 @@ func TestRunPathwayUsePatientWithPersonsAndMRNs(t *testing.T) {
 	}
 }
 
+func TestRunPathwayRelationshipsAndBirth(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {
+			Persons: &pathway.Persons{
+				"mother": {
+					FirstName:     "Mother",
+					Gender:        pathway.Female,
+					Relationships: []pathway.Relationship{{Person: "partner", Code: "SPO", Description: "Spouse", SharesAddress: true}},
+				},
+				"partner": {FirstName: "Partner"},
+			},
+			Pathway: []pathway.Step{
+				{UsePatient: &pathway.UsePatient{Patient: "partner"}},
+				{UsePatient: &pathway.UsePatient{Patient: "mother"}},
+				{Admission: &pathway.Admission{Loc: testLoc}},
+				{Birth: &pathway.Birth{Baby: "baby", Person: &pathway.Person{FirstName: "Baby"}}},
+				{UsePatient: &pathway.UsePatient{Patient: "baby"}},
+				{Registration: &pathway.Registration{}},
+				{UsePatient: &pathway.UsePatient{Patient: "mother"}},
+				{UpdatePerson: &pathway.UpdatePerson{}},
+			},
+		},
+	}
+
+	hospital := newHospital(ctx, t, Config{}, pathways)
+	defer hospital.Close()
+	startPathway(t, hospital, testPathwayName)
+	_, messages := hospital.ConsumeQueues(ctx, t)
+
+	wantMessageTypes := []string{"ADT^A01", "ADT^A28", "ADT^A04", "ADT^A08"}
+	gotMessageTypes := testhl7.Fields(t, messages, testhl7.MessageType)
+	if diff := cmp.Diff(wantMessageTypes, gotMessageTypes); diff != "" {
+		t.Fatalf("StartPathway(%v) generated message types with diff (-want, +got):\n%s", testPathwayName, diff)
+	}
+	wantFirstNames := []string{"Mother", "Baby", "Baby", "Mother"}
+	gotFirstNames := testhl7.Fields(t, messages, testhl7.FirstName)
+	if diff := cmp.Diff(wantFirstNames, gotFirstNames); diff != "" {
+		t.Errorf("StartPathway(%v) generated messages with first names with diff (-want, +got):\n%s", testPathwayName, diff)
+	}
+
+	// The mother is related to her partner, and lives at the same address.
+	motherPID := testhl7.PID(t, messages[0])
+	motherNK1 := testhl7.AllNK1(t, messages[0])
+	if got, want := len(motherNK1), 1; got != want {
+		t.Fatalf("len(AllNK1(%q))=%d, want %d", messages[0], got, want)
+	}
+	if got, want := motherNK1[0].Name[0].GivenName.String(), "Partner"; got != want {
+		t.Errorf("mother NK1.Name.GivenName got %q, want %q", got, want)
+	}
+	if got, want := motherNK1[0].Relationship.Identifier.String(), "SPO"; got != want {
+		t.Errorf("mother NK1.Relationship.Identifier got %q, want %q", got, want)
+	}
+	if diff := cmp.Diff(motherNK1[0].Address, motherPID.PatientAddress); diff != "" {
+		t.Errorf("mother PID.PatientAddress got diff with the address of the partner (-want, +got):\n%s", diff)
+	}
+
+	// The baby is born at the time of the step, and takes the surname and the address of the mother.
+	for _, m := range messages[1:3] {
+		babyPID := testhl7.PID(t, m)
+		if got, want := babyPID.DateTimeOfBirth.Time, now; !got.Equal(want) {
+			t.Errorf("baby PID.DateTimeOfBirth got %v, want %v", got, want)
+		}
+		if got, want := babyPID.PatientName[0].FamilyName.Surname.String(), motherPID.PatientName[0].FamilyName.Surname.String(); got != want {
+			t.Errorf("baby PID.PatientName.FamilyName got %q, want %q", got, want)
+		}
+		if diff := cmp.Diff(motherPID.PatientAddress, babyPID.PatientAddress); diff != "" {
+			t.Errorf("baby PID.PatientAddress got diff with the address of the mother (-want, +got):\n%s", diff)
+		}
+		babyNK1 := testhl7.AllNK1(t, m)
+		if got, want := len(babyNK1), 1; got != want {
+			t.Fatalf("len(AllNK1(%q))=%d, want %d", m, got, want)
+		}
+		if got, want := babyNK1[0].Name[0].GivenName.String(), "Mother"; got != want {
+			t.Errorf("baby NK1.Name.GivenName got %q, want %q", got, want)
+		}
+		if got, want := babyNK1[0].Relationship.Identifier.String(), "MTH"; got != want {
+			t.Errorf("baby NK1.Relationship.Identifier got %q, want %q", got, want)
+		}
+	}
+
+	// After the birth, the mother has the partner and the baby as associated parties.
+	var gotRelationships []string
+	for _, nk1 := range testhl7.AllNK1(t, messages[3]) {
+		gotRelationships = append(gotRelationships, nk1.Relationship.Identifier.String())
+	}
+	if diff := cmp.Diff([]string{"SPO", "CHD"}, gotRelationships); diff != "" {
+		t.Errorf("mother NK1.Relationship.Identifier got diff (-want, +got):\n%s", diff)
+	}
+}
+
 func TestStartPathway_InvalidPersonsSection(t *testing.T) {
 	ctx := context.Background()
 	mr := testmetrics.NewRetrieverFromGatherer(t)
@@ -3792,7 +3886,7 @@ func TestRunPathwayWithHardcodedMessage(t *testing.T) {
 	seed := "hardcoded_messages.yml"
 	dir := testwrite.BytesToDir(t, []byte(hardcodedMessageYml), seed)
 	msgControlGen := &header.MessageControlGenerator{}
-	hardcodedMessagesManager, err := hardcoded.NewManager(ctx, dir, msgControlGen)
+	hardcodedMessagesManager, err := hardcoded.NewManager(ctx, dir, msgControlGen, nil)
 	if err != nil {
 		t.Fatalf("NewManager(%s) failed with %v", hardcodedMessageYml, err)
 	}
@@ -3831,7 +3925,7 @@ func hospitalWithPatientSyncer(ctx context.Context, t *testing.T, cfg Config, pa
 
 func hospitalWithTime(ctx context.Context, t *testing.T, cfg Config, pathways map[string]pathway.Pathway, now time.Time) *testhospital.Hospital {
 	t.Helper()
-	pm, err := pathway.NewDistributionManager(pathways, nil, nil)
+	pm, err := pathway.NewDistributionManager(pathways, nil, nil, rand.New(rand.NewSource(1)))
 	if err != nil {
 		t.Fatalf("pathway.NewDistributionManager(%v,%v,%v) failed with %v", pathways, nil, nil, err)
 	}
@@ -4294,3 +4388,471 @@ func TestStartNextPathway(t *testing.T) {
 		}
 	}
 }
+
+func TestRunPathwayWaitForEvent(t *testing.T) {
+	ctx := context.Background()
+	timeout := 10 * time.Minute
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{WaitForEvent: &pathway.WaitForEvent{
+				Event:     "ORU^R01",
+				Timeout:   &timeout,
+				OnTimeout: []pathway.Step{{CancelVisit: &pathway.CancelVisit{}}},
+			}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	oru := func(mrn string) []byte {
+		return []byte("MSH|^~\\&|LAB|FAC|SIMHOSP|FAC|20180212000000||ORU^R01|1|T|2.3\rPID|1||" + mrn)
+	}
+
+	tests := []struct {
+		name             string
+		trigger          func(h *testhospital.Hospital, mrn string) error
+		wantMessageTypes []string
+	}{{
+		name:             "timeout",
+		wantMessageTypes: []string{"ADT^A01", "ADT^A11"},
+	}, {
+		name: "triggered by API call",
+		trigger: func(h *testhospital.Hospital, mrn string) error {
+			return h.TriggerEvent(mrn, "ORU^R01")
+		},
+		wantMessageTypes: []string{"ADT^A01", "ADT^A03"},
+	}, {
+		name: "triggered by inbound message",
+		trigger: func(h *testhospital.Hospital, mrn string) error {
+			return h.TriggerEventFromMessage(oru(mrn))
+		},
+		wantMessageTypes: []string{"ADT^A01", "ADT^A03"},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hospital := newHospital(ctx, t, Config{}, pathways)
+			defer hospital.Close()
+			p, err := hospital.PathwayManager.GetPathway(testPathwayName)
+			if err != nil {
+				t.Fatalf("GetPathway(%s) failed with %v", testPathwayName, err)
+			}
+			persons, err := hospital.StartPathway(p)
+			if err != nil {
+				t.Fatalf("StartPathway(%v) failed with %v", testPathwayName, err)
+			}
+			mrn := persons[0].MRN
+
+			// Run the Admission only: the pathway is then waiting for the external event.
+			events, messages := hospital.ConsumeQueuesWithLimit(ctx, t, 1, true)
+			if got, want := events, 1; got != want {
+				t.Fatalf("ConsumeQueuesWithLimit(1) ran %d events, want %d", got, want)
+			}
+			if tc.trigger != nil {
+				if err := tc.trigger(hospital, mrn); err != nil {
+					t.Fatalf("trigger(%s) failed with %v", mrn, err)
+				}
+				// The event is not waiting anymore, so it cannot be triggered again.
+				if err := hospital.TriggerEvent(mrn, "ORU^R01"); err == nil {
+					t.Error("TriggerEvent() for an event that was already triggered got nil error, want error")
+				}
+			}
+			_, rest := hospital.ConsumeQueues(ctx, t)
+			messages = append(messages, rest...)
+
+			gotMessageTypes := testhl7.Fields(t, messages, testhl7.MessageType)
+			if diff := cmp.Diff(tc.wantMessageTypes, gotMessageTypes); diff != "" {
+				t.Errorf("StartPathway(%v) generated message types with diff (-want, +got):\n%s", testPathwayName, diff)
+			}
+		})
+	}
+}
+
+func TestTriggerEventNoWaitingPathway(t *testing.T) {
+	ctx := context.Background()
+	timeout := time.Hour
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{WaitForEvent: &pathway.WaitForEvent{Event: "lab_callback", Timeout: &timeout}},
+		}},
+	}
+	hospital := newHospital(ctx, t, Config{}, pathways)
+	defer hospital.Close()
+	p, err := hospital.PathwayManager.GetPathway(testPathwayName)
+	if err != nil {
+		t.Fatalf("GetPathway(%s) failed with %v", testPathwayName, err)
+	}
+	persons, err := hospital.StartPathway(p)
+	if err != nil {
+		t.Fatalf("StartPathway(%v) failed with %v", testPathwayName, err)
+	}
+	mrn := persons[0].MRN
+
+	if err := hospital.TriggerEvent(mrn, "another_event"); err == nil {
+		t.Error("TriggerEvent(_, another_event) got nil error, want error")
+	}
+	if err := hospital.TriggerEvent("unknown-mrn", "lab_callback"); err == nil {
+		t.Error("TriggerEvent(unknown-mrn, _) got nil error, want error")
+	}
+	if err := hospital.TriggerEvent(mrn, "lab_callback"); err != nil {
+		t.Errorf("TriggerEvent(%s, lab_callback) failed with %v", mrn, err)
+	}
+	if got, want := hospital.EventsLen(), 1; got != want {
+		t.Errorf("EventsLen() = %d, want %d", got, want)
+	}
+}
+
+func TestRunPathwayParallel(t *testing.T) {
+	ctx := context.Background()
+	fiveMinutes := 5 * time.Minute
+	tenMinutes := 10 * time.Minute
+	fifteenMinutes := 15 * time.Minute
+	twoHours := 2 * time.Hour
+	updateName := func(name string) pathway.Step {
+		return pathway.Step{UpdatePerson: &pathway.UpdatePerson{Person: &pathway.Person{FirstName: name}}}
+	}
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Parallel: &pathway.Parallel{Tracks: []pathway.Track{{
+				Name: "A",
+				Steps: []pathway.Step{
+					{Delay: &pathway.Delay{From: tenMinutes, To: tenMinutes}},
+					updateName("A"),
+				},
+			}, {
+				Name: "B",
+				Steps: []pathway.Step{
+					{Delay: &pathway.Delay{From: fiveMinutes, To: fiveMinutes}},
+					{PendingDischarge: &pathway.PendingDischarge{ExpectedDischargeTimeFromNow: &twoHours}},
+					{Delay: &pathway.Delay{From: fifteenMinutes, To: fifteenMinutes}},
+					updateName("B"),
+				},
+			}}}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+
+	hospital := newHospital(ctx, t, Config{}, pathways)
+	defer hospital.Close()
+	startPathway(t, hospital, testPathwayName)
+	_, messages := hospital.ConsumeQueues(ctx, t)
+
+	wantMessageTypes := []string{"ADT^A01", "ADT^A16", "ADT^A08", "ADT^A08", "ADT^A03"}
+	gotMessageTypes := testhl7.Fields(t, messages, testhl7.MessageType)
+	if diff := cmp.Diff(wantMessageTypes, gotMessageTypes); diff != "" {
+		t.Fatalf("StartPathway(%v) generated message types with diff (-want, +got):\n%s", testPathwayName, diff)
+	}
+	gotFirstNames := testhl7.Fields(t, messages[2:4], testhl7.FirstName)
+	if diff := cmp.Diff([]string{"A", "B"}, gotFirstNames); diff != "" {
+		t.Errorf("StartPathway(%v) generated updates with first names with diff (-want, +got):\n%s", testPathwayName, diff)
+	}
+
+	// The steps after the Parallel step run when the longest track finishes.
+	wantTimes := []time.Time{now, now.Add(fiveMinutes), now.Add(tenMinutes), now.Add(fiveMinutes + fifteenMinutes), now.Add(fiveMinutes + fifteenMinutes)}
+	for i, m := range messages {
+		if got, want := testhl7.EVN(t, m).RecordedDateTime.Time, wantTimes[i]; !got.Equal(want) {
+			t.Errorf("messages[%d] EVN.RecordedDateTime got %v, want %v", i, got, want)
+		}
+	}
+	if got := hospital.EventsLen(); got != 0 {
+		t.Errorf("EventsLen() = %d, want 0", got)
+	}
+}
+
+func TestRunPathwayTimeConstraints(t *testing.T) {
+	ctx := context.Background()
+	// now is Monday 2018-02-12 at 00:00; Monday 2018-02-19 is a holiday.
+	cal, err := calendar.New(calendar.Config{Holidays: []string{"2018-02-19"}})
+	if err != nil {
+		t.Fatalf("calendar.New() failed with %v", err)
+	}
+	fourDays := 4 * 24 * time.Hour
+	oneDay := 24 * time.Hour
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{
+				Admission:  &pathway.Admission{Loc: testLoc},
+				Parameters: &pathway.Parameters{Constraints: &pathway.TimeConstraints{NotBefore: "08:00"}},
+			},
+			{Delay: &pathway.Delay{From: fourDays, To: fourDays}},
+			{
+				Transfer:   &pathway.Transfer{Loc: testLocAE},
+				Parameters: &pathway.Parameters{Constraints: &pathway.TimeConstraints{WorkingHoursOf: testLocAE}},
+			},
+			{Delay: &pathway.Delay{From: oneDay, To: oneDay}},
+			{
+				Discharge:  &pathway.Discharge{},
+				Parameters: &pathway.Parameters{Constraints: &pathway.TimeConstraints{WeekdaysOnly: true, NotBefore: "10:00"}},
+			},
+		}},
+	}
+
+	hospital := newHospital(ctx, t, Config{Calendar: cal}, pathways)
+	defer hospital.Close()
+	startPathway(t, hospital, testPathwayName)
+	_, messages := hospital.ConsumeQueues(ctx, t)
+
+	wantMessageTypes := []string{"ADT^A01", "ADT^A02", "ADT^A03"}
+	gotMessageTypes := testhl7.Fields(t, messages, testhl7.MessageType)
+	if diff := cmp.Diff(wantMessageTypes, gotMessageTypes); diff != "" {
+		t.Fatalf("StartPathway(%v) generated message types with diff (-want, +got):\n%s", testPathwayName, diff)
+	}
+	wantTimes := []time.Time{
+		// Not before 08:00.
+		now.Add(8 * time.Hour),
+		// Friday 08:00 is within the default working hours, from 09:00 to 17:00.
+		now.Add(fourDays + 9*time.Hour),
+		// Saturday 09:00 is moved to the next weekday that is not a holiday, Tuesday, at 10:00.
+		now.Add(8*oneDay + 10*time.Hour),
+	}
+	for i, m := range messages {
+		if got, want := testhl7.EVN(t, m).RecordedDateTime.Time, wantTimes[i]; !got.Equal(want) {
+			t.Errorf("messages[%d] EVN.RecordedDateTime got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestRunPathwayFullWardPolicy(t *testing.T) {
+	ctx := context.Background()
+	oneMinute := time.Minute
+	oneHour := time.Hour
+	pathways := map[string]pathway.Pathway{
+		"first": {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: oneHour, To: oneHour}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+		"second": {Pathway: []pathway.Step{
+			{Delay: &pathway.Delay{From: oneMinute, To: oneMinute}},
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	locations := []byte(`
+Ward 1:
+  poc: Ward 1
+  capacity: 1
+
+ED:
+  poc: ED
+  type: ED`)
+
+	tests := []struct {
+		policy           string
+		wantMessageTypes []string
+		wantTimes        []time.Time
+	}{{
+		policy:           FullWardFail,
+		wantMessageTypes: []string{"ADT^A01", "ADT^A03"},
+		wantTimes:        []time.Time{now, now.Add(oneHour)},
+	}, {
+		policy: FullWardWait,
+		// The second admission happens as soon as the first patient is discharged.
+		wantMessageTypes: []string{"ADT^A01", "ADT^A03", "ADT^A01", "ADT^A03"},
+		wantTimes:        []time.Time{now, now.Add(oneHour), now.Add(oneHour), now.Add(oneHour)},
+	}, {
+		policy:           FullWardPending,
+		wantMessageTypes: []string{"ADT^A01", "ADT^A14", "ADT^A03", "ADT^A01", "ADT^A03"},
+		wantTimes:        []time.Time{now, now.Add(oneMinute), now.Add(oneHour), now.Add(oneHour), now.Add(oneHour)},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.policy, func(t *testing.T) {
+			pm, err := pathway.NewDistributionManager(pathways, nil, nil, rand.New(rand.NewSource(1)))
+			if err != nil {
+				t.Fatalf("pathway.NewDistributionManager(%v,%v,%v) failed with %v", pathways, nil, nil, err)
+			}
+			lm, err := location.NewManager(ctx, testwrite.BytesToFile(t, locations))
+			if err != nil {
+				t.Fatalf("location.NewManager() failed with %v", err)
+			}
+			cfg := Config{
+				PathwayManager:  pm,
+				LocationManager: lm,
+				FullWardPolicy:  tc.policy,
+			}
+			hospital := testhospital.WithTime(ctx, t, testhospital.Config{Config: cfg, Arguments: testhospital.Arguments}, now)
+			defer hospital.Close()
+			startPathway(t, hospital, "first", "second")
+			_, messages := hospital.ConsumeQueues(ctx, t)
+
+			gotMessageTypes := testhl7.Fields(t, messages, testhl7.MessageType)
+			if diff := cmp.Diff(tc.wantMessageTypes, gotMessageTypes); diff != "" {
+				t.Fatalf("StartPathway() generated message types with diff (-want, +got):\n%s", diff)
+			}
+			for i, m := range messages {
+				if got, want := testhl7.EVN(t, m).RecordedDateTime.Time, tc.wantTimes[i]; !got.Equal(want) {
+					t.Errorf("messages[%d] EVN.RecordedDateTime got %v, want %v", i, got, want)
+				}
+			}
+			if got := hospital.EventsLen(); got != 0 {
+				t.Errorf("EventsLen() = %d, want 0", got)
+			}
+			if got := lm.RoomManagers[testLoc].OccupiedBeds(); got != 0 {
+				t.Errorf("RoomManagers[%s].OccupiedBeds()=%d, want 0", testLoc, got)
+			}
+		})
+	}
+}
+
+func TestNewHospitalInvalidConfig(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{{
+		name:   "unknown full ward policy",
+		modify: func(c *Config) { c.FullWardPolicy = "unknown" },
+	}, {
+		name:   "returning patients over 1",
+		modify: func(c *Config) { c.ReturningPatients = 1.5 },
+	}, {
+		name: "returning patients deleted from memory",
+		modify: func(c *Config) {
+			c.ReturningPatients = 0.5
+			c.DeletePatientsFromMemory = true
+		},
+	}, {
+		name:   "negative retention",
+		modify: func(c *Config) { c.Retention = RetentionPolicy{Patients: -time.Hour} },
+	}, {
+		name:   "no source of randomness",
+		modify: func(c *Config) { c.Rand = nil },
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := DefaultConfig(ctx, testhospital.Arguments)
+			if err != nil {
+				t.Fatalf("DefaultConfig() failed with %v", err)
+			}
+			tc.modify(&c)
+			if _, err := NewHospital(ctx, c); err == nil {
+				t.Errorf("NewHospital() got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestStartPathwayReturningPatients(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	tests := []struct {
+		returningPatients float64
+		wantSamePatient   bool
+	}{
+		{returningPatients: 0, wantSamePatient: false},
+		{returningPatients: 1, wantSamePatient: true},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v", tc.returningPatients), func(t *testing.T) {
+			hospital := newHospital(ctx, t, Config{ReturningPatients: tc.returningPatients}, pathways)
+			defer hospital.Close()
+			p, err := hospital.PathwayManager.GetPathway(testPathwayName)
+			if err != nil {
+				t.Fatalf("GetPathway(%s) failed with %v", testPathwayName, err)
+			}
+
+			first, err := hospital.StartPathway(p)
+			if err != nil {
+				t.Fatalf("StartPathway(%v) failed with %v", testPathwayName, err)
+			}
+			hospital.ConsumeQueues(ctx, t)
+			second, err := hospital.StartPathway(p)
+			if err != nil {
+				t.Fatalf("StartPathway(%v) failed with %v", testPathwayName, err)
+			}
+			if got := first[0].MRN == second[0].MRN; got != tc.wantSamePatient {
+				t.Errorf("StartPathway() twice got MRNs %q and %q, want same patient? %t", first[0].MRN, second[0].MRN, tc.wantSamePatient)
+			}
+		})
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+		}},
+	}
+	hospital := newHospital(ctx, t, Config{}, pathways)
+	defer hospital.Close()
+
+	other := &testhl7.Sender{}
+	restore := hospital.SetOutput(other, nil)
+	startPathway(t, hospital, testPathwayName)
+	if _, messages := hospital.ConsumeQueues(ctx, t); len(messages) != 0 {
+		t.Errorf("ConsumeQueues() after SetOutput() got %d messages from the hospital's sender, want 0", len(messages))
+	}
+	if got := len(other.GetSentMessages()); got != 1 {
+		t.Errorf("len(other.GetSentMessages()) after SetOutput() got %d, want 1", got)
+	}
+
+	restore()
+	startPathway(t, hospital, testPathwayName)
+	if _, messages := hospital.ConsumeQueues(ctx, t); len(messages) != 1 {
+		t.Errorf("ConsumeQueues() after restore() got %d messages from the hospital's sender, want 1", len(messages))
+	}
+	if got := len(other.GetSentMessages()); got != 1 {
+		t.Errorf("len(other.GetSentMessages()) after restore() got %d, want 1", got)
+	}
+}
+
+func TestStartPathway_SameSeedGeneratesSameMessages(t *testing.T) {
+	ctx := context.Background()
+	twoPersonsPathwayName := "two_persons"
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Minute, To: time.Hour}},
+			{Order: &pathway.Order{OrderID: "order1", OrderProfile: "UREA AND ELECTROLYTES"}},
+			{Result: &pathway.Results{OrderID: "order1"}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+		// The names of both persons are generated from the seed.
+		twoPersonsPathwayName: {
+			Persons: &pathway.Persons{"patient-1": {}, "patient-2": {}},
+			Pathway: []pathway.Step{
+				{UsePatient: &pathway.UsePatient{Patient: "patient-1"}},
+				{Admission: &pathway.Admission{Loc: testLoc}},
+				{UsePatient: &pathway.UsePatient{Patient: "patient-2"}},
+				{Admission: &pathway.Admission{Loc: testLoc}},
+			},
+		},
+	}
+
+	// The test hospital uses a shared address generator that is deterministic but keeps state
+	// across hospitals; reset it for every run.
+	addressGenerator := testaddress.ArbitraryGenerator
+	run := func(seed int64) []string {
+		testaddress.ArbitraryGenerator = addressGenerator
+		pm, err := pathway.NewDistributionManager(pathways, nil, nil, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatalf("pathway.NewDistributionManager(%v,%v,%v) failed with %v", pathways, nil, nil, err)
+		}
+		args := testhospital.Arguments
+		args.Seed = seed
+		cfg := Config{
+			PathwayManager:  pm,
+			LocationManager: testlocation.NewLocationManager(ctx, t, testLoc, testLocAE),
+		}
+		h := testhospital.WithTime(ctx, t, testhospital.Config{Config: cfg, Arguments: args}, now)
+		defer h.Close()
+		startPathway(t, h, testPathwayName, testPathwayName, twoPersonsPathwayName)
+		_, messages := h.ConsumeQueues(ctx, t)
+		return messages
+	}
+
+	first := run(42)
+	second := run(42)
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Errorf("StartPathway(%v) with the same seed generated different messages, diff (-first, +second):\n%s", testPathwayName, diff)
+	}
+	if diff := cmp.Diff(first, run(43)); diff == "" {
+		t.Errorf("StartPathway(%v) with different seeds generated the same messages %v, want different", testPathwayName, first)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/random"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+)
+
+// Snapshot is the complete state of a Hospital at a point in time. Restoring a snapshot in a Hospital
+// created with the same configuration makes it continue exactly where the original Hospital was when
+// the snapshot was taken.
+type Snapshot struct {
+	// Time is the time of the hospital's clock when the snapshot was taken.
+	Time time.Time
+	// Events are the events waiting to run, in the order they run.
+	Events []state.Event
+	// Messages are the messages waiting to be sent, in the order they are sent.
+	Messages []state.HL7Message
+	// Patients are the patients in memory.
+	Patients []state.Patient
+	// OccupiedBeds are the names of the occupied beds, indexed by the name of their location.
+	OccupiedBeds map[string][]string
+	// LastMessageControlID is the last Message Control ID used in a message.
+	LastMessageControlID uint64
+	// Doctors are all the doctors, including the ones added by pathways, in the order they were added.
+	Doctors []*ir.Doctor
+	// ReturningPatients are the MRNs of the patients that can return to the hospital.
+	ReturningPatients []string
+	// Random is the state of the source of randomness.
+	// Only set if Config.RandSource was set.
+	Random *random.State
+	// NextPathwayIndex is the index of the next pathway to run.
+	// Only set if the pathway manager is a *pathway.DeterministicManager.
+	NextPathwayIndex *int
+}
+
+// Snapshot returns the current state of the hospital. Events, messages and pathways do not run while
+// the snapshot is taken.
+func (h *Hospital) Snapshot() (*Snapshot, error) {
+	h.snapshotMu.Lock()
+	defer h.snapshotMu.Unlock()
+
+	s := &Snapshot{
+		Time:                 h.clock.Now(),
+		OccupiedBeds:         h.locationManager.OccupiedBedNames(),
+		LastMessageControlID: h.messageControlGenerator.LastID(),
+		Doctors:              h.doctors.All(),
+		ReturningPatients:    h.returningPatients.all(),
+	}
+	events, err := h.eventQ.Items()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get events")
+	}
+	for _, e := range events {
+		s.Events = append(s.Events, e.(state.Event))
+	}
+	messages, err := h.messageQ.Items()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get messages")
+	}
+	for _, m := range messages {
+		s.Messages = append(s.Messages, m.(state.HL7Message))
+	}
+	for _, p := range h.patients.InMemory() {
+		s.Patients = append(s.Patients, *p)
+	}
+	if h.randSource != nil {
+		st := h.randSource.State()
+		s.Random = &st
+	}
+	if m, ok := h.deterministicManager(); ok {
+		i := m.NextIndex()
+		s.NextPathwayIndex = &i
+	}
+	return s, nil
+}
+
+// Restore replaces the state of the hospital with the given snapshot. The events, messages and
+// patients of the hospital are deleted, including from the item syncers if set, and replaced by the
+// ones in the snapshot. Events, messages and pathways do not run while the snapshot is restored.
+// Restore does not change the hospital's clock: events and messages that were due after the time of the
+// snapshot, but are before the current time of the clock, are due immediately.
+// Restore returns an error if the snapshot does not match the hospital's configuration, e.g., because
+// it refers to locations that don't exist; in this case, the state of the hospital doesn't change.
+func (h *Hospital) Restore(s *Snapshot) error {
+	h.snapshotMu.Lock()
+	defer h.snapshotMu.Unlock()
+
+	// Validate the whole snapshot before changing anything, so that a snapshot that doesn't match
+	// doesn't leave the hospital half-restored.
+	if s.Random != nil && h.randSource == nil {
+		return errors.New("the snapshot has the state of the source of randomness, but the hospital doesn't have a source that can be restored")
+	}
+	m, isDeterministic := h.deterministicManager()
+	if s.NextPathwayIndex != nil {
+		if !isDeterministic {
+			return errors.New("the snapshot has the index of the next pathway, but the hospital's pathway manager is not deterministic")
+		}
+		if err := m.ValidateNextIndex(*s.NextPathwayIndex); err != nil {
+			return errors.Wrap(err, "cannot restore the next pathway")
+		}
+	}
+	if err := h.locationManager.ValidateOccupiedBeds(s.OccupiedBeds); err != nil {
+		return errors.Wrap(err, "cannot restore occupied beds")
+	}
+	events := make([]state.MarshallableQueueItem, len(s.Events))
+	for i, e := range s.Events {
+		if _, err := e.ID(); err != nil {
+			return errors.Wrapf(err, "cannot restore event %d", i)
+		}
+		events[i] = e
+	}
+	messages := make([]state.MarshallableQueueItem, len(s.Messages))
+	for i, msg := range s.Messages {
+		if _, err := msg.ID(); err != nil {
+			return errors.Wrapf(err, "cannot restore message %d", i)
+		}
+		messages[i] = msg
+	}
+
+	// Replace the events and messages first: each queue is replaced atomically, and they only fail
+	// if the queues can't be used at all.
+	all := func(state.MarshallableQueueItem) bool { return true }
+	if _, err := h.eventQ.Update(all, func([]state.MarshallableQueueItem) ([]state.MarshallableQueueItem, error) { return events, nil }); err != nil {
+		return errors.Wrap(err, "cannot restore events")
+	}
+	if _, err := h.messageQ.Update(all, func([]state.MarshallableQueueItem) ([]state.MarshallableQueueItem, error) { return messages, nil }); err != nil {
+		return errors.Wrap(err, "cannot restore messages")
+	}
+	if err := h.locationManager.SetOccupiedBeds(s.OccupiedBeds); err != nil {
+		return errors.Wrap(err, "cannot restore occupied beds")
+	}
+	if s.NextPathwayIndex != nil {
+		if err := m.SetNextIndex(*s.NextPathwayIndex); err != nil {
+			return errors.Wrap(err, "cannot restore the next pathway")
+		}
+	}
+	h.patients.Clear()
+	for _, p := range s.Patients {
+		p := p
+		h.patients.Put(&p)
+	}
+
+	h.messageControlGenerator.SetLastID(s.LastMessageControlID)
+	for _, d := range s.Doctors {
+		if h.doctors.GetByID(d.ID) == nil {
+			h.doctors.Add(d)
+		}
+	}
+	h.returningPatients.set(s.ReturningPatients)
+	if s.Random != nil {
+		h.randSource.Restore(*s.Random)
+	}
+	log.Infof("Restored snapshot taken at %v: %d events, %d messages, %d patients", s.Time, len(s.Events), len(s.Messages), len(s.Patients))
+	return nil
+}
+
+// deterministicManager returns the pathway manager of the hospital if it is a
+// *pathway.DeterministicManager, including if it is wrapped in a *pathway.ReloadableManager.
+func (h *Hospital) deterministicManager() (*pathway.DeterministicManager, bool) {
+	m := h.pathwayManager
+	if r, ok := m.(*pathway.ReloadableManager); ok {
+		m = r.Manager()
+	}
+	d, ok := m.(*pathway.DeterministicManager)
+	return d, ok
+}
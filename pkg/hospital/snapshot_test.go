@@ -0,0 +1,154 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hospital_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	. "github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/test/testhospital"
+	"github.com/Arend-melissant/simhospital/pkg/test/testlocation"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Minute, To: time.Hour}},
+			{Order: &pathway.Order{OrderID: "order1", OrderProfile: "UREA AND ELECTROLYTES"}},
+			{Delay: &pathway.Delay{From: time.Minute, To: time.Hour}},
+			{Result: &pathway.Results{OrderID: "order1"}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	newHospital := func(seed int64, now time.Time) *testhospital.Hospital {
+		pm, err := pathway.NewDistributionManager(pathways, nil, nil, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatalf("pathway.NewDistributionManager(%v,%v,%v) failed with %v", pathways, nil, nil, err)
+		}
+		args := testhospital.Arguments
+		args.Seed = seed
+		cfg := Config{
+			PathwayManager:  pm,
+			LocationManager: testlocation.NewLocationManager(ctx, t, testLoc, testLocAE),
+		}
+		return testhospital.WithTime(ctx, t, testhospital.Config{Config: cfg, Arguments: args}, now)
+	}
+
+	original := newHospital(42, now)
+	defer original.Close()
+	startPathway(t, original, testPathwayName, testPathwayName, testPathwayName)
+	original.ConsumeQueuesWithLimit(ctx, t, 4, true)
+
+	snapshot, err := original.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() failed with %v", err)
+	}
+	// Restore the snapshot as read from a file.
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("json.Marshal(%+v) failed with %v", snapshot, err)
+	}
+	var fromFile Snapshot
+	if err := json.Unmarshal(b, &fromFile); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed with %v", b, err)
+	}
+	if got := len(fromFile.OccupiedBeds[testLoc]); got == 0 {
+		t.Errorf("len(OccupiedBeds[%q]) got %d, want > 0", testLoc, got)
+	}
+
+	restored := newHospital(43, snapshot.Time)
+	defer restored.Close()
+	if err := restored.Restore(&fromFile); err != nil {
+		t.Fatalf("Restore() failed with %v", err)
+	}
+	if diff := cmp.Diff(original.LocationManager.OccupiedBedNames(), restored.LocationManager.OccupiedBedNames()); diff != "" {
+		t.Errorf("OccupiedBedNames() after Restore() diff (-original, +restored):\n%s", diff)
+	}
+
+	_, wantMessages := original.ConsumeQueues(ctx, t)
+	_, gotMessages := restored.ConsumeQueues(ctx, t)
+	if len(wantMessages) == 0 {
+		t.Fatal("ConsumeQueues() in the original hospital got no messages, want some")
+	}
+	if diff := cmp.Diff(wantMessages, gotMessages); diff != "" {
+		t.Errorf("ConsumeQueues() after Restore() diff (-original, +restored):\n%s", diff)
+	}
+}
+
+func TestRestore_UnknownLocation(t *testing.T) {
+	ctx := context.Background()
+	h := newHospital(ctx, t, Config{}, map[string]pathway.Pathway{})
+	defer h.Close()
+	if err := h.Restore(&Snapshot{OccupiedBeds: map[string][]string{"unknown": {"Bed 1"}}}); err == nil {
+		t.Error("Restore() with an unknown location got nil error, want non-nil")
+	}
+}
+
+func TestRestore_InvalidSnapshotChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	pathways := map[string]pathway.Pathway{
+		testPathwayName: {Pathway: []pathway.Step{
+			{Admission: &pathway.Admission{Loc: testLoc}},
+			{Delay: &pathway.Delay{From: time.Hour, To: time.Hour}},
+			{Discharge: &pathway.Discharge{}},
+		}},
+	}
+	pm, err := pathway.NewDeterministicManager(pathways, []string{testPathwayName}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("pathway.NewDeterministicManager(%v) failed with %v", pathways, err)
+	}
+	cfg := Config{
+		PathwayManager:  pm,
+		LocationManager: testlocation.NewLocationManager(ctx, t, testLoc, testLocAE),
+	}
+	h := testhospital.WithTime(ctx, t, testhospital.Config{Config: cfg, Arguments: testhospital.Arguments}, now)
+	defer h.Close()
+	startPathway(t, h, testPathwayName)
+	h.ConsumeQueuesWithLimit(ctx, t, 1, true)
+
+	before, err := h.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() failed with %v", err)
+	}
+	if len(before.OccupiedBeds[testLoc]) == 0 || len(before.Events) == 0 {
+		t.Fatalf("Snapshot() got occupied beds %v and %d events, want some of each", before.OccupiedBeds, len(before.Events))
+	}
+
+	// The beds and the events are valid, but the index of the next pathway is not.
+	outOfRange := 10
+	invalid := &Snapshot{Time: now, NextPathwayIndex: &outOfRange}
+	if err := h.Restore(invalid); err == nil {
+		t.Fatal("Restore() with an index of the next pathway out of range got nil error, want non-nil")
+	}
+
+	after, err := h.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() failed with %v", err)
+	}
+	if diff := cmp.Diff(before.OccupiedBeds, after.OccupiedBeds); diff != "" {
+		t.Errorf("OccupiedBeds after a failed Restore() diff (-before, +after):\n%s", diff)
+	}
+	if got, want := len(after.Events), len(before.Events); got != want {
+		t.Errorf("len(Events) after a failed Restore() got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instances contains an HTTP controller to inspect and control the pathways that are
+// running.
+package instances
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+)
+
+var log = logging.ForCallerPackage()
+
+// Actions that can be applied to a pathway instance.
+const (
+	ActionPause  = "pause"
+	ActionResume = "resume"
+	ActionCancel = "cancel"
+	ActionSkip   = "skip"
+)
+
+// Manager lists and controls the pathway instances that are running.
+// *hospital.Hospital implements Manager.
+type Manager interface {
+	PathwayInstances() ([]hospital.PathwayInstance, error)
+	PausePathwayInstance(id string) error
+	ResumePathwayInstance(id string) error
+	CancelPathwayInstance(id string) error
+	RunNextStepNow(id string) error
+}
+
+// Controller handles requests to inspect and control pathway instances.
+type Controller struct {
+	Manager Manager
+}
+
+// NewController creates a new Controller.
+func NewController(m Manager) *Controller {
+	return &Controller{Manager: m}
+}
+
+// ServeHTTP handles the requests to inspect and control pathway instances.
+// Use a GET request to list the pathway instances that are running, as JSON.
+// Use a POST request with a form-encoded body naming the instance and the action to apply to it,
+// e.g. "id=0123456789ABCDEF&action=pause". The actions are:
+// * pause: none of the steps of the instance run until it is resumed.
+// * resume: resumes a paused instance.
+// * cancel: none of the remaining steps of the instance run.
+// * skip: the next step of the instance runs now, instead of when it was due.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		c.handleGet(w)
+	case "POST":
+		c.handlePost(w, r)
+	case "PUT", "DELETE":
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown method: %q", r.Method), http.StatusInternalServerError)
+	}
+}
+
+func (c *Controller) handleGet(w http.ResponseWriter) {
+	instances, err := c.Manager.PathwayInstances()
+	if err != nil {
+		log.WithError(err).Warning("Failed to list pathway instances")
+		http.Error(w, fmt.Sprintf("Failed to list pathway instances: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(instances); err != nil {
+		log.WithError(err).Warning("Failed to write pathway instances")
+	}
+}
+
+func (c *Controller) handlePost(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	errStr := "Failed to update pathway instance"
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	sbody := strings.TrimSpace(string(body))
+	values, err := url.ParseQuery(sbody)
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+	id, action := values.Get("id"), values.Get("action")
+	if id == "" || action == "" {
+		log.Warningf("%s: missing id or action in request body: %q", errStr, sbody)
+		http.Error(w, `Error extracting values: the request must be in the format "id=X&action=Y"`, http.StatusBadRequest)
+		return
+	}
+	var apply func(string) error
+	switch action {
+	case ActionPause:
+		apply = c.Manager.PausePathwayInstance
+	case ActionResume:
+		apply = c.Manager.ResumePathwayInstance
+	case ActionCancel:
+		apply = c.Manager.CancelPathwayInstance
+	case ActionSkip:
+		apply = c.Manager.RunNextStepNow
+	default:
+		log.Warningf("%s: unknown action %q", errStr, action)
+		http.Error(w, fmt.Sprintf("Unknown action %q; must be one of [%s, %s, %s, %s]", action, ActionPause, ActionResume, ActionCancel, ActionSkip), http.StatusBadRequest)
+		return
+	}
+	if err := apply(id); err != nil {
+		log.WithError(err).WithField("id", id).WithField("action", action).Warning(errStr)
+		status := http.StatusBadRequest
+		if errors.Cause(err) == hospital.ErrPathwayInstanceNotFound {
+			status = http.StatusNotFound
+		}
+		http.Error(w, fmt.Sprintf("%s: %v", errStr, err), status)
+		return
+	}
+	log.WithField("id", id).WithField("action", action).Info("Updated pathway instance")
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instances
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/hospital"
+)
+
+type fakeManager struct {
+	instances []hospital.PathwayInstance
+	// calls records the calls to the methods that update instances, as "method(id)".
+	calls []string
+	err   error
+}
+
+func (f *fakeManager) PathwayInstances() ([]hospital.PathwayInstance, error) {
+	return f.instances, f.err
+}
+
+func (f *fakeManager) record(method string, id string) error {
+	f.calls = append(f.calls, method+"("+id+")")
+	return f.err
+}
+
+func (f *fakeManager) PausePathwayInstance(id string) error  { return f.record("pause", id) }
+func (f *fakeManager) ResumePathwayInstance(id string) error { return f.record("resume", id) }
+func (f *fakeManager) CancelPathwayInstance(id string) error { return f.record("cancel", id) }
+func (f *fakeManager) RunNextStepNow(id string) error        { return f.record("skip", id) }
+
+func TestInstancesHandlerGET(t *testing.T) {
+	next := time.Date(2020, 2, 12, 1, 0, 0, 0, time.UTC)
+	f := &fakeManager{instances: []hospital.PathwayInstance{{
+		ID:            "0123456789ABCDEF",
+		PathwayName:   "pathway",
+		PatientMRN:    "1234",
+		Started:       next.Add(-time.Hour),
+		StepIndex:     2,
+		NextStep:      "Discharge",
+		NextEventTime: &next,
+		Events:        1,
+	}}}
+	ts := httptest.NewServer(NewController(f))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("http.Get() failed with %v", err)
+	}
+	defer res.Body.Close()
+	if got, want := res.StatusCode, http.StatusOK; got != want {
+		t.Errorf("StatusCode got %d, want %d", got, want)
+	}
+	var got []hospital.PathwayInstance
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode() failed with %v", err)
+	}
+	if diff := cmp.Diff(f.instances, got); diff != "" {
+		t.Errorf("GET pathway instances diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestInstancesHandlerPOST(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+		wantCalls  []string
+	}{{
+		name:       "pause",
+		body:       "id=abc&action=pause",
+		wantStatus: http.StatusOK,
+		wantCalls:  []string{"pause(abc)"},
+	}, {
+		name:       "resume",
+		body:       "id=abc&action=resume",
+		wantStatus: http.StatusOK,
+		wantCalls:  []string{"resume(abc)"},
+	}, {
+		name:       "cancel",
+		body:       "id=abc&action=cancel",
+		wantStatus: http.StatusOK,
+		wantCalls:  []string{"cancel(abc)"},
+	}, {
+		name:       "skip",
+		body:       "id=abc&action=skip",
+		wantStatus: http.StatusOK,
+		wantCalls:  []string{"skip(abc)"},
+	}, {
+		name:       "missing id",
+		body:       "action=pause",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "unknown action",
+		body:       "id=abc&action=restart",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "instance not found",
+		body:       "id=abc&action=cancel",
+		err:        errors.Wrap(hospital.ErrPathwayInstanceNotFound, "no pathway instance"),
+		wantStatus: http.StatusNotFound,
+		wantCalls:  []string{"cancel(abc)"},
+	}, {
+		name:       "instance paused",
+		body:       "id=abc&action=skip",
+		err:        errors.Wrap(hospital.ErrPathwayInstancePaused, "cannot run"),
+		wantStatus: http.StatusBadRequest,
+		wantCalls:  []string{"skip(abc)"},
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeManager{err: tc.err}
+			ts := httptest.NewServer(NewController(f))
+			defer ts.Close()
+
+			res, err := http.Post(ts.URL, "application/x-www-form-urlencoded", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("http.Post(%q) failed with %v", tc.body, err)
+			}
+			defer res.Body.Close()
+			if got, want := res.StatusCode, tc.wantStatus; got != want {
+				t.Errorf("StatusCode got %d, want %d", got, want)
+			}
+			if diff := cmp.Diff(tc.wantCalls, f.calls); diff != "" {
+				t.Errorf("calls diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
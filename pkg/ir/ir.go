@@ -18,6 +18,7 @@ package ir
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -504,6 +505,40 @@ func (t *NullTime) GobDecode(data []byte) error {
 	return dec.Decode(&t.Midnight)
 }
 
+// nullTimeJSON is the JSON representation of NullTime.
+type nullTimeJSON struct {
+	Time     time.Time
+	Valid    bool
+	Midnight bool
+}
+
+// MarshalJSON returns the JSON encoding of NullTime.
+// This is necessary to prevent `time.Time.MarshalJSON()` being called instead,
+// which will discard the `Valid` and `Midnight` fields.
+func (t NullTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nullTimeJSON{Time: t.Time, Valid: t.Valid, Midnight: t.Midnight})
+}
+
+// UnmarshalJSON performs the inverse of MarshalJSON.
+// It also accepts a plain time, as NullTime was encoded before MarshalJSON existed; in this case, the
+// NullTime is valid if the time is not zero.
+// It modifies the receiver, so it must take a pointer receiver.
+func (t *NullTime) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		if err := t.Time.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		t.Valid, t.Midnight = !t.Time.IsZero(), false
+		return nil
+	}
+	var j nullTimeJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	t.Time, t.Valid, t.Midnight = j.Time, j.Valid, j.Midnight
+	return nil
+}
+
 // NewMidnightTime returns a NullTime from the given time with Midnight and Valid set.
 func NewMidnightTime(t time.Time) NullTime {
 	return NullTime{
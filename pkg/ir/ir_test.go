@@ -15,6 +15,7 @@
 package ir
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -412,3 +413,33 @@ func wardBed(i int) *PatientLocation {
 		Bed:          fmt.Sprintf("Bed %d", i),
 	}
 }
+
+func TestNullTime_JSON(t *testing.T) {
+	d := time.Date(2018, 2, 12, 0, 0, 0, 0, time.UTC)
+	for _, nt := range []NullTime{NewValidTime(d), NewMidnightTime(d), NewInvalidTime()} {
+		t.Run(fmt.Sprintf("%+v", nt), func(t *testing.T) {
+			b, err := json.Marshal(nt)
+			if err != nil {
+				t.Fatalf("json.Marshal(%+v) failed with %v", nt, err)
+			}
+			var got NullTime
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("json.Unmarshal(%s) failed with %v", b, err)
+			}
+			if diff := cmp.Diff(nt, got); diff != "" {
+				t.Errorf("json.Unmarshal(json.Marshal(%+v)) diff (-want, +got):\n%s", nt, diff)
+			}
+		})
+	}
+}
+
+func TestNullTime_UnmarshalJSON_PlainTime(t *testing.T) {
+	var got NullTime
+	if err := json.Unmarshal([]byte(`"2018-02-12T00:00:00Z"`), &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %v", err)
+	}
+	want := NewValidTime(time.Date(2018, 2, 12, 0, 0, 0, 0, time.UTC))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("json.Unmarshal() diff (-want, +got):\n%s", diff)
+	}
+}
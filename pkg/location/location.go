@@ -18,6 +18,8 @@ package location
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -35,24 +37,47 @@ var (
 	counters        struct {
 		SimulatedHospital struct {
 			OccupiedBeds *prometheus.GaugeVec `help:"Number of occupied beds" labels:"poc"`
+			BedCapacity  *prometheus.GaugeVec `help:"Number of beds, for locations with a limited capacity" labels:"poc"`
 		}
 	}
 	log = logging.ForCallerPackage()
+
+	// ErrNoBedAvailable is returned when a bed cannot be occupied because the location, and all its
+	// overflow locations, are full.
+	ErrNoBedAvailable = errors.New("no bed available")
 )
 
 // Manager is a manager of locations that contains multiple room managers.
+// The methods of Manager are safe for concurrent use.
 type Manager struct {
+	// RoomManagers are the locations, keyed by name. It must not be accessed directly while the
+	// Manager is in use; see Replace.
 	RoomManagers map[string]*RoomManager
+	// mu guards RoomManagers and the occupied beds of the room managers.
+	mu sync.Mutex
 }
 
 // RoomManager is a manager of rooms.
 type RoomManager struct {
-	Poc      string
+	// Poc is the point of care.
+	Poc string
+	// Facility is the facility the point of care is in.
 	Facility string
+	// Building is the building the point of care is in.
 	Building string
-	Floor    string
-	Room     string
-	Type     string
+	// Floor is the floor the point of care is in.
+	Floor string
+	// Room is the room of the point of care.
+	Room string
+	// Type is the type of the location, e.g., "ED".
+	Type string
+	// Capacity is the number of beds in the location.
+	// Optional: if zero or not set, the location has an unlimited number of beds.
+	Capacity int `yaml:"capacity,omitempty"`
+	// Overflow are the names of the locations where patients are given a bed, in order, when this
+	// location is full, e.g., ["WardB01", "WardB02"].
+	// Optional.
+	Overflow []string `yaml:"overflow,omitempty"`
 	// occupiedBeds is a counter of occupied beds for each point of care.
 	// This counter is modified through OccupyAvailableBed and FreeBed.
 	occupiedBeds int
@@ -67,6 +92,12 @@ func init() {
 	}
 }
 
+// FileFormat returns an empty value of the type that locations files are unmarshalled into.
+// It is used to generate the JSON Schema of the files.
+func FileFormat() interface{} {
+	return map[string]*RoomManager{}
+}
+
 // NewManager returns a location Manager.
 func NewManager(ctx context.Context, fileName string) (*Manager, error) {
 	roomManagers := map[string]*RoomManager{}
@@ -93,19 +124,38 @@ func NewManager(ctx context.Context, fileName string) (*Manager, error) {
 			Floor:         rm.Floor,
 			Room:          rm.Room,
 			Type:          t,
+			Capacity:      rm.Capacity,
+			Overflow:      rm.Overflow,
 			occupiedBeds:  0,
 			isBedOccupied: make(map[string]bool),
 		}
 		log.Infof(" - id: %s, poc: %s", n, rm.Poc)
+		if rm.Capacity > 0 {
+			counters.SimulatedHospital.BedCapacity.With(prometheus.Labels{
+				"poc": n,
+			}).Set(float64(rm.Capacity))
+		}
 	}
 	if _, ok := roomManagers[aAndEID]; !ok {
 		return nil, fmt.Errorf("no ED Location found, this is a required Location. File: %s", fileName)
 	}
+	for n, rm := range roomManagers {
+		if rm.Capacity < 0 {
+			return nil, fmt.Errorf("invalid capacity %d of location %s, it must not be negative. File: %s", rm.Capacity, n, fileName)
+		}
+		for _, o := range rm.Overflow {
+			if _, ok := roomManagers[o]; !ok {
+				return nil, fmt.Errorf("unknown overflow location %s of location %s. File: %s", o, n, fileName)
+			}
+		}
+	}
 	return &Manager{RoomManagers: roomManagers}, nil
 }
 
 // GetAAndELocation returns the ED location.
 func (m *Manager) GetAAndELocation() *ir.PatientLocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	roomManager := m.RoomManagers[aAndEID]
 	if roomManager == nil {
 		return &ir.PatientLocation{LocationType: "ED"}
@@ -118,18 +168,76 @@ func (m *Manager) GetAAndELocation() *ir.PatientLocation {
 	}
 }
 
+// Location returns the given location without a bed, e.g., to refer to a ward that a patient is
+// expected to be admitted to.
+// Returns an error if the location doesn't exist.
+func (m *Manager) Location(locationName string) (*ir.PatientLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roomManager, ok := m.RoomManagers[locationName]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", unknownLocation, locationName)
+	}
+	return &ir.PatientLocation{
+		Poc:          roomManager.Poc,
+		Room:         roomManager.Room,
+		Facility:     roomManager.Facility,
+		LocationType: roomManager.Type,
+		Building:     roomManager.Building,
+		Floor:        roomManager.Floor,
+	}, nil
+}
+
+// HasAvailableBed returns whether a bed can be occupied in the given location or any of its overflow
+// locations.
+func (m *Manager) HasAvailableBed(locationName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roomManager, ok := m.RoomManagers[locationName]
+	if !ok {
+		return false
+	}
+	if !roomManager.isFull() {
+		return true
+	}
+	for _, o := range roomManager.Overflow {
+		if !m.RoomManagers[o].isFull() {
+			return true
+		}
+	}
+	return false
+}
+
 // OccupyAvailableBed picks an available bed in the given location and occupies it.
 // This would be equivalent to assigning a patient to a bed.
-// Returns an error if the location doesn't exist.
+// If the location is full, the bed is picked from the first overflow location that isn't full.
+// Returns an error if the location doesn't exist, or an error that wraps ErrNoBedAvailable if the
+// location and all its overflow locations are full.
 func (m *Manager) OccupyAvailableBed(locationName string) (*ir.PatientLocation, error) {
-	if _, ok := m.RoomManagers[locationName]; !ok {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.occupyAvailableBed(locationName)
+}
+
+func (m *Manager) occupyAvailableBed(locationName string) (*ir.PatientLocation, error) {
+	roomManager, ok := m.RoomManagers[locationName]
+	if !ok {
 		return nil, fmt.Errorf("%s: %s", unknownLocation, locationName)
 	}
+	if roomManager.isFull() {
+		for _, o := range roomManager.Overflow {
+			if !m.RoomManagers[o].isFull() {
+				log.Debugf("Location %s is full, overflowing to %s", locationName, o)
+				return m.occupyAvailableBed(o)
+			}
+		}
+		return nil, errors.Wrapf(ErrNoBedAvailable, "location %q and its overflow locations are full", locationName)
+	}
 
 	// Bed names start in Bed 1 as that's how most humans count.
 	for i := 1; ; i++ {
 		bedName := fmt.Sprintf("Bed %d", i)
-		if location, err := m.OccupySpecificBed(locationName, bedName); err == nil {
+		if location, err := m.occupySpecificBed(locationName, bedName); err == nil {
 			return location, nil
 		}
 	}
@@ -138,6 +246,12 @@ func (m *Manager) OccupyAvailableBed(locationName string) (*ir.PatientLocation,
 // OccupySpecificBed occupies the given bed in the given location.
 // Returns an error if the location doesn't exist, or the bed is already occupied.
 func (m *Manager) OccupySpecificBed(locationName string, bedName string) (*ir.PatientLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.occupySpecificBed(locationName, bedName)
+}
+
+func (m *Manager) occupySpecificBed(locationName string, bedName string) (*ir.PatientLocation, error) {
 	roomManager, ok := m.RoomManagers[locationName]
 	if !ok {
 		return nil, fmt.Errorf("%s: %s", unknownLocation, locationName)
@@ -145,6 +259,9 @@ func (m *Manager) OccupySpecificBed(locationName string, bedName string) (*ir.Pa
 	if roomManager.isBedOccupied[bedName] {
 		return nil, fmt.Errorf("bed %q in location %q already occupied", bedName, locationName)
 	}
+	if roomManager.isFull() {
+		return nil, errors.Wrapf(ErrNoBedAvailable, "location %q is full", locationName)
+	}
 	roomManager.isBedOccupied[bedName] = true
 	roomManager.occupiedBeds++
 	counters.SimulatedHospital.OccupiedBeds.With(prometheus.Labels{
@@ -168,8 +285,10 @@ func (m *Manager) FreeBed(pl *ir.PatientLocation) error {
 	if !IsBed(pl) {
 		return fmt.Errorf("patient location %+v is not a bed", pl)
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	for key, roomManager := range m.RoomManagers {
-		matches, err := m.Matches(key, pl)
+		matches, err := m.matches(key, pl)
 		if err != nil {
 			return errors.Wrapf(err, "finding matching location for %+v failed", pl)
 		}
@@ -183,10 +302,11 @@ func (m *Manager) FreeBed(pl *ir.PatientLocation) error {
 		}
 		roomManager.isBedOccupied[pl.Bed] = false
 		roomManager.occupiedBeds--
+		// The gauge is labelled with the location name, as when the bed was occupied.
 		counters.SimulatedHospital.OccupiedBeds.With(prometheus.Labels{
-			"poc": pl.Poc,
+			"poc": key,
 		}).Set(float64(roomManager.occupiedBeds))
-		log.Debugf("Occupied beds in %s: %d", pl.Poc, roomManager.occupiedBeds)
+		log.Debugf("Occupied beds in %s: %d", key, roomManager.occupiedBeds)
 		return nil
 	}
 	return fmt.Errorf("%s: %+v", unknownLocation, pl)
@@ -195,6 +315,12 @@ func (m *Manager) FreeBed(pl *ir.PatientLocation) error {
 // Matches returns whether the location name matches the patient location.
 // If pl is nil, this method returns an error.
 func (m *Manager) Matches(locationName string, pl *ir.PatientLocation) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.matches(locationName, pl)
+}
+
+func (m *Manager) matches(locationName string, pl *ir.PatientLocation) (bool, error) {
 	if pl == nil {
 		return false, errors.New("nil patient location")
 	}
@@ -205,11 +331,115 @@ func (m *Manager) Matches(locationName string, pl *ir.PatientLocation) (bool, er
 	return roomManager.equalToPatientLocation(pl), nil
 }
 
+// Names returns the names of all the locations, sorted.
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.RoomManagers))
+	for n := range m.RoomManagers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Replace replaces the locations of the Manager with the locations of other, e.g., to reload the
+// locations file while Simulated Hospital runs. The beds that are occupied stay occupied in the new
+// locations with the same names, even if their capacity is now lower. other must not be used after
+// calling Replace.
+// Returns an error if a location with occupied beds doesn't exist in other; in this case, the
+// locations don't change.
+func (m *Manager) Replace(other *Manager) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for n, rm := range m.RoomManagers {
+		if _, ok := other.RoomManagers[n]; !ok && rm.occupiedBeds > 0 {
+			return fmt.Errorf("location %s has %d occupied bed(s), so it cannot be removed", n, rm.occupiedBeds)
+		}
+	}
+	for n, rm := range other.RoomManagers {
+		old, ok := m.RoomManagers[n]
+		if !ok {
+			continue
+		}
+		rm.isBedOccupied = old.isBedOccupied
+		rm.occupiedBeds = old.occupiedBeds
+	}
+	m.RoomManagers = other.RoomManagers
+	return nil
+}
+
+// OccupiedBedNames returns the names of the beds that are currently occupied, sorted, indexed by the
+// name of their location. Locations without occupied beds are not included.
+func (m *Manager) OccupiedBedNames() map[string][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	occupied := map[string][]string{}
+	for n, rm := range m.RoomManagers {
+		for bed, ok := range rm.isBedOccupied {
+			if ok {
+				occupied[n] = append(occupied[n], bed)
+			}
+		}
+		if beds, ok := occupied[n]; ok {
+			sort.Strings(beds)
+		}
+	}
+	return occupied
+}
+
+// ValidateOccupiedBeds returns the error that SetOccupiedBeds would return for the given beds, if any,
+// without changing any beds.
+func (m *Manager) ValidateOccupiedBeds(occupied map[string][]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.validateOccupiedBeds(occupied)
+}
+
+func (m *Manager) validateOccupiedBeds(occupied map[string][]string) error {
+	for n := range occupied {
+		if _, ok := m.RoomManagers[n]; !ok {
+			return fmt.Errorf("%s: %s", unknownLocation, n)
+		}
+	}
+	return nil
+}
+
+// SetOccupiedBeds frees all beds and then occupies the given beds, indexed by the name of their location,
+// e.g., to restore a snapshot of a hospital. The capacity of the locations is not checked, so that
+// a snapshot can be restored even if the capacities have changed since it was taken.
+// Returns an error if any of the locations doesn't exist; in this case, no beds are changed.
+func (m *Manager) SetOccupiedBeds(occupied map[string][]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.validateOccupiedBeds(occupied); err != nil {
+		return err
+	}
+	for n, rm := range m.RoomManagers {
+		rm.isBedOccupied = make(map[string]bool)
+		for _, bed := range occupied[n] {
+			rm.isBedOccupied[bed] = true
+		}
+		rm.occupiedBeds = len(rm.isBedOccupied)
+		counters.SimulatedHospital.OccupiedBeds.With(prometheus.Labels{
+			"poc": n,
+		}).Set(float64(rm.occupiedBeds))
+	}
+	return nil
+}
+
 // OccupiedBeds returns the number of beds that are currently occupied.
+// It must not be called while the beds are being occupied or freed through the Manager.
 func (r *RoomManager) OccupiedBeds() int {
 	return r.occupiedBeds
 }
 
+// isFull returns whether all the beds in the location are occupied.
+// Locations without a capacity are never full.
+func (r *RoomManager) isFull() bool {
+	return r.Capacity > 0 && r.occupiedBeds >= r.Capacity
+}
+
 func (r *RoomManager) equalToPatientLocation(pl *ir.PatientLocation) bool {
 	return r.Poc == pl.Poc && r.Facility == pl.Facility &&
 		r.Building == pl.Building && r.Floor == pl.Floor && r.Room == pl.Room
@@ -16,9 +16,11 @@ package location_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	. "github.com/Arend-melissant/simhospital/pkg/location"
@@ -85,6 +87,40 @@ Ward 1:
   floor: 7
   room: Room-1`)
 
+	withCapacity := []byte(`
+Ward 1:
+  poc: Ward 1
+  room: Room-1
+  capacity: 2
+  overflow: [Ward 2]
+
+Ward 2:
+  poc: Ward 2
+  room: Room-2
+
+ED:
+  poc: ED
+  room: Room-3
+  type: ED`)
+
+	negativeCapacity := []byte(`
+Ward 1:
+  poc: Ward 1
+  capacity: -1
+
+ED:
+  poc: ED
+  type: ED`)
+
+	unknownOverflow := []byte(`
+Ward 1:
+  poc: Ward 1
+  overflow: [Ward 2]
+
+ED:
+  poc: ED
+  type: ED`)
+
 	invalid := []byte(`
 poc: Ward 1
 facility: Simulated Hospital
@@ -129,6 +165,38 @@ room: Room-1`)
 					},
 				},
 			},
+		}, {
+			name:       "with capacity",
+			locContent: withCapacity,
+			want: &Manager{
+				RoomManagers: map[string]*RoomManager{
+					"Ward 1": {
+						Poc:      "Ward 1",
+						Room:     "Room-1",
+						Type:     "BED",
+						Capacity: 2,
+						Overflow: []string{"Ward 2"},
+					},
+					"Ward 2": {
+						Poc:  "Ward 2",
+						Room: "Room-2",
+						Type: "BED",
+					},
+					"ED": {
+						Poc:  "ED",
+						Room: "Room-3",
+						Type: "ED",
+					},
+				},
+			},
+		}, {
+			name:       "negative capacity",
+			locContent: negativeCapacity,
+			wantErr:    true,
+		}, {
+			name:       "unknown overflow location",
+			locContent: unknownOverflow,
+			wantErr:    true,
 		}, {
 			name:       "invalid yml",
 			locContent: invalid,
@@ -153,7 +221,7 @@ room: Room-1`)
 				return
 			}
 
-			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreUnexported(RoomManager{})); diff != "" {
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreUnexported(Manager{}, RoomManager{})); diff != "" {
 				t.Errorf("NewManager(%s) got diff (-want, +got):\n%s", string(tc.locContent), diff)
 			}
 		})
@@ -231,6 +299,160 @@ func TestManagerOccupyAvailableBedReoccupyFreedBed(t *testing.T) {
 	}
 }
 
+func TestManagerOccupyAvailableBedCapacity(t *testing.T) {
+	ctx := context.Background()
+	fName := testwrite.BytesToFile(t, []byte(`
+Ward 1:
+  poc: Ward 1
+  capacity: 1
+  overflow: [Ward 2]
+
+Ward 2:
+  poc: Ward 2
+  capacity: 1
+
+ED:
+  poc: ED
+  type: ED`))
+	locationManager, err := NewManager(ctx, fName)
+	if err != nil {
+		t.Fatalf("NewManager(%s) failed with %v", fName, err)
+	}
+
+	wantPocs := []string{"Ward 1", "Ward 2"}
+	var occupied []*ir.PatientLocation
+	for _, wantPoc := range wantPocs {
+		if !locationManager.HasAvailableBed("Ward 1") {
+			t.Errorf("HasAvailableBed(%s) got false, want true", "Ward 1")
+		}
+		got, err := locationManager.OccupyAvailableBed("Ward 1")
+		if err != nil {
+			t.Fatalf("OccupyAvailableBed(%s) failed with %v", "Ward 1", err)
+		}
+		if got.Poc != wantPoc {
+			t.Errorf("OccupyAvailableBed(%s).Poc=%q, want %q", "Ward 1", got.Poc, wantPoc)
+		}
+		occupied = append(occupied, got)
+	}
+
+	// Both the location and its overflow location are full.
+	if locationManager.HasAvailableBed("Ward 1") {
+		t.Errorf("HasAvailableBed(%s) got true, want false", "Ward 1")
+	}
+	if _, err := locationManager.OccupyAvailableBed("Ward 1"); !errors.Is(err, ErrNoBedAvailable) {
+		t.Errorf("OccupyAvailableBed(%s) got err %v, want %v", "Ward 1", err, ErrNoBedAvailable)
+	}
+	if _, err := locationManager.OccupySpecificBed("Ward 2", "Bed 2"); !errors.Is(err, ErrNoBedAvailable) {
+		t.Errorf("OccupySpecificBed(%s, %s) got err %v, want %v", "Ward 2", "Bed 2", err, ErrNoBedAvailable)
+	}
+
+	// Freeing a bed in the overflow location makes it available again.
+	if err := locationManager.FreeBed(occupied[1]); err != nil {
+		t.Fatalf("FreeBed(%v) failed with %v", occupied[1], err)
+	}
+	if !locationManager.HasAvailableBed("Ward 1") {
+		t.Errorf("HasAvailableBed(%s) got false, want true", "Ward 1")
+	}
+	got, err := locationManager.OccupyAvailableBed("Ward 1")
+	if err != nil {
+		t.Fatalf("OccupyAvailableBed(%s) failed with %v", "Ward 1", err)
+	}
+	if got.Poc != "Ward 2" {
+		t.Errorf("OccupyAvailableBed(%s).Poc=%q, want %q", "Ward 1", got.Poc, "Ward 2")
+	}
+}
+
+func TestManagerOccupyAvailableBedConcurrently(t *testing.T) {
+	ctx := context.Background()
+	fName := testwrite.BytesToFile(t, []byte(`
+Ward 1:
+  poc: Ward 1
+  capacity: 10
+  overflow: [Ward 2]
+
+Ward 2:
+  poc: Ward 2
+  capacity: 10
+
+ED:
+  poc: ED
+  type: ED`))
+	locationManager, err := NewManager(ctx, fName)
+	if err != nil {
+		t.Fatalf("NewManager(%s) failed with %v", fName, err)
+	}
+
+	// More patients than beds are admitted at the same time.
+	const patients = 30
+	var wg sync.WaitGroup
+	results := make(chan *ir.PatientLocation, patients)
+	errs := make(chan error, patients)
+	for i := 0; i < patients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := locationManager.OccupyAvailableBed("Ward 1")
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- l
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	beds := map[string]bool{}
+	for l := range results {
+		bed := l.Poc + "/" + l.Bed
+		if beds[bed] {
+			t.Errorf("OccupyAvailableBed(%s) returned bed %q more than once", "Ward 1", bed)
+		}
+		beds[bed] = true
+	}
+	if got, want := len(beds), 20; got != want {
+		t.Errorf("OccupyAvailableBed(%s) occupied %d beds, want %d", "Ward 1", got, want)
+	}
+	for err := range errs {
+		if !errors.Is(err, ErrNoBedAvailable) {
+			t.Errorf("OccupyAvailableBed(%s) got err %v, want %v", "Ward 1", err, ErrNoBedAvailable)
+		}
+	}
+	got := locationManager.OccupiedBedNames()
+	if got, want := len(got["Ward 1"])+len(got["Ward 2"]), 20; got != want {
+		t.Errorf("OccupiedBedNames() got %d beds, want %d", got, want)
+	}
+}
+
+func TestManagerLocation(t *testing.T) {
+	ctx := context.Background()
+	locationManager := testlocation.NewLocationManager(ctx, t, aAndEID)
+
+	got, err := locationManager.Location(aAndEID)
+	if err != nil {
+		t.Fatalf("Location(%s) failed with %v", aAndEID, err)
+	}
+	want := &ir.PatientLocation{
+		Poc:          "ED",
+		Facility:     "Simulated Hospital",
+		Building:     "Building-1",
+		Floor:        "7",
+		Room:         "Room-1",
+		LocationType: "BED",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Location(%s) got diff (-want, +got):\n%s", aAndEID, diff)
+	}
+	if got := locationManager.RoomManagers[aAndEID].OccupiedBeds(); got != 0 {
+		t.Errorf("RoomManagers[%s].OccupiedBeds()=%d, want 0", aAndEID, got)
+	}
+
+	if _, err := locationManager.Location("unknown-ward"); err == nil {
+		t.Errorf("Location(%s) got nil error, want error", "unknown-ward")
+	}
+}
+
 func TestManagerOccupySpecificBed(t *testing.T) {
 	ctx := context.Background()
 	cases := []struct {
@@ -346,6 +568,113 @@ func TestManagerFreeBed(t *testing.T) {
 	}
 }
 
+func TestManagerSetOccupiedBeds(t *testing.T) {
+	ctx := context.Background()
+	locationManager := testlocation.NewLocationManager(ctx, t, aAndEID)
+	if _, err := locationManager.OccupyAvailableBed(aAndEID); err != nil {
+		t.Fatalf("OccupyAvailableBed(%s) failed with %v", aAndEID, err)
+	}
+
+	occupied := map[string][]string{aAndEID: {"Bed 3", bed2}}
+	if err := locationManager.SetOccupiedBeds(occupied); err != nil {
+		t.Fatalf("SetOccupiedBeds(%v) failed with %v", occupied, err)
+	}
+	want := map[string][]string{aAndEID: {bed2, "Bed 3"}}
+	if diff := cmp.Diff(want, locationManager.OccupiedBedNames()); diff != "" {
+		t.Errorf("OccupiedBedNames() got diff (-want, +got):\n%s", diff)
+	}
+	if got, want := locationManager.RoomManagers[aAndEID].OccupiedBeds(), 2; got != want {
+		t.Errorf("OccupiedBeds() got %d, want %d", got, want)
+	}
+	// Bed 1 was freed, so it is the next available bed.
+	got, err := locationManager.OccupyAvailableBed(aAndEID)
+	if err != nil {
+		t.Fatalf("OccupyAvailableBed(%s) failed with %v", aAndEID, err)
+	}
+	if diff := cmp.Diff(aAndEBed1, got); diff != "" {
+		t.Errorf("OccupyAvailableBed(%s) got diff (-want, +got):\n%s", aAndEID, diff)
+	}
+
+	unknown := map[string][]string{"unknown": {"Bed 1"}}
+	if err := locationManager.SetOccupiedBeds(unknown); err == nil {
+		t.Errorf("SetOccupiedBeds(%v) got nil err, want not nil error", unknown)
+	}
+}
+
+func TestManagerReplace(t *testing.T) {
+	ctx := context.Background()
+	newManager := func(content string) *Manager {
+		t.Helper()
+		fName := testwrite.BytesToFile(t, []byte(content))
+		m, err := NewManager(ctx, fName)
+		if err != nil {
+			t.Fatalf("NewManager(%s) failed with %v", content, err)
+		}
+		return m
+	}
+	locationManager := newManager(`
+ED:
+  poc: ED
+  facility: Simulated Hospital
+Ward1:
+  poc: Ward1
+  facility: Simulated Hospital
+  capacity: 2
+Ward2:
+  poc: Ward2
+  facility: Simulated Hospital
+`)
+	if _, err := locationManager.OccupyAvailableBed("Ward1"); err != nil {
+		t.Fatalf("OccupyAvailableBed(Ward1) failed with %v", err)
+	}
+
+	// Ward1 has an occupied bed, so it cannot be removed.
+	withoutWard1 := newManager(`
+ED:
+  poc: ED
+  facility: Simulated Hospital
+Ward2:
+  poc: Ward2
+  facility: Simulated Hospital
+`)
+	if err := locationManager.Replace(withoutWard1); err == nil {
+		t.Error("Replace() without a location with occupied beds got nil err, want not nil error")
+	}
+	if _, err := locationManager.Location("Ward1"); err != nil {
+		t.Errorf("Location(Ward1) after a failed Replace() failed with %v", err)
+	}
+
+	// Ward2 can be removed, and the capacity of Ward1 can be lowered.
+	reloaded := newManager(`
+ED:
+  poc: ED
+  facility: Simulated Hospital
+Ward1:
+  poc: Ward1
+  facility: Simulated Hospital
+  capacity: 1
+Ward3:
+  poc: Ward3
+  facility: Simulated Hospital
+`)
+	if err := locationManager.Replace(reloaded); err != nil {
+		t.Fatalf("Replace() failed with %v", err)
+	}
+	if diff := cmp.Diff([]string{"ED", "Ward1", "Ward3"}, locationManager.Names()); diff != "" {
+		t.Errorf("Names() got diff (-want, +got):\n%s", diff)
+	}
+	want := map[string][]string{"Ward1": {"Bed 1"}}
+	if diff := cmp.Diff(want, locationManager.OccupiedBedNames()); diff != "" {
+		t.Errorf("OccupiedBedNames() got diff (-want, +got):\n%s", diff)
+	}
+	if locationManager.HasAvailableBed("Ward1") {
+		t.Error("HasAvailableBed(Ward1) got true, want false")
+	}
+	if err := locationManager.FreeBed(&ir.PatientLocation{Poc: "Ward1", Facility: "Simulated Hospital", Bed: "Bed 1"}); err != nil {
+		t.Errorf("FreeBed() of a bed occupied before Replace() failed with %v", err)
+	}
+}
+
 func TestManagerFreeBedError(t *testing.T) {
 	ctx := context.Background()
 	locationManager := testlocation.NewLocationManager(ctx, t, aAndEID)
@@ -867,6 +867,13 @@ func BuildUpdatePatientADTA08(h *HeaderInfo, p *ir.PatientInfo, eventTime time.T
 	}
 	segments = append(segments, pid)
 	segments = append(segments, BuildPseudoPV1())
+	for id, ap := range p.AssociatedParties {
+		nk1, err := BuildNK1(id, ap)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot build NK1 segment")
+		}
+		segments = append(segments, nk1)
+	}
 	for id, al := range p.Allergies {
 		al1, err := BuildAL1(id, al)
 		if err != nil {
@@ -1095,6 +1102,13 @@ func BuildAddPersonADTA28(h *HeaderInfo, p *ir.PatientInfo, eventTime time.Time,
 	}
 	segments = append(segments, pd1)
 	segments = append(segments, BuildPseudoPV1())
+	for id, ap := range p.AssociatedParties {
+		nk1, err := BuildNK1(id, ap)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot build NK1 segment")
+		}
+		segments = append(segments, nk1)
+	}
 	for id, al := range p.Allergies {
 		al1, err := BuildAL1(id, al)
 		if err != nil {
@@ -1132,6 +1146,13 @@ func BuildUpdatePersonADTA31(h *HeaderInfo, p *ir.PatientInfo, eventTime time.Ti
 	}
 	segments = append(segments, pid)
 	segments = append(segments, BuildPseudoPV1())
+	for id, ap := range p.AssociatedParties {
+		nk1, err := BuildNK1(id, ap)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot build NK1 segment")
+		}
+		segments = append(segments, nk1)
+	}
 	for id, al := range p.Allergies {
 		al1, err := BuildAL1(id, al)
 		if err != nil {
@@ -19,6 +19,8 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
+	"sync"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
@@ -26,14 +28,17 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/constants"
 	"github.com/Arend-melissant/simhospital/pkg/files"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // OrderProfiles contains Order Profile information.
+// The methods of OrderProfiles are safe for concurrent use.
 type OrderProfiles struct {
 	// op is a map of Order Profiles keyed by their names.
 	op map[string]*OrderProfile
 	// names is a slice of all Order Profile names.
 	names []string
+	mu    sync.RWMutex
 }
 
 // OrderProfile contains details of an Order Profile.
@@ -85,6 +90,8 @@ func New(m map[string]*OrderProfile) *OrderProfiles {
 	for k := range m {
 		keys = append(keys, k)
 	}
+	// Sorted so that the random order profiles only depend on the source of randomness.
+	sort.Strings(keys)
 
 	return &OrderProfiles{
 		op:    m,
@@ -94,18 +101,30 @@ func New(m map[string]*OrderProfile) *OrderProfiles {
 
 // Get returns an OrderProfile for the given Order Profile name.
 func (op *OrderProfiles) Get(name string) (*OrderProfile, bool) {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
 	v, ok := op.op[name]
 	return v, ok
 }
 
+// Len returns the number of Order Profiles.
+func (op *OrderProfiles) Len() int {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	return len(op.names)
+}
+
 // Generate returns a CodedElement for the given name.
 // If the name is constants.RandomString, it returns a CodedElement for a random Order Profile.
 // If the name is a name of any existing Order Profile, the CodedElement for that Order Profile
 // is returned.
 // Otherwise, returns CodedElement with ID and Text equal to given name.
-func (op *OrderProfiles) Generate(name string) *ir.CodedElement {
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func (op *OrderProfiles) Generate(rng *rand.Rand, name string) *ir.CodedElement {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
 	if name == constants.RandomString {
-		name = op.names[rand.Intn(len(op.names))]
+		name = op.names[random.Or(rng).Intn(len(op.names))]
 	}
 	if v, ok := op.op[name]; ok {
 		return &v.UniversalService
@@ -115,12 +134,26 @@ func (op *OrderProfiles) Generate(name string) *ir.CodedElement {
 	return &ir.CodedElement{ID: name, Text: name}
 }
 
+// Replace replaces the Order Profiles with the ones in other, e.g., to reload the order profiles file
+// while Simulated Hospital runs.
+func (op *OrderProfiles) Replace(other *OrderProfiles) {
+	other.mu.RLock()
+	m, names := other.op, other.names
+	other.mu.RUnlock()
+
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.op = m
+	op.names = names
+}
+
 // RandomisedValueWithFlag generates a random value for the given type.
 // Returns:
 // - the value, which is either defaultValue if set, or the random value generated using the valueGenerator.
 // - abnormal flag, HIGH if randomType is ABNORMAL_HIGH, LOW if randomType is ABNORMAL_LOW, or else an empty string.
 // - an error if something went wrong.
-func (tt *TestType) RandomisedValueWithFlag(randomType string) (string, constants.AbnormalFlag, error) {
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func (tt *TestType) RandomisedValueWithFlag(rng *rand.Rand, randomType string) (string, constants.AbnormalFlag, error) {
 	abnormalFlag := constants.FromRandomType(randomType)
 
 	if tt.defaultValue.valid {
@@ -128,26 +161,44 @@ func (tt *TestType) RandomisedValueWithFlag(randomType string) (string, constant
 			return tt.defaultValue.value, abnormalFlag, nil
 		}
 	}
-	v, err := tt.ValueGenerator.Random(randomType)
+	v, err := tt.ValueGenerator.Random(rng, randomType)
 	if err != nil {
 		return "", "", errors.Wrap(err, "cannot generate random value with flag")
 	}
 	return fmt.Sprintf("%s%s", tt.valuePrefix, v), abnormalFlag, nil
 }
 
+// tt is a test type in an order profiles file.
 type tt struct {
-	ID           string
+	// ID is the identifier of the test type.
+	ID string
+	// CodingSystem is the coding system of the test type.
+	// If not set, the coding system of the order profile is used.
 	CodingSystem string `yaml:"coding_system"`
-	ValueType    string `yaml:"value_type"`
-	Value        string
-	Unit         string
-	RefRange     string `yaml:"ref_range"`
+	// ValueType is the HL7 value type of the results, e.g., "NM" for numerical values.
+	ValueType string `yaml:"value_type"`
+	// Value is an example value of the results.
+	Value string
+	// Unit is the unit of the results.
+	Unit string
+	// RefRange is the reference range of the results, e.g., "[ < 4.5]".
+	RefRange string `yaml:"ref_range"`
 }
 
+// op is an order profile in an order profiles file.
 type op struct {
-	UniversalServiceID string        `yaml:"universal_service_id"`
-	CodingSystem       string        `yaml:"coding_system"`
-	TestTypes          map[string]tt `yaml:"test_types"`
+	// UniversalServiceID is the identifier of the order profile.
+	UniversalServiceID string `yaml:"universal_service_id"`
+	// CodingSystem is the coding system of the order profile.
+	CodingSystem string `yaml:"coding_system"`
+	// TestTypes are the test types of the order profile, indexed by name.
+	TestTypes map[string]tt `yaml:"test_types"`
+}
+
+// FileFormat returns an empty value of the type that order profiles files are unmarshalled into.
+// It is used to generate the JSON Schema of the files.
+func FileFormat() interface{} {
+	return map[string]op{}
 }
 
 func testType(ttName string, ttValue tt, codingSystem string) *TestType {
@@ -282,7 +282,7 @@ func TestRandomisedValueWithFlag_NumericalValue(t *testing.T) {
 			}
 			for _, rType := range randomTypes {
 				t.Run(rType.randomType, func(t *testing.T) {
-					gotValue, gotFlag, err := tt.RandomisedValueWithFlag(rType.randomType)
+					gotValue, gotFlag, err := tt.RandomisedValueWithFlag(nil, rType.randomType)
 					if err != nil {
 						t.Fatalf("[%+v].RandomisedValueWithFlag(%v) failed with %v", tt, rType.randomType, err)
 					}
@@ -384,7 +384,7 @@ func TestRandomisedValueWithFlag_NonNumericalValue(t *testing.T) {
 
 		for _, rType := range randomTypes {
 			t.Run(fmt.Sprintf("%s-%s", tc.name, rType.randomType), func(t *testing.T) {
-				gotValue, gotFlag, err := tt.RandomisedValueWithFlag(rType.randomType)
+				gotValue, gotFlag, err := tt.RandomisedValueWithFlag(nil, rType.randomType)
 				if err != nil {
 					t.Fatalf("[%+v].RandomisedValueWithFlag(%v) failed with %v", tt, rType.randomType, err)
 				}
@@ -438,7 +438,7 @@ func TestRandomisedValueWithFlagRange_Error(t *testing.T) {
 			}
 
 			// The value_type is NM, but we cannot parse the range, so always use default value.
-			gotValue, gotFlag, err := tt.RandomisedValueWithFlag(constants.NormalValue)
+			gotValue, gotFlag, err := tt.RandomisedValueWithFlag(nil, constants.NormalValue)
 			if err != nil {
 				t.Fatalf("RandomisedValueWithFlag(%v) failed with %v", constants.NormalValue, err)
 			}
@@ -454,7 +454,7 @@ func TestRandomisedValueWithFlagRange_Error(t *testing.T) {
 
 			for _, val := range randomisedValues {
 				t.Run(fmt.Sprintf("%s-%s", tc.name, val), func(t *testing.T) {
-					if _, _, err := tt.RandomisedValueWithFlag(val); err == nil {
+					if _, _, err := tt.RandomisedValueWithFlag(nil, val); err == nil {
 						t.Errorf("RandomisedValueWithFlag(%v) returned nil error, want non-nil error", val)
 					}
 				})
@@ -491,7 +491,7 @@ func TestGenerate(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := orderProfiles.Generate(tc.input)
+			got := orderProfiles.Generate(nil, tc.input)
 			if diff := cmp.Diff(tc.want, got); diff != "" {
 				t.Errorf("Generate(%v) -want, +got:\n%s", tc.input, diff)
 			}
@@ -519,7 +519,7 @@ func TestGenerateRandom(t *testing.T) {
 	gotAll := make(map[string]int)
 	runs := 1000
 	for i := 0; i < runs; i++ {
-		got := orderProfiles.Generate(input)
+		got := orderProfiles.Generate(nil, input)
 
 		if !contains(allOrderProfiles, got.Text) {
 			t.Errorf("Generate(%v) got Text=%q, want one of %v", input, got.Text, allOrderProfiles)
@@ -21,6 +21,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/Arend-melissant/simhospital/pkg/constants"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 const valueFormat = "%.2f"
@@ -116,15 +117,16 @@ func (g *ValueGenerator) IsNormal(v float64) bool {
 
 // Random returns the random value based on the randomType, which is either within normal ranges,
 // or outside the normal ranges (ie: higher or lower).
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
 // Returns error if the random value cannot be generated.
-func (g *ValueGenerator) Random(randomType string) (string, error) {
+func (g *ValueGenerator) Random(rng *rand.Rand, randomType string) (string, error) {
 	switch randomType {
 	case constants.AbnormalLow:
-		return g.AbnormalLow()
+		return g.AbnormalLow(rng)
 	case constants.AbnormalHigh:
-		return g.AbnormalHigh()
+		return g.AbnormalHigh(rng)
 	case constants.NormalValue:
-		return g.Normal()
+		return g.Normal(rng)
 	default:
 		log.WithField("random_type", randomType).Error("Unknown random type")
 		return "", errors.New("unknown random type")
@@ -148,7 +150,7 @@ func (g *ValueGenerator) Random(randomType string) (string, error) {
 //
 // If g == nil, returns 0.
 // Returns error if both: start and end of the range are open.
-func (g *ValueGenerator) Normal() (string, error) {
+func (g *ValueGenerator) Normal(rng *rand.Rand) (string, error) {
 	if g == nil {
 		return fmt.Sprintf(valueFormat, 0.0), nil
 	}
@@ -187,7 +189,7 @@ func (g *ValueGenerator) Normal() (string, error) {
 		to = 0
 	}
 
-	return randomFromRange(from, to)
+	return randomFromRange(rng, from, to)
 }
 
 // AbnormalLow returns a random number formatted as string, which is lower than the normal range.
@@ -198,7 +200,7 @@ func (g *ValueGenerator) Normal() (string, error) {
 // - the start of the normal range is open
 // - the start of the normal range is 0 -> the assumption is that if start of the normal range is positive,
 //   the negative numbers are invalid, thus it is impossible to generate the abnormal low value if range starts at 0
-func (g *ValueGenerator) AbnormalLow() (string, error) {
+func (g *ValueGenerator) AbnormalLow(rng *rand.Rand) (string, error) {
 	if g == nil {
 		return "", errors.New("cannot generate abnormal low value for nil ValueGenerator")
 	}
@@ -213,7 +215,7 @@ func (g *ValueGenerator) AbnormalLow() (string, error) {
 		from, to = 10*g.from.value, g.from.value
 	}
 
-	return randomFromRange(from, to)
+	return randomFromRange(rng, from, to)
 }
 
 // AbnormalHigh returns a random number formatted as string, which is higher than the normal range.
@@ -224,7 +226,7 @@ func (g *ValueGenerator) AbnormalLow() (string, error) {
 // - the end of the normal range is open
 // - the end of the normal range is 0 -> the assumption is that if the end of the normal range is negative,
 //   the positive numbers are invalid, thus it is impossible to generate the abnormal high value if range ends at 0
-func (g *ValueGenerator) AbnormalHigh() (string, error) {
+func (g *ValueGenerator) AbnormalHigh(rng *rand.Rand) (string, error) {
 	if g == nil {
 		return "", errors.New("cannot generate abnormal high value for nil ValueGenerator")
 	}
@@ -239,12 +241,12 @@ func (g *ValueGenerator) AbnormalHigh() (string, error) {
 		from, to = g.to.value, 0
 	}
 
-	return randomFromRange(from, to)
+	return randomFromRange(rng, from, to)
 }
 
-func randomFromRange(from float64, to float64) (string, error) {
+func randomFromRange(rng *rand.Rand, from float64, to float64) (string, error) {
 	for i := 0; i < 100; i++ {
-		f := rand.Float64()*(to-from) + from
+		f := random.Or(rng).Float64()*(to-from) + from
 
 		// The rand.Float64() returns value between [0.0, 1.0), ie the start of the range is inclusive, while
 		// the number generated by the ValueGenerator needs to be exclusive.
@@ -66,7 +66,7 @@ func TestValueGeneratorFromRange(t *testing.T) {
 
 			// Generate Normal, AbnormalHigh and AbnormalLow values multiple times.
 			for i := 0; i < 1; i++ {
-				gotNormal, err := vg.Normal()
+				gotNormal, err := vg.Normal(nil)
 				if err != nil {
 					t.Fatalf("Normal() failed with err %v", err)
 				}
@@ -78,7 +78,7 @@ func TestValueGeneratorFromRange(t *testing.T) {
 					t.Errorf("Normal() = %q, want in range (%f, %f)", gotNormal, tc.wantNormal.from, tc.wantNormal.to)
 				}
 
-				gotHigh, err := vg.AbnormalHigh()
+				gotHigh, err := vg.AbnormalHigh(nil)
 				if err != nil {
 					t.Fatalf("AbnormalHigh() failed with err %v", err)
 				}
@@ -90,7 +90,7 @@ func TestValueGeneratorFromRange(t *testing.T) {
 					t.Errorf("AbnormalHigh() = %q, want in range (%f, %f)", gotHigh, tc.wantHigh.from, tc.wantHigh.to)
 				}
 
-				gotLow, err := vg.AbnormalLow()
+				gotLow, err := vg.AbnormalLow(nil)
 				if err != nil {
 					t.Fatalf("AbnormalLow() failed with err %v", err)
 				}
@@ -139,7 +139,7 @@ func TestValueGeneratorFromRange_LeftOpenRange(t *testing.T) {
 
 			// Generate Normal, AbnormalHigh and AbnormalLow values multiple times.
 			for i := 0; i < 1; i++ {
-				gotNormal, err := vg.Normal()
+				gotNormal, err := vg.Normal(nil)
 				if err != nil {
 					t.Fatalf("Normal() failed with err %v", err)
 				}
@@ -151,7 +151,7 @@ func TestValueGeneratorFromRange_LeftOpenRange(t *testing.T) {
 					t.Errorf("Normal() = %q, want in range (%f, %f)", gotNormal, tc.wantNormal.from, tc.wantNormal.to)
 				}
 
-				gotHigh, err := vg.AbnormalHigh()
+				gotHigh, err := vg.AbnormalHigh(nil)
 				if err != nil {
 					t.Fatalf("AbnormalHigh() failed with err %v", err)
 				}
@@ -163,7 +163,7 @@ func TestValueGeneratorFromRange_LeftOpenRange(t *testing.T) {
 					t.Errorf("AbnormalHigh() = %q, want in range (%f, %f)", gotHigh, tc.wantHigh.from, tc.wantHigh.to)
 				}
 
-				if _, err = vg.AbnormalLow(); err == nil {
+				if _, err = vg.AbnormalLow(nil); err == nil {
 					t.Error("AbnormalLow() got nil err, want non-nill err")
 				}
 			}
@@ -204,7 +204,7 @@ func TestValueGeneratorFromRange_RightOpenRange(t *testing.T) {
 
 			// Generate Normal, AbnormalHigh and AbnormalLow values multiple times.
 			for i := 0; i < 1; i++ {
-				gotNormal, err := vg.Normal()
+				gotNormal, err := vg.Normal(nil)
 				if err != nil {
 					t.Fatalf("Normal() failed with err %v", err)
 				}
@@ -216,7 +216,7 @@ func TestValueGeneratorFromRange_RightOpenRange(t *testing.T) {
 					t.Errorf("Normal() = %q, want in range (%f, %f)", gotNormal, tc.wantNormal.from, tc.wantNormal.to)
 				}
 
-				gotLow, err := vg.AbnormalLow()
+				gotLow, err := vg.AbnormalLow(nil)
 				if err != nil {
 					t.Fatalf("AbnormalLow() failed with err %v", err)
 				}
@@ -228,7 +228,7 @@ func TestValueGeneratorFromRange_RightOpenRange(t *testing.T) {
 					t.Errorf("AbnormalLow() = %q, want in range (%f, %f)", gotLow, tc.wantLow.from, tc.wantLow.to)
 				}
 
-				if _, err := vg.AbnormalHigh(); err == nil {
+				if _, err := vg.AbnormalHigh(nil); err == nil {
 					t.Error("AbnormalHigh() got nil err, want non-nill err")
 				}
 			}
@@ -270,7 +270,7 @@ func TestValueGeneratorFromRange_ZeroOrSmallRange(t *testing.T) {
 
 			// Generate Normal, AbnormalHigh and AbnormalLow values multiple times.
 			for i := 0; i < 1; i++ {
-				gotNormal, err := vg.Normal()
+				gotNormal, err := vg.Normal(nil)
 				if gotErr := err != nil; gotErr != tc.wantNormal.err {
 					t.Fatalf("Normal() got err %v, want err? %t", err, tc.wantNormal.err)
 				}
@@ -284,7 +284,7 @@ func TestValueGeneratorFromRange_ZeroOrSmallRange(t *testing.T) {
 					}
 				}
 
-				gotHigh, err := vg.AbnormalHigh()
+				gotHigh, err := vg.AbnormalHigh(nil)
 				if gotErr := err != nil; gotErr != tc.wantHigh.err {
 					t.Fatalf("AbnormalHigh() got err %v, want err? %t", err, tc.wantHigh.err)
 				}
@@ -298,7 +298,7 @@ func TestValueGeneratorFromRange_ZeroOrSmallRange(t *testing.T) {
 					}
 				}
 
-				gotLow, err := vg.AbnormalLow()
+				gotLow, err := vg.AbnormalLow(nil)
 				if gotErr := err != nil; gotErr != tc.wantLow.err {
 					t.Fatalf("AbnormalLow() got err %v, want err? %t", err, tc.wantLow.err)
 				}
@@ -360,7 +360,7 @@ func TestValueGenerator_NotInitialised(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := tc.vg.Normal()
+			got, err := tc.vg.Normal(nil)
 			if gotErr := err != nil; gotErr != tc.wantNormalErr {
 				t.Errorf("[%v].Normal() got err %v, want err? %t", tc.vg, err, tc.wantNormalErr)
 			}
@@ -375,11 +375,11 @@ func TestValueGenerator_NotInitialised(t *testing.T) {
 				}
 			}
 
-			_, err = tc.vg.AbnormalHigh()
+			_, err = tc.vg.AbnormalHigh(nil)
 			if gotErr := err != nil; gotErr != tc.wantHighErr {
 				t.Errorf("[%v].AbnormalHigh() got err %v, want err? %t", tc.vg, err, tc.wantHighErr)
 			}
-			_, err = tc.vg.AbnormalLow()
+			_, err = tc.vg.AbnormalLow(nil)
 			if gotErr := err != nil; gotErr != tc.wantLowErr {
 				t.Errorf("[%v].AbnormalLow() got err %v, want err? %t", tc.vg, err, tc.wantLowErr)
 			}
@@ -410,7 +410,7 @@ func TestValueGenerator_Random(t *testing.T) {
 				t.Fatalf("ValueGeneratorFromRange(%s) failed with err %v", tc.inRange, err)
 			}
 
-			got, err := vg.Random(tc.randomType)
+			got, err := vg.Random(nil, tc.randomType)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Fatalf("Random(%s) got err %v, want err? %t", tc.randomType, err, tc.wantErr)
 			}
@@ -16,9 +16,11 @@ package pathway
 
 import (
 	"fmt"
+	"math/rand"
 	"sort"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 )
 
 // Collection represents a collection of pathways.
@@ -27,6 +29,8 @@ type Collection struct {
 	pathways map[string]Pathway
 	// pathwayNames is a sorted list of the names of all pathways.
 	pathwayNames []string
+	// rand is the source of randomness used to make pathways runnable.
+	rand *rand.Rand
 }
 
 // GetPathway gets the pathway with the given name.
@@ -36,7 +40,7 @@ func (c Collection) GetPathway(pathwayName string) (*Pathway, error) {
 	if !ok {
 		return nil, fmt.Errorf("pathwayName %s does not exist within Collection", pathwayName)
 	}
-	runnable, err := pathway.Runnable()
+	runnable, err := pathway.Runnable(c.rand)
 	if err != nil {
 		return nil, errors.Wrapf(err, "pathway with name %s is not runnable", pathwayName)
 	}
@@ -55,9 +59,14 @@ func (c Collection) Pathways() map[string]Pathway {
 
 // NewCollection creates a new Collection with the given pathway map.
 // All pathways are initialised.
-func NewCollection(pathways map[string]Pathway) (Collection, error) {
+// rng is the source of randomness used to make pathways runnable, and is required.
+func NewCollection(pathways map[string]Pathway, rng *rand.Rand) (Collection, error) {
+	if rng == nil {
+		return Collection{}, errors.New("the source of randomness is not set; this is required")
+	}
 	p := Collection{
 		pathways: map[string]Pathway{},
+		rand:     rng,
 	}
 	for k, v := range pathways {
 		v.Init(k)
@@ -69,9 +78,21 @@ func NewCollection(pathways map[string]Pathway) (Collection, error) {
 }
 
 // Print prints the names of the pathways in the collection, with optional suffixes.
+// Pathways that extend other pathways are printed in their resolved form, ie: with the fields and
+// steps inherited from their base pathways and with their overrides applied.
 func (c Collection) Print(suffixes map[string]string) {
 	log.Infof("Loaded %d pathways:", len(c.pathwayNames))
 	for _, name := range c.pathwayNames {
-		log.Infof(" - %s%s", name, suffixes[name])
+		p := c.pathways[name]
+		if p.Extends == "" {
+			log.Infof(" - %s%s", name, suffixes[name])
+			continue
+		}
+		resolved, err := yaml.Marshal(p)
+		if err != nil {
+			log.WithError(err).Errorf(" - %s%s (extends %s): cannot print resolved pathway", name, suffixes[name], p.Extends)
+			continue
+		}
+		log.Infof(" - %s%s (extends %s), resolved:\n%s", name, suffixes[name], p.Extends, resolved)
 	}
 }
@@ -15,6 +15,7 @@
 package pathway
 
 import (
+	"math/rand"
 	"strings"
 	"testing"
 
@@ -66,7 +67,7 @@ func TestCollection_GetPathway(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			c, err := NewCollection(pathways)
+			c, err := NewCollection(pathways, rand.New(rand.NewSource(1)))
 			if err != nil {
 				t.Fatalf("NewCollection(%v) failed with %v", pathways, err)
 			}
@@ -95,7 +96,7 @@ func TestCollection_PathwayNames(t *testing.T) {
 	}
 	pathways := map[string]Pathway{"pathway1": pathway, "pathway2": pathway, "pathway0": pathway}
 
-	c, err := NewCollection(pathways)
+	c, err := NewCollection(pathways, rand.New(rand.NewSource(1)))
 	if err != nil {
 		t.Fatalf("NewCollection(%v) failed with %v", pathways, err)
 	}
@@ -121,7 +122,7 @@ func TestCollection_Pathways(t *testing.T) {
 		"pathway2": {Pathway: steps2},
 	}
 
-	c, err := NewCollection(pathways)
+	c, err := NewCollection(pathways, rand.New(rand.NewSource(1)))
 	if err != nil {
 		t.Fatalf("NewCollection(%v) failed with %v", pathways, err)
 	}
@@ -141,3 +142,10 @@ func TestCollection_Pathways(t *testing.T) {
 		t.Errorf("collection.Pathways() -want, +got:\n%s", diff)
 	}
 }
+
+func TestNewCollection_NoRand(t *testing.T) {
+	pathways := map[string]Pathway{"pathway1": {Pathway: []Step{{Admission: &Admission{}}}}}
+	if _, err := NewCollection(pathways, nil); err == nil {
+		t.Errorf("NewCollection(%v, nil) got nil error, want non nil", pathways)
+	}
+}
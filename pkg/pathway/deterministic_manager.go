@@ -16,6 +16,7 @@ package pathway
 
 import (
 	"fmt"
+	"math/rand"
 
 	"github.com/pkg/errors"
 )
@@ -42,8 +43,9 @@ func (m *DeterministicManager) NextPathway() (*Pathway, error) {
 // All pathways are initialised.
 // The order slice must have at least one element, and all elements must correspond to existing pathways.
 // Otherwise NewDeterministicManager returns an error.
-func NewDeterministicManager(pathways map[string]Pathway, order []string) (*DeterministicManager, error) {
-	collection, err := NewCollection(pathways)
+// rng is the source of randomness used to make pathways runnable, and is required.
+func NewDeterministicManager(pathways map[string]Pathway, order []string, rng *rand.Rand) (*DeterministicManager, error) {
+	collection, err := NewCollection(pathways, rng)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +65,30 @@ func NewDeterministicManager(pathways map[string]Pathway, order []string) (*Dete
 	return &m, nil
 }
 
+// NextIndex returns the index in the order of the next pathway to be run.
+func (m *DeterministicManager) NextIndex() int {
+	return m.nextIdx
+}
+
+// SetNextIndex sets the index in the order of the next pathway to be run, e.g., to restore a snapshot
+// of a hospital. Returns an error if the index is out of range.
+func (m *DeterministicManager) SetNextIndex(i int) error {
+	if err := m.ValidateNextIndex(i); err != nil {
+		return err
+	}
+	m.nextIdx = i
+	return nil
+}
+
+// ValidateNextIndex returns the error that SetNextIndex would return for the given index, if any,
+// without changing the next pathway.
+func (m *DeterministicManager) ValidateNextIndex(i int) error {
+	if i < 0 || i >= len(m.order) {
+		return fmt.Errorf("index %d out of range; there are %d pathways in the order", i, len(m.order))
+	}
+	return nil
+}
+
 func (m DeterministicManager) print() {
 	m.Collection.Print(nil)
 	log.Infof("Pathways will be run in the following order: %v", m.order)
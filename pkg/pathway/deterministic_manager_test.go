@@ -61,7 +61,7 @@ func TestNewDeterministicManager(t *testing.T) {
 	}}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := NewDeterministicManager(pathways, tc.order)
+			_, err := NewDeterministicManager(pathways, tc.order, rand.New(rand.NewSource(1)))
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Fatalf("NewDeterministicManager(%v, %v) got err %v, want err? %t", pathways, tc.order, err, tc.wantErr)
 			}
@@ -114,7 +114,7 @@ func TestDeterministicManager_GetPathway(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			m, err := NewDeterministicManager(pathways, tc.order)
+			m, err := NewDeterministicManager(pathways, tc.order, rand.New(rand.NewSource(1)))
 			if err != nil {
 				t.Fatalf("NewDeterministicManager(%v, %v) failed with %v", pathways, tc.order, err)
 			}
@@ -162,7 +162,7 @@ func TestDeterministicManager_PathwayNames(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			m, err := NewDeterministicManager(tc.pathways, tc.order)
+			m, err := NewDeterministicManager(tc.pathways, tc.order, rand.New(rand.NewSource(1)))
 			if err != nil {
 				t.Fatalf("NewDeterministicManager(%v, %v) failed with %v", tc.pathways, tc.order, err)
 			}
@@ -175,8 +175,6 @@ func TestDeterministicManager_PathwayNames(t *testing.T) {
 }
 
 func TestDeterministicManager_NextPathway(t *testing.T) {
-	rand.Seed(1)
-
 	steps := []Step{
 		{Admission: &Admission{}},
 		{Discharge: &Discharge{}},
@@ -210,7 +208,7 @@ func TestDeterministicManager_NextPathway(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			manager, err := NewDeterministicManager(pathways, tc.order)
+			manager, err := NewDeterministicManager(pathways, tc.order, rand.New(rand.NewSource(1)))
 			if err != nil {
 				t.Fatalf("NewDeterministicManager(%+v,%v) failed with %v", pathways, tc.order, err)
 			}
@@ -17,7 +17,9 @@ package pathway
 import (
 	"fmt"
 	"math"
+	"math/rand"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -57,7 +59,8 @@ func (m DistributionManager) NextPathway() (*Pathway, error) {
 // If includeStr contains any elements, then only pathways that match any regex in includeStr are eligible
 // to be returned by NextPathway.
 // Pathways that match any regex in excludeStr are never returned by NextPathway.
-func NewDistributionManager(pathways map[string]Pathway, includeStr []string, excludeStr []string) (DistributionManager, error) {
+// rng is the source of randomness used to pick pathways and make them runnable, and is required.
+func NewDistributionManager(pathways map[string]Pathway, includeStr []string, excludeStr []string, rng *rand.Rand) (DistributionManager, error) {
 	include, err := toRegexps(includeStr)
 	if err != nil {
 		return DistributionManager{}, errors.Wrapf(err, "Failed to convert %v to regexps", include)
@@ -67,7 +70,7 @@ func NewDistributionManager(pathways map[string]Pathway, includeStr []string, ex
 		return DistributionManager{}, errors.Wrapf(err, "Failed to convert %v to regexps", exclude)
 	}
 
-	collection, err := NewCollection(pathways)
+	collection, err := NewCollection(pathways, rng)
 	if err != nil {
 		return DistributionManager{}, err
 	}
@@ -75,7 +78,7 @@ func NewDistributionManager(pathways map[string]Pathway, includeStr []string, ex
 	distr, percentages := calculateDistribution(collection.Pathways(), include, exclude)
 	m := DistributionManager{
 		Collection:   collection,
-		distribution: &sample.DiscreteDistribution{WeightedValues: distr},
+		distribution: &sample.DiscreteDistribution{WeightedValues: distr, Rand: rng},
 	}
 	m.print(percentages)
 	return m, nil
@@ -87,7 +90,14 @@ func calculateDistribution(pathways map[string]Pathway, include []*regexp.Regexp
 	accPercentage := 0.0
 	// We'll later share the remaining percentage budget among the pathways without an explicit one.
 	var noPercentage []string
-	for k, v := range pathways {
+	// The pathways are visited in order of name, so that the distribution only depends on the source of randomness.
+	names := make([]string, 0, len(pathways))
+	for k := range pathways {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := pathways[k]
 		switch {
 		case len(include) > 0 && !matches(k, include) || matches(k, exclude):
 			log.WithField("pathway_name", k).Debug("Pathway disabled")
@@ -82,7 +82,7 @@ func TestDistributionManager_GetPathway(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			m, err := NewDistributionManager(pathways, tc.include, tc.exclude)
+			m, err := NewDistributionManager(pathways, tc.include, tc.exclude, rand.New(rand.NewSource(1)))
 			if err != nil {
 				t.Fatalf("NewDistributionManager(%v, %v, %v) failed with %v", pathways, tc.include, tc.exclude, err)
 			}
@@ -145,7 +145,7 @@ func TestDistributionManager_PathwayNames(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			m, err := NewDistributionManager(tc.pathways, tc.include, tc.exclude)
+			m, err := NewDistributionManager(tc.pathways, tc.include, tc.exclude, rand.New(rand.NewSource(1)))
 			if err != nil {
 				t.Fatalf("NewDistributionManager(%v, %v, %v) failed with %v", tc.pathways, tc.include, tc.exclude, err)
 			}
@@ -158,8 +158,6 @@ func TestDistributionManager_PathwayNames(t *testing.T) {
 }
 
 func TestDistributionManager_NextPathway(t *testing.T) {
-	rand.Seed(1)
-
 	pathway1, pathway2, pathway3 := "pathway1", "pathway2", "pathway3"
 	cases := []struct {
 		name        string
@@ -279,7 +277,7 @@ func TestDistributionManager_NextPathway(t *testing.T) {
 					},
 				}
 			}
-			manager, err := NewDistributionManager(pathways, tc.include, tc.exclude)
+			manager, err := NewDistributionManager(pathways, tc.include, tc.exclude, rand.New(rand.NewSource(1)))
 			if gotErr := err != nil; gotErr != tc.wantErrNew {
 				t.Fatalf("NewDistributionManager(%+v, %v, %v) got err %v, want err? %t", pathways, tc.include, tc.exclude, err, tc.wantErrNew)
 			}
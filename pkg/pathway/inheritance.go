@@ -0,0 +1,164 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathway
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StepOverride replaces a step of the base pathway in a pathway that extends it.
+// Exactly one of Index and Label must be set.
+type StepOverride struct {
+	// Index is the index of the step to replace within the Pathway section of the base pathway.
+	Index *int `yaml:",omitempty"`
+	// Label is the label of the step to replace, either in the Pathway or the Historical Data
+	// section of the base pathway.
+	Label string `yaml:",omitempty"`
+	// Step is the step that replaces the original one.
+	// If it doesn't have a label, it keeps the label of the step it replaces.
+	Step Step
+}
+
+// resolveInheritance returns the given pathways where the pathways that extend others have been
+// merged with their base pathways and had their overrides applied.
+// Returns an error if a base pathway does not exist, if there is a cycle in the chain of base
+// pathways, or if an override does not match any step.
+func resolveInheritance(pathways map[string]Pathway) (map[string]Pathway, error) {
+	names := make([]string, 0, len(pathways))
+	for name := range pathways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := map[string]Pathway{}
+	var ec error
+	for _, name := range names {
+		if _, err := resolve(name, pathways, resolved, nil); err != nil {
+			ec = combineErrors(ec, errors.Wrapf(err, "cannot resolve pathway %q", name))
+		}
+	}
+	if ec != nil {
+		return nil, ec
+	}
+	return resolved, nil
+}
+
+// resolve resolves the pathway with the given name and adds it to resolved.
+// chain contains the names of the pathways that extend this pathway, directly or indirectly,
+// and is used to detect cycles.
+func resolve(name string, pathways map[string]Pathway, resolved map[string]Pathway, chain []string) (Pathway, error) {
+	if p, ok := resolved[name]; ok {
+		return p, nil
+	}
+	for _, c := range chain {
+		if c == name {
+			return Pathway{}, fmt.Errorf("cycle in base pathways: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+	p, ok := pathways[name]
+	if !ok {
+		return Pathway{}, fmt.Errorf("base pathway %q does not exist", name)
+	}
+	if p.Extends == "" {
+		if len(p.Overrides) > 0 {
+			return Pathway{}, errors.New("overrides can only be set in pathways that extend another pathway")
+		}
+		resolved[name] = p
+		return p, nil
+	}
+
+	base, err := resolve(p.Extends, pathways, resolved, append(chain, name))
+	if err != nil {
+		return Pathway{}, err
+	}
+	p.inherit(base)
+	if err := p.applyOverrides(); err != nil {
+		return Pathway{}, errors.Wrapf(err, "cannot apply overrides to base pathway %q", p.Extends)
+	}
+	resolved[name] = p
+	return p, nil
+}
+
+// inherit sets the fields of p that are not set from the given base pathway.
+func (p *Pathway) inherit(base Pathway) {
+	copied := base.getCopy()
+	if p.Percentage == nil {
+		p.Percentage = copied.Percentage
+	}
+	if p.Persons == nil {
+		p.Persons = copied.Persons
+	}
+	if p.Consultant == nil {
+		p.Consultant = copied.Consultant
+	}
+	if p.Pathway == nil {
+		p.Pathway = copied.Pathway
+	}
+	if p.History == nil {
+		p.History = copied.History
+	}
+}
+
+// applyOverrides replaces the steps of p as specified in p.Overrides, and clears them.
+func (p *Pathway) applyOverrides() error {
+	var ec error
+	for i, o := range p.Overrides {
+		if err := p.applyOverride(o); err != nil {
+			ec = combineErrors(ec, errors.Wrapf(err, "invalid override %d", i))
+		}
+	}
+	p.Overrides = nil
+	return ec
+}
+
+func (p *Pathway) applyOverride(o StepOverride) error {
+	steps, i, err := p.stepToOverride(o)
+	if err != nil {
+		return err
+	}
+	if o.Step.Label == "" {
+		o.Step.Label = steps[i].Label
+	}
+	steps[i] = o.Step
+	return nil
+}
+
+// stepToOverride returns the steps that contain the step matched by the given override, and its index.
+func (p *Pathway) stepToOverride(o StepOverride) ([]Step, int, error) {
+	switch {
+	case o.Index != nil && o.Label != "":
+		return nil, 0, errors.New("only one of index and label can be set")
+	case o.Index != nil:
+		if *o.Index < 0 || *o.Index >= len(p.Pathway) {
+			return nil, 0, fmt.Errorf("index %d out of range: the pathway has %d steps", *o.Index, len(p.Pathway))
+		}
+		return p.Pathway, *o.Index, nil
+	case o.Label != "":
+		for _, steps := range [][]Step{p.History, p.Pathway} {
+			for i, s := range steps {
+				if s.Label == o.Label {
+					return steps, i, nil
+				}
+			}
+		}
+		return nil, 0, fmt.Errorf("no step with label %q", o.Label)
+	default:
+		return nil, 0, errors.New("either index or label must be set")
+	}
+}
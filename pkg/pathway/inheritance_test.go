@@ -0,0 +1,300 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathway
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
+)
+
+const basePathway = `
+base:
+  persons:
+    main_patient:
+      gender: F
+  historical_data:
+    - registration: {}
+      label: registration
+      parameters:
+        time_from_now: -48h
+  pathway:
+    - admission:
+        loc: ED
+      label: admission
+    - delay:
+        from: 1h
+        to: 1h
+    - discharge: {}
+      label: discharge
+`
+
+func TestParsePathways_Inheritance(t *testing.T) {
+	ctx := context.Background()
+	oneHour := time.Hour
+	twoHours := 2 * time.Hour
+	minusTwoDays := -48 * time.Hour
+	baseHistory := []Step{{Registration: &Registration{}, Label: "registration", Parameters: &Parameters{TimeFromNow: &minusTwoDays}}}
+
+	cases := []struct {
+		name        string
+		derived     string
+		wantPersons *Persons
+		wantHistory []Step
+		wantPathway []Step
+	}{{
+		name: "inherit everything",
+		derived: `
+derived:
+  extends: base
+`,
+		wantPersons: &Persons{"main_patient": {Gender: "F"}},
+		wantHistory: baseHistory,
+		wantPathway: []Step{
+			{Admission: &Admission{Loc: "ED"}, Label: "admission"},
+			{Delay: &Delay{From: oneHour, To: oneHour}},
+			{Discharge: &Discharge{}, Label: "discharge"},
+		},
+	}, {
+		name: "override persons and steps by label and index",
+		derived: `
+derived:
+  extends: base
+  persons:
+    main_patient:
+      gender: M
+  overrides:
+    - label: admission
+      step:
+        admission:
+          loc: Renal
+    - index: 1
+      step:
+        delay:
+          from: 2h
+          to: 2h
+    - label: registration
+      step:
+        registration: {}
+        label: outpatient_registration
+        parameters:
+          time_from_now: -48h
+`,
+		wantPersons: &Persons{"main_patient": {Gender: "M"}},
+		wantHistory: []Step{{Registration: &Registration{}, Label: "outpatient_registration", Parameters: &Parameters{TimeFromNow: &minusTwoDays}}},
+		wantPathway: []Step{
+			{Admission: &Admission{Loc: "Renal"}, Label: "admission"},
+			{Delay: &Delay{From: twoHours, To: twoHours}},
+			{Discharge: &Discharge{}, Label: "discharge"},
+		},
+	}, {
+		name: "chain of base pathways",
+		derived: `
+middle:
+  extends: base
+  overrides:
+    - label: admission
+      step:
+        admission:
+          loc: Renal
+derived:
+  extends: middle
+  pathway:
+    - admission:
+        loc: ED
+      label: admission
+    - discharge: {}
+`,
+		wantPersons: &Persons{"main_patient": {Gender: "F"}},
+		wantHistory: baseHistory,
+		wantPathway: []Step{
+			{Admission: &Admission{Loc: "ED"}, Label: "admission"},
+			{Discharge: &Discharge{}},
+		},
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mainDir := testwrite.TempDir(t)
+			testwrite.BytesToFileInExistingDir(t, []byte(basePathway), mainDir, "base.yml")
+			testwrite.BytesToFileInExistingDir(t, []byte(tc.derived), mainDir, "derived.yml")
+
+			p := newDefaultParser(ctx, t, time.Now())
+			pathways, err := p.ParsePathways(ctx, mainDir)
+			if err != nil {
+				t.Fatalf("ParsePathways(%s) failed with %v", tc.derived, err)
+			}
+			got, ok := pathways["derived"]
+			if !ok {
+				t.Fatalf("ParsePathways(%s) got pathways %v, want pathway %q", tc.derived, pathways, "derived")
+			}
+			opts := cmpopts.IgnoreUnexported(Step{})
+			if diff := cmp.Diff(tc.wantPersons, got.Persons); diff != "" {
+				t.Errorf("ParsePathways(%s) got Persons with diff (-want, +got):\n%s", tc.derived, diff)
+			}
+			if diff := cmp.Diff(tc.wantHistory, got.History, opts); diff != "" {
+				t.Errorf("ParsePathways(%s) got History with diff (-want, +got):\n%s", tc.derived, diff)
+			}
+			if diff := cmp.Diff(tc.wantPathway, got.Pathway, opts); diff != "" {
+				t.Errorf("ParsePathways(%s) got Pathway with diff (-want, +got):\n%s", tc.derived, diff)
+			}
+			if got.Overrides != nil {
+				t.Errorf("ParsePathways(%s) got Overrides %v, want <nil>", tc.derived, got.Overrides)
+			}
+
+			// The base pathway is not modified by the pathways that extend it.
+			if got, want := pathways["base"].Pathway[0].Admission.Loc, "ED"; got != want {
+				t.Errorf("base pathway admission location got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParsePathways_InheritanceInvalid(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name            string
+		derived         string
+		wantErrContains string
+	}{{
+		name: "base pathway does not exist",
+		derived: `
+derived:
+  extends: unknown
+`,
+		wantErrContains: `base pathway "unknown" does not exist`,
+	}, {
+		name: "cycle",
+		derived: `
+derived1:
+  extends: derived2
+derived2:
+  extends: derived1
+`,
+		wantErrContains: "cycle in base pathways: derived1 -> derived2 -> derived1",
+	}, {
+		name: "self cycle",
+		derived: `
+derived:
+  extends: derived
+`,
+		wantErrContains: "cycle in base pathways: derived -> derived",
+	}, {
+		name: "unknown label",
+		derived: `
+derived:
+  extends: base
+  overrides:
+    - label: unknown
+      step:
+        discharge: {}
+`,
+		wantErrContains: `no step with label "unknown"`,
+	}, {
+		name: "index out of range",
+		derived: `
+derived:
+  extends: base
+  overrides:
+    - index: 3
+      step:
+        discharge: {}
+`,
+		wantErrContains: "index 3 out of range",
+	}, {
+		name: "index and label",
+		derived: `
+derived:
+  extends: base
+  overrides:
+    - index: 0
+      label: admission
+      step:
+        discharge: {}
+`,
+		wantErrContains: "only one of index and label can be set",
+	}, {
+		name: "overrides without base pathway",
+		derived: `
+derived:
+  overrides:
+    - index: 0
+      step:
+        discharge: {}
+  pathway:
+    - admission:
+        loc: ED
+`,
+		wantErrContains: "overrides can only be set in pathways that extend another pathway",
+	}, {
+		name: "resolved pathway is invalid",
+		derived: `
+derived:
+  extends: base
+  overrides:
+    - label: admission
+      step:
+        admission:
+          loc: unknown
+`,
+		wantErrContains: "unknown location",
+	}, {
+		name: "duplicate labels",
+		derived: `
+derived:
+  extends: base
+  overrides:
+    - label: admission
+      step:
+        admission:
+          loc: ED
+        label: discharge
+`,
+		wantErrContains: `duplicate step label "discharge"`,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mainDir := testwrite.TempDir(t)
+			testwrite.BytesToFileInExistingDir(t, []byte(basePathway), mainDir, "base.yml")
+			testwrite.BytesToFileInExistingDir(t, []byte(tc.derived), mainDir, "derived.yml")
+
+			p := newDefaultParser(ctx, t, time.Now())
+			_, err := p.ParsePathways(ctx, mainDir)
+			if err == nil {
+				t.Fatalf("ParsePathways(%s) got nil error, want error", tc.derived)
+			}
+			if !strings.Contains(err.Error(), tc.wantErrContains) {
+				t.Errorf("ParsePathways(%s) got err %v, want err to contain %q", tc.derived, err, tc.wantErrContains)
+			}
+		})
+	}
+}
+
+func TestParseSinglePathway_Extends(t *testing.T) {
+	ctx := context.Background()
+	p := newDefaultParser(ctx, t, time.Now())
+	def := []byte(`
+extends: base
+`)
+	if _, err := p.ParseSinglePathway(def); err == nil {
+		t.Errorf("ParseSinglePathway(%s) got nil error, want error", def)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathway
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+)
+
+// yamlErrorLineRegexp matches the line number in the errors returned by the yaml library,
+// e.g., "line 12: field foo not found in type pathway.Step".
+var yamlErrorLineRegexp = regexp.MustCompile(`line (\d+): (.*)$`)
+
+// Problem is a problem found when linting pathways.
+type Problem struct {
+	// File is the path of the file where the problem is.
+	File string
+	// Line is the line of the file where the problem is, or 0 if it is not known.
+	// For problems in a step, it is the line where the step is declared; for problems that affect a
+	// whole pathway, or steps that the pathway inherits, it is the line where the pathway is declared.
+	Line int
+	// Pathway is the name of the pathway with the problem. It is empty if the problem is not
+	// specific to one pathway, e.g., if the file cannot be parsed.
+	Pathway string
+	// Err describes the problem.
+	Err error
+}
+
+// String returns the problem in the form "file:line: pathway "name": error".
+func (p Problem) String() string {
+	location := declaration{file: p.File, line: p.Line}.String()
+	if p.Pathway == "" {
+		return fmt.Sprintf("%s: %v", location, p.Err)
+	}
+	return fmt.Sprintf("%s: pathway %q: %v", location, p.Pathway, p.Err)
+}
+
+// declaration is where a pathway is declared.
+type declaration struct {
+	file string
+	line int
+	// steps are the lines where the steps of the pathway are declared, keyed by the section of the
+	// pathway that contains them, i.e., historySection or pathwaySection.
+	steps map[string][]int
+}
+
+// lineOf returns the line where the problem described by err is, in the given pathway declared at d.
+// parsed is the pathway as it is declared, before it inherits the steps of its base pathway, and
+// resolved is the pathway after that.
+func (d declaration) lineOf(err error, parsed Pathway, resolved Pathway) int {
+	se, ok := err.(stepError)
+	if !ok {
+		return d.line
+	}
+	declared := map[string][2][]Step{
+		historySection: {parsed.History, resolved.History},
+		pathwaySection: {parsed.Pathway, resolved.Pathway},
+	}[se.section]
+	// Steps that are inherited are not declared in the pathway itself.
+	if declared[0] == nil || len(declared[0]) != len(declared[1]) {
+		return d.line
+	}
+	if lines := d.steps[se.section]; se.index < len(lines) {
+		return lines[se.index]
+	}
+	return d.line
+}
+
+// String returns the declaration in the form "file:line", or "file" if the line is not known.
+func (d declaration) String() string {
+	if d.line > 0 {
+		return fmt.Sprintf("%s:%d", d.file, d.line)
+	}
+	return d.file
+}
+
+// Lint parses and validates all pathways defined in the pathwaysDir, like ParsePathways.
+// Unlike ParsePathways, Lint does not stop at the first problem: it reports every problem it finds,
+// with the file and the line where it is, sorted by file and line.
+// Lint returns the pathways that are valid, initialised, so that they can be run even if other pathways
+// in the directory are not valid.
+// Lint only returns an error if the directory cannot be read.
+func (p *Parser) Lint(ctx context.Context, pathwaysDir string) (map[string]Pathway, []Problem, error) {
+	files, err := files.List(ctx, pathwaysDir)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read pathways files from %s", pathwaysDir)
+	}
+
+	var problems []Problem
+	parsed := map[string]Pathway{}
+	declarations := map[string]declaration{}
+	redeclared := map[string]bool{}
+	for _, file := range files {
+		if !fileExtensionIsValid(file.Name()) {
+			continue
+		}
+		data, err := file.Read(ctx)
+		if err != nil {
+			problems = append(problems, Problem{File: file.FullPath(), Err: errors.Wrap(err, "cannot read file")})
+			continue
+		}
+		pathways := map[string]Pathway{}
+		// The yaml library parses JSON too, we don't need anything extra to support JSON.
+		if err := yaml.UnmarshalStrict(data, &pathways); err != nil {
+			problems = append(problems, unmarshalProblems(file.FullPath(), err)...)
+			continue
+		}
+		lines := declarationLines(data)
+		for name, pathway := range pathways {
+			d := lines[name]
+			d.file = file.FullPath()
+			if first, ok := declarations[name]; ok {
+				problems = append(problems, Problem{
+					File:    d.file,
+					Line:    d.line,
+					Pathway: name,
+					Err:     fmt.Errorf("pathway re-declared; it was first declared in %s", first),
+				})
+				redeclared[name] = true
+				continue
+			}
+			declarations[name] = d
+			parsed[name] = pathway
+		}
+	}
+	for name := range redeclared {
+		delete(parsed, name)
+	}
+
+	names := make([]string, 0, len(parsed))
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := map[string]Pathway{}
+	valid := map[string]Pathway{}
+	for _, name := range names {
+		d := declarations[name]
+		pathway, err := resolve(name, parsed, resolved, nil)
+		if err != nil {
+			problems = append(problems, Problem{File: d.file, Line: d.line, Pathway: name, Err: err})
+			continue
+		}
+		pathway.Init(name)
+		if err := pathway.Valid(p.Clock, p.OrderProfiles, p.Doctors, p.LocationManager, p.Valid, p.Rand); err != nil {
+			errs := []error{err}
+			if ec, ok := err.(errorCollection); ok {
+				errs = ec
+			}
+			for _, e := range errs {
+				line := d.lineOf(e, parsed[name], pathway)
+				if se, ok := e.(stepError); ok {
+					e = se.err
+				}
+				problems = append(problems, Problem{File: d.file, Line: line, Pathway: name, Err: e})
+			}
+			continue
+		}
+		valid[name] = pathway
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return valid, problems, nil
+}
+
+// unmarshalProblems returns the problems in the given error returned by the yaml library.
+// The yaml library reports all the fields that cannot be unmarshalled at once, so there is one
+// problem for each of them.
+func unmarshalProblems(file string, err error) []Problem {
+	msgs := []string{err.Error()}
+	if te, ok := err.(*yaml.TypeError); ok {
+		msgs = te.Errors
+	}
+	var problems []Problem
+	for _, msg := range msgs {
+		problem := Problem{File: file, Err: errors.New(msg)}
+		if m := yamlErrorLineRegexp.FindStringSubmatch(msg); m != nil {
+			problem.Line, _ = strconv.Atoi(m[1])
+			problem.Err = errors.New(m[2])
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// declarationLines returns where each of the pathways in data, and each of their steps, is declared,
+// without the file. The yaml library parses JSON too, so this works for both formats.
+// Pathways whose declaration cannot be found are not included in the returned map.
+func declarationLines(data []byte) map[string]declaration {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	declarations := map[string]declaration{}
+	// The content of a mapping node alternates keys and values.
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if _, ok := declarations[key.Value]; ok {
+			continue
+		}
+		d := declaration{line: key.Line, steps: map[string][]int{}}
+		if value.Kind == yamlv3.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				section, steps := value.Content[j].Value, value.Content[j+1]
+				if (section != historySection && section != pathwaySection) || steps.Kind != yamlv3.SequenceNode {
+					continue
+				}
+				for _, step := range steps.Content {
+					d.steps[section] = append(d.steps[section], step.Line)
+				}
+			}
+		}
+		declarations[key.Value] = d
+	}
+	return declarations
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathway
+
+import (
+	"context"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
+)
+
+func TestLint(t *testing.T) {
+	ctx := context.Background()
+	valid := []byte(`valid:
+  pathway:
+    - admission:
+        loc: ED
+    - discharge: {}
+
+invalid:
+  pathway:
+    - admission:
+        loc: unknown
+    - order: {}
+
+extends_unknown:
+  extends: unknown
+
+inherits_invalid:
+  extends: invalid
+
+invalid_history:
+  historical_data:
+    - admission:
+        loc: ED
+  pathway:
+    - discharge: {}
+`)
+	unparseable := []byte(`first:
+  pathway:
+    - admission:
+        loc: ED
+      unknown_field: value
+    - discharge:
+        unknown_field: value
+`)
+	json := []byte(`{
+  "valid": {
+    "pathway": [{"discharge": {}}]
+  },
+  "json_pathway": {
+    "pathway": [{"use_patient": {}}]
+  },
+  "valid_json": {
+    "pathway": [{"discharge": {}}]
+  }
+}`)
+
+	dir := testwrite.TempDir(t)
+	testwrite.BytesToFileInExistingDir(t, valid, dir, "a.yml")
+	testwrite.BytesToFileInExistingDir(t, unparseable, dir, "b.yml")
+	testwrite.BytesToFileInExistingDir(t, json, dir, "c.json")
+	a := path.Join(dir, "a.yml")
+	b := path.Join(dir, "b.yml")
+	c := path.Join(dir, "c.json")
+
+	parser := newDefaultParser(ctx, t, time.Now())
+	pathways, problems, err := parser.Lint(ctx, dir)
+	if err != nil {
+		t.Fatalf("Lint(%s) failed with %v", dir, err)
+	}
+
+	type problem struct {
+		file    string
+		line    int
+		pathway string
+	}
+	wantProblems := []problem{
+		// The admission has an unknown location, and the order has no order profile.
+		// Problems in steps are reported at the step.
+		{file: a, line: 9, pathway: "invalid"},
+		{file: a, line: 11, pathway: "invalid"},
+		{file: a, line: 13, pathway: "extends_unknown"},
+		// Problems in inherited steps are reported at the pathway that inherits them.
+		{file: a, line: 16, pathway: "inherits_invalid"},
+		{file: a, line: 16, pathway: "inherits_invalid"},
+		// The historical step has no time_from_now.
+		{file: a, line: 21, pathway: "invalid_history"},
+		{file: b, line: 5},
+		{file: b, line: 7},
+		{file: c, line: 2, pathway: "valid"},
+		{file: c, line: 6, pathway: "json_pathway"},
+	}
+	var gotProblems []problem
+	for _, p := range problems {
+		gotProblems = append(gotProblems, problem{file: p.File, line: p.Line, pathway: p.Pathway})
+	}
+	if diff := cmp.Diff(wantProblems, gotProblems, cmp.AllowUnexported(problem{})); diff != "" {
+		t.Errorf("Lint(%s) got problems diff (-want, +got):\n%s\nproblems: %v", dir, diff, problems)
+	}
+	// "valid" is re-declared, so it is not returned.
+	var gotNames []string
+	for name := range pathways {
+		gotNames = append(gotNames, name)
+	}
+	sort.Strings(gotNames)
+	if diff := cmp.Diff([]string{"valid_json"}, gotNames); diff != "" {
+		t.Errorf("Lint(%s) got pathways diff (-want, +got):\n%s", dir, diff)
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	errUnknown := errors.New("unknown")
+	cases := []struct {
+		problem Problem
+		want    string
+	}{{
+		problem: Problem{File: "pathways.yml", Line: 3, Pathway: "pathway1", Err: errUnknown},
+		want:    `pathways.yml:3: pathway "pathway1": unknown`,
+	}, {
+		problem: Problem{File: "pathways.yml", Err: errUnknown},
+		want:    `pathways.yml: unknown`,
+	}}
+	for _, tc := range cases {
+		if got := tc.problem.String(); got != tc.want {
+			t.Errorf("%+v.String() got %q, want %q", tc.problem, got, tc.want)
+		}
+	}
+}
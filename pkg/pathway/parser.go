@@ -17,7 +17,9 @@ package pathway
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"path/filepath"
+	"sort"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
@@ -45,6 +47,16 @@ type Parser struct {
 	Valid func(*Pathway) error
 	// LocationManager contains the patient locations.
 	LocationManager *location.Manager
+	// Rand is the source of randomness used to generate the values that pathways don't specify, such as
+	// the IDs of new consultants.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
+}
+
+// FileFormat returns an empty value of the type that pathway files are unmarshalled into.
+// It is used to generate the JSON Schema of the files.
+func FileFormat() interface{} {
+	return map[string]Pathway{}
 }
 
 // ParsePathways parses all pathways defined in the pathwaysDir.
@@ -55,6 +67,8 @@ type Parser struct {
 // All pathways are initialised, but are not necessarily runnable yet. Ensure that Runnable() is called
 // before the pathway is ran.
 // Pathways can be specified in YAML or JSON.
+// Pathways can extend pathways defined in any file of the directory; an error is returned if a base pathway
+// does not exist or if there is a cycle in the chain of base pathways.
 func (p *Parser) ParsePathways(ctx context.Context, pathwaysDir string) (map[string]Pathway, error) {
 	logLocal := log.WithField("pathway_dir", pathwaysDir)
 	logLocal.Info("Parsing pathways from directory")
@@ -63,7 +77,8 @@ func (p *Parser) ParsePathways(ctx context.Context, pathwaysDir string) (map[str
 		return nil, errors.Wrapf(err, "Failed to read pathways files from %s", pathwaysDir)
 	}
 
-	validPathways := map[string]Pathway{}
+	parsedPathways := map[string]Pathway{}
+	pathwayFiles := map[string]string{}
 	redeclaredPathways := make(map[string]bool, 0)
 	for _, file := range files {
 		if !fileExtensionIsValid(file.Name()) {
@@ -79,17 +94,18 @@ func (p *Parser) ParsePathways(ctx context.Context, pathwaysDir string) (map[str
 
 		for pathwayName, pathway := range p {
 			logLocal := logLocal.WithField("pathway_name", pathwayName)
-			if _, ok := validPathways[pathwayName]; ok {
+			if _, ok := parsedPathways[pathwayName]; ok {
 				logLocal.Error("Pathway re-declared")
 				redeclaredPathways[pathwayName] = true
 				continue
 			}
 
 			logLocal.Debug("Adding pathway")
-			validPathways[pathwayName] = pathway
+			parsedPathways[pathwayName] = pathway
+			pathwayFiles[pathwayName] = file.FullPath()
 		}
 	}
-	if len(validPathways) == 0 {
+	if len(parsedPathways) == 0 {
 		return nil, fmt.Errorf("cannot load pathways from %s: no valid pathways", pathwaysDir)
 	}
 
@@ -97,6 +113,16 @@ func (p *Parser) ParsePathways(ctx context.Context, pathwaysDir string) (map[str
 		return nil, fmt.Errorf("cannot load pathways from %s: found re-declared pathways: %v", pathwaysDir, redeclaredPathways)
 	}
 
+	// Pathways can extend pathways defined in other files, so they are resolved and validated
+	// once all files have been parsed.
+	validPathways, err := resolveInheritance(parsedPathways)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load pathways from %s", pathwaysDir)
+	}
+	if err := p.validate(validPathways, pathwayFiles); err != nil {
+		return nil, errors.Wrapf(err, "cannot load pathways from %s", pathwaysDir)
+	}
+
 	return validPathways, nil
 }
 
@@ -123,12 +149,15 @@ func (p *Parser) ParseSinglePathway(pathwayDefinition []byte) (Pathway, error) {
 			pathwayName = k
 		}
 	}
+	if pathway.Extends != "" {
+		return Pathway{}, fmt.Errorf("cannot resolve base pathway %q: pathways that extend other pathways can only be parsed with ParsePathways", pathway.Extends)
+	}
 	pathway.Init(pathwayName)
-	if err := pathway.Valid(p.Clock, p.OrderProfiles, p.Doctors, p.LocationManager, p.Valid); err != nil {
+	if err := pathway.Valid(p.Clock, p.OrderProfiles, p.Doctors, p.LocationManager, p.Valid, p.Rand); err != nil {
 		return Pathway{}, errors.Wrap(err, "invalid pathway")
 	}
 
-	pathway, err = pathway.Runnable()
+	pathway, err = pathway.Runnable(p.Rand)
 	if err != nil {
 		return Pathway{}, errors.Wrap(err, "cannot run Runnable on pathway")
 	}
@@ -150,23 +179,36 @@ func (p *Parser) parse(ctx context.Context, file files.File) (map[string]Pathway
 		return nil, errors.Wrap(err, "cannot unmarshal pathways")
 	}
 
+	return pathways, nil
+}
+
+// validate initialises and validates the given pathways.
+// pathwayFiles contains the file where each pathway is defined, and is used for logging.
+func (p *Parser) validate(pathways map[string]Pathway, pathwayFiles map[string]string) error {
 	invalidPathways := make([]string, 0)
 	var allErrors []error
-	for name, pathway := range pathways {
+	// The pathways are validated in order of name, so that the values generated during validation only
+	// depend on the source of randomness.
+	names := make([]string, 0, len(pathways))
+	for name := range pathways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pathway := pathways[name]
 		pathway.Init(name)
 		pathways[name] = pathway
-		if err := pathway.Valid(p.Clock, p.OrderProfiles, p.Doctors, p.LocationManager, p.Valid); err != nil {
-			log.WithField("pathway_file", file.FullPath()).WithField("pathway_name", name).
+		if err := pathway.Valid(p.Clock, p.OrderProfiles, p.Doctors, p.LocationManager, p.Valid, p.Rand); err != nil {
+			log.WithField("pathway_file", pathwayFiles[name]).WithField("pathway_name", name).
 				WithError(err).Error("Invalid pathway")
 			invalidPathways = append(invalidPathways, name)
 			allErrors = append(allErrors, err)
 		}
 	}
 	if len(invalidPathways) > 0 {
-		return nil, fmt.Errorf("pathways %v are invalid: %v", invalidPathways, allErrors)
+		return fmt.Errorf("pathways %v are invalid: %v", invalidPathways, allErrors)
 	}
-
-	return pathways, nil
+	return nil
 }
 
 func fileExtensionIsValid(fileName string) bool {
@@ -430,6 +430,7 @@ func TestParseSinglePathway(t *testing.T) {
 			{Discharge: &Discharge{}},
 		},
 	}
+	twoHours := 2 * time.Hour
 	p := newDefaultParser(ctx, t, time.Now())
 
 	cases := []struct {
@@ -439,6 +440,28 @@ func TestParseSinglePathway(t *testing.T) {
 		want              Pathway
 		wantErr           bool
 	}{{
+		name: "valid yml pathway with wait_for_event",
+		pathwayDefinition: []byte(`
+pathway:
+  - admission:
+      loc: Renal
+  - wait_for_event:
+      event: ORU^R01
+      timeout: 2h
+      on_timeout:
+        - cancel_visit: {}
+  - discharge: {}
+`),
+		want: Pathway{
+			Persons: &Persons{defaultPatientID: {}},
+			Pathway: []Step{
+				{Admission: &Admission{Loc: "Renal"}},
+				{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &twoHours, OnTimeout: []Step{{CancelVisit: &CancelVisit{}}}}},
+				{Discharge: &Discharge{}},
+			},
+		},
+		wantName: UnknownPathwayName,
+	}, {
 		name: "valid yml pathway without name",
 		pathwayDefinition: []byte(`
 pathway:
@@ -1657,7 +1680,7 @@ pathway_autogenerate:
 	runs := 1000
 	gotFreq := make(map[string]int)
 	for i := 0; i < runs; i++ {
-		got, err := pathway.Runnable()
+		got, err := pathway.Runnable(nil)
 		if err != nil {
 			t.Fatalf("[%v].Runnable() failed with %v", pathway, err)
 		}
@@ -1767,7 +1790,7 @@ random_pathway:
 		t.Errorf("ParsePathways(%s)[%s] got diff (-want, +got):\n%s", string(pathwayDefinition), defaultPathwayName, diff)
 	}
 
-	got, err := gotOriginal.Runnable()
+	got, err := gotOriginal.Runnable(nil)
 	if err != nil {
 		t.Fatalf("[%+v].Runnable() failed with %v", gotOriginal, err)
 	}
@@ -1837,7 +1860,7 @@ func parsePathwayDefinition(ctx context.Context, t *testing.T, pathwayDefinition
 	}
 
 	pathway := pathways[defaultPathwayName]
-	runnable, err := pathway.Runnable()
+	runnable, err := pathway.Runnable(nil)
 	if err != nil {
 		t.Fatalf("[%v].Runnable() failed with %v", pathway, err)
 	}
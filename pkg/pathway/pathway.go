@@ -30,6 +30,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/constants"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
 	"github.com/Arend-melissant/simhospital/pkg/orderprofile"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // The following constants represent step types.
@@ -67,6 +68,9 @@ const (
 	StepDocument               = "Document"
 	StepGeneric                = "Generic"
 	StepGenerateResources      = "GenerateResources"
+	StepWaitForEvent           = "WaitForEvent"
+	StepParallel               = "Parallel"
+	StepBirth                  = "Birth"
 )
 
 const (
@@ -115,6 +119,22 @@ type Person struct {
 	Address     *Address
 	NHS         string
 	MRN         string
+	// Relationships are the relationships of this person with other persons in the pathway.
+	// Related persons are added to the person's associated parties, and are sent in NK1 segments.
+	Relationships []Relationship `yaml:",omitempty"`
+}
+
+// Relationship represents the relationship of a person with another person in the same pathway.
+type Relationship struct {
+	// Person is the ID of the related person. It must be one of the persons in the pathway.
+	Person PatientID
+	// Code is the HL7 code of the relationship, e.g., "MTH" for mother.
+	Code string
+	// Description is the human-readable description of the relationship, e.g., "Mother".
+	Description string
+	// SharesAddress indicates that the person lives in the same household as the related person,
+	// in which case the person takes the address of the related person.
+	SharesAddress bool `yaml:"shares_address"`
 }
 
 // Gender represents a gender of the person.
@@ -204,6 +224,48 @@ type Parameters struct {
 	ReceivingFacility string `yaml:"receiving_facility,omitempty"`
 	// Custom are other parameters that can be used for custom processing.
 	Custom map[string]string `yaml:"custom,omitempty"`
+	// Constraints restrict the times at which the event for this step can happen.
+	Constraints *TimeConstraints `yaml:"constraints,omitempty"`
+}
+
+// TimeOfDayLayout is the layout of times of day in TimeConstraints.
+const TimeOfDayLayout = "15:04"
+
+// EndOfDay is the time of day at the end of the day, which is accepted as a time of day in
+// TimeConstraints and working hours, e.g., for locations that close at midnight.
+const EndOfDay = "24:00"
+
+// ParseTimeOfDay parses a time of day in the format TimeOfDayLayout and returns the number of
+// minutes since midnight. EndOfDay is accepted too, and is 24 hours after midnight.
+func ParseTimeOfDay(s string) (int, error) {
+	if s == EndOfDay {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse(TimeOfDayLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: must be in the format HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// TimeConstraints restrict the times at which the event for a step can happen.
+// If the time calculated for the event does not satisfy the constraints, the event is moved
+// forward to the earliest time that does. Holidays are taken from the hospital's calendar.
+type TimeConstraints struct {
+	// NotBefore is the earliest time of day at which the event can happen, eg.: "08:00".
+	NotBefore string `yaml:"not_before,omitempty"`
+	// NotAfter is the latest time of day at which the event can happen, eg.: "17:30", or "24:00"
+	// for the end of the day.
+	NotAfter string `yaml:"not_after,omitempty"`
+	// WeekdaysOnly indicates that the event can only happen from Monday to Friday,
+	// excluding holidays.
+	WeekdaysOnly bool `yaml:"weekdays_only,omitempty"`
+	// NextClinicDay is the name of a location. If set, the event happens on the first clinic day
+	// of the location after the day it was due, at the time the clinic opens.
+	NextClinicDay string `yaml:"next_clinic_day,omitempty"`
+	// WorkingHoursOf is the name of a location. If set, the event can only happen within the
+	// working hours of the location, excluding holidays.
+	WorkingHoursOf string `yaml:"working_hours_of,omitempty"`
 }
 
 // Order is a step to place an order. It produces an ORM message followed by
@@ -452,6 +514,20 @@ type AddPerson struct {
 	Allergies []Allergy
 }
 
+// Birth is a step to register a newborn whose mother is the current patient.
+// The newborn is linked to the mother as an associated party and vice versa.
+// It produces an ADT^A28 message for the newborn.
+// After this step, the newborn can be used in the pathway with a UsePatient step.
+type Birth struct {
+	// Baby is the ID that identifies the newborn in the rest of the pathway.
+	// It must not be one of the persons in the Persons section.
+	Baby PatientID
+	// Person contains the details of the newborn.
+	// The age, date of birth and relationships cannot be set: the date of birth is the time of the step,
+	// and the newborn is always related to their mother.
+	Person *Person `yaml:",omitempty"`
+}
+
 // CancelTransfer is a step to cancel a Transfer. It produces an ADT^A12 message.
 type CancelTransfer struct{}
 
@@ -578,6 +654,17 @@ func (p *Persons) HasOnePerson() bool {
 	return !p.isEmpty() && len(*p) == 1
 }
 
+// SortedIDs returns the IDs of the persons, sorted, so that the persons can be iterated over in
+// the same order every time.
+func (p Persons) SortedIDs() []PatientID {
+	ids := make([]PatientID, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 // isEmpty returns true if the underlying map has no persons.
 func (p *Persons) isEmpty() bool {
 	return p == nil || len(*p) == 0
@@ -595,7 +682,7 @@ func (p *Persons) isDefault() bool {
 	for pID := range *p {
 		patientIDs[0] = pID
 	}
-	return patientIDs[0] == defaultPatientID && (*p)[defaultPatientID] == Person{}
+	return patientIDs[0] == defaultPatientID && reflect.DeepEqual((*p)[defaultPatientID], Person{})
 }
 
 // OnlyPerson returns the key and the value of the only item in the Persons map.
@@ -783,6 +870,47 @@ type HardcodedMessage struct {
 	Regex string
 }
 
+// WaitForEvent step pauses the pathway until an external event arrives for the patient,
+// or until Timeout elapses, whichever happens first.
+// External events are either inbound HL7 messages, in which case the event name is the message type
+// and trigger event (e.g., "ORU^R01") and the patient is identified by the first identifier in PID-3,
+// or explicit requests made to the trigger endpoint of the authenticated API.
+// If the event arrives in time, the pathway continues with the next step.
+// If the timeout elapses, the steps in OnTimeout run instead of the rest of the pathway.
+// WaitForEvent steps are not supported in historical steps.
+type WaitForEvent struct {
+	// Event is the name of the external event the pathway waits for.
+	// Required.
+	Event string
+	// Timeout is the maximum time to wait for the event.
+	// Required.
+	Timeout *time.Duration
+	// OnTimeout are the steps that run instead of the rest of the pathway if the timeout elapses.
+	// Optional. If not set, the pathway finishes when the timeout elapses.
+	OnTimeout []Step `yaml:"on_timeout,omitempty"`
+}
+
+// Parallel step runs several sequences of steps, or tracks, concurrently for the same patient.
+// Every track is scheduled independently from the others, starting at the time the Parallel step runs,
+// so that the events of different tracks interleave based on their delays.
+// The pathway continues with the step after Parallel once all the tracks have finished.
+// Parallel steps are not supported in historical steps.
+type Parallel struct {
+	// Tracks are the sequences of steps to run concurrently.
+	// Required.
+	Tracks []Track
+}
+
+// Track is a sequence of steps that runs as part of a Parallel step.
+type Track struct {
+	// Name is the name of the track. It is only used for logging.
+	// Optional.
+	Name string
+	// Steps are the steps in the track.
+	// Required.
+	Steps []Step
+}
+
 // Age is randomly chosen as a number of years between From and To.
 // If DayOfYear is provided as a nonzero value, it is used as a 1-indexed
 // value to indicate which day of a year that person was born.
@@ -892,57 +1020,60 @@ func (r *Result) IsValueRandom() bool {
 	return randomValues[r.Value]
 }
 
-// Random returns random duration between [d.From, d.To).
+// Random returns random duration between [d.From, d.To), using rng as the source of randomness.
+// If rng is nil, the default source of the math/rand package is used.
 // If d == nil, returns 0.
 // If d.From == d.To, returns d.From.
-func (d *Delay) Random() time.Duration {
+func (d *Delay) Random(rng *rand.Rand) time.Duration {
 	if d == nil {
 		return 0
 	}
 	if d.From == d.To {
 		return d.From
 	}
-	return time.Duration(rand.Int63n(int64(d.To)-int64(d.From)) + int64(d.From))
+	return time.Duration(random.Or(rng).Int63n(int64(d.To)-int64(d.From)) + int64(d.From))
 }
 
-// Random returns random int between [i.From, i.To).
+// Random returns random int between [i.From, i.To), using rng as the source of randomness.
+// If rng is nil, the default source of the math/rand package is used.
 // If i.From == a.To, returns i.From.
-func (i *Interval) Random() int {
+func (i *Interval) Random(rng *rand.Rand) int {
 	if i.From == i.To {
 		return i.From
 	}
-	return rand.Intn(i.To-i.From) + i.From
+	return random.Or(rng).Intn(i.To-i.From) + i.From
 }
 
 // random returns random int between [a.From, a.To).
 // If a.From == a.To, returns a.From.
-func (a *Age) random() int {
+func (a *Age) random(rng *rand.Rand) int {
 	if a.From == a.To {
 		return a.From
 	}
-	return rand.Intn(a.To-a.From) + a.From
+	return random.Or(rng).Intn(a.To-a.From) + a.From
 }
 
 // getDayOfYear returns the 0-indexed day of the year the person was born;
 // this is either given in the pathway or randomized.
-func (a *Age) getDayOfYear() int {
+func (a *Age) getDayOfYear(rng *rand.Rand) int {
 	if a.DayOfYear > 0 {
 		return a.DayOfYear - 1
 	}
-	return rand.Intn(365)
+	return random.Or(rng).Intn(365)
 }
 
-// Birthdate returns the date of birth for the givem age given a clock.
-func (a *Age) Birthdate(clock clock.Clock) time.Time {
-	year := clock.Now().Year() - a.random()
-	dayOfYear := a.getDayOfYear()
+// Birthdate returns the date of birth for the givem age given a clock, using rng as the source of
+// randomness. If rng is nil, the default source of the math/rand package is used.
+func (a *Age) Birthdate(clock clock.Clock, rng *rand.Rand) time.Time {
+	year := clock.Now().Year() - a.random(rng)
+	dayOfYear := a.getDayOfYear(rng)
 	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, dayOfYear)
 }
 
 // RandomBirthdate returns the date of birth, so that the age is between 1 and 100.
-func RandomBirthdate(clock clock.Clock) time.Time {
+func RandomBirthdate(clock clock.Clock, rng *rand.Rand) time.Time {
 	a := &Age{From: 1, To: 100}
-	return a.Birthdate(clock)
+	return a.Birthdate(clock, rng)
 }
 
 // Step represents an event in a patient pathway. Exactly one field (Delay, Admission, etc.) should
@@ -981,12 +1112,18 @@ type Step struct {
 	Document               *Document               `yaml:",omitempty"`
 	Generic                *Generic                `yaml:",omitempty"`
 	GenerateResources      *GenerateResources      `yaml:"generate_resources,omitempty"`
+	WaitForEvent           *WaitForEvent           `yaml:"wait_for_event,omitempty"`
+	Parallel               *Parallel               `yaml:",omitempty"`
+	Birth                  *Birth                  `yaml:",omitempty"`
 	// Up to this point, only one of the fields can be set. The pathway will be considered invalid if
 	// more than one of the above fields is set.
 
 	// Parameters contain additional parameters of this step and can be set
 	// is addition to the actual Step field.
 	Parameters *Parameters `yaml:",omitempty"`
+	// Label identifies the step, so that pathways that extend this pathway can override it.
+	// Labels must be unique within a pathway.
+	Label string `yaml:",omitempty"`
 	// stepType is a Step Type. It is derived based on which Step field is set and is caches,
 	// so that we don't need to re-calculate it. Note this means that if fields are
 	// set or unset manually, the type and the set fields could be inconsistent. However in practice
@@ -1010,7 +1147,13 @@ type Consultant struct {
 
 // Pathway represents a pathway.
 type Pathway struct {
-	Percentage *Percentage `yaml:"percentage_of_patients,omitempty"`
+	// Extends is the name of the base pathway of this pathway, if any.
+	// The fields that are not set in this pathway are taken from the base pathway.
+	Extends string `yaml:"extends,omitempty"`
+	// Overrides replace steps of the base pathway.
+	// They are applied when the pathway is parsed, and are not set in parsed pathways.
+	Overrides  []StepOverride `yaml:"overrides,omitempty"`
+	Percentage *Percentage    `yaml:"percentage_of_patients,omitempty"`
 	// Persons contain persons this pathway relates to.
 	// The code that uses pathways can assume that Persons will always be present after the pathway
 	// is parsed through ParsePathways or ParseSinglePathway.
@@ -1097,7 +1240,7 @@ func (s *Step) StepType() string {
 
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
-		if !f.IsNil() && isStepTypeField(v, f) {
+		if isStepTypeField(v, f) && !f.IsNil() {
 			if name != stepInvalid {
 				// Only one field of the fields that define the step type can be set at once.
 				s.stepType = &stepType{stepInvalid}
@@ -1113,7 +1256,7 @@ func (s *Step) StepType() string {
 // isStepTypeField returns whether the field "f" is a field that defines the step type of a Step,
 // e.g., "Admission" or "Results".
 func isStepTypeField(v reflect.Value, f reflect.Value) bool {
-	return f != v.FieldByName("Parameters") && f != v.FieldByName("stepType")
+	return f.Kind() == reflect.Ptr && f != v.FieldByName("Parameters") && f != v.FieldByName("stepType")
 }
 
 // GetDateTime evaluates the configured DateTime value and returns an absolute time or nil.
@@ -1161,8 +1304,16 @@ func (p *Pathway) Init(pathwayName string) {
 // numberOfMessages returns the number of messages the step generates.
 func (s *Step) numberOfMessages() int {
 	switch {
-	case s.UsePatient != nil || s.Delay != nil:
+	case s.UsePatient != nil || s.Delay != nil || s.WaitForEvent != nil:
 		return 0
+	case s.Parallel != nil:
+		n := 0
+		for _, t := range s.Parallel.Tracks {
+			for _, ts := range t.Steps {
+				n += ts.numberOfMessages()
+			}
+		}
+		return n
 	case s.Order != nil && s.Order.NoAcknowledgementMessage:
 		return 1
 	case s.Order != nil:
@@ -1174,12 +1325,13 @@ func (s *Step) numberOfMessages() int {
 
 // Runnable returns the pathway that is ready to be ran.
 // It never modifies the original pathway, but rather creates a copy.
-// If the pathway has AutoGenerate steps, it parses them and generates relevant steps.
+// If the pathway has AutoGenerate steps, it parses them and generates relevant steps, using rng as the
+// source of randomness for the delays; if rng is nil, the default source of the math/rand package is used.
 // Returns an error if AutoGenerate steps cannot be parsed.
-func (p *Pathway) Runnable() (Pathway, error) {
+func (p *Pathway) Runnable(rng *rand.Rand) (Pathway, error) {
 	pathway := p.getCopy()
 	if pathway.hasAutoGenerateStep() {
-		if err := pathway.parseAutoGenerate(); err != nil {
+		if err := pathway.parseAutoGenerate(rng); err != nil {
 			return Pathway{}, errors.Wrap(err, "cannot parse AutoGenerate step")
 		}
 	}
@@ -1191,11 +1343,11 @@ func (p *Pathway) Runnable() (Pathway, error) {
 // (1) It will add a delay step at pathway end if pathway time < t, or
 // (2) Break up the delay step into two smaller ones if t is in the middle of it,
 // 	   and insert the step in between those two delays.
-func (p *Pathway) insertAtTime(s Step, t time.Duration) error {
+func (p *Pathway) insertAtTime(s Step, t time.Duration, rng *rand.Rand) error {
 	if t < time.Duration(0) {
 		return p.insertInHistory(s, t)
 	}
-	return p.insertInPathway(s, t)
+	return p.insertInPathway(s, t, rng)
 }
 
 func (p *Pathway) insertInHistory(s Step, t time.Duration) error {
@@ -1214,8 +1366,8 @@ func (p *Pathway) insertInHistory(s Step, t time.Duration) error {
 	return nil
 }
 
-func (p *Pathway) insertInPathway(s Step, t time.Duration) error {
-	i, pathwayTime, err := pathwayIndexAtTime(p.Pathway, t)
+func (p *Pathway) insertInPathway(s Step, t time.Duration, rng *rand.Rand) error {
+	i, pathwayTime, err := pathwayIndexAtTime(p.Pathway, t, rng)
 	if err != nil {
 		return errors.Wrapf(err, "cannot calculate index at time %v", t)
 	}
@@ -1267,7 +1419,7 @@ func (p *Pathway) insertInPathway(s Step, t time.Duration) error {
 //		we return index where delay is and pathway time after that step.
 //	(3) If we traverse the whole pathway and pathway time is less than t,
 //		we return index after the last element, and current pathway time.
-func pathwayIndexAtTime(p []Step, t time.Duration) (int, time.Duration, error) {
+func pathwayIndexAtTime(p []Step, t time.Duration, rng *rand.Rand) (int, time.Duration, error) {
 	if t < time.Duration(0) {
 		return 0, time.Duration(0), fmt.Errorf("time is negative: %v", t)
 	}
@@ -1275,7 +1427,7 @@ func pathwayIndexAtTime(p []Step, t time.Duration) (int, time.Duration, error) {
 	pathwayTime := time.Duration(0)
 	for i, x := range p {
 		if x.Delay != nil {
-			delay := x.Delay.Random()
+			delay := x.Delay.Random(rng)
 			p[i] = Step{Delay: &Delay{From: delay, To: delay}}
 
 			pathwayTime += delay
@@ -1324,7 +1476,7 @@ func insertAtIndex(p []Step, s Step, i int) ([]Step, error) {
 	return p, nil
 }
 
-func (p *Pathway) parseAutoGenerate() error {
+func (p *Pathway) parseAutoGenerate(rng *rand.Rand) error {
 	var agSteps []Step
 
 	// Removing AutoGenerate steps from the pathway in reverse order.
@@ -1343,7 +1495,7 @@ func (p *Pathway) parseAutoGenerate() error {
 	for _, s := range agSteps {
 		resultTime := *s.AutoGenerate.From
 		for resultTime <= *s.AutoGenerate.To {
-			if err := p.insertAtTime(Step{Result: s.AutoGenerate.Result, Parameters: s.Parameters}, resultTime); err != nil {
+			if err := p.insertAtTime(Step{Result: s.AutoGenerate.Result, Parameters: s.Parameters}, resultTime, rng); err != nil {
 				return errors.Wrapf(err, "cannot insert at time %v", resultTime)
 			}
 
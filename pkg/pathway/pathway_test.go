@@ -96,7 +96,7 @@ func TestAgeBirthdate(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := tc.age.Birthdate(clock)
+			got := tc.age.Birthdate(clock, nil)
 			if got.Year() < tc.wantYearFrom || got.Year() > tc.wantYearTo {
 				t.Errorf("(%+v).Birthdate(%+v)=%v, want year between (%d, %d)", tc.age, clock, got, tc.wantYearFrom, tc.wantYearTo)
 			}
@@ -133,7 +133,7 @@ func TestBirthdateDayOfYear(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			age := &Age{DayOfYear: tc.dayOfYear}
-			dob := age.Birthdate(clock)
+			dob := age.Birthdate(clock, nil)
 			if got, want := int(dob.Month()), tc.wantMonth; got != want {
 				t.Errorf("(%+v).Birthdate(_).Month()=%v, want %v", age, got, want)
 			}
@@ -150,7 +150,7 @@ func TestRandomBirthdate(t *testing.T) {
 
 	wantYearFrom := now.Year() - 100
 	wantYearTo := now.Year() - 1
-	got := RandomBirthdate(clock)
+	got := RandomBirthdate(clock, nil)
 	if got.Year() < wantYearFrom || got.Year() > wantYearTo {
 		t.Errorf("RandomBirthdate(%+v)=%v, want year between (%d, %d)", clock, got, wantYearFrom, wantYearTo)
 	}
@@ -363,6 +363,9 @@ func TestStepStepType(t *testing.T) {
 		{step: Step{ClinicalNote: &ClinicalNote{}}, want: StepClinicalNote},
 		{step: Step{HardcodedMessage: &HardcodedMessage{}}, want: StepHardcodedMessage},
 		{step: Step{Document: &Document{}}, want: StepDocument},
+		{step: Step{WaitForEvent: &WaitForEvent{}}, want: StepWaitForEvent},
+		{step: Step{Parallel: &Parallel{}}, want: StepParallel},
+		{step: Step{Birth: &Birth{}}, want: StepBirth},
 	}
 	for _, tc := range cases {
 		t.Run(fmt.Sprintf("%v", tc.want), func(t *testing.T) {
@@ -623,3 +626,36 @@ func TestInitCalledMultipleTimes(t *testing.T) {
 		t.Errorf("[%+v].MessageCount()=%d, want %d", pathway, got, want)
 	}
 }
+
+func TestPersonsSortedIDs(t *testing.T) {
+	persons := Persons{"patient-2": {}, "patient-3": {}, "patient-1": {}}
+	want := []PatientID{"patient-1", "patient-2", "patient-3"}
+	if diff := cmp.Diff(want, persons.SortedIDs()); diff != "" {
+		t.Errorf("SortedIDs() diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "00:00", want: 0},
+		{in: "08:30", want: 8*60 + 30},
+		{in: "23:59", want: 23*60 + 59},
+		{in: "24:00", want: 24 * 60},
+		{in: "24:01", wantErr: true},
+		{in: "8am", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := ParseTimeOfDay(tc.in)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("ParseTimeOfDay(%q) got err %v, want error: %t", tc.in, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("ParseTimeOfDay(%q) got %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathway
+
+import (
+	"sync"
+)
+
+// ReloadableManager is a pathway manager that delegates to another manager, which can be replaced
+// while the ReloadableManager is in use, e.g., to reload the pathways while Simulated Hospital runs.
+// The methods of ReloadableManager are safe for concurrent use.
+type ReloadableManager struct {
+	m  Manager
+	mu sync.Mutex
+}
+
+// NewReloadableManager creates a new ReloadableManager that delegates to the given manager.
+func NewReloadableManager(m Manager) *ReloadableManager {
+	return &ReloadableManager{m: m}
+}
+
+// GetPathway returns the pathway with the given name from the current manager.
+func (r *ReloadableManager) GetPathway(pathwayName string) (*Pathway, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.m.GetPathway(pathwayName)
+}
+
+// NextPathway returns the next pathway to run from the current manager.
+func (r *ReloadableManager) NextPathway() (*Pathway, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.m.NextPathway()
+}
+
+// Manager returns the current manager.
+func (r *ReloadableManager) Manager() Manager {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.m
+}
+
+// Replace replaces the current manager with the given one. The pathways that were returned by the
+// current manager are not affected.
+// If both managers are DeterministicManagers, the new manager continues from the same index of its
+// order, if it is in range, instead of from the beginning.
+func (r *ReloadableManager) Replace(m Manager) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.m.(*DeterministicManager); ok {
+		if next, ok := m.(*DeterministicManager); ok {
+			// An index that is out of range is ignored.
+			next.SetNextIndex(old.NextIndex())
+		}
+	}
+	r.m = m
+}
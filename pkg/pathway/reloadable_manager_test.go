@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathway
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestReloadableManager(t *testing.T) {
+	steps := []Step{
+		{Admission: &Admission{}},
+		{Discharge: &Discharge{}},
+	}
+	pathways := map[string]Pathway{
+		"pathway1": {Pathway: steps},
+		"pathway2": {Pathway: steps},
+		"pathway3": {Pathway: steps},
+	}
+	m, err := NewDeterministicManager(pathways, []string{"pathway1", "pathway2", "pathway3"}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("NewDeterministicManager() failed with %v", err)
+	}
+	r := NewReloadableManager(m)
+	if p, err := r.NextPathway(); err != nil || p.Name() != "pathway1" {
+		t.Fatalf("NextPathway() got (%v, %v), want pathway1", p, err)
+	}
+
+	// The new manager continues from the same index.
+	reloaded := map[string]Pathway{
+		"pathway1": {Pathway: steps},
+		"pathway4": {Pathway: steps},
+	}
+	next, err := NewDeterministicManager(reloaded, []string{"pathway1", "pathway4"}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("NewDeterministicManager() failed with %v", err)
+	}
+	r.Replace(next)
+	if got := r.Manager(); got != next {
+		t.Errorf("Manager() got %v, want the new manager", got)
+	}
+	if p, err := r.NextPathway(); err != nil || p.Name() != "pathway4" {
+		t.Errorf("NextPathway() got (%v, %v), want pathway4", p, err)
+	}
+	if _, err := r.GetPathway("pathway2"); err == nil {
+		t.Error("GetPathway(pathway2) of a pathway that was removed got nil error, want error")
+	}
+	if _, err := r.GetPathway("pathway4"); err != nil {
+		t.Errorf("GetPathway(pathway4) failed with %v", err)
+	}
+
+	// An index that is out of range for the new manager is ignored.
+	r.NextPathway()
+	if got, want := r.Manager().(*DeterministicManager).NextIndex(), 1; got != want {
+		t.Fatalf("NextIndex() got %d, want %d", got, want)
+	}
+	single, err := NewDeterministicManager(reloaded, []string{"pathway4"}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("NewDeterministicManager() failed with %v", err)
+	}
+	r.Replace(single)
+	if p, err := r.NextPathway(); err != nil || p.Name() != "pathway4" {
+		t.Errorf("NextPathway() got (%v, %v), want pathway4", p, err)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/Arend-melissant/simhospital/pkg/ir"
 	"github.com/Arend-melissant/simhospital/pkg/location"
 	"github.com/Arend-melissant/simhospital/pkg/orderprofile"
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 func (d *Delay) valid() error {
@@ -58,21 +59,12 @@ func validLocation(loc string, lm *location.Manager) error {
 	if loc == "" {
 		return errors.New("location not provided")
 	}
-	if _, ok := lm.RoomManagers[loc]; !ok {
-		locs := locationNames(lm)
-		return fmt.Errorf("unknown location %q, supported locations are [%v]", loc, strings.Join(locs, ","))
+	if _, err := lm.Location(loc); err != nil {
+		return fmt.Errorf("unknown location %q, supported locations are [%v]", loc, strings.Join(lm.Names(), ","))
 	}
 	return nil
 }
 
-func locationNames(lm *location.Manager) []string {
-	var locs []string
-	for l := range lm.RoomManagers {
-		locs = append(locs, l)
-	}
-	return locs
-}
-
 func (p *PreAdmission) valid(lm *location.Manager) error {
 	if p == nil {
 		return nil
@@ -224,6 +216,56 @@ func (a *AutoGenerate) valid() error {
 	return a.Result.valid()
 }
 
+func (w *WaitForEvent) valid() error {
+	if w == nil {
+		return nil
+	}
+	if w.Event == "" {
+		return errors.New("wait_for_event requires the event to be set")
+	}
+	if w.Timeout == nil || w.Timeout.Seconds() <= 0 {
+		return errors.New("wait_for_event requires a positive timeout")
+	}
+	return nil
+}
+
+func (b *Birth) valid() error {
+	if b == nil {
+		return nil
+	}
+	if b.Baby == "" {
+		return errors.New("baby is required")
+	}
+	if b.Baby == Current {
+		return fmt.Errorf("baby cannot be %s", Current)
+	}
+	if b.Person == nil {
+		return nil
+	}
+	if b.Person.Age != nil || b.Person.DateOfBirth != nil {
+		return errors.New("the age and the date of birth of the baby cannot be set")
+	}
+	if len(b.Person.Relationships) > 0 {
+		return errors.New("the relationships of the baby cannot be set")
+	}
+	return errors.Wrap(b.Person.valid(), "invalid person")
+}
+
+func (p *Parallel) valid() error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Tracks) == 0 {
+		return errors.New("parallel requires at least one track")
+	}
+	for i, t := range p.Tracks {
+		if len(t.Steps) == 0 {
+			return errors.Errorf("parallel track %d (%q) has no steps", i, t.Name)
+		}
+	}
+	return nil
+}
+
 func (n *ClinicalNote) valid() error {
 	if n == nil {
 		return nil
@@ -247,10 +289,7 @@ func (s Step) validAllergies() error {
 	v := reflect.ValueOf(&s).Elem()
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
-		if f.IsNil() {
-			continue
-		}
-		if f.Kind() != reflect.Ptr {
+		if f.Kind() != reflect.Ptr || f.IsNil() {
 			continue
 		}
 		f = f.Elem() // All fields of a Step are pointers.
@@ -394,7 +433,7 @@ func (r *Result) validValueAndRefRange(refRange string) error {
 			return errors.Wrapf(err, "cannot generate random value from invalid reference range %q", refRange)
 		}
 	} else if r.IsValueRandom() {
-		_, err := g.Random(r.Value)
+		_, err := g.Random(nil, r.Value)
 		if err != nil {
 			return errors.Wrap(err, "cannot generate random value")
 		}
@@ -502,6 +541,14 @@ func (p *Pathway) validatePersons() error {
 		if err := v.valid(); err != nil {
 			return errors.Wrapf(err, "invalid person %s in pathway", k)
 		}
+		for _, r := range v.Relationships {
+			if err := r.valid(k, persons); err != nil {
+				return errors.Wrapf(err, "invalid relationship of person %s in pathway", k)
+			}
+		}
+	}
+	if err := p.validateBirths(); err != nil {
+		return err
 	}
 
 	if len(persons) == 1 {
@@ -531,14 +578,84 @@ func (p *Pathway) validatePersons() error {
 	return nil
 }
 
-func validateWithRelativePositions(steps []Step, now time.Time, lm *location.Manager) error {
+func (r Relationship) valid(self PatientID, persons Persons) error {
+	if r.Person == self {
+		return errors.New("a person cannot be related to themselves")
+	}
+	if _, ok := persons[r.Person]; !ok {
+		return fmt.Errorf("unknown person %s", r.Person)
+	}
+	if r.Code == "" {
+		return errors.New("code is required")
+	}
+	return nil
+}
+
+// validateBirths makes sure that the newborns created in Birth steps can be told apart from
+// the persons in Persons and from each other.
+func (p *Pathway) validateBirths() error {
+	babies := map[PatientID]bool{}
+	for _, s := range append(append([]Step{}, p.History...), p.Pathway...) {
+		if s.Birth == nil {
+			continue
+		}
+		if _, ok := (*p.Persons)[s.Birth.Baby]; ok {
+			return fmt.Errorf("baby %s in Birth step is already one of the persons in Persons", s.Birth.Baby)
+		}
+		if babies[s.Birth.Baby] {
+			return fmt.Errorf("baby %s is born in more than one Birth step", s.Birth.Baby)
+		}
+		babies[s.Birth.Baby] = true
+	}
+	return nil
+}
+
+// The sections of a pathway that contain steps, named after their keys in the pathway files.
+const (
+	historySection = "historical_data"
+	pathwaySection = "pathway"
+)
+
+// stepError is an error in one of the steps of a pathway, so that it can be reported with the
+// step, e.g., by Lint.
+type stepError struct {
+	// section is the section of the pathway that contains the step.
+	section string
+	// index is the index of the step within the section.
+	index int
+	err   error
+}
+
+func (e stepError) Error() string {
+	return e.err.Error()
+}
+
+// inStep returns the given error, or each of the errors in it if it is an errorCollection, as an
+// error in the step at the given index of the given section. If section is empty, e.g., for the
+// steps nested in other steps, err is returned as is.
+func inStep(section string, index int, err error) error {
+	if err == nil || section == "" {
+		return err
+	}
+	errs := []error{err}
+	if ec, ok := err.(errorCollection); ok {
+		errs = ec
+	}
+	var ec error
+	for _, e := range errs {
+		ec = combineErrors(ec, stepError{section: section, index: index, err: e})
+	}
+	return ec
+}
+
+func validateWithRelativePositions(steps []Step, section string, now time.Time, lm *location.Manager) error {
 	var ec error
 	for i, s := range steps {
 		if err := s.valid(now, lm); err != nil {
-			ec = combineErrors(ec, fmt.Errorf("invalid step: %v", err))
+			ec = combineErrors(ec, inStep(section, i, fmt.Errorf("invalid step: %v", err)))
 		}
 		if s.StepType() == StepAddPerson && i != 0 {
-			ec = combineErrors(ec, errors.New("add_person should be the first step"))
+			ec = combineErrors(ec, inStep(section, i, errors.New("add_person should be the first step")))
 		}
 	}
 	return ec
@@ -655,6 +772,9 @@ func (s Step) valid(now time.Time, lm *location.Manager) error {
 		if s.Parameters.Status != nil && s.Parameters.Status.TimeOfDeath != nil && s.Parameters.Status.TimeSinceDeath != nil {
 			return errors.New("only one of TimeOfDeath and TimeSinceDeath may be set in the same step")
 		}
+		if err := s.Parameters.Constraints.valid(lm); err != nil {
+			return errors.Wrap(err, "invalid parameters.constraints")
+		}
 	}
 	if s.Merge != nil {
 		if len(s.Merge.Children) == 0 {
@@ -712,14 +832,56 @@ func (s Step) valid(now time.Time, lm *location.Manager) error {
 	if err := s.HardcodedMessage.valid(); err != nil {
 		return errors.Wrap(err, "invalid HardcodedMessage step")
 	}
+	if err := s.WaitForEvent.valid(); err != nil {
+		return errors.Wrap(err, "invalid WaitForEvent step")
+	}
+	if err := s.Birth.valid(); err != nil {
+		return errors.Wrap(err, "invalid Birth step")
+	}
+	if err := s.Parallel.valid(); err != nil {
+		return errors.Wrap(err, "invalid Parallel step")
+	}
 	return nil
 }
 
+func (c *TimeConstraints) valid(lm *location.Manager) error {
+	if c == nil {
+		return nil
+	}
+	var ec error
+	// The times of day are parsed like the calendar does when it applies the constraints.
+	notBefore, err := ParseTimeOfDay(c.NotBefore)
+	if c.NotBefore != "" && err != nil {
+		ec = combineErrors(ec, fmt.Errorf("invalid not_before %q: must be in the format HH:MM, or %s", c.NotBefore, EndOfDay))
+	}
+	notAfter, err := ParseTimeOfDay(c.NotAfter)
+	if c.NotAfter != "" && err != nil {
+		ec = combineErrors(ec, fmt.Errorf("invalid not_after %q: must be in the format HH:MM, or %s", c.NotAfter, EndOfDay))
+	}
+	if ec == nil && c.NotBefore != "" && c.NotAfter != "" && notAfter < notBefore {
+		ec = combineErrors(ec, fmt.Errorf("not_before %q is after not_after %q", c.NotBefore, c.NotAfter))
+	}
+	if c.NextClinicDay != "" {
+		if err := validLocation(c.NextClinicDay, lm); err != nil {
+			ec = combineErrors(ec, errors.Wrap(err, "invalid next_clinic_day"))
+		}
+	}
+	if c.WorkingHoursOf != "" {
+		if err := validLocation(c.WorkingHoursOf, lm); err != nil {
+			ec = combineErrors(ec, errors.Wrap(err, "invalid working_hours_of"))
+		}
+	}
+	return ec
+}
+
 func (up *UpdatePerson) valid(now time.Time) error {
 	if up == nil {
 		return nil
 	}
 	ec := up.Person.valid()
+	if up.Person != nil && len(up.Person.Relationships) > 0 {
+		ec = combineErrors(ec, errors.New("relationships can only be set in the Persons section"))
+	}
 	for _, d := range up.Diagnoses {
 		if err := d.valid(now); err != nil {
 			ec = combineErrors(ec, errors.Wrap(err, "invalid diagnosis"))
@@ -784,7 +946,7 @@ func strExists(str *string) bool {
 // in the map, but the combination of LastName and FirstName exists in the map, fail validation.
 // Otherwise, validation succeeds.
 // The reason field is not used if we return true, but has been added to improve readability.
-func (c *Consultant) valid(doctors *doctor.Doctors) (*Consultant, error) {
+func (c *Consultant) valid(doctors *doctor.Doctors, rng *rand.Rand) (*Consultant, error) {
 	if c == nil {
 		return nil, nil
 	}
@@ -823,7 +985,7 @@ func (c *Consultant) valid(doctors *doctor.Doctors) (*Consultant, error) {
 			updatePrefix(c, doctor)
 			return c, nil
 		}
-		newID := newConsultantID(doctors)
+		newID := newConsultantID(doctors, rng)
 		c.ID = &newID
 		return c, nil
 	}
@@ -837,12 +999,12 @@ func updatePrefix(c *Consultant, withPrefix *ir.Doctor) {
 	}
 }
 
-func newConsultantID(doctors *doctor.Doctors) string {
+func newConsultantID(doctors *doctor.Doctors, rng *rand.Rand) string {
 	for {
 		var sb strings.Builder
 		sb.WriteString("C")
 		for i := 0; i < 7; i++ {
-			sb.WriteString(strconv.Itoa(rand.Intn(10)))
+			sb.WriteString(strconv.Itoa(random.Or(rng).Intn(10)))
 		}
 		newID := sb.String()
 		doctor := doctors.GetByID(newID)
@@ -914,53 +1076,159 @@ func (p *Percentage) valid() error {
 
 func validateHistory(history []Step, clock clock.Clock, lm *location.Manager, validator orderIDAndProfileValidator) error {
 	var ec error
-	if err := validateWithRelativePositions(history, clock.Now(), lm); err != nil {
+	if err := validateWithRelativePositions(history, historySection, clock.Now(), lm); err != nil {
 		ec = combineErrors(ec, err)
 	}
 
-	for _, s := range history {
+	for i, s := range history {
+		var stepEC error
 		if s.Delay != nil {
-			ec = combineErrors(ec, errors.New("delays in historical steps are not supported"))
+			stepEC = combineErrors(stepEC, errors.New("delays in historical steps are not supported"))
 		}
 		if s.AutoGenerate != nil {
-			ec = combineErrors(ec, errors.New("step AutoGenerate in historical steps is not supported"))
+			stepEC = combineErrors(stepEC, errors.New("step AutoGenerate in historical steps is not supported"))
+		}
+		if s.WaitForEvent != nil {
+			stepEC = combineErrors(stepEC, errors.New("step WaitForEvent in historical steps is not supported"))
+		}
+		if s.Parallel != nil {
+			stepEC = combineErrors(stepEC, errors.New("step Parallel in historical steps is not supported"))
 		}
 		if s.UsePatient == nil {
 			if s.Parameters == nil || s.Parameters.TimeFromNow == nil || s.Parameters.TimeFromNow.Seconds() >= 0 {
-				ec = combineErrors(ec, errors.New("parameters.time_from_now must be set and negative for a historical step"))
+				stepEC = combineErrors(stepEC, errors.New("parameters.time_from_now must be set and negative for a historical step"))
 			}
 		}
-		ec = combineErrors(ec, validator.addOrderIDAndProfile(s))
+		stepEC = combineErrors(stepEC, validator.addOrderIDAndProfile(s))
+		ec = combineErrors(ec, inStep(historySection, i, stepEC))
 	}
 	return ec
 }
 
-func validatePathway(pathway []Step, clock clock.Clock, lm *location.Manager, validator orderIDAndProfileValidator) error {
+// validatePathway validates the given steps of the given section of a pathway. section is empty for
+// the steps nested in other steps, whose errors are reported with the step they are nested in.
+func validatePathway(pathway []Step, section string, clock clock.Clock, lm *location.Manager, validator orderIDAndProfileValidator) error {
 	var ec error
-	if err := validateWithRelativePositions(pathway, clock.Now(), lm); err != nil {
+	if err := validateWithRelativePositions(pathway, section, clock.Now(), lm); err != nil {
 		ec = combineErrors(ec, err)
 	}
 
-	for _, s := range pathway {
+	for i, s := range pathway {
+		var stepEC error
 		if s.Parameters != nil && s.Parameters.TimeFromNow != nil {
-			ec = combineErrors(ec, errors.New("parameters.time_from_now in Pathway steps is not supported"))
+			stepEC = combineErrors(stepEC, errors.New("parameters.time_from_now in Pathway steps is not supported"))
+		}
+		stepEC = combineErrors(stepEC, validator.addOrderIDAndProfile(s))
+		if s.WaitForEvent != nil && len(s.WaitForEvent.OnTimeout) > 0 {
+			if err := validatePathway(s.WaitForEvent.OnTimeout, "", clock, lm, validator); err != nil {
+				stepEC = combineErrors(stepEC, errors.Wrap(err, "invalid on_timeout steps"))
+			}
+		}
+		if s.Parallel != nil {
+			for _, t := range s.Parallel.Tracks {
+				if err := validatePathway(t.Steps, "", clock, lm, validator); err != nil {
+					stepEC = combineErrors(stepEC, errors.Wrapf(err, "invalid steps in parallel track %q", t.Name))
+				}
+				for _, ts := range t.Steps {
+					if ts.AutoGenerate != nil {
+						stepEC = combineErrors(stepEC, errors.New("step AutoGenerate in parallel tracks is not supported"))
+					}
+				}
+			}
+		}
+		ec = combineErrors(ec, inStep(section, i, stepEC))
+	}
+	return ec
+}
+
+// validateLabels returns an error if several steps in the pathway have the same label.
+func (p *Pathway) validateLabels() error {
+	var ec error
+	seen := map[string]bool{}
+	for _, s := range append(append([]Step{}, p.History...), p.Pathway...) {
+		if s.Label == "" {
+			continue
+		}
+		if seen[s.Label] {
+			ec = combineErrors(ec, fmt.Errorf("duplicate step label %q", s.Label))
+		}
+		seen[s.Label] = true
+	}
+	return ec
+}
+
+// ValidRemainingSteps returns an error if any of the given steps, which are the steps that a running
+// pathway has yet to run, would fail with the given order profiles, doctors and locations, e.g.,
+// because they were reloaded after the pathway started. placedOrders maps the IDs of the orders
+// that were placed before these steps to the names of their order profiles.
+func ValidRemainingSteps(steps []Step, now time.Time, orderProfiles *orderprofile.OrderProfiles, doctors *doctor.Doctors, lm *location.Manager, placedOrders map[string]string) error {
+	validator := orderIDAndProfileValidator{
+		orderProfiles:         orderProfiles,
+		orderIDSeen:           make(map[string]bool),
+		orderIDToOrderProfile: make(map[string]string),
+	}
+	for id, profile := range placedOrders {
+		validator.orderIDSeen[id] = true
+		validator.orderIDToOrderProfile[id] = profile
+	}
+	return validateRemainingSteps(steps, now, len(doctors.All()) > 0, lm, validator)
+}
+
+func validateRemainingSteps(steps []Step, now time.Time, hasDoctors bool, lm *location.Manager, validator orderIDAndProfileValidator) error {
+	var ec error
+	for _, s := range steps {
+		if err := s.valid(now, lm); err != nil {
+			ec = combineErrors(ec, fmt.Errorf("invalid step: %v", err))
 		}
 		ec = combineErrors(ec, validator.addOrderIDAndProfile(s))
+		if s.usesRandomOrderProfile() && validator.orderProfiles.Len() == 0 {
+			ec = combineErrors(ec, fmt.Errorf("step %s uses a random order profile, but there are no order profiles", s.StepType()))
+		}
+		if s.usesRandomDoctor() && !hasDoctors {
+			ec = combineErrors(ec, fmt.Errorf("step %s needs a random doctor, but there are no doctors", s.StepType()))
+		}
+		if s.WaitForEvent != nil {
+			if err := validateRemainingSteps(s.WaitForEvent.OnTimeout, now, hasDoctors, lm, validator); err != nil {
+				ec = combineErrors(ec, errors.Wrap(err, "invalid on_timeout steps"))
+			}
+		}
+		if s.Parallel != nil {
+			for _, t := range s.Parallel.Tracks {
+				if err := validateRemainingSteps(t.Steps, now, hasDoctors, lm, validator); err != nil {
+					ec = combineErrors(ec, errors.Wrapf(err, "invalid steps in parallel track %q", t.Name))
+				}
+			}
+		}
 	}
 	return ec
 }
 
+// usesRandomOrderProfile returns whether the step picks a random order profile.
+func (s Step) usesRandomOrderProfile() bool {
+	return (s.Order != nil && s.Order.OrderProfile == constants.RandomString) ||
+		(s.Result != nil && s.Result.OrderProfile == constants.RandomString)
+}
+
+// usesRandomDoctor returns whether the step picks a random doctor, i.e., the ordering provider of
+// orders and results, or the clinician of diagnoses and procedures.
+func (s Step) usesRandomDoctor() bool {
+	return s.Order != nil || s.Result != nil ||
+		(s.UpdatePerson != nil && (len(s.UpdatePerson.Diagnoses) > 0 || len(s.UpdatePerson.Procedures) > 0))
+}
+
 // Valid returns whether the pathway is valid.
 // It applies custom validation that depends on whether the steps are historical or not.
+// rng is the source of randomness used to generate the values that the pathway doesn't specify; if nil, the
+// default source of the math/rand package is used.
 // Returns an error if the pathway is invalid.
-func (p *Pathway) Valid(clock clock.Clock, orderProfiles *orderprofile.OrderProfiles, doctors *doctor.Doctors, lm *location.Manager, validFn func(*Pathway) error) error {
+func (p *Pathway) Valid(clock clock.Clock, orderProfiles *orderprofile.OrderProfiles, doctors *doctor.Doctors, lm *location.Manager, validFn func(*Pathway) error, rng *rand.Rand) error {
 	var ec error
 
 	if len(p.History) == 0 && len(p.Pathway) == 0 {
 		ec = combineErrors(ec, errors.New("pathway, historical_data and merge cannot be empty at the same time"))
 	}
 
-	consultant, err := p.Consultant.valid(doctors)
+	consultant, err := p.Consultant.valid(doctors, rng)
 	if err != nil {
 		ec = combineErrors(ec, errors.Wrap(err, "invalid consultant"))
 	}
@@ -988,9 +1256,15 @@ func (p *Pathway) Valid(clock clock.Clock, orderProfiles *orderprofile.OrderProf
 	if err := validateHistory(p.History, clock, lm, validator); err != nil {
 		ec = combineErrors(ec, err)
 	}
-	if err := validatePathway(p.Pathway, clock, lm, validator); err != nil {
+	if err := validatePathway(p.Pathway, pathwaySection, clock, lm, validator); err != nil {
+		ec = combineErrors(ec, err)
+	}
+	if err := p.validateLabels(); err != nil {
 		ec = combineErrors(ec, err)
 	}
+	if len(p.Overrides) > 0 {
+		ec = combineErrors(ec, errors.New("overrides have not been applied: the base pathway has not been resolved"))
+	}
 
 	if ec != nil {
 		log.WithField("pathway_name", p.Name()).Error(ec)
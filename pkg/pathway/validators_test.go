@@ -150,7 +150,7 @@ func TestResultValid(t *testing.T) {
 			}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, tc.op, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, tc.op, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, %+v, _, _) got err %v; want err? %t", p, tc.op, err, tc.wantErr)
 			}
@@ -195,7 +195,7 @@ func TestResultValidRandomValue(t *testing.T) {
 					}
 					p.Init(pathwayName)
 
-					err := p.Valid(defaultClock, tc.op, emptyDoctors, defaultLocationManager, defaultValid)
+					err := p.Valid(defaultClock, tc.op, emptyDoctors, defaultLocationManager, defaultValid, nil)
 					if gotErr := err != nil; gotErr != tc.wantErr {
 						t.Errorf("[%+v].Valid(_, %+v, _, _) got err %v; want err? %t", p, tc.op, err, tc.wantErr)
 					}
@@ -238,7 +238,7 @@ func TestResultValidReferenceRange(t *testing.T) {
 			}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, %+v, _, _) got err %v; want err? %t", p, emptyOP, err, tc.wantErr)
 			}
@@ -364,7 +364,7 @@ func TestResultValidAbnormalFlag(t *testing.T) {
 			}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, tc.op, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, tc.op, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, %+v, _, _) got err %v; want err? %t", p, tc.op, err, tc.wantErr)
 			}
@@ -478,7 +478,7 @@ func TestResultsValid(t *testing.T) {
 			}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", p, err, tc.wantErr)
 			}
@@ -518,7 +518,7 @@ func TestResultsValidOverrideDates(t *testing.T) {
 			}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != d.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", p, err, d.wantErr)
 			}
@@ -546,7 +546,7 @@ func TestResultsValidOverrideDates(t *testing.T) {
 			}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != d.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", p, err, d.wantErr)
 			}
@@ -781,10 +781,40 @@ func TestPathwayValidStep(t *testing.T) {
 		{step: Step{HardcodedMessage: &HardcodedMessage{Regex: ""}}, wantErr: true},
 		{step: Step{HardcodedMessage: &HardcodedMessage{}}, wantErr: true},
 		{step: Step{HardcodedMessage: &HardcodedMessage{}}, wantErr: true},
+		// WaitForEvent requires an event name and a positive timeout.
+		{step: Step{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &oneHour}}},
+		{step: Step{WaitForEvent: &WaitForEvent{Event: "ORU^R01"}}, wantErr: true},
+		{step: Step{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &negativeOneHour}}, wantErr: true},
+		{step: Step{WaitForEvent: &WaitForEvent{Timeout: &oneHour}}, wantErr: true},
+		// Parallel requires at least one track, and all tracks need steps.
+		{step: Step{Parallel: &Parallel{Tracks: []Track{{Steps: []Step{{Discharge: &Discharge{}}}}}}}},
+		{step: Step{Parallel: &Parallel{}}, wantErr: true},
+		{step: Step{Parallel: &Parallel{Tracks: []Track{{Steps: []Step{{Discharge: &Discharge{}}}}, {Name: "empty"}}}}, wantErr: true},
+		// Birth requires a baby ID, and the age, date of birth and relationships of the baby are derived.
+		{step: Step{Birth: &Birth{Baby: "baby"}}},
+		{step: Step{Birth: &Birth{Baby: "baby", Person: &Person{FirstName: "Ava", Gender: Female}}}},
+		{step: Step{Birth: &Birth{}}, wantErr: true},
+		{step: Step{Birth: &Birth{Baby: Current}}, wantErr: true},
+		{step: Step{Birth: &Birth{Baby: "baby", Person: &Person{Gender: "invalid"}}}, wantErr: true},
+		{step: Step{Birth: &Birth{Baby: "baby", Person: &Person{Age: &Age{From: 1, To: 2}}}}, wantErr: true},
+		{step: Step{Birth: &Birth{Baby: "baby", Person: &Person{Relationships: []Relationship{{Person: "mother", Code: "MTH"}}}}}, wantErr: true},
+		// Relationships can only be set in the Persons section.
+		{step: Step{UpdatePerson: &UpdatePerson{Person: &Person{Relationships: []Relationship{{Person: "mother", Code: "MTH"}}}}}, wantErr: true},
 		// DeathStatus cannot have both TimeSinceDeath and TimeOfDeath set at the same time.
 		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Status: &DeathStatus{TimeSinceDeath: &oneHour}}}},
 		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Status: &DeathStatus{TimeSinceDeath: &oneHour, TimeOfDeath: &fifteenHoursAgo}}}, wantErr: true},
 		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Status: &DeathStatus{TimeOfDeath: &fifteenHoursAgo}}}},
+		// Time constraints.
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NotBefore: "08:00", NotAfter: "17:30", WeekdaysOnly: true}}}},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NextClinicDay: "ED", WorkingHoursOf: "ED"}}}},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NotBefore: "8am"}}}, wantErr: true},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NotAfter: "25:00"}}}, wantErr: true},
+		// The calendar accepts 24:00 as the end of the day.
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NotBefore: "20:00", NotAfter: "24:00"}}}},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NotAfter: "24:01"}}}, wantErr: true},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NotBefore: "17:00", NotAfter: "08:00"}}}, wantErr: true},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{NextClinicDay: "unknown"}}}, wantErr: true},
+		{step: Step{Admission: &Admission{Loc: "ED"}, Parameters: &Parameters{Constraints: &TimeConstraints{WorkingHoursOf: "unknown"}}}, wantErr: true},
 		{step: Step{Document: &Document{ID: "docid1"}}},
 		{step: Step{Document: &Document{}}},
 		// All NumRandomContentLines in Documents must be valid Intervals.
@@ -814,7 +844,7 @@ func TestPathwayValidStep(t *testing.T) {
 			p := Pathway{Pathway: []Step{tc.step}}
 			p.Init(pathwayName)
 
-			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid)
+			err := p.Valid(defaultClock, emptyOP, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(%v, %v, %v, _) got err %v; want err? %t", p, defaultClock, emptyOP, emptyDoctors, err, tc.wantErr)
 			}
@@ -860,6 +890,11 @@ func TestPathwayValidPathway(t *testing.T) {
 	usePatientFirst := Step{UsePatient: &UsePatient{Patient: PatientID("first")}}
 	usePatientSecond := Step{UsePatient: &UsePatient{Patient: PatientID("second")}}
 	twoPersonsOneInvalid := &Persons{"first": {Gender: "invalid"}, "second": {}}
+	relatedPersons := &Persons{
+		"first":  {Relationships: []Relationship{{Person: "second", Code: "CHD", Description: "Child", SharesAddress: true}}},
+		"second": {Relationships: []Relationship{{Person: "first", Code: "MTH", Description: "Mother"}}},
+	}
+	birthBaby := Step{Birth: &Birth{Baby: "baby"}}
 
 	ctx := context.Background()
 	doctors, err := doctor.LoadDoctors(ctx, test.DoctorsConfigTest)
@@ -907,6 +942,16 @@ func TestPathwayValidPathway(t *testing.T) {
 		// Delays are not allowed in History.
 		{pathway: &Pathway{History: []Step{{Delay: &Delay{From: oneHour, To: twoHours}}}}, wantErr: true},
 		{pathway: &Pathway{Pathway: []Step{{Delay: &Delay{From: oneHour, To: twoHours}}}}, wantErr: false},
+		// WaitForEvent steps are not allowed in History, and their OnTimeout steps must be valid.
+		{pathway: &Pathway{History: []Step{{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &oneHour}, Parameters: &Parameters{TimeFromNow: &oneHourAgo}}}}, wantErr: true},
+		{pathway: &Pathway{Pathway: []Step{{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &oneHour}}}}, wantErr: false},
+		{pathway: &Pathway{Pathway: []Step{{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &oneHour, OnTimeout: []Step{discharge}}}}}, wantErr: false},
+		{pathway: &Pathway{Pathway: []Step{{WaitForEvent: &WaitForEvent{Event: "ORU^R01", Timeout: &oneHour, OnTimeout: []Step{invalidNote}}}}}, wantErr: true},
+		// Parallel steps are not allowed in History, and the steps in their tracks must be valid.
+		{pathway: &Pathway{History: []Step{{Parallel: &Parallel{Tracks: []Track{{Steps: []Step{discharge}}}}, Parameters: &Parameters{TimeFromNow: &oneHourAgo}}}}, wantErr: true},
+		{pathway: &Pathway{Pathway: []Step{admit, {Parallel: &Parallel{Tracks: []Track{{Steps: []Step{validNote}}, {Steps: []Step{result}}}}}, discharge}}, wantErr: false},
+		{pathway: &Pathway{Pathway: []Step{{Parallel: &Parallel{Tracks: []Track{{Steps: []Step{validNote}}, {Steps: []Step{invalidNote}}}}}}}, wantErr: true},
+		{pathway: &Pathway{Pathway: []Step{{Parallel: &Parallel{Tracks: []Track{{Steps: []Step{{AutoGenerate: &AutoGenerate{From: &oneHour, To: &oneHour}}}}}}}}}, wantErr: true},
 
 		// OrderID only allowed if there is something to link to
 		// The step in the pathway that uses OrderID first time, has to specify order profile.
@@ -953,6 +998,15 @@ func TestPathwayValidPathway(t *testing.T) {
 		{pathway: &Pathway{Persons: twoPersons, Pathway: []Step{usePatientFirst, usePatientSecond}}, wantErr: false},
 		// All persons in Persons need to be valid.
 		{pathway: &Pathway{Persons: twoPersonsOneInvalid, Pathway: []Step{usePatientFirst, usePatientSecond}}, wantErr: true},
+		// Relationships must refer to other persons in Persons, and have a code.
+		{pathway: &Pathway{Persons: relatedPersons, Pathway: []Step{usePatientFirst, usePatientSecond}}, wantErr: false},
+		{pathway: &Pathway{Persons: &Persons{"first": {Relationships: []Relationship{{Person: "unknown", Code: "MTH"}}}, "second": {}}, Pathway: []Step{usePatientFirst, usePatientSecond}}, wantErr: true},
+		{pathway: &Pathway{Persons: &Persons{"first": {Relationships: []Relationship{{Person: "first", Code: "MTH"}}}, "second": {}}, Pathway: []Step{usePatientFirst, usePatientSecond}}, wantErr: true},
+		{pathway: &Pathway{Persons: &Persons{"first": {Relationships: []Relationship{{Person: "second"}}}, "second": {}}, Pathway: []Step{usePatientFirst, usePatientSecond}}, wantErr: true},
+		// Newborns cannot reuse the ID of persons in Persons or of other newborns.
+		{pathway: &Pathway{Persons: twoPersons, Pathway: []Step{usePatientFirst, usePatientSecond, birthBaby, {UsePatient: &UsePatient{Patient: "baby"}}}}, wantErr: false},
+		{pathway: &Pathway{Persons: twoPersons, Pathway: []Step{usePatientFirst, usePatientSecond, {Birth: &Birth{Baby: "first"}}}}, wantErr: true},
+		{pathway: &Pathway{Persons: twoPersons, Pathway: []Step{usePatientFirst, usePatientSecond, birthBaby, birthBaby}}, wantErr: true},
 		{pathway: &Pathway{Persons: nil, Pathway: []Step{admit, discharge}}, wantErr: false},
 		{pathway: &Pathway{Persons: nil, Pathway: []Step{admit, discharge}, Consultant: wantConsultant}, wantErr: false},
 		{pathway: &Pathway{Persons: nil, Pathway: []Step{admit, discharge}, Consultant: consultantMissingFields}, wantErr: false},
@@ -965,7 +1019,7 @@ func TestPathwayValidPathway(t *testing.T) {
 	for i, tc := range cases {
 		t.Run(fmt.Sprintf("id:%d-pathway:%+v-valid:%t", i, tc.pathway, !tc.wantErr), func(t *testing.T) {
 			tc.pathway.Init(fmt.Sprintf("test-pathway-%d", i))
-			err := tc.pathway.Valid(defaultClock, emptyOrderProfiles, doctors, defaultLocationManager, defaultValid)
+			err := tc.pathway.Valid(defaultClock, emptyOrderProfiles, doctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(%v, %v, %v, _) got err %v; want err? %t", tc.pathway, defaultClock, emptyOrderProfiles, doctors, err, tc.wantErr)
 			}
@@ -1006,7 +1060,7 @@ func TestPathwayValidFn(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.desc, func(t *testing.T) {
-			err := pathway.Valid(defaultClock, emptyOrderProfiles, emptyDoctors, defaultLocationManager, tt.fn)
+			err := pathway.Valid(defaultClock, emptyOrderProfiles, emptyDoctors, defaultLocationManager, tt.fn, nil)
 			if gotErr := err != nil; gotErr != tt.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", pathway, err, tt.wantErr)
 			}
@@ -1036,7 +1090,7 @@ func TestPathwayValidPercentage(t *testing.T) {
 			}
 			pathway.Init(pathwayName)
 
-			err := pathway.Valid(defaultClock, emptyOrderProfiles, emptyDoctors, defaultLocationManager, defaultValid)
+			err := pathway.Valid(defaultClock, emptyOrderProfiles, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", pathway, err, tc.wantErr)
 			}
@@ -1082,7 +1136,7 @@ func TestPathwayValidPerson(t *testing.T) {
 			}
 			pathway.Init(pathwayName)
 
-			err := pathway.Valid(defaultClock, emptyOrderProfiles, emptyDoctors, defaultLocationManager, defaultValid)
+			err := pathway.Valid(defaultClock, emptyOrderProfiles, emptyDoctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", pathway, err, tc.wantErr)
 			}
@@ -1229,7 +1283,7 @@ func TestPathwayValidConsultant(t *testing.T) {
 			}
 			pathway.Init(pathwayName)
 
-			err := pathway.Valid(defaultClock, emptyOrderProfiles, doctors, defaultLocationManager, defaultValid)
+			err := pathway.Valid(defaultClock, emptyOrderProfiles, doctors, defaultLocationManager, defaultValid, nil)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("[%+v].Valid(_, _, _, _) got err %v; want err? %t", pathway, err, tc.wantErr)
 			}
@@ -1253,3 +1307,79 @@ func TestPathwayValidConsultant(t *testing.T) {
 		})
 	}
 }
+
+func TestValidRemainingSteps(t *testing.T) {
+	ctx := context.Background()
+	doctors, err := doctor.LoadDoctors(ctx, test.DoctorsConfigTest)
+	if err != nil {
+		t.Fatalf("LoadDoctors(%s) failed with %v", test.DoctorsConfigTest, err)
+	}
+	resultOfPlacedOrder := Step{Result: &Results{
+		OrderID: "order-1",
+		Results: []*Result{{TestName: "Creatinine", Value: constants.NormalValue}},
+	}}
+	placedOrders := map[string]string{"order-1": "UREA AND ELECTROLYTES"}
+
+	cases := []struct {
+		name          string
+		steps         []Step
+		orderProfiles *orderprofile.OrderProfiles
+		doctors       *doctor.Doctors
+		placedOrders  map[string]string
+		wantErr       bool
+	}{{
+		name:          "result of a placed order",
+		steps:         []Step{resultOfPlacedOrder},
+		orderProfiles: ureaOP,
+		doctors:       doctors,
+		placedOrders:  placedOrders,
+	}, {
+		name:          "result of an order that was not placed",
+		steps:         []Step{resultOfPlacedOrder},
+		orderProfiles: ureaOP,
+		doctors:       doctors,
+		wantErr:       true,
+	}, {
+		name:          "order profile of a placed order removed",
+		steps:         []Step{resultOfPlacedOrder},
+		orderProfiles: emptyOP,
+		doctors:       doctors,
+		placedOrders:  placedOrders,
+		wantErr:       true,
+	}, {
+		name:          "random order profile without order profiles",
+		steps:         []Step{{Order: &Order{OrderProfile: constants.RandomString}}},
+		orderProfiles: emptyOP,
+		doctors:       doctors,
+		wantErr:       true,
+	}, {
+		name:          "order without doctors",
+		steps:         []Step{{Order: &Order{OrderProfile: "UREA AND ELECTROLYTES"}}},
+		orderProfiles: ureaOP,
+		doctors:       emptyDoctors,
+		wantErr:       true,
+	}, {
+		name:          "unknown location",
+		steps:         []Step{{Admission: &Admission{Loc: "nonexistent-location"}}},
+		orderProfiles: ureaOP,
+		doctors:       doctors,
+		wantErr:       true,
+	}, {
+		name: "unknown location in a parallel track",
+		steps: []Step{{Parallel: &Parallel{Tracks: []Track{{
+			Name:  "track",
+			Steps: []Step{{Admission: &Admission{Loc: "nonexistent-location"}}},
+		}}}}},
+		orderProfiles: ureaOP,
+		doctors:       doctors,
+		wantErr:       true,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidRemainingSteps(tc.steps, defaultClock.Now(), tc.orderProfiles, tc.doctors, defaultLocationManager, tc.placedOrders)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidRemainingSteps(%+v) got err %v, want error: %t", tc.steps, err, tc.wantErr)
+			}
+		})
+	}
+}
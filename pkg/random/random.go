@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package random provides sources of pseudo-random numbers that can be seeded, so that runs of
+// Simulated Hospital can be reproduced.
+package random
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+)
+
+var log = logging.ForCallerPackage()
+
+// global is a *rand.Rand backed by the default source of the math/rand package.
+var global = rand.New(globalSource{})
+
+// warnOnce logs the warning when global is used for the first time.
+var warnOnce sync.Once
+
+// New returns a new *rand.Rand seeded with the given seed.
+// Two values returned by New with the same seed produce the same sequence of numbers.
+// All methods of the returned value other than Read are safe for concurrent use.
+func New(seed int64) *rand.Rand {
+	return rand.New(NewSource(seed))
+}
+
+// NewSeed returns a seed based on the current time, to be used when no explicit seed is provided.
+func NewSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// Or returns r if it is not nil, or a *rand.Rand backed by the default source of the math/rand
+// package otherwise. It lets the callers that don't care about reproducibility, e.g., tests, leave
+// their sources of randomness unset. The first time that the default source is used, a warning is
+// logged, as runs that use it cannot be reproduced with the same seed.
+func Or(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+	warnOnce.Do(func() {
+		log.Warning("A source of randomness is not set; using the default source, so this run cannot be reproduced with the same seed")
+	})
+	return global
+}
+
+// State is the state of a Source: the seed it was created with and the number of values it has
+// generated since.
+type State struct {
+	Seed  int64
+	Count uint64
+}
+
+// Source is a seeded rand.Source64 that is safe for concurrent use.
+// It counts the values it generates, so that its state can be saved and restored, e.g., to take a
+// snapshot of a running hospital.
+type Source struct {
+	mu    sync.Mutex
+	src   rand.Source64
+	seed  int64
+	count uint64
+}
+
+// NewSource returns a new Source seeded with the given seed.
+func NewSource(seed int64) *Source {
+	return &Source{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer as an int64.
+func (s *Source) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	return s.src.Int63()
+}
+
+// Uint64 returns a pseudo-random 64-bit value as a uint64.
+func (s *Source) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	return s.src.Uint64()
+}
+
+// Seed seeds the source with the given seed, and resets its count.
+func (s *Source) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+	s.seed = seed
+	s.count = 0
+}
+
+// State returns the current state of the source.
+func (s *Source) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return State{Seed: s.seed, Count: s.count}
+}
+
+// Restore sets the source to the given state, so that it generates the same values that the source
+// the state was taken from generates after that point.
+// Restoring requires generating all the values up to st.Count again, so it takes longer the more
+// values the original source had generated.
+func (s *Source) Restore(st State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(st.Seed)
+	for i := uint64(0); i < st.Count; i++ {
+		s.src.Uint64()
+	}
+	s.seed = st.Seed
+	s.count = st.Count
+}
+
+// globalSource is a rand.Source64 that uses the default source of the math/rand package.
+type globalSource struct{}
+
+func (globalSource) Int63() int64 {
+	return rand.Int63()
+}
+
+func (globalSource) Uint64() uint64 {
+	return rand.Uint64()
+}
+
+func (globalSource) Seed(seed int64) {
+	rand.Seed(seed)
+}
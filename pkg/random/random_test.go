@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package random
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNew_SameSeedSameSequence(t *testing.T) {
+	r1 := New(42)
+	r2 := New(42)
+	for i := 0; i < 100; i++ {
+		if got, want := r1.Int63(), r2.Int63(); got != want {
+			t.Fatalf("New(42).Int63() at iteration %d got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestOr(t *testing.T) {
+	r := New(1)
+	if got := Or(r); got != r {
+		t.Errorf("Or(r) got %v, want %v", got, r)
+	}
+	if got := Or(nil); got == nil {
+		t.Error("Or(nil) got nil, want non-nil")
+	}
+}
+
+func TestSource_Restore(t *testing.T) {
+	s := NewSource(42)
+	r := rand.New(s)
+	for i := 0; i < 10; i++ {
+		r.Int63()
+		r.Uint64()
+	}
+	st := s.State()
+	want := []int64{r.Int63(), r.Int63(), r.Int63()}
+
+	restored := NewSource(1)
+	restored.Restore(st)
+	r = rand.New(restored)
+	got := []int64{r.Int63(), r.Int63(), r.Int63()}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Restore(%+v) generated values with diff (-want, +got):\n%s", st, diff)
+	}
+	if got, want := restored.State(), (State{Seed: 42, Count: st.Count + 3}); got != want {
+		t.Errorf("State() got %+v, want %+v", got, want)
+	}
+}
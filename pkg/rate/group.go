@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+)
+
+// groupNameRegex matches valid group names. Group names are part of the URLs of the dashboard endpoints
+// that control the groups, so they cannot contain arbitrary characters.
+var groupNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Group is a group of pathways that start at their own rate, independently of other groups, e.g.,
+// arrivals to the emergency department, elective admissions or outpatient clinics.
+// Within a group, the pathway to start is picked based on the pathways' percentage_of_patients.
+type Group struct {
+	// Name identifies the group. It can only contain letters, digits, "_" and "-".
+	Name string `yaml:"name"`
+	// PathwaysPerHour is the number of pathways of the group that start per hour.
+	// If Profile is set, this is the base rate that the multipliers in the profile apply to.
+	PathwaysPerHour float64 `yaml:"pathways_per_hour"`
+	// Profile describes how the rate of the group varies over time.
+	// Optional: if not set, the group's pathways start at a constant rate.
+	Profile *Profile `yaml:"profile,omitempty"`
+	// PathwayNames are the names of the pathways in the group, or regular expressions that match them.
+	// Optional: if not set, all pathways are included.
+	PathwayNames []string `yaml:"pathway_names,omitempty"`
+	// ExcludePathwayNames are the names of the pathways to exclude from the group, or regular expressions
+	// that match them. Pathways that match both PathwayNames and ExcludePathwayNames are excluded.
+	// Optional.
+	ExcludePathwayNames []string `yaml:"exclude_pathway_names,omitempty"`
+	// Paused is whether the group starts paused, i.e., not starting pathways until it is resumed from
+	// the dashboard.
+	Paused bool `yaml:"paused,omitempty"`
+}
+
+// Groups is a list of groups of pathways.
+type Groups []Group
+
+// LoadGroups loads groups of pathways from the given YAML file.
+func LoadGroups(ctx context.Context, fileName string) (Groups, error) {
+	data, err := files.Read(ctx, fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read rate groups file %s", fileName)
+	}
+	g, err := ParseGroups(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse rate groups file %s", fileName)
+	}
+	return g, nil
+}
+
+// ParseGroups parses groups of pathways in YAML format, and validates them.
+func ParseGroups(data []byte) (Groups, error) {
+	var groups Groups
+	if err := yaml.UnmarshalStrict(data, &groups); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal rate groups")
+	}
+	names := map[string]bool{}
+	for _, g := range groups {
+		if !groupNameRegex.MatchString(g.Name) {
+			return nil, fmt.Errorf("invalid group name %q: it must match %s", g.Name, groupNameRegex)
+		}
+		if names[g.Name] {
+			return nil, fmt.Errorf("duplicate group name %q", g.Name)
+		}
+		names[g.Name] = true
+		if g.PathwaysPerHour < 0 {
+			return nil, fmt.Errorf("pathways_per_hour of group %q must not be negative, got %v", g.Name, g.PathwaysPerHour)
+		}
+		if g.Profile != nil {
+			if err := g.Profile.init(); err != nil {
+				return nil, errors.Wrapf(err, "invalid profile of group %q", g.Name)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// Controller creates a new Controller for the group's rate, profile and initial pause state.
+// rng is the source of randomness of the arrivals; if nil, the default source of the math/rand package is used.
+func (g Group) Controller(rng *rand.Rand) *Controller {
+	c := NewControllerWithProfile(g.PathwaysPerHour, time.Hour, g.Profile, rng)
+	c.paused = g.Paused
+	return c
+}
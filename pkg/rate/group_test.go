@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/test"
+)
+
+func TestLoadGroupsProd(t *testing.T) {
+	if _, err := LoadGroups(context.Background(), test.RateGroupsConfigProd); err != nil {
+		t.Errorf("LoadGroups(%q) failed with %v", test.RateGroupsConfigProd, err)
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	data := `
+- name: emergency
+  pathways_per_hour: 6
+  pathway_names: [ed_.*]
+  profile:
+    weekly: [1, 1, 1, 1, 1, 2, 2]
+- name: elective
+  pathways_per_hour: 2
+  exclude_pathway_names: [ed_.*]
+  paused: true`
+	got, err := ParseGroups([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseGroups(%q) failed with %v", data, err)
+	}
+	want := Groups{{
+		Name:            "emergency",
+		PathwaysPerHour: 6,
+		PathwayNames:    []string{"ed_.*"},
+		Profile:         &Profile{Weekly: []float64{1, 1, 1, 1, 1, 2, 2}},
+	}, {
+		Name:                "elective",
+		PathwaysPerHour:     2,
+		ExcludePathwayNames: []string{"ed_.*"},
+		Paused:              true,
+	}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(Profile{})); diff != "" {
+		t.Errorf("ParseGroups(%q) got diff (-want, +got):\n%s", data, diff)
+	}
+
+	c := got[1].Controller(nil)
+	if got, want := c.Rate(), 2.0; got != want {
+		t.Errorf("Controller().Rate() got %v; want %v", got, want)
+	}
+	if !c.Paused() {
+		t.Error("Controller().Paused() got false; want true")
+	}
+}
+
+func TestParseGroupsInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{name: "unknown field", data: "- name: a\n  pathways_per_hr: 1"},
+		{name: "no name", data: "- pathways_per_hour: 1"},
+		{name: "invalid name", data: "- name: a/b"},
+		{name: "duplicate name", data: "- name: a\n- name: a"},
+		{name: "negative rate", data: "- name: a\n  pathways_per_hour: -1"},
+		{name: "invalid profile", data: "- name: a\n  profile:\n    weekly: [1]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseGroups([]byte(tc.data)); err == nil {
+				t.Errorf("ParseGroups(%q) got nil error; want error", tc.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+	"github.com/Arend-melissant/simhospital/pkg/random"
+)
+
+const (
+	hoursPerDay = 24
+	daysPerWeek = 7
+	// maxDelay is the delay returned when no pathway will ever start.
+	maxDelay = time.Duration(1<<63 - 1)
+	// arrivalHorizon is how far in the future NextArrival looks for the next arrival.
+	// Profiles repeat every year at most, so if there is no arrival within a year there is none at all.
+	arrivalHorizon = 366 * hoursPerDay * time.Hour
+	// seasonDateLayout is the layout of the start and end dates of seasons.
+	seasonDateLayout = "01-02"
+)
+
+// Profile describes how the rate at which pathways start varies over time, e.g., to make the emergency
+// department busier in the evening than at night.
+// The rate at a given time is the base rate multiplied by the multiplier of the hour of the day, the
+// multiplier of the day of the week, and the multipliers of all the seasons the day is in.
+type Profile struct {
+	// Timezone is the timezone in which hours and days are calculated, e.g., "Europe/London".
+	// Optional. If not set, the timezone of the hospital's clock is used.
+	Timezone string `yaml:"timezone,omitempty"`
+	// Hourly are the multipliers for each hour of the day, starting at 00:00.
+	// Optional. If set, there must be 24 values.
+	Hourly []float64 `yaml:"hourly,omitempty"`
+	// Weekly are the multipliers for each day of the week, starting on Monday.
+	// Optional. If set, there must be 7 values.
+	Weekly []float64 `yaml:"weekly,omitempty"`
+	// Seasons are the periods of the year with a different rate, e.g., winter.
+	// Optional.
+	Seasons []Season `yaml:"seasons,omitempty"`
+
+	location *time.Location
+}
+
+// Season is a period of the year in which the rate is multiplied by a given value.
+type Season struct {
+	// Name is the name of the season. It is only used for documentation.
+	Name string `yaml:"name,omitempty"`
+	// From is the first day of the season, in MM-DD format, e.g., "12-01".
+	From string `yaml:"from"`
+	// To is the last day of the season, in MM-DD format, e.g., "02-28".
+	// It can be earlier in the year than From, for seasons that span the end of the year.
+	To string `yaml:"to"`
+	// Multiplier is the value that the rate is multiplied by during the season.
+	Multiplier float64 `yaml:"multiplier"`
+
+	from monthDay
+	to   monthDay
+}
+
+// monthDay is a day of the year, e.g., 1201 for December 1st, so that days can be compared.
+type monthDay int
+
+func newMonthDay(t time.Time) monthDay {
+	return monthDay(int(t.Month())*100 + t.Day())
+}
+
+func (s Season) contains(t time.Time) bool {
+	d := newMonthDay(t)
+	if s.from <= s.to {
+		return s.from <= d && d <= s.to
+	}
+	return d >= s.from || d <= s.to
+}
+
+// LoadProfile loads a rate profile from the given YAML file.
+func LoadProfile(ctx context.Context, fileName string) (*Profile, error) {
+	data, err := files.Read(ctx, fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read rate profile file %s", fileName)
+	}
+	p, err := ParseProfile(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse rate profile file %s", fileName)
+	}
+	return p, nil
+}
+
+// ParseProfile parses a rate profile in YAML format, and validates it.
+func ParseProfile(data []byte) (*Profile, error) {
+	p := &Profile{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal rate profile")
+	}
+	if err := p.init(); err != nil {
+		return nil, errors.Wrap(err, "invalid rate profile")
+	}
+	return p, nil
+}
+
+// init validates the profile and initialises its unexported fields.
+func (p *Profile) init() error {
+	if p.Timezone != "" {
+		loc, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			return errors.Wrapf(err, "invalid timezone %q", p.Timezone)
+		}
+		p.location = loc
+	}
+	if len(p.Hourly) != 0 && len(p.Hourly) != hoursPerDay {
+		return fmt.Errorf("hourly must have %d values, got %d", hoursPerDay, len(p.Hourly))
+	}
+	if len(p.Weekly) != 0 && len(p.Weekly) != daysPerWeek {
+		return fmt.Errorf("weekly must have %d values, got %d", daysPerWeek, len(p.Weekly))
+	}
+	for _, m := range append(append([]float64{}, p.Hourly...), p.Weekly...) {
+		if m < 0 || math.IsNaN(m) || math.IsInf(m, 0) {
+			return fmt.Errorf("multipliers must be non-negative numbers, got %v", m)
+		}
+	}
+	for i := range p.Seasons {
+		s := &p.Seasons[i]
+		from, err := time.Parse(seasonDateLayout, s.From)
+		if err != nil {
+			return errors.Wrapf(err, "invalid start date of season %q; the format is MM-DD", s.Name)
+		}
+		to, err := time.Parse(seasonDateLayout, s.To)
+		if err != nil {
+			return errors.Wrapf(err, "invalid end date of season %q; the format is MM-DD", s.Name)
+		}
+		if s.Multiplier < 0 || math.IsNaN(s.Multiplier) || math.IsInf(s.Multiplier, 0) {
+			return fmt.Errorf("the multiplier of season %q must be a non-negative number, got %v", s.Name, s.Multiplier)
+		}
+		s.from, s.to = newMonthDay(from), newMonthDay(to)
+	}
+	return nil
+}
+
+// Multiplier returns the value that the base rate is multiplied by at time t.
+func (p *Profile) Multiplier(t time.Time) float64 {
+	t = p.in(t)
+	m := 1.0
+	if len(p.Hourly) > 0 {
+		m *= p.Hourly[t.Hour()]
+	}
+	if len(p.Weekly) > 0 {
+		// time.Weekday starts on Sunday.
+		m *= p.Weekly[(int(t.Weekday())+daysPerWeek-1)%daysPerWeek]
+	}
+	for _, s := range p.Seasons {
+		if s.contains(t) {
+			m *= s.Multiplier
+		}
+	}
+	return m
+}
+
+func (p *Profile) in(t time.Time) time.Time {
+	if p.location != nil {
+		return t.In(p.location)
+	}
+	return t
+}
+
+// NextArrival returns how long after now the next pathway starts, if pathways start following a
+// non-homogeneous Poisson process whose rate is the base rate multiplied by the profile's multipliers.
+// rate is the base number of pathways that start in every interval of length per.
+// The multipliers only change at the start of each hour, so the time of the next arrival is calculated
+// exactly by integrating the rate hour by hour. If no pathway starts within a year, it returns the
+// maximum duration.
+// rng is the source of randomness; if nil, the default source of the math/rand package is used.
+func (p *Profile) NextArrival(now time.Time, rate float64, per time.Duration, rng *rand.Rand) time.Duration {
+	if rate <= 0 || per <= 0 {
+		return maxDelay
+	}
+	// The number of expected arrivals until the next one is exponentially distributed.
+	target := random.Or(rng).ExpFloat64()
+	expected := 0.0
+	t := now
+	for t.Sub(now) < arrivalHorizon {
+		local := p.in(t)
+		end := time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, local.Location())
+		if !end.After(t) {
+			// The start of the next hour might not be well defined when the clocks change.
+			end = t.Add(time.Hour)
+		}
+		perNanosecond := rate * p.Multiplier(t) / float64(per)
+		segment := end.Sub(t)
+		if perNanosecond > 0 {
+			if need := (target - expected) / perNanosecond; need <= float64(segment) {
+				return t.Sub(now) + time.Duration(need)
+			}
+		}
+		expected += perNanosecond * float64(segment)
+		t = end
+	}
+	return maxDelay
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rate
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Arend-melissant/simhospital/pkg/test"
+)
+
+func flat(n int, v float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func TestLoadProfileProd(t *testing.T) {
+	if _, err := LoadProfile(context.Background(), test.RateProfileConfigProd); err != nil {
+		t.Errorf("LoadProfile(%q) failed with %v", test.RateProfileConfigProd, err)
+	}
+}
+
+func TestParseProfileInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{name: "unknown field", data: "hourlyy: []"},
+		{name: "wrong number of hourly", data: "hourly: [1, 2, 3]"},
+		{name: "wrong number of weekly", data: "weekly: [1, 2, 3, 4, 5, 6, 7, 8]"},
+		{name: "negative multiplier", data: "weekly: [1, 1, 1, 1, 1, 1, -1]"},
+		{name: "invalid timezone", data: "timezone: Europe/Atlantis"},
+		{name: "invalid season start", data: "seasons: [{from: 13-01, to: 02-28, multiplier: 2}]"},
+		{name: "invalid season end", data: "seasons: [{from: 12-01, to: 02-30, multiplier: 2}]"},
+		{name: "negative season multiplier", data: "seasons: [{from: 12-01, to: 02-28, multiplier: -2}]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseProfile([]byte(tc.data)); err == nil {
+				t.Errorf("ParseProfile(%q) got nil error; want error", tc.data)
+			}
+		})
+	}
+}
+
+func TestMultiplier(t *testing.T) {
+	hourly := flat(hoursPerDay, 1)
+	hourly[18] = 2
+	weekly := flat(daysPerWeek, 1)
+	weekly[0] = 3 // Monday
+	p := &Profile{
+		Timezone: "UTC",
+		Hourly:   hourly,
+		Weekly:   weekly,
+		Seasons: []Season{
+			{Name: "winter", From: "12-01", To: "02-28", Multiplier: 5},
+			{Name: "summer", From: "07-01", To: "08-31", Multiplier: 0.5},
+		},
+	}
+	if err := p.init(); err != nil {
+		t.Fatalf("init() failed with %v", err)
+	}
+
+	cases := []struct {
+		t    time.Time
+		want float64
+	}{
+		// Tuesday.
+		{t: time.Date(2020, 3, 10, 9, 0, 0, 0, time.UTC), want: 1},
+		{t: time.Date(2020, 3, 10, 18, 30, 0, 0, time.UTC), want: 2},
+		// Monday.
+		{t: time.Date(2020, 3, 9, 18, 0, 0, 0, time.UTC), want: 6},
+		// Winter wraps around the end of the year; 2020-12-31 and 2021-01-05 are a Thursday and a Tuesday.
+		{t: time.Date(2020, 12, 31, 9, 0, 0, 0, time.UTC), want: 5},
+		{t: time.Date(2021, 1, 5, 9, 0, 0, 0, time.UTC), want: 5},
+		{t: time.Date(2021, 3, 2, 9, 0, 0, 0, time.UTC), want: 1},
+		// Summer; Wednesday.
+		{t: time.Date(2020, 7, 1, 9, 0, 0, 0, time.UTC), want: 0.5},
+		// The profile's timezone is used: Tuesday 00:30 in Paris is Monday 23:30 in UTC.
+		{t: time.Date(2020, 3, 10, 0, 30, 0, 0, time.FixedZone("Paris", 3600)), want: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.t.String(), func(t *testing.T) {
+			if got := p.Multiplier(tc.t); got != tc.want {
+				t.Errorf("Multiplier(%v) got %v; want %v", tc.t, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNextArrivalNoArrivals(t *testing.T) {
+	now := time.Date(2020, 3, 10, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		profile *Profile
+		rate    float64
+	}{
+		{name: "zero rate", profile: &Profile{}, rate: 0},
+		{name: "zero multipliers", profile: &Profile{Weekly: flat(daysPerWeek, 0)}, rate: 10},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.profile.NextArrival(now, tc.rate, time.Hour, nil); got != maxDelay {
+				t.Errorf("NextArrival(%v, %v, %v) got %v; want %v", now, tc.rate, time.Hour, got, maxDelay)
+			}
+		})
+	}
+}
+
+func TestNextArrival(t *testing.T) {
+	// Pathways only start between 10:00 and 11:00.
+	hourly := flat(hoursPerDay, 0)
+	hourly[10] = 1
+	p := &Profile{Hourly: hourly}
+	now := time.Date(2020, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	const n = 2000
+	rate := 60.0
+	var sum time.Duration
+	for i := 0; i < n; i++ {
+		d := p.NextArrival(now, rate, time.Hour, nil)
+		start := now.Add(d)
+		if start.Hour() != 10 {
+			t.Fatalf("NextArrival(%v, %v, %v) got %v, so the pathway starts at %v; want it to start between 10:00 and 11:00", now, rate, time.Hour, d, start)
+		}
+		sum += d - time.Hour
+	}
+	// Within the hour, the time to the next arrival is exponentially distributed with a mean of one minute.
+	mean := sum / n
+	if want := time.Minute; math.Abs(float64(mean-want)) > 0.1*float64(want) {
+		t.Errorf("NextArrival(%v, %v, %v) got a mean delay of %v after 10:00; want approximately %v", now, rate, time.Hour, mean, want)
+	}
+}
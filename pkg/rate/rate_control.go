@@ -18,29 +18,54 @@ package rate
 import (
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
 )
 
 var log = logging.ForCallerPackage()
 
 // Controller is a rate controller.
+// The rate is either constant, or varies over time following a Profile.
 type Controller struct {
+	// mu guards rate, profile and paused, that can be changed from the dashboard.
+	mu          sync.Mutex
 	rate        float64
 	per         time.Duration
+	profile     *Profile
+	paused      bool
 	rateChanged chan bool
+	// rand is the source of randomness of the arrivals when there is a profile.
+	rand *rand.Rand
+	// next is the time at which the next pathway is scheduled to start, as set by SetNext.
+	next time.Time
+	// nextRestored is whether next was set by Restore and hasn't been taken by TakeRestoredNext yet.
+	nextRestored bool
 }
 
-// NewController creates a new Controller.
+// NewController creates a new Controller with a constant rate.
 func NewController(rate float64, per time.Duration) *Controller {
+	return NewControllerWithProfile(rate, per, nil, nil)
+}
+
+// NewControllerWithProfile creates a new Controller whose rate is the given base rate multiplied by
+// the multipliers in the profile. If the profile is nil, the rate is constant.
+// rng is the source of randomness of the arrivals when there is a profile; if nil, the default source
+// of the math/rand package is used.
+func NewControllerWithProfile(rate float64, per time.Duration, profile *Profile, rng *rand.Rand) *Controller {
 	return &Controller{
 		rate:        rate,
 		per:         per,
+		profile:     profile,
 		rateChanged: make(chan bool),
+		rand:        rng,
 	}
 }
 
@@ -48,7 +73,9 @@ func NewController(rate float64, per time.Duration) *Controller {
 func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
+		c.mu.Lock()
 		s := strconv.FormatFloat(c.rate, 'f', -1, 64)
+		c.mu.Unlock()
 		w.Write([]byte(s))
 	case "POST":
 		c.handlePost(w, r)
@@ -91,12 +118,145 @@ func (c *Controller) handlePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Sometimes we get a POST message even if the value didn't change.
-	if c.rate != f {
-		c.rate = f
+	c.mu.Lock()
+	changed := c.rate != f
+	c.rate = f
+	c.mu.Unlock()
+	if changed {
 		c.rateChanged <- true
 	}
 }
 
+// ServePauseHTTP handles the requests to pause and resume the controller from the control dashboard.
+// GET requests return "true" if the controller is paused and "false" otherwise.
+// POST requests must be in the format "value=X", where X is "true" to pause or "false" to resume.
+func (c *Controller) ServePauseHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Write([]byte(strconv.FormatBool(c.Paused())))
+	case "POST":
+		c.handlePausePost(w, r)
+	case "PUT", "DELETE":
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown method: %q", r.Method), http.StatusInternalServerError)
+	}
+}
+
+func (c *Controller) handlePausePost(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	errStr := "Failed to pause or resume"
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	sbody := string(body)
+	prefix := "value="
+	if !strings.HasPrefix(sbody, prefix) {
+		log.Warningf("%s: missing prefix %q in request body: %q", errStr, prefix, sbody)
+		http.Error(w, `Error extracting value: the request must be in the format "value=X"`, http.StatusInternalServerError)
+		return
+	}
+	paused, err := strconv.ParseBool(strings.TrimPrefix(sbody, prefix))
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error parsing value to bool", http.StatusInternalServerError)
+		return
+	}
+	c.mu.Lock()
+	changed := c.paused != paused
+	c.paused = paused
+	c.mu.Unlock()
+	if changed {
+		c.rateChanged <- true
+	}
+}
+
+// ServeProfileHTTP handles the requests to get and change the rate profile from the control dashboard.
+// GET requests return the current profile in YAML format, or an empty body if the rate is constant.
+// POST requests set the profile to the one in the body, in YAML format. An empty body removes the
+// profile, so that the rate becomes constant.
+func (c *Controller) ServeProfileHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		c.handleProfileGet(w)
+	case "POST":
+		c.handleProfilePost(w, r)
+	case "PUT", "DELETE":
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown method: %q", r.Method), http.StatusInternalServerError)
+	}
+}
+
+func (c *Controller) handleProfileGet(w http.ResponseWriter) {
+	c.mu.Lock()
+	p := c.profile
+	c.mu.Unlock()
+	if p == nil {
+		return
+	}
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		log.WithError(err).Error("Failed to marshal the rate profile")
+		http.Error(w, "Error marshalling the rate profile", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(b)
+}
+
+func (c *Controller) handleProfilePost(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	errStr := "Failed to change the rate profile"
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	var p *Profile
+	if strings.TrimSpace(string(body)) != "" {
+		if p, err = ParseProfile(body); err != nil {
+			log.WithError(err).Warning(errStr)
+			http.Error(w, fmt.Sprintf("%s: %v", errStr, err), http.StatusBadRequest)
+			return
+		}
+	}
+	c.mu.Lock()
+	c.profile = p
+	c.mu.Unlock()
+	c.rateChanged <- true
+}
+
+// Delay returns how long to wait until the next pathway starts, given that elapsed time has passed since
+// the previous pathway started or since the rate was last changed.
+// With a constant rate, pathways start at regular intervals of Heartbeat.
+// With a profile, pathways start following a non-homogeneous Poisson process; in this case elapsed is
+// not relevant, since the time to the next arrival does not depend on the previous arrivals.
+// If the controller is paused, Delay returns the maximum duration.
+func (c *Controller) Delay(now time.Time, elapsed time.Duration) time.Duration {
+	c.mu.Lock()
+	p, rate, paused := c.profile, c.rate, c.paused
+	c.mu.Unlock()
+	if paused {
+		log.Info("Rate controller paused. Not generating pathway")
+		return maxDelay
+	}
+	if p == nil {
+		return c.Heartbeat() - elapsed
+	}
+	d := p.NextArrival(now, rate, c.per, c.rand)
+	log.Debugf("Rate set to %v / %v with a profile. Generating the next pathway in %v", rate, c.per, d)
+	return d
+}
+
 func (c *Controller) heartbeat() time.Duration {
 	return time.Duration(float64(c.per) / c.rate)
 }
@@ -104,6 +264,8 @@ func (c *Controller) heartbeat() time.Duration {
 // Heartbeat returns a duration between two pathways based on rate and per values.
 // If the rate is set to zero, returns the maximum duration value.
 func (c *Controller) Heartbeat() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.rate == 0 {
 		log.Infof("Rate set to %v / %v. Not generating pathway", c.rate, c.per)
 		// Max Duration value.
@@ -114,6 +276,21 @@ func (c *Controller) Heartbeat() time.Duration {
 	return h
 }
 
+// Rate returns the current rate, i.e., the number of pathways that start in every interval of length per
+// or, if the controller has a profile, the base rate that the multipliers in the profile apply to.
+func (c *Controller) Rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// Paused returns whether the controller is paused.
+func (c *Controller) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
 // RateChanged returns a channel, where the changes of the rate are signaled.
 func (c *Controller) RateChanged() <-chan bool {
 	return c.rateChanged
@@ -122,8 +299,100 @@ func (c *Controller) RateChanged() <-chan bool {
 // InitialElapsed returns a value of the heartbeat, if the rate is not zero.
 // Otherwise, returns zero.
 func (c *Controller) InitialElapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.rate > 0 {
 		return c.heartbeat()
 	}
 	return 0
 }
+
+// State is the state of a Controller that can be changed while it runs, e.g., from the dashboard.
+type State struct {
+	// Rate is the rate, or the base rate if there is a profile.
+	Rate float64
+	// Profile is the rate profile in YAML format, or empty if the rate is constant.
+	Profile string
+	// Paused is whether the controller is paused.
+	Paused bool
+	// Next is the time at which the next pathway is scheduled to start, or zero if it isn't known.
+	Next time.Time
+}
+
+// Validate returns the error that Controller.Restore would return for the state, if any.
+func (s State) Validate() error {
+	_, err := s.profile()
+	return err
+}
+
+// profile validates the state and returns its parsed profile, or nil if the rate is constant.
+func (s State) profile() (*Profile, error) {
+	if s.Rate < 0 {
+		return nil, errors.Errorf("invalid rate %v; it must not be negative", s.Rate)
+	}
+	if s.Profile == "" {
+		return nil, nil
+	}
+	p, err := ParseProfile([]byte(s.Profile))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse the rate profile")
+	}
+	return p, nil
+}
+
+// State returns the current state of the controller.
+func (c *Controller) State() (State, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := State{Rate: c.rate, Paused: c.paused, Next: c.next}
+	if c.profile != nil {
+		b, err := yaml.Marshal(c.profile)
+		if err != nil {
+			return State{}, errors.Wrap(err, "cannot marshal the rate profile")
+		}
+		s.Profile = string(b)
+	}
+	return s, nil
+}
+
+// Restore sets the controller to the given state, e.g., to restore a snapshot of a hospital.
+// If the controller is running, it respects the new state immediately.
+func (c *Controller) Restore(s State) error {
+	p, err := s.profile()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.rate, c.profile, c.paused = s.Rate, p, s.Paused
+	c.next, c.nextRestored = s.Next, !s.Next.IsZero()
+	c.mu.Unlock()
+	// Only notify the change if the controller is running; otherwise, the new state is used when it
+	// starts.
+	select {
+	case c.rateChanged <- true:
+	default:
+	}
+	return nil
+}
+
+// SetNext records the time at which the next pathway is scheduled to start, so that it is part of the
+// controller's State.
+func (c *Controller) SetNext(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next = t
+}
+
+// TakeRestoredNext returns the time at which the next pathway was scheduled to start in the State
+// given to Restore, so that the next pathway starts at the same time as it would have started in the
+// controller the state was taken from. It returns false if Restore hasn't been called with such time
+// since the last call to TakeRestoredNext.
+func (c *Controller) TakeRestoredNext() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.nextRestored {
+		return time.Time{}, false
+	}
+	c.nextRestored = false
+	return c.next, true
+}
@@ -23,6 +23,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestHeartbeat(t *testing.T) {
@@ -216,3 +218,203 @@ func TestMessageRateHandlerPOSTError(t *testing.T) {
 		})
 	}
 }
+
+func TestProfileHandler(t *testing.T) {
+	c := NewController(1, time.Hour)
+
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-c.RateChanged():
+				continue
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ts := httptest.NewServer(http.HandlerFunc(c.ServeProfileHTTP))
+	defer ts.Close()
+
+	get := func() string {
+		t.Helper()
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("http.Get(%v) failed with %v", ts.URL, err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll(%v) failed with %v", resp.Body, err)
+		}
+		return string(body)
+	}
+	post := func(profile string) int {
+		t.Helper()
+		resp, err := http.Post(ts.URL, "text/plain", strings.NewReader(profile))
+		if err != nil {
+			t.Fatalf("http.Post(%v, %q) failed with %v", ts.URL, profile, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := get(); got != "" {
+		t.Errorf("GET request got %q; want empty profile", got)
+	}
+
+	profile := "weekly: [1, 1, 1, 1, 1, 2, 2]\n"
+	if got, want := post(profile), http.StatusOK; got != want {
+		t.Errorf("POST %q response status code got %v; want %v", profile, got, want)
+	}
+	got, err := ParseProfile([]byte(get()))
+	if err != nil {
+		t.Fatalf("ParseProfile(GET response) failed with %v", err)
+	}
+	if diff := cmp.Diff([]float64{1, 1, 1, 1, 1, 2, 2}, got.Weekly); diff != "" {
+		t.Errorf("GET request got weekly multipliers diff (-want, +got):\n%s", diff)
+	}
+
+	invalid := "weekly: [1, 2]"
+	if got, want := post(invalid), http.StatusBadRequest; got != want {
+		t.Errorf("POST %q response status code got %v; want %v", invalid, got, want)
+	}
+	if got := get(); got == "" {
+		t.Error("GET request after invalid POST got empty profile; want the previous profile")
+	}
+
+	if got, want := post(""), http.StatusOK; got != want {
+		t.Errorf("POST empty profile response status code got %v; want %v", got, want)
+	}
+	if got := get(); got != "" {
+		t.Errorf("GET request after removing the profile got %q; want empty profile", got)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	now := time.Date(2020, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	c := NewController(2, time.Hour)
+	if got, want := c.Delay(now, 10*time.Minute), 20*time.Minute; got != want {
+		t.Errorf("Delay(%v, %v) without profile got %v; want %v", now, 10*time.Minute, got, want)
+	}
+
+	// Pathways never start on Tuesdays, so the next one starts on Wednesday.
+	weekly := []float64{1, 0, 1, 1, 1, 1, 1}
+	c = NewControllerWithProfile(2, time.Hour, &Profile{Weekly: weekly}, nil)
+	got := c.Delay(now, 10*time.Minute)
+	if wantMin := 15 * time.Hour; got < wantMin {
+		t.Errorf("Delay(%v, %v) with profile %v got %v; want at least %v", now, 10*time.Minute, weekly, got, wantMin)
+	}
+}
+
+func TestPauseHandler(t *testing.T) {
+	c := NewController(1, time.Hour)
+
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-c.RateChanged():
+				continue
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ts := httptest.NewServer(http.HandlerFunc(c.ServePauseHTTP))
+	defer ts.Close()
+
+	now := time.Date(2020, 3, 10, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value      string
+		wantCode   int
+		wantPaused bool
+	}{
+		{value: "value=true", wantCode: http.StatusOK, wantPaused: true},
+		{value: "value=invalid", wantCode: http.StatusInternalServerError, wantPaused: true},
+		{value: "true", wantCode: http.StatusInternalServerError, wantPaused: true},
+		{value: "value=false", wantCode: http.StatusOK, wantPaused: false},
+	}
+	for _, tc := range cases {
+		resp, err := http.Post(ts.URL, "application/x-www-form-urlencoded", strings.NewReader(tc.value))
+		if err != nil {
+			t.Fatalf("http.Post(%v, %q) failed with %v", ts.URL, tc.value, err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode; got != tc.wantCode {
+			t.Errorf("POST %q response status code got %v; want %v", tc.value, got, tc.wantCode)
+		}
+
+		resp, err = http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("http.Get(%v) failed with %v", ts.URL, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll(%v) failed with %v", resp.Body, err)
+		}
+		if got, want := string(body), strconv.FormatBool(tc.wantPaused); got != want {
+			t.Errorf("GET request after POST %q got %q; want %q", tc.value, got, want)
+		}
+		if gotMax := c.Delay(now, 0) == maxDelay; gotMax != tc.wantPaused {
+			t.Errorf("Delay(%v, 0) after POST %q got %v; want the maximum duration: %t", now, tc.value, c.Delay(now, 0), tc.wantPaused)
+		}
+	}
+}
+
+func TestStateAndRestore(t *testing.T) {
+	p, err := ParseProfile([]byte("weekly: [1, 0, 1, 1, 1, 1, 1]\n"))
+	if err != nil {
+		t.Fatalf("ParseProfile() failed with %v", err)
+	}
+	c := NewControllerWithProfile(2, time.Hour, p, nil)
+	next := time.Date(2020, 3, 10, 9, 0, 0, 0, time.UTC)
+	c.SetNext(next)
+	s, err := c.State()
+	if err != nil {
+		t.Fatalf("State() failed with %v", err)
+	}
+
+	restored := NewController(5, time.Hour)
+	restored.Restore(State{Rate: 1, Paused: true})
+	if err := restored.Restore(s); err != nil {
+		t.Fatalf("Restore(%+v) failed with %v", s, err)
+	}
+	got, err := restored.State()
+	if err != nil {
+		t.Fatalf("State() failed with %v", err)
+	}
+	if diff := cmp.Diff(s, got); diff != "" {
+		t.Errorf("State() after Restore(%+v) got diff (-want, +got):\n%s", s, diff)
+	}
+	if got, want := restored.Rate(), 2.0; got != want {
+		t.Errorf("Rate() got %v, want %v", got, want)
+	}
+	if restored.Paused() {
+		t.Error("Paused() got true, want false")
+	}
+	if got, ok := restored.TakeRestoredNext(); !ok || !got.Equal(next) {
+		t.Errorf("TakeRestoredNext() got (%v, %v), want (%v, true)", got, ok, next)
+	}
+	if _, ok := restored.TakeRestoredNext(); ok {
+		t.Error("TakeRestoredNext() the second time got true, want false")
+	}
+
+	for _, invalid := range []State{{Rate: -1}, {Rate: 1, Profile: "hourly: [1]"}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("%+v.Validate() got nil error, want non-nil", invalid)
+		}
+		if err := restored.Restore(invalid); err == nil {
+			t.Errorf("Restore(%+v) got nil error, want non-nil", invalid)
+		}
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("%+v.Validate() failed with %v", s, err)
+	}
+}
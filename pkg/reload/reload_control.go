@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reload contains an HTTP controller and a watcher of files to reload the configuration of
+// Simulated Hospital while it runs.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+)
+
+var log = logging.ForCallerPackage()
+
+// Reloader reloads the configuration.
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// Controller handles requests to reload the configuration.
+type Controller struct {
+	Reloader Reloader
+}
+
+// NewController creates a new Controller.
+func NewController(r Reloader) *Controller {
+	return &Controller{Reloader: r}
+}
+
+// ServeHTTP handles the requests to reload the configuration.
+// Use a POST request with an empty body. If the new configuration is not valid, the request fails
+// and the configuration doesn't change.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		c.handlePost(w, r)
+	case "GET", "PUT", "DELETE":
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown method: %q", r.Method), http.StatusInternalServerError)
+	}
+}
+
+func (c *Controller) handlePost(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if err := c.Reloader.Reload(r.Context()); err != nil {
+		log.WithError(err).Warning("Failed to reload the configuration")
+		http.Error(w, fmt.Sprintf("Failed to reload the configuration: %v", err), http.StatusBadRequest)
+		return
+	}
+	log.Info("Reloaded the configuration")
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type fakeReloader struct {
+	calls int
+	err   error
+}
+
+func (f *fakeReloader) Reload(context.Context) error {
+	f.calls++
+	return f.err
+}
+
+func TestReloadHandlerPOST(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{{
+		name:       "reloaded",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "invalid configuration",
+		err:        errors.New("unknown location"),
+		wantStatus: http.StatusBadRequest,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeReloader{err: tc.err}
+			ts := httptest.NewServer(NewController(f))
+			defer ts.Close()
+
+			res, err := http.Post(ts.URL, "text/plain", nil)
+			if err != nil {
+				t.Fatalf("http.Post() failed with %v", err)
+			}
+			defer res.Body.Close()
+			if got, want := res.StatusCode, tc.wantStatus; got != want {
+				t.Errorf("StatusCode got %d, want %d", got, want)
+			}
+			if got, want := f.calls, 1; got != want {
+				t.Errorf("Reload() calls got %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestReloadHandlerGET(t *testing.T) {
+	f := &fakeReloader{}
+	ts := httptest.NewServer(NewController(f))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("http.Get() failed with %v", err)
+	}
+	defer res.Body.Close()
+	if got, want := res.StatusCode, http.StatusInternalServerError; got != want {
+		t.Errorf("StatusCode got %d, want %d", got, want)
+	}
+	if got, want := f.calls, 0; got != want {
+		t.Errorf("Reload() calls got %d, want %d", got, want)
+	}
+}
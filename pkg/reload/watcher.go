@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/files"
+)
+
+// Watcher reloads the configuration when the files it is loaded from change.
+// The files are polled, so that they can be either local or in GCS.
+type Watcher struct {
+	// Reloader reloads the configuration.
+	Reloader Reloader
+	// Files are the paths of the files to watch.
+	Files []string
+	// Dirs are the paths of the directories to watch. A directory changes when any of the files
+	// directly in it changes, or when files are added or removed.
+	Dirs []string
+	// Interval is how often the files are checked for changes.
+	Interval time.Duration
+}
+
+// Run checks the files for changes every w.Interval, and reloads the configuration when they change,
+// until the context is done. If the configuration cannot be reloaded, e.g., because the files are
+// not valid, the error is logged and the configuration is reloaded the next time the files change.
+func (w *Watcher) Run(ctx context.Context) {
+	last, err := w.fingerprint(ctx)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Warning("Cannot read the configuration files to watch")
+	}
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, fp, err := w.check(ctx, last)
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Warning("Cannot read the configuration files to watch")
+			continue
+		}
+		if !changed {
+			continue
+		}
+		last = fp
+		log.WithContext(ctx).Info("The configuration files changed; reloading the configuration")
+		if err := w.Reloader.Reload(ctx); err != nil {
+			log.WithContext(ctx).WithError(err).Error("Cannot reload the configuration")
+		}
+	}
+}
+
+// check returns whether the fingerprint of the files differs from the given one, and the new
+// fingerprint.
+func (w *Watcher) check(ctx context.Context, last []byte) (bool, []byte, error) {
+	fp, err := w.fingerprint(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	return string(fp) != string(last), fp, nil
+}
+
+// fingerprint returns a hash of the names and contents of all the watched files.
+func (w *Watcher) fingerprint(ctx context.Context) ([]byte, error) {
+	h := sha256.New()
+	add := func(name string, b []byte) {
+		sum := sha256.Sum256(b)
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(sum[:])
+	}
+	for _, f := range w.Files {
+		b, err := files.Read(ctx, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read file %s", f)
+		}
+		add(f, b)
+	}
+	for _, d := range w.Dirs {
+		list, err := files.List(ctx, d)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list directory %s", d)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].FullPath() < list[j].FullPath() })
+		for _, f := range list {
+			b, err := f.Read(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot read file %s", f.FullPath())
+			}
+			add(f.FullPath(), b)
+		}
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Arend-melissant/simhospital/pkg/test/testwrite"
+)
+
+func TestWatcherCheck(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name        string
+		change      func(t *testing.T, file string, dir string)
+		wantChanged bool
+	}{{
+		name:        "no change",
+		change:      func(*testing.T, string, string) {},
+		wantChanged: false,
+	}, {
+		name: "file changed",
+		change: func(t *testing.T, file string, _ string) {
+			write(t, file, "new")
+		},
+		wantChanged: true,
+	}, {
+		name: "file in directory changed",
+		change: func(t *testing.T, _ string, dir string) {
+			write(t, filepath.Join(dir, "pathways.yml"), "new")
+		},
+		wantChanged: true,
+	}, {
+		name: "file added to directory",
+		change: func(t *testing.T, _ string, dir string) {
+			write(t, filepath.Join(dir, "more_pathways.yml"), "pathways")
+		},
+		wantChanged: true,
+	}, {
+		name: "file removed from directory",
+		change: func(t *testing.T, _ string, dir string) {
+			if err := os.Remove(filepath.Join(dir, "pathways.yml")); err != nil {
+				t.Fatalf("os.Remove() failed with %v", err)
+			}
+		},
+		wantChanged: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := testwrite.BytesToFile(t, []byte("locations"))
+			dir := testwrite.BytesToDir(t, []byte("pathways"), "pathways.yml")
+			w := &Watcher{Files: []string{file}, Dirs: []string{dir}}
+			fp, err := w.fingerprint(ctx)
+			if err != nil {
+				t.Fatalf("fingerprint() failed with %v", err)
+			}
+
+			tc.change(t, file, dir)
+			changed, _, err := w.check(ctx, fp)
+			if err != nil {
+				t.Fatalf("check() failed with %v", err)
+			}
+			if changed != tc.wantChanged {
+				t.Errorf("check() got changed=%t, want %t", changed, tc.wantChanged)
+			}
+		})
+	}
+}
+
+func TestWatcherCheckMissingFile(t *testing.T) {
+	w := &Watcher{Files: []string{filepath.Join(testwrite.TempDir(t), "missing.yml")}}
+	if _, _, err := w.check(context.Background(), nil); err == nil {
+		t.Error("check() got nil error, want error")
+	}
+}
+
+type chanReloader chan struct{}
+
+func (c chanReloader) Reload(context.Context) error {
+	c <- struct{}{}
+	return nil
+}
+
+func TestWatcherRun(t *testing.T) {
+	file := testwrite.BytesToFile(t, []byte("locations"))
+	reloads := make(chanReloader)
+	w := &Watcher{Reloader: reloads, Files: []string{file}, Interval: 10 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Give the watcher time to take the fingerprint of the original file.
+	time.Sleep(50 * time.Millisecond)
+	write(t, file, "new locations")
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload() not called after the file changed")
+	}
+}
+
+func write(t *testing.T, file string, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile(%s) failed with %v", file, err)
+	}
+}
@@ -33,9 +33,21 @@ type Output interface {
 // StdOutput is a resource output that wraps stdout.
 type StdOutput struct{}
 
-// New just returns os.Stdout.
+// New returns a writer to os.Stdout. Closing the writer does not close os.Stdout, so that it can
+// still be written to afterwards.
 func (o *StdOutput) New(*ir.PatientInfo) (io.WriteCloser, error) {
-	return os.Stdout, nil
+	return stdout{}, nil
+}
+
+// stdout is a writer to os.Stdout that doesn't close it.
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdout) Close() error {
+	return nil
 }
 
 // DirectoryOutput is a resource output that stores information in multiple files in the same
@@ -17,6 +17,8 @@ package sample
 
 import (
 	"math/rand"
+
+	"github.com/Arend-melissant/simhospital/pkg/random"
 )
 
 // WeightedValue represents the value and its frequency.
@@ -28,6 +30,9 @@ type WeightedValue struct {
 // DiscreteDistribution represents a collection of weighted values that form a distribution.
 type DiscreteDistribution struct {
 	WeightedValues []WeightedValue
+	// Rand is the source of randomness used to sample the distribution.
+	// Optional: if nil, the default source of the math/rand package is used.
+	Rand *rand.Rand
 }
 
 func (d DiscreteDistribution) total() uint {
@@ -38,9 +43,9 @@ func (d DiscreteDistribution) total() uint {
 	return total
 }
 
-// randUint returns, as an uint, a pseudo-random number in [0,n) from the default Source.
-func randUint(n uint) uint {
-	return uint(rand.Intn(int(n)))
+// randUint returns, as an uint, a pseudo-random number in [0,n) from the distribution's source.
+func (d DiscreteDistribution) randUint(n uint) uint {
+	return uint(random.Or(d.Rand).Intn(int(n)))
 }
 
 // Random samples the DiscreteDistribution and returns the resulting value.
@@ -48,7 +53,7 @@ func (d DiscreteDistribution) Random() interface{} {
 	if d.total() == 0 {
 		return nil
 	}
-	r := randUint(d.total())
+	r := d.randUint(d.total())
 	current := uint(0)
 	for _, result := range d.WeightedValues {
 		current += result.Frequency
@@ -0,0 +1,380 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema generates JSON Schemas for the files that Simulated Hospital loads, e.g., pathways,
+// from the Go types that the files are unmarshalled into.
+// The schemas can be used by editors to autocomplete the files and to flag errors.
+package schema
+
+//go:generate go run ../../cmd/simulator schema -out ../../configs/schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/hardcoded"
+	"github.com/Arend-melissant/simhospital/pkg/location"
+	"github.com/Arend-melissant/simhospital/pkg/orderprofile"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/rate"
+)
+
+// Draft is the version of JSON Schema that the schemas conform to.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Extension is the extension of the schema files.
+const Extension = ".schema.json"
+
+const (
+	// durationPattern matches the durations that time.ParseDuration accepts, e.g., "1h30m".
+	durationPattern = `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+	// timestampPattern matches the start of YAML timestamps, e.g., "2020-02-12" or "2020-02-12T10:00:00Z".
+	timestampPattern = `^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt ]|$)`
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// Schema is a JSON Schema.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is either a string or a list of strings.
+	Type       interface{}        `json:"type,omitempty"`
+	Pattern    string             `json:"pattern,omitempty"`
+	Minimum    *int               `json:"minimum,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is either a boolean or a *Schema.
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// Format is the format of a type of file.
+type Format struct {
+	// Name is the name of the format. The schema file is named after it.
+	Name string
+	// Title is the title of the schema.
+	Title string
+	// Value is a value of the type that the files are unmarshalled into.
+	Value interface{}
+	// Strict is whether the files are unmarshalled strictly, i.e., whether unknown fields are errors.
+	Strict bool
+}
+
+// Formats are the formats of the files that Simulated Hospital loads.
+var Formats = []Format{
+	{Name: "pathways", Title: "Simulated Hospital pathways", Value: pathway.FileFormat(), Strict: true},
+	{Name: "hardcoded_messages", Title: "Simulated Hospital hardcoded messages", Value: hardcoded.FileFormat(), Strict: true},
+	{Name: "locations", Title: "Simulated Hospital locations", Value: location.FileFormat(), Strict: false},
+	{Name: "order_profiles", Title: "Simulated Hospital order profiles", Value: orderprofile.FileFormat(), Strict: true},
+	{Name: "rate_profile", Title: "Simulated Hospital rate profile", Value: rate.Profile{}, Strict: true},
+	{Name: "rate_groups", Title: "Simulated Hospital rate groups", Value: rate.Groups{}, Strict: true},
+}
+
+// Generate returns the JSON Schema of the given format.
+// The doc comments of the Go types and fields are used as descriptions, if the source code of their
+// packages can be found.
+func Generate(f Format) (*Schema, error) {
+	g := &generator{
+		strict:      f.Strict,
+		comments:    map[string]string{},
+		parsed:      map[string]bool{},
+		definitions: map[string]*Schema{},
+		types:       map[string]reflect.Type{},
+	}
+	s, err := g.schema(reflect.TypeOf(f.Value))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot generate schema %s", f.Name)
+	}
+	s.Schema = Draft
+	s.Title = f.Title
+	if len(g.definitions) > 0 {
+		s.Definitions = g.definitions
+	}
+	return s, nil
+}
+
+// Marshal returns the JSON encoding of s, as written to schema files.
+func Marshal(s *Schema) ([]byte, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal schema")
+	}
+	return append(b, '\n'), nil
+}
+
+// WriteAll generates the schemas of all Formats, and writes them to the given directory.
+func WriteAll(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "cannot create directory %s", dir)
+	}
+	for _, f := range Formats {
+		s, err := Generate(f)
+		if err != nil {
+			return err
+		}
+		b, err := Marshal(s)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal schema %s", f.Name)
+		}
+		fileName := path.Join(dir, f.Name+Extension)
+		if err := os.WriteFile(fileName, b, 0644); err != nil {
+			return errors.Wrapf(err, "cannot write schema file %s", fileName)
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	strict bool
+	// comments are the doc comments of types and fields, indexed by "<package path>.<type>" and
+	// "<package path>.<type>.<field>".
+	comments map[string]string
+	// parsed is the set of packages whose comments have been parsed.
+	parsed map[string]bool
+	// definitions are the schemas of named struct types, indexed by type name.
+	definitions map[string]*Schema
+	// types are the types of the definitions, to detect two types with the same name.
+	types map[string]reflect.Type
+}
+
+// schema returns the schema for values of type t.
+func (g *generator) schema(t reflect.Type) (*Schema, error) {
+	switch t {
+	case durationType:
+		return &Schema{Description: "A duration, e.g., 1h30m.", Type: []string{"string", "integer"}, Pattern: durationPattern}, nil
+	case timeType:
+		return &Schema{Description: "A timestamp, e.g., 2020-02-12T10:00:00Z.", Type: "string", Pattern: timestampPattern}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &Schema{Type: "integer", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		// YAML unmarshals any scalar into a string, and empty values into empty strings.
+		return &Schema{Type: []string{"string", "number", "boolean", "null"}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.definition(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %v", t)
+	}
+}
+
+// definition returns a reference to the definition of the named struct type t, and adds the
+// definition if it does not exist yet.
+func (g *generator) definition(t reflect.Type) (*Schema, error) {
+	name := t.Name()
+	ref := &Schema{Ref: "#/definitions/" + name}
+	if existing, ok := g.types[name]; ok {
+		if existing != t {
+			return nil, fmt.Errorf("types %v and %v have the same name", existing, t)
+		}
+		return ref, nil
+	}
+	// Register the type before generating the definition, so that recursive types refer to it.
+	g.types[name] = t
+	s, err := g.object(t)
+	if err != nil {
+		return nil, err
+	}
+	s.Description = g.comment(t.PkgPath(), name)
+	g.definitions[name] = s
+	return ref, nil
+}
+
+// object returns the schema of the struct type t, with one property per field that YAML unmarshals.
+func (g *generator) object(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if g.strict {
+		s.AdditionalProperties = false
+	}
+	if err := g.addFields(s, t); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// addFields adds the fields of struct type t to the properties of s, following the rules of
+// gopkg.in/yaml.v2 for naming fields and for inlining them.
+func (g *generator) addFields(s *Schema, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			// Unexported fields are not unmarshalled.
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, flags := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, flags = tag[:i], tag[i+1:]
+		}
+		if strings.Contains(flags, "inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				return fmt.Errorf("unsupported inline field %s.%s", t.Name(), f.Name)
+			}
+			if err := g.addFields(s, ft); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fs, err := g.schema(f.Type)
+		if err != nil {
+			return errors.Wrapf(err, "field %s.%s", t.Name(), f.Name)
+		}
+		description := g.comment(t.PkgPath(), t.Name()+"."+f.Name)
+		if description == "" && fs.Ref == "" && fs.Description == "" {
+			// Use the description of named non-struct types, e.g., Percentage.
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			description = g.comment(ft.PkgPath(), ft.Name())
+		}
+		if description != "" {
+			if fs.Ref != "" {
+				// Keywords next to $ref are ignored, so wrap the reference.
+				fs = &Schema{AllOf: []*Schema{fs}}
+			}
+			fs.Description = description
+		}
+		s.Properties[name] = fs
+	}
+	return nil
+}
+
+// comment returns the doc comment of the type or field with the given name in package pkgPath, or an
+// empty string if there is none or if the source code of the package cannot be found.
+// The comments of the standard library are not used, so that the schemas do not depend on the Go version.
+func (g *generator) comment(pkgPath, name string) string {
+	if pkgPath == "" || name == "" || !strings.Contains(strings.Split(pkgPath, "/")[0], ".") {
+		return ""
+	}
+	if !g.parsed[pkgPath] {
+		g.parsed[pkgPath] = true
+		g.parseComments(pkgPath)
+	}
+	return g.comments[pkgPath+"."+name]
+}
+
+// parseComments parses the doc comments of the types and struct fields in package pkgPath.
+func (g *generator) parseComments(pkgPath string) {
+	p, err := build.Import(pkgPath, ".", build.FindOnly)
+	if err != nil {
+		return
+	}
+	pkgs, err := parser.ParseDir(token.NewFileSet(), p.Dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					doc := ts.Doc
+					if doc == nil && len(gd.Specs) == 1 {
+						doc = gd.Doc
+					}
+					g.addComment(pkgPath+"."+ts.Name.Name, doc)
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						doc := field.Doc
+						if doc == nil {
+							doc = field.Comment
+						}
+						for _, n := range field.Names {
+							g.addComment(pkgPath+"."+ts.Name.Name+"."+n.Name, doc)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func (g *generator) addComment(key string, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	if text := strings.Join(strings.Fields(doc.Text()), " "); text != "" {
+		g.comments[key] = text
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/test"
+)
+
+type inlined struct {
+	Inlined string
+}
+
+type node struct {
+	Name     string `yaml:"node_name"`
+	Children []*node
+	Delay    *time.Duration `yaml:"delay,omitempty"`
+	Time     time.Time
+	Values   map[string]int
+	Count    uint
+	Ignored  string `yaml:"-"`
+	inlined  `yaml:",inline"`
+	private  string
+}
+
+func TestGenerate(t *testing.T) {
+	zero := 0
+	nodeProperties := map[string]*Schema{
+		"node_name": {Type: []string{"string", "number", "boolean", "null"}},
+		"children":  {Type: "array", Items: &Schema{Ref: "#/definitions/node"}},
+		"delay":     {Description: "A duration, e.g., 1h30m.", Type: []string{"string", "integer"}, Pattern: durationPattern},
+		"time":      {Description: "A timestamp, e.g., 2020-02-12T10:00:00Z.", Type: "string", Pattern: timestampPattern},
+		"values":    {Type: "object", AdditionalProperties: &Schema{Type: "integer"}},
+		"count":     {Type: "integer", Minimum: &zero},
+		"inlined":   {Type: []string{"string", "number", "boolean", "null"}},
+	}
+
+	cases := []struct {
+		name   string
+		strict bool
+		want   *Schema
+	}{{
+		name:   "strict",
+		strict: true,
+		want: &Schema{
+			Schema:               Draft,
+			Title:                "Nodes",
+			Type:                 "object",
+			AdditionalProperties: &Schema{Ref: "#/definitions/node"},
+			Definitions: map[string]*Schema{
+				"node": {Type: "object", Properties: nodeProperties, AdditionalProperties: false},
+			},
+		},
+	}, {
+		name:   "not strict",
+		strict: false,
+		want: &Schema{
+			Schema:               Draft,
+			Title:                "Nodes",
+			Type:                 "object",
+			AdditionalProperties: &Schema{Ref: "#/definitions/node"},
+			Definitions: map[string]*Schema{
+				"node": {Type: "object", Properties: nodeProperties},
+			},
+		},
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Generate(Format{Name: "nodes", Title: "Nodes", Value: map[string]node{}, Strict: tc.strict})
+			if err != nil {
+				t.Fatalf("Generate() failed with %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Generate() -> diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateUnsupportedType(t *testing.T) {
+	if _, err := Generate(Format{Name: "invalid", Value: map[int]string{}}); err == nil {
+		t.Error("Generate() got nil error, want non-nil error")
+	}
+}
+
+func TestGenerateDescriptions(t *testing.T) {
+	s, err := Generate(Format{Name: "pathways", Value: pathway.FileFormat(), Strict: true})
+	if err != nil {
+		t.Fatalf("Generate() failed with %v", err)
+	}
+	pathwaySchema, ok := s.Definitions["Pathway"]
+	if !ok {
+		t.Fatal("Definitions[Pathway] does not exist, want to exist")
+	}
+	if got, want := pathwaySchema.Description, "Pathway represents a pathway."; got != want {
+		t.Errorf("Definitions[Pathway].Description = %q, want %q", got, want)
+	}
+	extends, ok := pathwaySchema.Properties["extends"]
+	if !ok {
+		t.Fatal("Definitions[Pathway].Properties[extends] does not exist, want to exist")
+	}
+	if got, want := extends.Description, "Extends is the name of the base pathway of this pathway, if any. The fields that are not set in this pathway are taken from the base pathway."; got != want {
+		t.Errorf("Definitions[Pathway].Properties[extends].Description = %q, want %q", got, want)
+	}
+}
+
+// TestSchemaFilesUpToDate checks that the schema files match the Go types.
+// Run "go generate ./pkg/schema" to update the files.
+func TestSchemaFilesUpToDate(t *testing.T) {
+	for _, f := range Formats {
+		t.Run(f.Name, func(t *testing.T) {
+			s, err := Generate(f)
+			if err != nil {
+				t.Fatalf("Generate() failed with %v", err)
+			}
+			want, err := Marshal(s)
+			if err != nil {
+				t.Fatalf("Marshal() failed with %v", err)
+			}
+			fileName := path.Join(test.SchemaDirProd, f.Name+Extension)
+			got, err := os.ReadFile(fileName)
+			if err != nil {
+				t.Fatalf("os.ReadFile(%q) failed with %v", fileName, err)
+			}
+			if diff := cmp.Diff(string(want), string(got)); diff != "" {
+				t.Errorf("%s is out of date; run \"go generate ./pkg/schema\" to update it. Diff (-want, +got):\n%s", fileName, diff)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -696,7 +697,7 @@ type pathwayStarter struct {
 
 func newTestPathwayStarter(ctx context.Context, t *testing.T, pathways map[string]pathway.Pathway, cfg hospital.Config) *pathwayStarter {
 	t.Helper()
-	pathwayManager, err := pathway.NewDistributionManager(pathways, nil, nil)
+	pathwayManager, err := pathway.NewDistributionManager(pathways, nil, nil, rand.New(rand.NewSource(1)))
 	if err != nil {
 		t.Fatalf("pathway.NewDistributionManager(%v,%v,%v) failed with %v", pathways, nil, nil, err)
 	}
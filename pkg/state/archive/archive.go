@@ -0,0 +1,119 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive contains an archive of the items purged by retention policies, so that they can
+// still be inspected after they are deleted.
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+)
+
+var log = logging.ForCallerPackage()
+
+// Record is an archived item.
+type Record struct {
+	// ItemType is the type of the item, e.g., state.PatientItemType.
+	ItemType string `json:"itemType"`
+	// ID is the ID of the item.
+	ID string `json:"id"`
+	// ArchivedAt is the time the item was archived.
+	ArchivedAt time.Time `json:"archivedAt"`
+	// Item is the marshalled item, as returned by its Marshal method.
+	Item json.RawMessage `json:"item"`
+}
+
+// File is an archive in a JSON Lines file: each archived item is appended to the file as a Record,
+// in JSON format, in its own line.
+// It is safe for concurrent use, and must be closed when it is no longer used.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFile opens the archive in the given file, creating the file if it doesn't exist. Items are
+// appended to the file.
+func OpenFile(name string) (*File, error) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open archive file %s", name)
+	}
+	log.WithField("file", name).Info("Opened archive")
+	return &File{f: f}, nil
+}
+
+// Archive appends an item of the given type to the file. The item must be marshalled to JSON.
+func (a *File) Archive(itemType string, item persist.MarshallableItem) error {
+	id, err := item.ID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get ID")
+	}
+	b, err := item.Marshal()
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal item %q", id)
+	}
+	if !json.Valid(b) {
+		return errors.Errorf("cannot archive item %q: it is not marshalled to JSON", id)
+	}
+	line, err := json.Marshal(Record{ItemType: itemType, ID: id, ArchivedAt: time.Now().UTC(), Item: b})
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal record of item %q", id)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Each record is written with a single write, so that records are never interleaved.
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		return errors.Wrapf(err, "cannot archive item %q", id)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (a *File) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
+
+// ReadFile returns the records in the given archive file, in the order they were archived.
+func ReadFile(name string) ([]Record, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open archive file %s", name)
+	}
+	defer f.Close()
+	var records []Record
+	s := bufio.NewScanner(f)
+	// Patients with long histories can be large.
+	s.Buffer(nil, 64*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		var r Record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal record in line %d of %s", line, name)
+		}
+		records = append(records, r)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "cannot read archive file %s", name)
+	}
+	return records, nil
+}
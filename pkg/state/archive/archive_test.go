@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	. "github.com/Arend-melissant/simhospital/pkg/state/archive"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/test/teststate"
+)
+
+func TestFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "archive.jsonl")
+	patient := state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1", FirstName: "Jane"}}}
+
+	// Records are appended to the file when it is opened again.
+	for _, item := range []teststate.Item{teststate.Item1, teststate.Item2} {
+		a, err := OpenFile(name)
+		if err != nil {
+			t.Fatalf("OpenFile(%q) failed with %v", name, err)
+		}
+		if err := a.Archive(teststate.Type, item); err != nil {
+			t.Fatalf("Archive(%v) failed with %v", item, err)
+		}
+		if item == teststate.Item2 {
+			if err := a.Archive(state.PatientItemType, patient); err != nil {
+				t.Fatalf("Archive(%v) failed with %v", patient, err)
+			}
+		}
+		if err := a.Close(); err != nil {
+			t.Fatalf("Close() failed with %v", err)
+		}
+	}
+
+	got, err := ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) failed with %v", name, err)
+	}
+	want := []Record{
+		{ItemType: teststate.Type, ID: "1"},
+		{ItemType: teststate.Type, ID: "2"},
+		{ItemType: state.PatientItemType, ID: "1"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Record{}, "ArchivedAt", "Item")); diff != "" {
+		t.Errorf("ReadFile(%q) diff (-want, +got):\n%s", name, diff)
+	}
+	for _, r := range got {
+		if r.ArchivedAt.IsZero() {
+			t.Errorf("ArchivedAt of record %+v is zero, want the time it was archived", r)
+		}
+	}
+	if len(got) == 3 {
+		unmarshalled, err := (&state.PatientUnmarshaller{}).Unmarshal(got[2].Item)
+		if err != nil {
+			t.Fatalf("Unmarshal(%s) failed with %v", got[2].Item, err)
+		}
+		if diff := cmp.Diff(patient, unmarshalled); diff != "" {
+			t.Errorf("archived patient diff (-want, +got):\n%s", diff)
+		}
+	}
+}
+
+func TestFile_ArchiveFails(t *testing.T) {
+	a, err := OpenFile(filepath.Join(t.TempDir(), "archive.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenFile() failed with %v", err)
+	}
+	defer a.Close()
+	// The item cannot be marshalled.
+	if err := a.Archive(teststate.Type, teststate.Item{I: "1", S: false}); err == nil {
+		t.Error("Archive() of an item that cannot be marshalled got nil error, want error")
+	}
+}
@@ -38,10 +38,61 @@ type Event struct {
 	Index          int
 	// PatientIDs is a map from PatientID to MRN; only set if the pathway this event belongs to had a Persons section.
 	PatientIDs map[pathway.PatientID]string
+	// Triggered is set on WaitForEvent events when the external event they were waiting for has arrived.
+	// Untriggered WaitForEvent events sit in the queue with their EventTime set to the timeout.
+	Triggered bool
+	// Tracks are the parallel tracks this event runs in, from the outermost to the innermost one.
+	// Only set if the event belongs to a track of a Parallel step.
+	Tracks []ParallelTrack
+	// JoinFor is set on join events, and is the ID of the Parallel event whose tracks the join event is
+	// waiting for. Join events carry the rest of the pathway, which runs once all the tracks have finished.
+	JoinFor string
+	// PendingTracks is the number of tracks that haven't finished yet. Only set on join events.
+	PendingTracks int
+	// WaitingForBed is set on Admission events that are waiting for a bed because their location is full,
+	// and is the name of the location. Waiting events sit in the queue until a bed is freed.
+	WaitingForBed string
+	// WaitingSince is the time the Admission event started waiting for a bed.
+	// Only set on events that are, or have been, waiting for a bed.
+	WaitingSince time.Time
+	// Paused is set on the events of pathway instances that have been paused. Paused events sit in the
+	// queue, and never become due, until their pathway instance is resumed.
+	Paused bool
+	// PausedEventTime is the time at which a paused event becomes due once its pathway instance is
+	// resumed. Only set on paused events.
+	PausedEventTime time.Time
+}
+
+// ParallelTrack identifies a track of a Parallel step.
+type ParallelTrack struct {
+	// ForkID is the ID of the Parallel event that started the track.
+	ForkID string
+	// Index is the index of the track within the Parallel step.
+	Index int
 }
 
 func (e Event) String() string {
-	return fmt.Sprintf("time:%v, messageTime:%v pathwayName:%v, index:%v, mrn:%v", e.EventTime, e.MessageTime, e.PathwayName, e.Index, e.PatientMRN)
+	s := fmt.Sprintf("time:%v, messageTime:%v pathwayName:%v, index:%v, mrn:%v", e.EventTime, e.MessageTime, e.PathwayName, e.Index, e.PatientMRN)
+	if len(e.Tracks) > 0 {
+		s = fmt.Sprintf("%s, tracks:%v", s, e.Tracks)
+	}
+	if e.JoinFor != "" {
+		s = fmt.Sprintf("%s, joinFor:%v, pendingTracks:%v", s, e.JoinFor, e.PendingTracks)
+	}
+	if e.WaitingForBed != "" {
+		s = fmt.Sprintf("%s, waitingForBed:%v, waitingSince:%v", s, e.WaitingForBed, e.WaitingSince)
+	}
+	if e.Paused {
+		s = fmt.Sprintf("%s, pausedEventTime:%v", s, e.PausedEventTime)
+	}
+	return s
+}
+
+// InstanceID returns the identifier of the pathway instance the event belongs to, i.e., the pathway
+// with name PathwayName that started for the patient at PathwayStarted. All the events of a pathway
+// instance, including the ones of its parallel tracks, have the same InstanceID.
+func (e Event) InstanceID() string {
+	return id(fmt.Sprintf("%s|%s|%d", e.PathwayName, e.PatientMRN, e.PathwayStarted.UnixNano()))[:16]
 }
 
 // ResolveMRN transforms the given PatientID into an MRN.
@@ -53,6 +104,12 @@ func (e Event) ResolveMRN(patientID pathway.PatientID) string {
 	return string(patientID)
 }
 
+// IsWaitingFor returns whether this event is a WaitForEvent event for the patient with the given MRN
+// that is still waiting for the external event with the given name.
+func (e Event) IsWaitingFor(mrn string, eventName string) bool {
+	return e.Step.WaitForEvent != nil && !e.Triggered && e.PatientMRN == mrn && e.Step.WaitForEvent.Event == eventName
+}
+
 // Compare compares the current event with the given one.
 // This method is part of the queue.Item interface and allows the events to be added to the priority queue.
 // The items on the priority queue need to be sorted by their eventTime date. If two items have exactly
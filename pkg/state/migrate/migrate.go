@@ -0,0 +1,161 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate copies the persisted state of Simulated Hospital from one persistence backend to
+// another, e.g., from a BoltDB file to a SQL database.
+package migrate
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+)
+
+// maxReportedIDs is the maximum number of IDs of missing or unexpected items reported when the
+// verification fails.
+const maxReportedIDs = 10
+
+var log = logging.ForCallerPackage()
+
+// Result is the number of items of each type that were migrated, keyed by item type.
+type Result map[string]int
+
+// Migrate copies all the items of the source syncers to the destination syncers of the same item
+// types, and verifies that the destination syncers then have exactly the same items as the source
+// syncers: the same number of items, with the same IDs. The items that Simulated Hospital keeps in
+// queues, i.e., all items but patients, are also loaded from the destination into a
+// state.WrappedQueue, which must be consistent.
+//
+// Every item type in src must have a syncer in dst, and the destination syncers must be empty, so
+// that existing items are never overwritten nor mixed with the migrated ones. The item types are
+// migrated one by one, in alphabetical order; if the migration fails, Migrate returns the number of
+// items of the item types that were migrated before the failure.
+func Migrate(src, dst map[string]persist.ItemSyncer) (Result, error) {
+	itemTypes := make([]string, 0, len(src))
+	for itemType := range src {
+		if _, ok := dst[itemType]; !ok {
+			return nil, errors.Errorf("no destination syncer for item type %q", itemType)
+		}
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Strings(itemTypes)
+
+	// Check all the destination syncers before writing anything, so that nothing is written if any
+	// of them is not empty.
+	for _, itemType := range itemTypes {
+		items, err := dst[itemType].LoadAll()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load the %s items from the destination", itemType)
+		}
+		if len(items) > 0 {
+			return nil, errors.Errorf("the destination already has %d %s items; the destination must be empty", len(items), itemType)
+		}
+	}
+
+	result := Result{}
+	for _, itemType := range itemTypes {
+		n, err := migrate(itemType, src[itemType], dst[itemType])
+		if err != nil {
+			return result, errors.Wrapf(err, "cannot migrate the %s items", itemType)
+		}
+		result[itemType] = n
+		log.WithField("item_type", itemType).WithField("count", n).Info("Migrated items")
+	}
+	return result, nil
+}
+
+// migrate copies all the items of type itemType from src to dst, verifies them, and returns the
+// number of items copied.
+func migrate(itemType string, src, dst persist.ItemSyncer) (int, error) {
+	items, err := src.LoadAll()
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot load the items from the source")
+	}
+	ids := make([]string, len(items))
+	for i, item := range items {
+		id, err := item.ID()
+		if err != nil {
+			return 0, errors.Wrap(err, "cannot get ID")
+		}
+		if err := dst.Write(item); err != nil {
+			return 0, errors.Wrapf(err, "cannot write item %q to the destination", id)
+		}
+		ids[i] = id
+	}
+	if err := verify(itemType, ids, dst); err != nil {
+		return 0, errors.Wrap(err, "verification failed")
+	}
+	return len(items), nil
+}
+
+// verify checks that dst has exactly the items with the given IDs and, for the item types other
+// than patients, that they can be loaded into a consistent state.WrappedQueue.
+func verify(itemType string, want []string, dst persist.ItemSyncer) error {
+	items, err := dst.LoadAll()
+	if err != nil {
+		return errors.Wrap(err, "cannot load the items from the destination")
+	}
+	got := make([]string, len(items))
+	for i, item := range items {
+		if got[i], err = item.ID(); err != nil {
+			return errors.Wrap(err, "cannot get ID")
+		}
+	}
+	if missing, unexpected := difference(want, got), difference(got, want); len(got) != len(want) || len(missing) > 0 || len(unexpected) > 0 {
+		return errors.Errorf("the destination has %d items, want %d; missing IDs: %v; unexpected IDs: %v",
+			len(got), len(want), truncate(missing), truncate(unexpected))
+	}
+
+	// Patients are kept in a map, not in a queue.
+	if itemType == state.PatientItemType {
+		return nil
+	}
+	q, err := state.NewWrappedQueue(itemType, dst)
+	if err != nil {
+		return errors.Wrap(err, "cannot load the items into a queue")
+	}
+	if !q.IsConsistent() {
+		return errors.New("the queue with the items from the destination is not consistent")
+	}
+	if q.Len() != len(want) {
+		return errors.Errorf("the queue with the items from the destination has %d items, want %d", q.Len(), len(want))
+	}
+	return nil
+}
+
+// difference returns the IDs in a that are not in b.
+func difference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, id := range b {
+		inB[id] = true
+	}
+	var diff []string
+	for _, id := range a {
+		if !inB[id] {
+			diff = append(diff, id)
+		}
+	}
+	return diff
+}
+
+// truncate returns at most maxReportedIDs of the given IDs.
+func truncate(ids []string) []string {
+	if len(ids) > maxReportedIDs {
+		return append(ids[:maxReportedIDs:maxReportedIDs], "...")
+	}
+	return ids
+}
@@ -0,0 +1,220 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	. "github.com/Arend-melissant/simhospital/pkg/state/migrate"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistdb"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistsql"
+	"github.com/Arend-melissant/simhospital/pkg/test/teststate"
+)
+
+// lossySyncer is an ItemSyncer that drops the items with the given ID instead of writing them.
+type lossySyncer struct {
+	*teststate.ItemSyncer
+	drop string
+}
+
+func (s lossySyncer) Write(item persist.MarshallableItem) error {
+	if id, _ := item.ID(); id == s.drop {
+		return nil
+	}
+	return s.ItemSyncer.Write(item)
+}
+
+// failingSyncer is an ItemSyncer whose LoadAll always fails.
+type failingSyncer struct {
+	*teststate.ItemSyncer
+}
+
+func (failingSyncer) LoadAll() ([]persist.MarshallableItem, error) {
+	return nil, errors.New("LoadAll failed")
+}
+
+func syncerWith(t *testing.T, items ...persist.MarshallableItem) *teststate.ItemSyncer {
+	t.Helper()
+	s := teststate.NewItemSyncer()
+	for _, item := range items {
+		if err := s.Write(item); err != nil {
+			t.Fatalf("Write(%v) failed with %v", item, err)
+		}
+	}
+	return s
+}
+
+func ids(t *testing.T, s persist.ItemSyncer) []string {
+	t.Helper()
+	items, err := s.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll() failed with %v", err)
+	}
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i], _ = item.ID()
+	}
+	return ids
+}
+
+func TestMigrate(t *testing.T) {
+	src := map[string]persist.ItemSyncer{
+		teststate.Type:        syncerWith(t, teststate.Item1, teststate.Item2),
+		state.PatientItemType: syncerWith(t, teststate.NewItem("p1")),
+		"empty":               syncerWith(t),
+	}
+	dst := map[string]persist.ItemSyncer{
+		teststate.Type:        teststate.NewItemSyncer(),
+		state.PatientItemType: teststate.NewItemSyncer(),
+		"empty":               teststate.NewItemSyncer(),
+		// Destination syncers without a source syncer are ignored.
+		"other": teststate.NewItemSyncer(),
+	}
+
+	got, err := Migrate(src, dst)
+	if err != nil {
+		t.Fatalf("Migrate() failed with %v", err)
+	}
+	want := Result{teststate.Type: 2, state.PatientItemType: 1, "empty": 0}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Migrate() diff (-want, +got):\n%s", diff)
+	}
+	for itemType := range src {
+		if diff := cmp.Diff(ids(t, src[itemType]), ids(t, dst[itemType])); diff != "" {
+			t.Errorf("IDs of the %s items diff (-source, +destination):\n%s", itemType, diff)
+		}
+	}
+}
+
+func TestMigrate_Errors(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     map[string]persist.ItemSyncer
+		dst     map[string]persist.ItemSyncer
+		wantErr string
+		// wantWritten is the number of items that are written to the destination.
+		wantWritten int
+	}{{
+		name:    "no destination syncer",
+		src:     map[string]persist.ItemSyncer{teststate.Type: syncerWith(t, teststate.Item1)},
+		dst:     map[string]persist.ItemSyncer{state.PatientItemType: teststate.NewItemSyncer()},
+		wantErr: "no destination syncer",
+	}, {
+		name: "destination not empty",
+		src: map[string]persist.ItemSyncer{
+			"a":            syncerWith(t, teststate.Item1),
+			teststate.Type: syncerWith(t, teststate.Item1),
+		},
+		dst: map[string]persist.ItemSyncer{
+			"a":            teststate.NewItemSyncer(),
+			teststate.Type: syncerWith(t, teststate.Item2),
+		},
+		wantErr:     "must be empty",
+		wantWritten: 1,
+	}, {
+		name:    "source fails",
+		src:     map[string]persist.ItemSyncer{teststate.Type: failingSyncer{teststate.NewItemSyncer()}},
+		dst:     map[string]persist.ItemSyncer{teststate.Type: teststate.NewItemSyncer()},
+		wantErr: "LoadAll failed",
+	}, {
+		name:        "item missing in the destination",
+		src:         map[string]persist.ItemSyncer{teststate.Type: syncerWith(t, teststate.Item1, teststate.Item2)},
+		dst:         map[string]persist.ItemSyncer{teststate.Type: lossySyncer{ItemSyncer: teststate.NewItemSyncer(), drop: "2"}},
+		wantErr:     "missing IDs: [2]",
+		wantWritten: 1,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Migrate(tc.src, tc.dst)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Migrate() got err %v, want error containing %q", err, tc.wantErr)
+			}
+			var written int
+			for _, s := range tc.dst {
+				switch s := s.(type) {
+				case *teststate.ItemSyncer:
+					written += s.Count()
+				case lossySyncer:
+					written += s.Count()
+				}
+			}
+			// Nothing is written if the destination is not empty; the item that was already there is
+			// counted.
+			if written != tc.wantWritten {
+				t.Errorf("items in the destination got %d, want %d", written, tc.wantWritten)
+			}
+		})
+	}
+}
+
+func TestMigrate_BoltToSQL(t *testing.T) {
+	dir := t.TempDir()
+	bolt, err := persistdb.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("persistdb.Open() failed with %v", err)
+	}
+	defer bolt.Close()
+	db, err := persistsql.Open(persistsql.SQLite, filepath.Join(dir, "test.sqlite"))
+	if err != nil {
+		t.Fatalf("persistsql.Open() failed with %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+	src := persistdb.NewItemSyncers(bolt)
+	for itemType, items := range map[string][]persist.MarshallableItem{
+		state.EventItemType: {
+			state.Event{EventTime: now, PathwayName: "pathway1", PatientMRN: "1"},
+			state.Event{EventTime: now.Add(time.Hour), PathwayName: "pathway2", PatientMRN: "2"},
+		},
+		state.MessageItemType: {
+			state.HL7Message{Name: "ADT^A01", MessageTime: now, PathwayName: "pathway1"},
+		},
+		state.PatientItemType: {
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1", FirstName: "Jane"}}},
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "2", FirstName: "John"}}},
+		},
+	} {
+		for _, item := range items {
+			if err := src[itemType].Write(item); err != nil {
+				t.Fatalf("Write(%v) failed with %v", item, err)
+			}
+		}
+	}
+
+	dst := persistsql.NewItemSyncers(db)
+	got, err := Migrate(src, dst)
+	if err != nil {
+		t.Fatalf("Migrate() failed with %v", err)
+	}
+	want := Result{state.EventItemType: 2, state.MessageItemType: 1, state.PatientItemType: 2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Migrate() diff (-want, +got):\n%s", diff)
+	}
+	for itemType := range src {
+		if diff := cmp.Diff(ids(t, src[itemType]), ids(t, dst[itemType])); diff != "" {
+			t.Errorf("IDs of the %s items diff (-source, +destination):\n%s", itemType, diff)
+		}
+	}
+}
@@ -102,11 +102,16 @@ func (p Patient) ID() (string, error) {
 var minTime = time.Unix(0, 0) // Jan 1, 1900
 var maxTime = minTime.Add(1<<63 - 1)
 
+// End returns the time the last encounter of the patient ended. If the patient has no encounters,
+// or the last encounter hasn't ended yet, End returns a time far in the future.
 func (p Patient) End() (time.Time) {
 	result := maxTime
 	for _,enc := range p.PatientInfo.Encounters	{
 		if (enc.Start.Valid && enc.Start.Time.Unix() != enc.End.Time.Unix()) {
 			result = enc.End.Time
+			if !enc.End.Valid {
+				result = maxTime
+			}
 		}
 	}
 
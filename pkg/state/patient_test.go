@@ -16,6 +16,7 @@ package state
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/Arend-melissant/simhospital/pkg/ir"
@@ -170,3 +171,50 @@ func TestPatient_PushPastVisit_PopPastVisit(t *testing.T) {
 		t.Error("PopPastVisit() got nil error, want error because there are no past visits")
 	}
 }
+
+func TestPatient_End(t *testing.T) {
+	admission := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+	discharge := admission.Add(48 * time.Hour)
+	tests := []struct {
+		name       string
+		encounters []*ir.Encounter
+		want       time.Time
+		wantFuture bool
+	}{{
+		name:       "no encounters",
+		wantFuture: true,
+	}, {
+		name:       "ended",
+		encounters: []*ir.Encounter{{Start: ir.NewValidTime(admission), End: ir.NewValidTime(discharge)}},
+		want:       discharge,
+	}, {
+		name: "last encounter ended",
+		encounters: []*ir.Encounter{
+			{Start: ir.NewValidTime(admission.Add(-time.Hour)), End: ir.NewValidTime(admission.Add(-time.Minute))},
+			{Start: ir.NewValidTime(admission), End: ir.NewValidTime(discharge)},
+		},
+		want: discharge,
+	}, {
+		name: "last encounter not ended",
+		encounters: []*ir.Encounter{
+			{Start: ir.NewValidTime(admission.Add(-time.Hour)), End: ir.NewValidTime(admission.Add(-time.Minute))},
+			{Start: ir.NewValidTime(admission)},
+		},
+		wantFuture: true,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Patient{PatientInfo: &ir.PatientInfo{Encounters: tc.encounters}}
+			got := p.End()
+			if tc.wantFuture {
+				if future := time.Now().AddDate(100, 0, 0); !got.After(future) {
+					t.Errorf("End() = %v, want after %v", got, future)
+				}
+				return
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("End() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package state
 
 import (
 	"encoding/json"
+	"sort"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -143,6 +144,37 @@ func (m *PatientsMap) getAllFromSyncer() []*Patient {
 	return patients
 }
 
+// InMemory returns the patients in the internal patients map, sorted by their identifier.
+// Unlike GetAll, it doesn't load the patients from the syncer.
+func (m *PatientsMap) InMemory() []*Patient {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ids := make([]string, 0, len(m.m))
+	for id := range m.m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	patients := make([]*Patient, len(ids))
+	for i, id := range ids {
+		patients[i] = m.m[id]
+	}
+	return patients
+}
+
+// Clear deletes all the patients from the internal patients map and the syncer.
+func (m *PatientsMap) Clear() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for id, p := range m.m {
+		if m.syncer != nil {
+			if err := m.syncer.Delete(p); err != nil {
+				log.WithField("patient_id", id).WithError(err).Error("Cannot delete patient from the syncer")
+			}
+		}
+		delete(m.m, id)
+	}
+}
+
 // Delete deletes a patient from the internal patients map and the syncer, by its identifier.
 func (m *PatientsMap) Delete(id string) {
 	m.mutex.Lock()
@@ -157,6 +189,65 @@ func (m *PatientsMap) Delete(id string) {
 	}
 }
 
+// All returns all the patients, both in the internal patients map and in the syncer, sorted by their
+// identifier. If a patient is in both, the one in the internal patients map is returned.
+// Unlike GetAll, it doesn't add the patients in the syncer to the internal patients map.
+func (m *PatientsMap) All() ([]*Patient, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	all := make(map[string]*Patient, len(m.m))
+	if m.syncer != nil {
+		items, err := m.syncer.LoadAll()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load patients from the syncer")
+		}
+		for _, item := range items {
+			p := item.(Patient)
+			id, err := p.ID()
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot get ID")
+			}
+			all[id] = &p
+		}
+	}
+	for id, p := range m.m {
+		all[id] = p
+	}
+	ids := make([]string, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	patients := make([]*Patient, len(ids))
+	for i, id := range ids {
+		patients[i] = all[id]
+	}
+	return patients, nil
+}
+
+// Purge deletes a patient from the internal patients map and permanently from the syncer, even if
+// the syncer doesn't delete patients on Delete. If the syncer is set, it must implement
+// persist.Purger.
+func (m *PatientsMap) Purge(p *Patient) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	id, err := p.ID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get ID")
+	}
+	if m.syncer != nil {
+		purger, ok := m.syncer.(persist.Purger)
+		if !ok {
+			return errors.Errorf("cannot purge patient %q: the syncer of type %T cannot purge items", id, m.syncer)
+		}
+		if err := purger.Purge(*p); err != nil {
+			return errors.Wrapf(err, "cannot purge patient %q from the syncer", id)
+		}
+	}
+	delete(m.m, id)
+	return nil
+}
+
 // Len returns the length of the patients map.
 func (m *PatientsMap) Len() int {
 	m.mutex.Lock()
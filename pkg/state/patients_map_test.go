@@ -209,6 +209,70 @@ func TestPatientsMap_Delete(t *testing.T) {
 	}
 }
 
+func TestPatientsMap_All(t *testing.T) {
+	testInfo3 := &Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "3"}}}
+	syncer := teststate.NewItemSyncer()
+	pm := NewPatientsMap(syncer, true)
+	pm.Put(testInfo1)
+	pm.Put(testInfo2)
+	pm.Delete(testID2) // Delete from map only.
+	// testInfo3 is only in the map.
+	pm.m["3"] = testInfo3
+
+	got, err := pm.All()
+	if err != nil {
+		t.Fatalf("pm.All() failed with %v", err)
+	}
+	if want := []*Patient{testInfo1, testInfo2, testInfo3}; !cmp.Equal(got, want) {
+		t.Errorf("pm.All() = %v, want: %v", got, want)
+	}
+	if got, want := pm.Len(), 2; got != want {
+		t.Errorf("pm.Len() after pm.All() = %d, want: %d", got, want)
+	}
+
+	pm = NewPatientsMap(nil, false)
+	pm.Put(testInfo2)
+	got, err = pm.All()
+	if err != nil {
+		t.Fatalf("pm.All() failed with %v", err)
+	}
+	if want := []*Patient{testInfo2}; !cmp.Equal(got, want) {
+		t.Errorf("pm.All() without syncer = %v, want: %v", got, want)
+	}
+}
+
+// noPurgeSyncer is an ItemSyncer that doesn't implement persist.Purger.
+type noPurgeSyncer struct {
+	persist.ItemSyncer
+}
+
+func TestPatientsMap_Purge(t *testing.T) {
+	// The syncer doesn't delete patients, but purging them deletes them anyway.
+	syncer := teststate.NewItemSyncerWithDelete(false)
+	pm := NewPatientsMap(syncer, false)
+	pm.Put(testInfo1)
+	pm.Put(testInfo2)
+
+	if err := pm.Purge(testInfo1); err != nil {
+		t.Fatalf("pm.Purge(%v) failed with %v", testInfo1, err)
+	}
+	if got := pm.Get(testID); got != nil {
+		t.Errorf("pm.Get(%q) after pm.Purge() = %v, want: nil", testID, got)
+	}
+	if got, want := syncer.Count(), 1; got != want {
+		t.Errorf("syncer.Count() = %d, want: %d", got, want)
+	}
+
+	pm = NewPatientsMap(noPurgeSyncer{syncer}, false)
+	pm.Put(testInfo2)
+	if err := pm.Purge(testInfo2); err == nil {
+		t.Error("pm.Purge() with a syncer that cannot purge got nil error, want error")
+	}
+	if got := pm.Get(testID2); got == nil {
+		t.Errorf("pm.Get(%q) after a failed pm.Purge() = nil, want: the patient", testID2)
+	}
+}
+
 func TestPatientsMap_Len(t *testing.T) {
 	pm := NewPatientsMap(teststate.NewItemSyncer(), true)
 	testInfo1ID, err := testInfo1.ID()
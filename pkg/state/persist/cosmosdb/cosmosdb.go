@@ -1,291 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cosmosdb contains a thin wrapper around an Azure Cosmos DB container whose documents are
+// partitioned by item type.
 package cosmosdb
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
-	"time"
-	//"strconv"
+	"net/http"
 
-	"github.com/Arend-melissant/simhospital/pkg/state/persist"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
-	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
 )
 
-type itemData struct {
-	Id       string `json:"id"`
-	ItemType string `json:"itemType"`
-	Item     persist.MarshallableItem `json:"item"`
-	Start    int64 `json:"start"`
-	Stop     int64 `json:"stop"`
-}
+// PartitionKey is the path of the partition key of the container: the item type of the documents.
+const PartitionKey = "/itemType"
 
-type messageItemData struct {
-	Id       string `json:"id"`
-	ItemType string `json:"itemType"`
-	Item     state.HL7Message `json:"item"`
-	Start    int64 `json:"start"`
-	Stop     int64 `json:"stop"`
-}
+// throughput is the throughput, in request units per second, of the containers created by Open.
+const throughput = 400
 
-type eventItemData struct {
-	Id       string `json:"id"`
-	ItemType string `json:"itemType"`
-	Item     state.Event `json:"item"`
-	Start    int64 `json:"start"`
-	Stop     int64 `json:"stop"`
-}
+var log = logging.ForCallerPackage()
 
-type patientItemData struct {
-	Id       string `json:"id"`
-	ItemType string `json:"itemType"`
-	Item     state.Patient `json:"item"`
-	Start    int64 `json:"start"`
-	Stop     int64 `json:"stop"`
+// Container is a Cosmos DB container.
+type Container struct {
+	client *azcosmos.ContainerClient
 }
 
-func CreateDatabase(client *azcosmos.Client, databaseName string) error {
-//	databaseName := "adventureworks"
-
-	databaseProperties := azcosmos.DatabaseProperties{ID: databaseName}
-
-	// This is a helper function that swallows 409 errors
-	errorIs409 := func(err error) bool {
-		var responseErr *azcore.ResponseError
-		return err != nil && errors.As(err, &responseErr) && responseErr.StatusCode == 409
-	}
-	ctx := context.TODO()
-	databaseResp, err := client.CreateDatabase(ctx, databaseProperties, nil)
-
+// Open returns the given container of the given database, and creates them if they don't exist.
+// If the credentials are not allowed to create databases or containers, as is the case for Azure
+// Active Directory tokens, Open assumes that they exist.
+func Open(ctx context.Context, client *azcosmos.Client, database string, container string) (*Container, error) {
+	_, err := client.CreateDatabase(ctx, azcosmos.DatabaseProperties{ID: database}, nil)
 	switch {
-	case errorIs409(err):
-		log.Printf("Database [%s] already exists\n", databaseName)
+	case hasStatus(err, http.StatusConflict):
+		log.WithField("database", database).Debug("Cosmos DB database already exists")
+	case hasStatus(err, http.StatusForbidden):
+		log.WithField("database", database).Warning("Not allowed to create the Cosmos DB database; assuming that it exists")
 	case err != nil:
-		return err
+		return nil, errors.Wrapf(err, "cannot create Cosmos DB database %q", database)
 	default:
-		log.Printf("Database [%v] created. ActivityId %s\n", databaseName, databaseResp.ActivityID)
+		log.WithField("database", database).Info("Created Cosmos DB database")
 	}
-	return nil
-}
 
-func CreateContainer(client *azcosmos.Client, databaseName, containerName, partitionKey string) error {
-//	databaseName = adventureworks
-//	containerName = customer
-//	partitionKey = "/customerId"
-
-	databaseClient, err := client.NewDatabase(databaseName)
+	db, err := client.NewDatabase(database)
 	if err != nil {
-		return err
-	}
-
-	// creating a container
-	containerProperties := azcosmos.ContainerProperties{
-		ID: containerName,
-		PartitionKeyDefinition: azcosmos.PartitionKeyDefinition{
-			Paths: []string{partitionKey},
-		},
+		return nil, errors.Wrapf(err, "cannot create a client for Cosmos DB database %q", database)
 	}
-
-	// this is a helper function that swallows 409 errors
-	errorIs409 := func(err error) bool {
-		var responseErr *azcore.ResponseError
-		return err != nil && errors.As(err, &responseErr) && responseErr.StatusCode == 409
+	properties := azcosmos.ContainerProperties{
+		ID:                     container,
+		PartitionKeyDefinition: azcosmos.PartitionKeyDefinition{Paths: []string{PartitionKey}},
 	}
-
-	// setting options upon container creation
-	throughputProperties := azcosmos.NewManualThroughputProperties(400) //defaults to 400 if not set
-	options := &azcosmos.CreateContainerOptions{
-		ThroughputProperties: &throughputProperties,
-	}
-	ctx := context.TODO()
-	containerResponse, err := databaseClient.CreateContainer(ctx, containerProperties, options)
-	
+	tp := azcosmos.NewManualThroughputProperties(throughput)
+	_, err = db.CreateContainer(ctx, properties, &azcosmos.CreateContainerOptions{ThroughputProperties: &tp})
 	switch {
-	case errorIs409(err):
-		log.Printf("Container [%s] already exists\n", containerName)
+	case hasStatus(err, http.StatusConflict):
+		log.WithField("container", container).Debug("Cosmos DB container already exists")
+	case hasStatus(err, http.StatusForbidden):
+		log.WithField("container", container).Warning("Not allowed to create the Cosmos DB container; assuming that it exists")
 	case err != nil:
-		return err
+		return nil, errors.Wrapf(err, "cannot create Cosmos DB container %q", container)
 	default:
-		log.Printf("Container [%s] created. ActivityId %s\n", containerName, containerResponse.ActivityID)
+		log.WithField("container", container).Info("Created Cosmos DB container")
 	}
-	return nil
-}
 
-func CreateItem(client *azcosmos.Client, databaseName string, containerName string, itemType string, id string, item persist.MarshallableItem, start time.Time, end time.Time) error {
-	// create container client
-	containerClient, err := client.NewContainer(databaseName, containerName)
+	c, err := db.NewContainer(container)
 	if err != nil {
-		return fmt.Errorf("failed to create a container client: %s", err)
-	}
-
-	// specifies the value of the partiton key
-	pk := azcosmos.NewPartitionKeyString(itemType)
-
-	// setting the item options upon creating ie. consistency level
-	itemOptions := azcosmos.ItemOptions{
-		ConsistencyLevel: azcosmos.ConsistencyLevelSession.ToPtr(),
-	}
-
-	// this is a helper function that swallows 409 errors
-	errorIs409 := func(err error) bool {
-		var responseErr *azcore.ResponseError
-		return err != nil && errors.As(err, &responseErr) && responseErr.StatusCode == 409
-	}
-
-	data := itemData{
-		Id: id,
-		ItemType:  itemType,
-		Item:  item,
-		Start: start.Unix(),
-		Stop: end.Unix(),
-	}
-
-	marshalled, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to create data: %s", err)
-	}
-
-	ctx := context.TODO()
-	itemResponse, err := containerClient.UpsertItem(ctx, pk, marshalled, &itemOptions)
-	
-	if err != nil {
-		log.Printf("Wite to db, error = %s", err)
+		return nil, errors.Wrapf(err, "cannot create a client for Cosmos DB container %q", container)
 	}
+	return &Container{client: c}, nil
+}
 
-	switch {
-	case errorIs409(err):
-		log.Printf("Item with partitionkey value %s already exists\n", pk)
-	case err != nil:
-		return err
-	default:
-		//log.Printf("Status %d. Item %v created. ActivityId %s. Consuming %v Request Units.\n", itemResponse.RawResponse.StatusCode, pk, itemResponse.ActivityID, itemResponse.RequestCharge)
-		_ = itemResponse
-	}
-	
-	return nil
+// Upsert creates or replaces the document with the given ID and item type.
+func (c *Container) Upsert(ctx context.Context, itemType string, id string, doc []byte) error {
+	_, err := c.client.UpsertItem(ctx, azcosmos.NewPartitionKeyString(itemType), doc, nil)
+	return errors.Wrapf(err, "cannot upsert document %q of type %q", id, itemType)
 }
 
-func ReadItem(client *azcosmos.Client, databaseName string, containerName string, partitionKey string, itemId string) (persist.MarshallableItem, error) {
-	// Create container client
-	containerClient, err := client.NewContainer(databaseName, containerName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a container client: %s", err)
+// Read returns the document with the given ID and item type, or nil if it doesn't exist.
+func (c *Container) Read(ctx context.Context, itemType string, id string) ([]byte, error) {
+	resp, err := c.client.ReadItem(ctx, azcosmos.NewPartitionKeyString(itemType), id, nil)
+	if hasStatus(err, http.StatusNotFound) {
+		return nil, nil
 	}
-
-	// Specifies the value of the partiton key
-	pk := azcosmos.NewPartitionKeyString(partitionKey)
-
-	// Read an item
-	ctx := context.TODO()
-	itemResponse, err := containerClient.ReadItem(ctx, pk, itemId, nil)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "cannot read document %q of type %q", id, itemType)
 	}
-
-	var data persist.MarshallableItem
-	var dataerr error
-
-	switch(partitionKey){
-		case "HL7Message":
-			var itemResponseBody messageItemData
-			dataerr = json.Unmarshal(itemResponse.Value, &itemResponseBody)
-			if (err == nil)	{
-				data = itemResponseBody.Item
-			}
-		case "Event":
-			var itemResponseBody eventItemData
-			dataerr = json.Unmarshal(itemResponse.Value, &itemResponseBody)
-			if (err == nil)	{
-				data = itemResponseBody.Item
-			}
-		case "Patient":
-			var itemResponseBody patientItemData
-			dataerr = json.Unmarshal(itemResponse.Value, &itemResponseBody)
-			if (err == nil)	{
-				data = itemResponseBody.Item
-			}
-	}
-	if dataerr != nil {
-		return nil, dataerr
-	}
-
-	return data, nil
+	return resp.Value, nil
 }
 
-func ReadItems(client *azcosmos.Client, databaseName string, containerName string, partitionKey string) ([]persist.MarshallableItem, error) {
-	// Create container client
-	containerClient, err := client.NewContainer(databaseName, containerName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a container client: %s", err)
-	}
-
-	// Specifies the value of the partiton key
-	pk := azcosmos.NewPartitionKeyString(partitionKey)
-
-	result := make([]persist.MarshallableItem, 0)
-	ctx := context.TODO()
-	queryPager := containerClient.NewQueryItemsPager("select * from c where c.itemType = '"+ partitionKey + "' order by c.id", pk, nil)
-	for queryPager.More() {
-		queryResponse, err := queryPager.NextPage(ctx)
+// ReadAll returns all the documents of the given item type, sorted by ID.
+func (c *Container) ReadAll(ctx context.Context, itemType string) ([][]byte, error) {
+	pager := c.client.NewQueryItemsPager("SELECT * FROM c WHERE c.itemType = @itemType ORDER BY c.id",
+		azcosmos.NewPartitionKeyString(itemType),
+		&azcosmos.QueryOptions{QueryParameters: []azcosmos.QueryParameter{{Name: "@itemType", Value: itemType}}})
+	var docs [][]byte
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
 		if err != nil {
-			log.Printf("ERROR: %s", err)
+			return nil, errors.Wrapf(err, "cannot query documents of type %q", itemType)
 		}
-	
-		switch(partitionKey){
-		case "HL7Message":
-			for _, item := range queryResponse.Items {
-				var itemResponseBody messageItemData
-				json.Unmarshal(item, &itemResponseBody)
-				result = append(result, itemResponseBody.Item)
-			}
-		case "Event":
-			for _, item := range queryResponse.Items {
-				var itemResponseBody eventItemData
-				json.Unmarshal(item, &itemResponseBody)
-				result = append(result, itemResponseBody.Item)
-			}
-		case "Patient":
-			for _, item := range queryResponse.Items {
-				var itemResponseBody patientItemData
-				json.Unmarshal(item, &itemResponseBody)
-				result = append(result, itemResponseBody.Item)
-			}
-		}
-		
+		docs = append(docs, resp.Items...)
 	}
-
-	return result, nil
+	return docs, nil
 }
 
-func DeleteItem(client *azcosmos.Client, databaseName, containerName, partitionKey, itemId string) error {
-//	databaseName = "adventureworks"
-//	containerName = "customer"
-//	partitionKey = "1"
-//	itemId = "1"
-
-	// Create container client
-	containerClient, err := client.NewContainer(databaseName, containerName)
-	if err != nil {
-		return fmt.Errorf("failed to create a container client:: %s", err)
-	}
-	// Specifies the value of the partiton key
-	pk := azcosmos.NewPartitionKeyString(partitionKey)
-
-	// Delete an item
-	ctx := context.TODO()
-
-	res, err := containerClient.DeleteItem(ctx, pk, itemId, nil)
-	if err != nil {
-		return err
+// Delete deletes the document with the given ID and item type. Deleting a document that doesn't
+// exist is not an error.
+func (c *Container) Delete(ctx context.Context, itemType string, id string) error {
+	_, err := c.client.DeleteItem(ctx, azcosmos.NewPartitionKeyString(itemType), id, nil)
+	if hasStatus(err, http.StatusNotFound) {
+		return nil
 	}
+	return errors.Wrapf(err, "cannot delete document %q of type %q", id, itemType)
+}
 
-
-
-	//log.Printf("Status %d. Item %v deleted. ActivityId %s. Consuming %v Request Units.\n", res.RawResponse.StatusCode, pk, res.ActivityID, res.RequestCharge)
-	_ = res
-
-	return nil
+// hasStatus returns whether err is a response error with the given HTTP status code.
+func hasStatus(err error, code int) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == code
 }
@@ -35,6 +35,57 @@ type ItemSyncer interface {
 	LoadByID(string) (MarshallableItem, error)
 }
 
+// Purger is implemented by the ItemSyncers that can delete items permanently even if they don't
+// delete them on Delete, e.g., the syncers of patients, which keep the patients after their pathways
+// finish. It is used to enforce retention policies.
+type Purger interface {
+	// Purge deletes the item from the storage.
+	Purge(MarshallableItem) error
+}
+
+// RawItem is an item that is persisted in a marshalled form that ItemSyncers cannot interpret, e.g.,
+// because it is encrypted. ItemSyncers must only persist its ID and its marshalled form, and
+// nothing else derived from the original item.
+type RawItem struct {
+	// ItemID is the ID of the original item.
+	ItemID string
+	// StartTime and EndTime are the start and end times of the original item.
+	StartTime time.Time
+	EndTime   time.Time
+	// Data is the marshalled form of the item.
+	Data []byte
+}
+
+// Marshal returns the marshalled form of the item.
+func (i RawItem) Marshal() ([]byte, error) {
+	return i.Data, nil
+}
+
+// ID returns the ID of the original item.
+func (i RawItem) ID() (string, error) {
+	return i.ItemID, nil
+}
+
+// Start returns the start time of the original item.
+func (i RawItem) Start() time.Time {
+	return i.StartTime
+}
+
+// End returns the end time of the original item.
+func (i RawItem) End() time.Time {
+	return i.EndTime
+}
+
+// RawLoader is implemented by the ItemSyncers that can load items in their marshalled form, without
+// unmarshalling them. Together with RawItem, it allows wrapping ItemSyncers in syncers that
+// transform the marshalled items, e.g., to encrypt them.
+type RawLoader interface {
+	// LoadAllRaw returns the marshalled form of all the items, sorted by ID.
+	LoadAllRaw() ([][]byte, error)
+	// LoadRawByID returns the marshalled form of the item with the given ID, or nil if it doesn't exist.
+	LoadRawByID(id string) ([]byte, error)
+}
+
 // Unmarshaller is an interface for unmarshalling persisted items.
 type Unmarshaller interface {
 	Unmarshal([]byte) (MarshallableItem, error)
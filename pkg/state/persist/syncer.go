@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persist
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RawStore persists the marshalled form of the items of one type, e.g., in a table of a database.
+// It only contains the code that is specific to the storage; Syncer implements ItemSyncer on top of
+// it.
+type RawStore interface {
+	RawLoader
+	fmt.Stringer
+	// WriteRaw writes the marshalled form of the given item, replacing the item with the same ID if
+	// there is one. The item can be a RawItem.
+	WriteRaw(id string, data []byte, item MarshallableItem) error
+	// DeleteRaw deletes the item with the given ID, if it exists.
+	DeleteRaw(id string) error
+}
+
+// Syncer implements the ItemSyncer, Purger and RawLoader interfaces by persisting the items of one
+// type in a RawStore.
+type Syncer struct {
+	store        RawStore
+	unmarshaller Unmarshaller
+	// delete indicates whether to delete items or not.
+	delete bool
+}
+
+// NewSyncer returns a syncer that persists items in the given store and unmarshals them with the
+// given unmarshaller. If delete is false, Delete doesn't delete the items, but Purge does.
+func NewSyncer(store RawStore, unmarshaller Unmarshaller, delete bool) *Syncer {
+	return &Syncer{store: store, unmarshaller: unmarshaller, delete: delete}
+}
+
+// Write writes an item to the store, replacing the item with the same ID if there is one.
+func (s *Syncer) Write(item MarshallableItem) error {
+	id, err := item.ID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get ID")
+	}
+	b, err := item.Marshal()
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal item %q", id)
+	}
+	return s.store.WriteRaw(id, b, item)
+}
+
+// Delete deletes an item from the store, if the syncer deletes items.
+func (s *Syncer) Delete(item MarshallableItem) error {
+	if !s.delete {
+		return nil
+	}
+	return s.Purge(item)
+}
+
+// Purge deletes an item from the store, even if the syncer doesn't delete items.
+func (s *Syncer) Purge(item MarshallableItem) error {
+	id, err := item.ID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get ID")
+	}
+	return s.store.DeleteRaw(id)
+}
+
+// LoadAll returns a slice of all the items in the store, sorted by id.
+func (s *Syncer) LoadAll() ([]MarshallableItem, error) {
+	raw, err := s.store.LoadAllRaw()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]MarshallableItem, len(raw))
+	for i, b := range raw {
+		if items[i], err = s.unmarshaller.Unmarshal(b); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal item from %s", s.store)
+		}
+	}
+	return items, nil
+}
+
+// LoadByID returns the item in the store with the provided id, or nil if it doesn't exist.
+func (s *Syncer) LoadByID(id string) (MarshallableItem, error) {
+	b, err := s.store.LoadRawByID(id)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	item, err := s.unmarshaller.Unmarshal(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal item %q from %s", id, s.store)
+	}
+	return item, nil
+}
+
+// LoadAllRaw returns the marshalled form of all the items in the store, sorted by id.
+func (s *Syncer) LoadAllRaw() ([][]byte, error) {
+	return s.store.LoadAllRaw()
+}
+
+// LoadRawByID returns the marshalled form of the item in the store with the provided id, or nil if
+// it doesn't exist.
+func (s *Syncer) LoadRawByID(id string) ([]byte, error) {
+	return s.store.LoadRawByID(id)
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persist_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	. "github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/google/go-cmp/cmp"
+)
+
+// mapStore is a RawStore that keeps the items in memory.
+type mapStore map[string][]byte
+
+func (s mapStore) String() string {
+	return "map store"
+}
+
+func (s mapStore) WriteRaw(id string, data []byte, _ MarshallableItem) error {
+	s[id] = data
+	return nil
+}
+
+func (s mapStore) DeleteRaw(id string) error {
+	delete(s, id)
+	return nil
+}
+
+func (s mapStore) LoadAllRaw() ([][]byte, error) {
+	var ids []string
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var raw [][]byte
+	for _, id := range ids {
+		raw = append(raw, s[id])
+	}
+	return raw, nil
+}
+
+func (s mapStore) LoadRawByID(id string) ([]byte, error) {
+	return s[id], nil
+}
+
+// rawUnmarshaller unmarshals items whose marshalled form is their ID.
+type rawUnmarshaller struct{}
+
+func (rawUnmarshaller) Unmarshal(b []byte) (MarshallableItem, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty item")
+	}
+	return RawItem{ItemID: string(b), Data: b}, nil
+}
+
+func item(id string) RawItem {
+	return RawItem{ItemID: id, Data: []byte(id)}
+}
+
+func TestSyncer(t *testing.T) {
+	cases := []struct {
+		name   string
+		delete bool
+		want   []MarshallableItem
+	}{
+		{name: "delete", delete: true, want: []MarshallableItem{item("a")}},
+		{name: "no delete", delete: false, want: []MarshallableItem{item("a"), item("b")}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSyncer(mapStore{}, rawUnmarshaller{}, tc.delete)
+			for _, id := range []string{"c", "b", "a"} {
+				if err := s.Write(item(id)); err != nil {
+					t.Fatalf("Write(%q) failed with %v", id, err)
+				}
+			}
+			if err := s.Delete(item("b")); err != nil {
+				t.Fatalf("Delete(b) failed with %v", err)
+			}
+			if err := s.Purge(item("c")); err != nil {
+				t.Fatalf("Purge(c) failed with %v", err)
+			}
+			got, err := s.LoadAll()
+			if err != nil {
+				t.Fatalf("LoadAll() failed with %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("LoadAll() -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncerLoadByID(t *testing.T) {
+	s := NewSyncer(mapStore{}, rawUnmarshaller{}, true)
+	if err := s.Write(item("a")); err != nil {
+		t.Fatalf("Write(a) failed with %v", err)
+	}
+	got, err := s.LoadByID("a")
+	if err != nil {
+		t.Fatalf("LoadByID(a) failed with %v", err)
+	}
+	if diff := cmp.Diff(item("a"), got); diff != "" {
+		t.Errorf("LoadByID(a) -want, +got:\n%s", diff)
+	}
+	got, err = s.LoadByID("missing")
+	if err != nil {
+		t.Fatalf("LoadByID(missing) failed with %v", err)
+	}
+	if got != nil {
+		t.Errorf("LoadByID(missing) got %v, want nil", got)
+	}
+}
+
+func TestSyncerLoadAll_UnmarshalError(t *testing.T) {
+	store := mapStore{"a": nil}
+	s := NewSyncer(store, rawUnmarshaller{}, true)
+	if _, err := s.LoadAll(); err == nil {
+		t.Error("LoadAll() got nil error, want non-nil")
+	}
+}
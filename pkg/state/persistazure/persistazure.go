@@ -1,172 +1,253 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package persistazure contains an implementation of persist.ItemSyncer that persists items in
+// Azure Cosmos DB.
 package persistazure
 
 import (
-	//"encoding/json"
-	//"sort"
+	"context"
+	"encoding/json"
+	"os"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
 	"github.com/pkg/errors"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/state"
 	"github.com/Arend-melissant/simhospital/pkg/state/persist"
 	"github.com/Arend-melissant/simhospital/pkg/state/persist/cosmosdb"
-
-	//"github.com/Azure/azure-sdk-for-go/sdk/azcore"
-	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
-	//bolt "github.com/coreos/bbolt"
 )
 
-var endpoint = "https://calidosfhircosmosdb.documents.azure.com:443/"
-var key = "RAjirMQfTXKPRiBuK1Jpz6V44Kw0AGAQXL2yN9P0206BN27Rn6cBimRbahIFlnuv23dFYFAHgMtpGFqi0hUVmw=="
-var databaseName = "calidosfhirconvert"
-var containerName = "Data"
-var partitionKey = "/itemType"
-
-var client *azcosmos.Client 
+// Environment variables that the connection details are read from if they are not set explicitly.
+const (
+	EndpointEnv = "SIMHOSPITAL_COSMOS_ENDPOINT"
+	KeyEnv      = "SIMHOSPITAL_COSMOS_KEY"
+	TokenEnv    = "SIMHOSPITAL_COSMOS_TOKEN"
+)
 
 const (
-	MessageSyncer int = 0
-	EventSyncer       = 1
-	PatientSyncer     = 2
+	// requestTimeout is the timeout of each request to Cosmos DB.
+	requestTimeout = 30 * time.Second
 )
 
 var log = logging.ForCallerPackage()
 
-// ItemSyncer implements the persist.ItemSyncer interface using a map.
-// It tracks the LoadByID requests made to the syncer in the form of an
-// internal `reqs` map for testing purposes.
-type DbItemSyncer struct {
-	m    map[string]persist.MarshallableItem
-	// delete indicates whether to delete items or not.
-	delete   bool
-	nDeletes int
-	syncType int
+// documentTypes maps the item types to the itemType field of their documents, which is also their
+// partition key.
+var documentTypes = map[string]string{
+	state.MessageItemType: "HL7Message",
+	state.EventItemType:   "Event",
+	state.PatientItemType: "Patient",
 }
 
-func serviceInit() { 
-	cred, err := azcosmos.NewKeyCredential(key)
-	if err != nil {
-		log.Fatal("Failed to create a credential: ", err)
-	}
+// Config is the configuration to connect to Cosmos DB.
+type Config struct {
+	// Endpoint is the URL of the Cosmos DB account, e.g., https://myaccount.documents.azure.com:443/.
+	Endpoint string
+	// Key is the key of the Cosmos DB account. Exactly one of Key and Token must be set.
+	Key string
+	// Token is an Azure Active Directory access token for the Cosmos DB account, for instance,
+	// obtained with "az account get-access-token --resource <Endpoint>".
+	// The token cannot be refreshed: once it expires, usually after an hour, all the requests to
+	// Cosmos DB fail, so tokens are only suitable for short runs.
+	// Exactly one of Key and Token must be set.
+	Token string
+	// Database is the name of the database. It is created if it doesn't exist.
+	Database string
+	// Container is the name of the container, in Database. It is created if it doesn't exist.
+	Container string
+}
 
-	var clientErr error
-	// Create a CosmosDB client
-	client, clientErr = azcosmos.NewClientWithKey(endpoint, cred, nil)
-	if clientErr != nil {
-		log.Fatal("Failed to create cosmos db client: ", err)
+// WithEnvDefaults returns a copy of the config where the Endpoint, Key and Token that are not set
+// are read from the EndpointEnv, KeyEnv and TokenEnv environment variables. The Key and Token are
+// only read from the environment if neither of them is set.
+func (c Config) WithEnvDefaults() Config {
+	if c.Endpoint == "" {
+		c.Endpoint = os.Getenv(EndpointEnv)
 	}
-	
-	err = cosmosdb.CreateDatabase(client, databaseName)
-	if err != nil {
-		log.Fatal("createDatabase failed: %s\n", err)
+	if c.Key == "" && c.Token == "" {
+		c.Key = os.Getenv(KeyEnv)
+		c.Token = os.Getenv(TokenEnv)
 	}
+	return c
+}
 
-	err = cosmosdb.CreateContainer(client, databaseName, containerName, partitionKey)
-	if err != nil {
-		log.Fatal("CreateContainer failed: %s\n", err)
+func (c Config) validate() error {
+	switch {
+	case c.Endpoint == "":
+		return errors.Errorf("the Cosmos DB endpoint is not set; set it explicitly or with the %s environment variable", EndpointEnv)
+	case c.Key == "" && c.Token == "":
+		return errors.Errorf("neither a Cosmos DB key nor a token is set; set one of them explicitly or with the %s or %s environment variables", KeyEnv, TokenEnv)
+	case c.Key != "" && c.Token != "":
+		return errors.New("only one of a Cosmos DB key and a token can be set")
+	case c.Database == "":
+		return errors.New("the Cosmos DB database is not set")
+	case c.Container == "":
+		return errors.New("the Cosmos DB container is not set")
 	}
-
+	return nil
 }
 
-func open(tabel string) { 
+// Store is where the syncers persist their items, as JSON documents partitioned by item type.
+// *cosmosdb.Container implements Store.
+type Store interface {
+	// Upsert creates or replaces the document with the given ID and item type.
+	Upsert(ctx context.Context, itemType string, id string, doc []byte) error
+	// Read returns the document with the given ID and item type, or nil if it doesn't exist.
+	Read(ctx context.Context, itemType string, id string) ([]byte, error)
+	// ReadAll returns all the documents of the given item type, sorted by ID.
+	ReadAll(ctx context.Context, itemType string) ([][]byte, error)
+	// Delete deletes the document with the given ID and item type, if it exists.
+	Delete(ctx context.Context, itemType string, id string) error
 }
 
-// NewItemSyncer initializes the ItemSyncer.
-func NewItemSyncer(syncType int) *DbItemSyncer {
-	serviceInit()
-	return &DbItemSyncer{m: map[string]persist.MarshallableItem{}, syncType: syncType}
+// Open connects to the Cosmos DB container with the given configuration, creating the database and
+// the container if they don't exist.
+func Open(ctx context.Context, c Config) (*cosmosdb.Container, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	var client *azcosmos.Client
+	if c.Key != "" {
+		cred, err := azcosmos.NewKeyCredential(c.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid Cosmos DB key")
+		}
+		if client, err = azcosmos.NewClientWithKey(c.Endpoint, cred, nil); err != nil {
+			return nil, errors.Wrap(err, "cannot create Cosmos DB client")
+		}
+	} else {
+		cred, err := newTokenCredential(c.Token, time.Now)
+		if err != nil {
+			return nil, err
+		}
+		if client, err = azcosmos.NewClient(c.Endpoint, cred, nil); err != nil {
+			return nil, errors.Wrap(err, "cannot create Cosmos DB client")
+		}
+		log.WithField("expires_on", cred.expiresOn).
+			Warning("Connecting to Cosmos DB with an access token that cannot be refreshed; the requests will fail once it expires")
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	container, err := cosmosdb.Open(ctx, client, c.Database, c.Container)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open Cosmos DB container at %s", c.Endpoint)
+	}
+	log.WithField("endpoint", c.Endpoint).
+		WithField("database", c.Database).
+		WithField("container", c.Container).
+		Info("Connected to Cosmos DB")
+	return container, nil
 }
 
-// NewItemSyncerWithDelete initializes the ItemSyncer with a value for whether to delete items or not.
-func NewItemSyncerWithDelete(syncType int, delete bool) *DbItemSyncer {
-	return &DbItemSyncer{m: map[string]persist.MarshallableItem{}, delete: delete, syncType: syncType}
+// document is the JSON document in which an item is persisted.
+type document struct {
+	ID       string          `json:"id"`
+	ItemType string          `json:"itemType"`
+	Item     json.RawMessage `json:"item"`
+	Start    int64           `json:"start"`
+	Stop     int64           `json:"stop"`
 }
 
-func (s *DbItemSyncer) getSyncType() string {
-	var str string 
-	str = "unknown"
-
-	switch s.syncType {
-	case PatientSyncer:
-		str = "Patient" 
-	case EventSyncer:
-		str = "Event" 
-	case MessageSyncer:
-		str = "HL7Message" 
-		default:
+// NewItemSyncer returns a syncer of items of the given type, which must be one of
+// state.MessageItemType, state.EventItemType or state.PatientItemType, that persists them as
+// documents in the given store.
+func NewItemSyncer(store Store, itemType string, delete bool) (*persist.Syncer, error) {
+	docType, ok := documentTypes[itemType]
+	if !ok {
+		return nil, errors.Errorf("unsupported item type %q", itemType)
 	}
+	return state.NewItemSyncer(&documents{store: store, docType: docType}, itemType, delete)
+}
+
+// NewItemSyncers returns the syncers of all the item types, keyed by item type, that persist them
+// as documents in the given store; see state.NewItemSyncers.
+func NewItemSyncers(store Store) map[string]persist.ItemSyncer {
+	return state.NewItemSyncers(func(itemType string) persist.RawStore {
+		return &documents{store: store, docType: documentTypes[itemType]}
+	})
+}
 
-	return str
+// documents implements persist.RawStore with the documents of one type in a Store.
+type documents struct {
+	store   Store
+	docType string
 }
 
-//func storeItem(s *DbItemSyncer, )
+func (d *documents) String() string {
+	return d.docType + " documents"
+}
 
-// Write writes an item to the map.
-func (s *DbItemSyncer) Write(item persist.MarshallableItem) error {
-	str := s.getSyncType()
-	id, err := item.ID()
+// WriteRaw writes an item to the store, wrapped in a document.
+func (d *documents) WriteRaw(id string, data []byte, item persist.MarshallableItem) error {
+	doc, err := json.Marshal(document{
+		ID:       id,
+		ItemType: d.docType,
+		Item:     data,
+		Start:    item.Start().Unix(),
+		Stop:     item.End().Unix(),
+	})
 	if err != nil {
-		return errors.Wrap(err, "cannot get ID")
+		return errors.Wrapf(err, "cannot marshal document %q", id)
 	}
-	log.Infof("PersistDB: WRITE - %s - %s - %d - %d",str,id, item.Start().Unix(), item.End().Unix())
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return d.store.Upsert(ctx, d.docType, id, doc)
+}
 
-	cosmosdb.CreateItem(client, databaseName, containerName, str, id, item /* []byte(b)*/, item.Start(), item.End())
-	return nil
+// DeleteRaw deletes the document with the given ID from the store.
+func (d *documents) DeleteRaw(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return d.store.Delete(ctx, d.docType, id)
 }
 
-// Delete deletes an item from the map.
-func (s *DbItemSyncer) Delete(item persist.MarshallableItem) error {
-	if !s.delete {
-		return nil
-	}
-	str := s.getSyncType()
-	s.nDeletes++
-	id, err := item.ID()
+// LoadAllRaw returns the marshalled form of all the items in the store, sorted by id.
+func (d *documents) LoadAllRaw() ([][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	docs, err := d.store.ReadAll(ctx, d.docType)
 	if err != nil {
-		return errors.Wrap(err, "cannot get ID")
+		return nil, err
 	}
-	log.Infof("PersistDB: DELETE - %s - %s",str,id)
-
-	err = cosmosdb.DeleteItem(client, databaseName, containerName, str, id)
-
-	return err
-}
-
-// LoadAll returns a slice of all the items in the map, sorted by id.
-func (s *DbItemSyncer) LoadAll() ([]persist.MarshallableItem, error) {
-	str := s.getSyncType()
-	switch str {
-		case "Patient":
-			data, _ := cosmosdb.ReadItems(client, databaseName, containerName, str)
-			return data, nil
-		case "Event":
-			data, _ := cosmosdb.ReadItems(client, databaseName, containerName, str)
-			return data, nil
-		case "HL7Message":
-			data, _ := cosmosdb.ReadItems(client, databaseName, containerName, str)
-			return data, nil
+	raw := make([][]byte, len(docs))
+	for i, doc := range docs {
+		if raw[i], err = d.item(doc); err != nil {
+			return nil, err
 		}
-	return nil, nil
-}
-
-// LoadByID returns an item in the map with the provided id, if it exists.
-func (s *DbItemSyncer) LoadByID(id string) (persist.MarshallableItem, error) {
-	str := s.getSyncType()
-	log.Infof("PersistDB: LOADBYID - %s - %s",str,id)
-	
-	dat, err := cosmosdb.ReadItem(client, databaseName, containerName, str, id)
-	return dat, err
+	}
+	return raw, nil
 }
 
-// Count returns number of elements in the syncer for testing.
-func (s *DbItemSyncer) Count() int {
-	//str := s.getSyncType()
-    //fmt.Println("PersistDB: COUNT " + str)
-	return len(s.m)
+// LoadRawByID returns the marshalled form of the item in the store with the provided id, or nil if
+// it doesn't exist.
+func (d *documents) LoadRawByID(id string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	doc, err := d.store.Read(ctx, d.docType, id)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return d.item(doc)
 }
 
-// CountDeletes returns the number of deletions requested.
-func (s *DbItemSyncer) CountDeletes() int {
-	//str := s.getSyncType()
-    //fmt.Println("PersistDB: COUNTDELETES " + str)
-	return s.nDeletes
+// item returns the marshalled item in the given document.
+func (d *documents) item(b []byte) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal %s document", d.docType)
+	}
+	return doc.Item, nil
 }
-
@@ -0,0 +1,282 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistazure_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	. "github.com/Arend-melissant/simhospital/pkg/state/persistazure"
+)
+
+// fakeStore is an in-memory Store that, like Cosmos DB, requires the documents to have an "id" and
+// an "itemType" that match the ID and the partition they are stored in.
+type fakeStore struct {
+	docs map[string]map[string][]byte
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{docs: map[string]map[string][]byte{}}
+}
+
+func (s *fakeStore) Upsert(_ context.Context, itemType string, id string, doc []byte) error {
+	var keys struct {
+		ID       string `json:"id"`
+		ItemType string `json:"itemType"`
+	}
+	if err := json.Unmarshal(doc, &keys); err != nil {
+		return err
+	}
+	if keys.ID != id || keys.ItemType != itemType {
+		return fmt.Errorf("document has id %q and itemType %q, want %q and %q", keys.ID, keys.ItemType, id, itemType)
+	}
+	if s.docs[itemType] == nil {
+		s.docs[itemType] = map[string][]byte{}
+	}
+	s.docs[itemType][id] = doc
+	return nil
+}
+
+func (s *fakeStore) Read(_ context.Context, itemType string, id string) ([]byte, error) {
+	return s.docs[itemType][id], nil
+}
+
+func (s *fakeStore) ReadAll(_ context.Context, itemType string) ([][]byte, error) {
+	var ids []string
+	for id := range s.docs[itemType] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var docs [][]byte
+	for _, id := range ids {
+		docs = append(docs, s.docs[itemType][id])
+	}
+	return docs, nil
+}
+
+func (s *fakeStore) Delete(_ context.Context, itemType string, id string) error {
+	delete(s.docs[itemType], id)
+	return nil
+}
+
+func TestItemSyncers(t *testing.T) {
+	testItemSyncers(t, newFakeStore())
+}
+
+// TestItemSyncers_Emulator runs against the Cosmos DB emulator or a real Cosmos DB account, whose
+// connection details are set in the environment variables; it is skipped if they are not set.
+func TestItemSyncers_Emulator(t *testing.T) {
+	if os.Getenv(EndpointEnv) == "" {
+		t.Skipf("%s is not set", EndpointEnv)
+	}
+	ctx := context.Background()
+	c := Config{
+		Database:  "simhospital-test",
+		Container: fmt.Sprintf("test-%d", time.Now().UnixNano()),
+	}.WithEnvDefaults()
+	store, err := Open(ctx, c)
+	if err != nil {
+		t.Fatalf("Open(%+v) failed with %v", c, err)
+	}
+	testItemSyncers(t, store)
+}
+
+func testItemSyncers(t *testing.T, store Store) {
+	t.Helper()
+	now := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		itemType   string
+		items      []persist.MarshallableItem
+		wantDelete bool
+	}{{
+		itemType: state.EventItemType,
+		items: []persist.MarshallableItem{
+			state.Event{EventTime: now, PathwayName: "pathway1", PatientMRN: "1"},
+			state.Event{EventTime: now.Add(time.Hour), PathwayName: "pathway2", PatientMRN: "2"},
+		},
+		wantDelete: true,
+	}, {
+		itemType: state.MessageItemType,
+		items: []persist.MarshallableItem{
+			state.HL7Message{Name: "ADT^A01", MessageTime: now, PathwayName: "pathway1"},
+			state.HL7Message{Name: "ADT^A03", MessageTime: now.Add(time.Hour), PathwayName: "pathway1"},
+		},
+		wantDelete: true,
+	}, {
+		itemType: state.PatientItemType,
+		items: []persist.MarshallableItem{
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1", FirstName: "Jane"}}},
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "2", FirstName: "John"}}},
+		},
+		wantDelete: false,
+	}}
+
+	syncers := NewItemSyncers(store)
+	for _, tc := range cases {
+		t.Run(tc.itemType, func(t *testing.T) {
+			s := syncers[tc.itemType]
+			if s == nil {
+				t.Fatalf("NewItemSyncers()[%q] got nil, want a syncer", tc.itemType)
+			}
+			ids := make([]string, len(tc.items))
+			for i, item := range tc.items {
+				if err := s.Write(item); err != nil {
+					t.Fatalf("Write(%v) failed with %v", item, err)
+				}
+				ids[i], _ = item.ID()
+			}
+
+			for i, item := range tc.items {
+				got, err := s.LoadByID(ids[i])
+				if err != nil {
+					t.Fatalf("LoadByID(%q) failed with %v", ids[i], err)
+				}
+				if diff := cmp.Diff(item, got, cmpopts.IgnoreUnexported(pathway.Step{})); diff != "" {
+					t.Errorf("LoadByID(%q) diff (-want, +got):\n%s", ids[i], diff)
+				}
+			}
+			if got, err := s.LoadByID("unknown"); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+			}
+
+			all, err := s.LoadAll()
+			if err != nil {
+				t.Fatalf("LoadAll() failed with %v", err)
+			}
+			if got, want := len(all), len(tc.items); got != want {
+				t.Errorf("len(LoadAll()) got %d, want %d", got, want)
+			}
+
+			if err := s.Delete(tc.items[0]); err != nil {
+				t.Fatalf("Delete(%v) failed with %v", tc.items[0], err)
+			}
+			got, err := s.LoadByID(ids[0])
+			if err != nil {
+				t.Fatalf("LoadByID(%q) failed with %v", ids[0], err)
+			}
+			if deleted := got == nil; deleted != tc.wantDelete {
+				t.Errorf("LoadByID(%q) after Delete() got %v, want deleted=%t", ids[0], got, tc.wantDelete)
+			}
+
+			// Purge deletes the item even if Delete doesn't.
+			if err := s.(persist.Purger).Purge(tc.items[0]); err != nil {
+				t.Fatalf("Purge(%v) failed with %v", tc.items[0], err)
+			}
+			if got, err := s.LoadByID(ids[0]); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) after Purge() got (%v, %v), want (nil, nil)", ids[0], got, err)
+			}
+		})
+	}
+}
+
+func TestItemSyncer_RawItems(t *testing.T) {
+	s, err := NewItemSyncer(newFakeStore(), state.PatientItemType, false)
+	if err != nil {
+		t.Fatalf("NewItemSyncer() failed with %v", err)
+	}
+	// Documents can only contain JSON items.
+	item := persist.RawItem{ItemID: "1", Data: []byte(`{"opaque":true}`)}
+	if err := s.Write(item); err != nil {
+		t.Fatalf("Write(%v) failed with %v", item, err)
+	}
+	got, err := s.LoadRawByID("1")
+	if err != nil {
+		t.Fatalf("LoadRawByID(%q) failed with %v", "1", err)
+	}
+	if diff := cmp.Diff(item.Data, got); diff != "" {
+		t.Errorf("LoadRawByID(%q) diff (-want, +got):\n%s", "1", diff)
+	}
+	if got, err := s.LoadRawByID("unknown"); err != nil || got != nil {
+		t.Errorf("LoadRawByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+	}
+	all, err := s.LoadAllRaw()
+	if err != nil {
+		t.Fatalf("LoadAllRaw() failed with %v", err)
+	}
+	if diff := cmp.Diff([][]byte{item.Data}, all); diff != "" {
+		t.Errorf("LoadAllRaw() diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestNewItemSyncer_UnknownType(t *testing.T) {
+	if _, err := NewItemSyncer(newFakeStore(), "unknown", true); err == nil {
+		t.Error("NewItemSyncer(unknown) got nil error, want non nil")
+	}
+}
+
+func TestConfig_WithEnvDefaults(t *testing.T) {
+	t.Setenv(EndpointEnv, "https://env:443/")
+	t.Setenv(KeyEnv, "env-key")
+	t.Setenv(TokenEnv, "")
+
+	cases := []struct {
+		name string
+		c    Config
+		want Config
+	}{{
+		name: "not set",
+		c:    Config{},
+		want: Config{Endpoint: "https://env:443/", Key: "env-key"},
+	}, {
+		name: "set explicitly",
+		c:    Config{Endpoint: "https://flag:443/", Key: "flag-key"},
+		want: Config{Endpoint: "https://flag:443/", Key: "flag-key"},
+	}, {
+		name: "token set explicitly",
+		c:    Config{Token: "flag-token"},
+		want: Config{Endpoint: "https://env:443/", Token: "flag-token"},
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.c.WithEnvDefaults()); diff != "" {
+				t.Errorf("WithEnvDefaults() diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOpen_InvalidConfig(t *testing.T) {
+	valid := Config{Endpoint: "https://localhost:8081/", Key: "a2V5", Database: "db", Container: "c"}
+	cases := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{name: "no endpoint", modify: func(c *Config) { c.Endpoint = "" }},
+		{name: "no credentials", modify: func(c *Config) { c.Key = "" }},
+		{name: "key and token", modify: func(c *Config) { c.Token = "token" }},
+		{name: "no database", modify: func(c *Config) { c.Database = "" }},
+		{name: "no container", modify: func(c *Config) { c.Container = "" }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := valid
+			tc.modify(&c)
+			if _, err := Open(context.Background(), c); err == nil {
+				t.Errorf("Open(%+v) got nil error, want non nil", c)
+			}
+		})
+	}
+}
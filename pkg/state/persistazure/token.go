@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistazure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/pkg/errors"
+)
+
+// tokenCredential is an azcore.TokenCredential that always returns the same access token.
+// The token cannot be refreshed, so once it expires all the requests to Cosmos DB fail.
+type tokenCredential struct {
+	token     string
+	expiresOn time.Time
+	now       func() time.Time
+}
+
+// newTokenCredential returns a credential with the given token, which must be a JSON Web Token
+// that hasn't expired, as the ones issued by Azure Active Directory.
+func newTokenCredential(token string, now func() time.Time) (*tokenCredential, error) {
+	expiresOn, err := tokenExpiry(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Cosmos DB token")
+	}
+	c := &tokenCredential{token: token, expiresOn: expiresOn, now: now}
+	if c.expired() {
+		return nil, c.expiredError()
+	}
+	return c, nil
+}
+
+// GetToken returns the token, or an error if it has expired.
+func (c *tokenCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if c.expired() {
+		return azcore.AccessToken{}, c.expiredError()
+	}
+	return azcore.AccessToken{Token: c.token, ExpiresOn: c.expiresOn}, nil
+}
+
+func (c *tokenCredential) expired() bool {
+	return !c.now().Before(c.expiresOn)
+}
+
+func (c *tokenCredential) expiredError() error {
+	return errors.Errorf("the Cosmos DB token expired at %s and cannot be refreshed; use a new token, or use a key for long runs",
+		c.expiresOn.Format(time.RFC3339))
+}
+
+// tokenExpiry returns the expiry time in the "exp" claim of the given JSON Web Token.
+// The signature of the token is not verified: Cosmos DB verifies it.
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("the token is not a JSON Web Token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "cannot decode the payload of the token")
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, errors.Wrap(err, "cannot unmarshal the claims of the token")
+	}
+	if claims.Exp == nil {
+		return time.Time{}, errors.New("the token has no expiry time")
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid expiry time %q", *claims.Exp)
+	}
+	return time.Unix(int64(exp), 0), nil
+}
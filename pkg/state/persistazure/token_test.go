@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistazure
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// jwt returns a JSON Web Token with the given claims and a fake signature.
+func jwt(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".c2ln"
+}
+
+func TestTokenCredential(t *testing.T) {
+	expiresOn := time.Date(2020, 2, 12, 1, 0, 0, 0, time.UTC)
+	token := jwt(`{"aud":"https://localhost:8081","exp":1581469200}`)
+	now := expiresOn.Add(-time.Hour)
+	c, err := newTokenCredential(token, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("newTokenCredential() failed with %v", err)
+	}
+
+	got, err := c.GetToken(context.Background(), policy.TokenRequestOptions{})
+	if err != nil {
+		t.Fatalf("GetToken() failed with %v", err)
+	}
+	if got.Token != token {
+		t.Errorf("GetToken().Token=%q, want %q", got.Token, token)
+	}
+	if !got.ExpiresOn.Equal(expiresOn) {
+		t.Errorf("GetToken().ExpiresOn=%v, want %v", got.ExpiresOn, expiresOn)
+	}
+
+	now = expiresOn
+	if _, err := c.GetToken(context.Background(), policy.TokenRequestOptions{}); err == nil {
+		t.Error("GetToken() after the token expired got nil error, want non nil")
+	}
+}
+
+func TestNewTokenCredential_Invalid(t *testing.T) {
+	now := time.Date(2020, 2, 12, 1, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{name: "not a JWT", token: "token"},
+		{name: "invalid payload", token: "a.!!!.c"},
+		{name: "payload is not JSON", token: jwt("exp")},
+		{name: "no expiry", token: jwt(`{"aud":"https://localhost:8081"}`)},
+		{name: "invalid expiry", token: jwt(`{"exp":"tomorrow"}`)},
+		{name: "expired", token: jwt(`{"exp":1581469200}`)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newTokenCredential(tc.token, func() time.Time { return now }); err == nil {
+				t.Errorf("newTokenCredential(%q) got nil error, want non nil", tc.token)
+			}
+		})
+	}
+}
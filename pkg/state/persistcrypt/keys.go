@@ -0,0 +1,132 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeySize is the size of the keys, in bytes: the items are encrypted with AES-256.
+const KeySize = 32
+
+// KeysEnv is the environment variable that the keys are read from if they are not read from a
+// file. Keys are secrets, and shouldn't be set in the command line.
+const KeysEnv = "SIMHOSPITAL_ENCRYPTION_KEYS"
+
+// Key is an encryption key.
+type Key struct {
+	// ID identifies the key. It is stored with every item encrypted with the key, so that the item
+	// can be decrypted after other keys are added. It must not be empty nor contain ":" or ",".
+	ID string
+	// Key is the key, of KeySize bytes.
+	Key []byte
+}
+
+// Keyring is a set of keys, one of which is the primary key. Items are encrypted with the primary
+// key, and can be decrypted with any key. To rotate the keys, make a new key the primary key and
+// keep the old keys until all the items have been encrypted again with the new key.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring returns a keyring with the given keys. The first key is the primary key.
+func NewKeyring(keys []Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("there are no keys")
+	}
+	k := &Keyring{primary: keys[0].ID, aeads: map[string]cipher.AEAD{}}
+	for _, key := range keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, ":,") {
+			return nil, errors.Errorf("invalid key ID %q: it must not be empty nor contain ':' or ','", key.ID)
+		}
+		if _, ok := k.aeads[key.ID]; ok {
+			return nil, errors.Errorf("duplicate key ID %q", key.ID)
+		}
+		if len(key.Key) != KeySize {
+			return nil, errors.Errorf("invalid key %q: got %d bytes, want %d", key.ID, len(key.Key), KeySize)
+		}
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key %q", key.ID)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key %q", key.ID)
+		}
+		k.aeads[key.ID] = aead
+	}
+	return k, nil
+}
+
+// ParseKeyring parses a keyring in the format "id1:key1,id2:key2", where the keys are encoded in
+// standard base64, e.g., as generated with "openssl rand -base64 32". Keys can also be separated by
+// new lines, and lines that start with "#" are ignored. The first key is the primary key.
+func ParseKeyring(s string) (*Keyring, error) {
+	var keys []Key
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			parts := strings.SplitN(entry, ":", 2)
+			if len(parts) != 2 {
+				// The entry is not logged, since it might be a key.
+				return nil, errors.Errorf("invalid key number %d: want id:key", len(keys)+1)
+			}
+			b, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, errors.Errorf("invalid key %q: it is not encoded in base64", parts[0])
+			}
+			keys = append(keys, Key{ID: parts[0], Key: b})
+		}
+	}
+	return NewKeyring(keys)
+}
+
+// LoadKeyring loads the keyring in the given file, in the format of ParseKeyring.
+func LoadKeyring(file string) (*Keyring, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read key file %s", file)
+	}
+	k, err := ParseKeyring(string(b))
+	return k, errors.Wrapf(err, "invalid key file %s", file)
+}
+
+// KeyringFromEnv returns the keyring in the KeysEnv environment variable, in the format of
+// ParseKeyring, or nil if the variable is not set.
+func KeyringFromEnv() (*Keyring, error) {
+	s := os.Getenv(KeysEnv)
+	if s == "" {
+		return nil, nil
+	}
+	k, err := ParseKeyring(s)
+	return k, errors.Wrapf(err, "invalid %s environment variable", KeysEnv)
+}
+
+// PrimaryKeyID returns the ID of the primary key.
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
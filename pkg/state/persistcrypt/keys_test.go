@@ -0,0 +1,128 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistcrypt_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/Arend-melissant/simhospital/pkg/state/persistcrypt"
+)
+
+// testKey returns a key of KeySize bytes with all the bytes set to b, encoded in base64.
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, KeySize))
+}
+
+func TestParseKeyring(t *testing.T) {
+	cases := []struct {
+		name        string
+		s           string
+		wantPrimary string
+		wantErr     bool
+	}{{
+		name:        "one key",
+		s:           "k1:" + testKey(1),
+		wantPrimary: "k1",
+	}, {
+		name:        "comma separated",
+		s:           "k2:" + testKey(2) + ", k1:" + testKey(1),
+		wantPrimary: "k2",
+	}, {
+		name:        "new line separated with comments",
+		s:           "# Rotated on 2020-02-12.\nk2:" + testKey(2) + "\n\n# Old key.\nk1:" + testKey(1) + "\n",
+		wantPrimary: "k2",
+	}, {
+		name:    "empty",
+		s:       "# No keys.\n",
+		wantErr: true,
+	}, {
+		name:    "no ID",
+		s:       testKey(1),
+		wantErr: true,
+	}, {
+		name:    "empty ID",
+		s:       ":" + testKey(1),
+		wantErr: true,
+	}, {
+		name:    "not base64",
+		s:       "k1:not-base64!",
+		wantErr: true,
+	}, {
+		name:    "short key",
+		s:       "k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		wantErr: true,
+	}, {
+		name:    "duplicate ID",
+		s:       "k1:" + testKey(1) + ",k1:" + testKey(2),
+		wantErr: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			k, err := ParseKeyring(tc.s)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseKeyring(%q) got err %v, want error: %t", tc.s, err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := k.PrimaryKeyID(); got != tc.wantPrimary {
+				t.Errorf("PrimaryKeyID() got %q, want %q", got, tc.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(file, []byte("k2:"+testKey(2)+"\nk1:"+testKey(1)+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile(%q) failed with %v", file, err)
+	}
+	k, err := LoadKeyring(file)
+	if err != nil {
+		t.Fatalf("LoadKeyring(%q) failed with %v", file, err)
+	}
+	if got, want := k.PrimaryKeyID(), "k2"; got != want {
+		t.Errorf("PrimaryKeyID() got %q, want %q", got, want)
+	}
+	if _, err := LoadKeyring(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadKeyring(missing) got nil error, want non nil")
+	}
+}
+
+func TestKeyringFromEnv(t *testing.T) {
+	t.Setenv(KeysEnv, "")
+	if k, err := KeyringFromEnv(); err != nil || k != nil {
+		t.Errorf("KeyringFromEnv() with %s not set got (%v, %v), want (nil, nil)", KeysEnv, k, err)
+	}
+
+	t.Setenv(KeysEnv, "k1:"+testKey(1))
+	k, err := KeyringFromEnv()
+	if err != nil {
+		t.Fatalf("KeyringFromEnv() failed with %v", err)
+	}
+	if got, want := k.PrimaryKeyID(), "k1"; got != want {
+		t.Errorf("PrimaryKeyID() got %q, want %q", got, want)
+	}
+
+	t.Setenv(KeysEnv, "invalid")
+	if _, err := KeyringFromEnv(); err == nil {
+		t.Error("KeyringFromEnv() with an invalid key got nil error, want non nil")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package persistcrypt contains an implementation of persist.ItemSyncer that encrypts the items
+// persisted by another persist.ItemSyncer, so that the state of Simulated Hospital is encrypted at
+// rest in any backend.
+//
+// The items are encrypted with AES-256-GCM. Each encrypted item is persisted as a JSON document
+// with the ID of the key it was encrypted with, so that the keys can be rotated; see Keyring.
+package persistcrypt
+
+import (
+	"crypto/rand"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+)
+
+var log = logging.ForCallerPackage()
+
+// envelope is the JSON document in which an encrypted item is persisted.
+type envelope struct {
+	// KeyID is the ID of the key the item is encrypted with.
+	KeyID string `json:"keyId"`
+	// ID is the ID of the item. It is authenticated, so an envelope cannot be passed as another item.
+	ID         string `json:"id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// parseEnvelope parses the given persisted item as an envelope, and returns whether it is one.
+func parseEnvelope(b []byte) (envelope, bool) {
+	var e envelope
+	if err := json.Unmarshal(b, &e); err != nil || e.KeyID == "" || e.Ciphertext == nil {
+		return envelope{}, false
+	}
+	return e, true
+}
+
+// ItemSyncer implements the persist.ItemSyncer interface by encrypting the items of one type and
+// persisting them with another ItemSyncer, which must implement persist.RawLoader.
+type ItemSyncer struct {
+	syncer       persist.ItemSyncer
+	loader       persist.RawLoader
+	itemType     string
+	unmarshaller persist.Unmarshaller
+	keys         *Keyring
+}
+
+// NewItemSyncer returns a syncer of items of the given type, which must be one of
+// state.MessageItemType, state.EventItemType or state.PatientItemType, that encrypts the items with
+// the given keys and persists them with the given syncer.
+func NewItemSyncer(syncer persist.ItemSyncer, itemType string, keys *Keyring) (*ItemSyncer, error) {
+	unmarshaller, err := state.UnmarshallerFor(itemType)
+	if err != nil {
+		return nil, err
+	}
+	loader, ok := syncer.(persist.RawLoader)
+	if !ok {
+		return nil, errors.Errorf("the syncer of %s items, of type %T, cannot load raw items", itemType, syncer)
+	}
+	if keys == nil {
+		return nil, errors.New("nil keys")
+	}
+	return &ItemSyncer{syncer: syncer, loader: loader, itemType: itemType, unmarshaller: unmarshaller, keys: keys}, nil
+}
+
+// Wrap returns syncers that encrypt the items with the given keys and persist them with the given
+// syncers, keyed by item type.
+func Wrap(syncers map[string]persist.ItemSyncer, keys *Keyring) (map[string]persist.ItemSyncer, error) {
+	wrapped := map[string]persist.ItemSyncer{}
+	for itemType, s := range syncers {
+		w, err := NewItemSyncer(s, itemType, keys)
+		if err != nil {
+			return nil, err
+		}
+		wrapped[itemType] = w
+	}
+	log.WithField("key_id", keys.PrimaryKeyID()).Info("Encrypting the persisted items")
+	return wrapped, nil
+}
+
+// Write encrypts an item with the primary key and writes it with the wrapped syncer.
+func (s *ItemSyncer) Write(item persist.MarshallableItem) error {
+	id, err := item.ID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get ID")
+	}
+	b, err := item.Marshal()
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal item %q", id)
+	}
+	aead := s.keys.aeads[s.keys.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "cannot generate nonce")
+	}
+	data, err := json.Marshal(envelope{
+		KeyID:      s.keys.primary,
+		ID:         id,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, b, s.additionalData(id)),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal envelope of item %q", id)
+	}
+	return s.syncer.Write(persist.RawItem{ItemID: id, StartTime: item.Start(), EndTime: item.End(), Data: data})
+}
+
+// Delete deletes an item with the wrapped syncer.
+func (s *ItemSyncer) Delete(item persist.MarshallableItem) error {
+	raw, err := rawItem(item)
+	if err != nil {
+		return err
+	}
+	return s.syncer.Delete(raw)
+}
+
+// Purge purges an item with the wrapped syncer, which must implement persist.Purger.
+func (s *ItemSyncer) Purge(item persist.MarshallableItem) error {
+	p, ok := s.syncer.(persist.Purger)
+	if !ok {
+		return errors.Errorf("the syncer of %s items, of type %T, cannot purge items", s.itemType, s.syncer)
+	}
+	raw, err := rawItem(item)
+	if err != nil {
+		return err
+	}
+	return p.Purge(raw)
+}
+
+// LoadAll returns a slice of all the items, sorted by id, decrypted.
+func (s *ItemSyncer) LoadAll() ([]persist.MarshallableItem, error) {
+	raw, err := s.loader.LoadAllRaw()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]persist.MarshallableItem, len(raw))
+	for i, b := range raw {
+		e, ok := parseEnvelope(b)
+		if !ok {
+			return nil, errors.Errorf("found %s item that is not encrypted", s.itemType)
+		}
+		if items[i], err = s.decrypt(e); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// LoadByID returns the item with the provided id, decrypted, or nil if it doesn't exist.
+func (s *ItemSyncer) LoadByID(id string) (persist.MarshallableItem, error) {
+	b, err := s.loader.LoadRawByID(id)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	e, ok := parseEnvelope(b)
+	if !ok {
+		return nil, errors.Errorf("%s item %q is not encrypted", s.itemType, id)
+	}
+	if e.ID != id {
+		return nil, errors.Errorf("%s item %q contains item %q", s.itemType, id, e.ID)
+	}
+	return s.decrypt(e)
+}
+
+// Reencrypt encrypts again with the primary key the items that are encrypted with other keys, or
+// that are not encrypted, e.g., because they were persisted before encryption was enabled. After
+// that, the other keys can be removed from the keyring.
+// It returns the number of items that were encrypted again. Items must not be written concurrently.
+func (s *ItemSyncer) Reencrypt() (int, error) {
+	raw, err := s.loader.LoadAllRaw()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, b := range raw {
+		var item persist.MarshallableItem
+		if e, ok := parseEnvelope(b); !ok {
+			if item, err = s.unmarshaller.Unmarshal(b); err != nil {
+				return n, errors.Wrapf(err, "cannot unmarshal unencrypted %s item", s.itemType)
+			}
+		} else if e.KeyID == s.keys.primary {
+			continue
+		} else if item, err = s.decrypt(e); err != nil {
+			return n, err
+		}
+		if err := s.Write(item); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *ItemSyncer) decrypt(e envelope) (persist.MarshallableItem, error) {
+	aead, ok := s.keys.aeads[e.KeyID]
+	if !ok {
+		return nil, errors.Errorf("cannot decrypt %s item %q: unknown key %q", s.itemType, e.ID, e.KeyID)
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, errors.Errorf("cannot decrypt %s item %q: invalid nonce", s.itemType, e.ID)
+	}
+	b, err := aead.Open(nil, e.Nonce, e.Ciphertext, s.additionalData(e.ID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decrypt %s item %q with key %q", s.itemType, e.ID, e.KeyID)
+	}
+	item, err := s.unmarshaller.Unmarshal(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal %s item %q", s.itemType, e.ID)
+	}
+	return item, nil
+}
+
+// additionalData returns the data that is authenticated together with the item with the given ID,
+// so that encrypted items cannot be passed as items of another type or with another ID.
+func (s *ItemSyncer) additionalData(id string) []byte {
+	return []byte(s.itemType + "\x00" + id)
+}
+
+// rawItem returns a persist.RawItem with the ID and times of the given item, and no data.
+func rawItem(item persist.MarshallableItem) (persist.RawItem, error) {
+	id, err := item.ID()
+	if err != nil {
+		return persist.RawItem{}, errors.Wrap(err, "cannot get ID")
+	}
+	return persist.RawItem{ItemID: id, StartTime: item.Start(), EndTime: item.End()}, nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistcrypt_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/Arend-melissant/simhospital/pkg/state/persistdb"
+	"github.com/Arend-melissant/simhospital/pkg/test/teststate"
+	. "github.com/Arend-melissant/simhospital/pkg/state/persistcrypt"
+)
+
+func keyring(t *testing.T, s string) *Keyring {
+	t.Helper()
+	k, err := ParseKeyring(s)
+	if err != nil {
+		t.Fatalf("ParseKeyring() failed with %v", err)
+	}
+	return k
+}
+
+func openDB(t *testing.T) *persistdb.DB {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.db")
+	db, err := persistdb.Open(file)
+	if err != nil {
+		t.Fatalf("persistdb.Open(%q) failed with %v", file, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func wrap(t *testing.T, syncers map[string]persist.ItemSyncer, keys *Keyring) map[string]persist.ItemSyncer {
+	t.Helper()
+	w, err := Wrap(syncers, keys)
+	if err != nil {
+		t.Fatalf("Wrap() failed with %v", err)
+	}
+	return w
+}
+
+func patient(mrn string, name string) state.Patient {
+	return state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: mrn, FirstName: name}}}
+}
+
+func TestItemSyncers(t *testing.T) {
+	now := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		itemType string
+		items    []persist.MarshallableItem
+		// secret is a value in the items that must not be persisted in plain text.
+		secret     string
+		wantDelete bool
+	}{{
+		itemType: state.EventItemType,
+		items: []persist.MarshallableItem{
+			state.Event{EventTime: now, PathwayName: "secret-pathway", PatientMRN: "1"},
+			state.Event{EventTime: now.Add(time.Hour), PathwayName: "secret-pathway", PatientMRN: "2"},
+		},
+		secret:     "secret-pathway",
+		wantDelete: true,
+	}, {
+		itemType: state.MessageItemType,
+		items: []persist.MarshallableItem{
+			state.HL7Message{Name: "ADT^A01", MessageTime: now, PathwayName: "secret-pathway"},
+			state.HL7Message{Name: "ADT^A03", MessageTime: now.Add(time.Hour), PathwayName: "secret-pathway"},
+		},
+		secret:     "secret-pathway",
+		wantDelete: true,
+	}, {
+		itemType:   state.PatientItemType,
+		items:      []persist.MarshallableItem{patient("1", "Jane"), patient("2", "Jane")},
+		secret:     "Jane",
+		wantDelete: false,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.itemType, func(t *testing.T) {
+			plain := persistdb.NewItemSyncers(openDB(t))
+			s := wrap(t, plain, keyring(t, "k1:"+testKey(1)))[tc.itemType]
+			ids := make([]string, len(tc.items))
+			for i, item := range tc.items {
+				if err := s.Write(item); err != nil {
+					t.Fatalf("Write(%v) failed with %v", item, err)
+				}
+				ids[i], _ = item.ID()
+			}
+
+			raw, err := plain[tc.itemType].(persist.RawLoader).LoadAllRaw()
+			if err != nil {
+				t.Fatalf("LoadAllRaw() failed with %v", err)
+			}
+			for _, b := range raw {
+				if bytes.Contains(b, []byte(tc.secret)) {
+					t.Errorf("persisted item %s contains %q, want it encrypted", b, tc.secret)
+				}
+			}
+
+			for i, item := range tc.items {
+				got, err := s.LoadByID(ids[i])
+				if err != nil {
+					t.Fatalf("LoadByID(%q) failed with %v", ids[i], err)
+				}
+				if diff := cmp.Diff(item, got, cmpopts.IgnoreUnexported(pathway.Step{})); diff != "" {
+					t.Errorf("LoadByID(%q) diff (-want, +got):\n%s", ids[i], diff)
+				}
+			}
+			if got, err := s.LoadByID("unknown"); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+			}
+			all, err := s.LoadAll()
+			if err != nil {
+				t.Fatalf("LoadAll() failed with %v", err)
+			}
+			if got, want := len(all), len(tc.items); got != want {
+				t.Errorf("len(LoadAll()) got %d, want %d", got, want)
+			}
+
+			if err := s.Delete(tc.items[0]); err != nil {
+				t.Fatalf("Delete(%v) failed with %v", tc.items[0], err)
+			}
+			got, err := s.LoadByID(ids[0])
+			if err != nil {
+				t.Fatalf("LoadByID(%q) failed with %v", ids[0], err)
+			}
+			if deleted := got == nil; deleted != tc.wantDelete {
+				t.Errorf("LoadByID(%q) after Delete() got %v, want deleted=%t", ids[0], got, tc.wantDelete)
+			}
+			if err := s.(persist.Purger).Purge(tc.items[0]); err != nil {
+				t.Fatalf("Purge(%v) failed with %v", tc.items[0], err)
+			}
+			if got, err := s.LoadByID(ids[0]); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) after Purge() got (%v, %v), want (nil, nil)", ids[0], got, err)
+			}
+		})
+	}
+}
+
+func TestItemSyncer_KeyRotation(t *testing.T) {
+	db := openDB(t)
+	old := keyring(t, "k1:"+testKey(1))
+	s := wrap(t, persistdb.NewItemSyncers(db), old)[state.PatientItemType]
+	for _, p := range []state.Patient{patient("1", "Jane"), patient("2", "John")} {
+		if err := s.Write(p); err != nil {
+			t.Fatalf("Write(%v) failed with %v", p, err)
+		}
+	}
+
+	// With the new key as the primary key, the items encrypted with the old key can still be read.
+	rotated := wrap(t, persistdb.NewItemSyncers(db), keyring(t, "k2:"+testKey(2)+",k1:"+testKey(1)))[state.PatientItemType]
+	if _, err := rotated.LoadAll(); err != nil {
+		t.Fatalf("LoadAll() with both keys failed with %v", err)
+	}
+	for want, wantN := range []int{2, 0} {
+		n, err := rotated.(*ItemSyncer).Reencrypt()
+		if err != nil {
+			t.Fatalf("Reencrypt() #%d failed with %v", want, err)
+		}
+		if n != wantN {
+			t.Errorf("Reencrypt() #%d got %d items, want %d", want, n, wantN)
+		}
+	}
+
+	// After re-encrypting the items, the old key is no longer needed.
+	s = wrap(t, persistdb.NewItemSyncers(db), keyring(t, "k2:"+testKey(2)))[state.PatientItemType]
+	if all, err := s.LoadAll(); err != nil || len(all) != 2 {
+		t.Errorf("LoadAll() with the new key got (%d items, %v), want (2 items, nil)", len(all), err)
+	}
+	s = wrap(t, persistdb.NewItemSyncers(db), old)[state.PatientItemType]
+	if _, err := s.LoadAll(); err == nil {
+		t.Error("LoadAll() with the old key got nil error, want non nil")
+	}
+}
+
+func TestItemSyncer_ReencryptUnencrypted(t *testing.T) {
+	plain := persistdb.NewItemSyncers(openDB(t))
+	p := patient("1", "Jane")
+	if err := plain[state.PatientItemType].Write(p); err != nil {
+		t.Fatalf("Write(%v) failed with %v", p, err)
+	}
+	s := wrap(t, plain, keyring(t, "k1:"+testKey(1)))[state.PatientItemType].(*ItemSyncer)
+	if _, err := s.LoadAll(); err == nil {
+		t.Error("LoadAll() with unencrypted items got nil error, want non nil")
+	}
+	n, err := s.Reencrypt()
+	if err != nil {
+		t.Fatalf("Reencrypt() failed with %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Reencrypt() got %d items, want 1", n)
+	}
+	got, err := s.LoadByID("1")
+	if err != nil {
+		t.Fatalf("LoadByID(%q) failed with %v", "1", err)
+	}
+	if diff := cmp.Diff(p, got); diff != "" {
+		t.Errorf("LoadByID(%q) diff (-want, +got):\n%s", "1", diff)
+	}
+}
+
+func TestItemSyncer_Tampered(t *testing.T) {
+	db := openDB(t)
+	plain := persistdb.NewItemSyncers(db)[state.PatientItemType]
+	s := wrap(t, persistdb.NewItemSyncers(db), keyring(t, "k1:"+testKey(1)))[state.PatientItemType]
+	for _, p := range []state.Patient{patient("1", "Jane"), patient("2", "John")} {
+		if err := s.Write(p); err != nil {
+			t.Fatalf("Write(%v) failed with %v", p, err)
+		}
+	}
+
+	// A key with the same ID but different bytes cannot decrypt the items.
+	other := wrap(t, persistdb.NewItemSyncers(db), keyring(t, "k1:"+testKey(9)))[state.PatientItemType]
+	if _, err := other.LoadByID("1"); err == nil {
+		t.Error("LoadByID() with the wrong key got nil error, want non nil")
+	}
+
+	// An encrypted item cannot be passed as another item.
+	b, err := plain.(persist.RawLoader).LoadRawByID("2")
+	if err != nil {
+		t.Fatalf("LoadRawByID(%q) failed with %v", "2", err)
+	}
+	if err := plain.Write(persist.RawItem{ItemID: "1", Data: b}); err != nil {
+		t.Fatalf("Write() failed with %v", err)
+	}
+	if got, err := s.LoadByID("1"); err == nil {
+		t.Errorf("LoadByID(%q) with the item of patient 2 got %v, want error", "1", got)
+	}
+}
+
+func TestNewItemSyncer_Errors(t *testing.T) {
+	keys := keyring(t, "k1:"+testKey(1))
+	plain := persistdb.NewItemSyncers(openDB(t))[state.PatientItemType]
+	cases := []struct {
+		name     string
+		syncer   persist.ItemSyncer
+		itemType string
+		keys     *Keyring
+	}{
+		{name: "unknown item type", syncer: plain, itemType: "unknown", keys: keys},
+		{name: "syncer cannot load raw items", syncer: teststate.NewItemSyncer(), itemType: state.PatientItemType, keys: keys},
+		{name: "nil keys", syncer: plain, itemType: state.PatientItemType},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewItemSyncer(tc.syncer, tc.itemType, tc.keys); err == nil {
+				t.Error("NewItemSyncer() got nil error, want non nil")
+			}
+		})
+	}
+}
@@ -1,229 +1,154 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package persistdb contains an implementation of persist.ItemSyncer that persists items in an
+// embedded BoltDB database file.
 package persistdb
 
 import (
-	"encoding/json"
-	"sort"
-	//"log"
-	//"reflect"
+	"time"
 
-	//"github.com/golang-collections/go-datastructures/queue"
+	bolt "go.etcd.io/bbolt"
 	"github.com/pkg/errors"
-	"github.com/Arend-melissant/simhospital/pkg/state/persist"
-	bolt "github.com/coreos/bbolt"
-	"github.com/Arend-melissant/simhospital/pkg/state"
 	"github.com/Arend-melissant/simhospital/pkg/logging"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
 )
 
-const (
-	MessageSyncer int = 0
-	EventSyncer       = 1
-	PatientSyncer     = 2
-)
+// DefaultFile is the default path of the database file.
+const DefaultFile = "simhosp.db"
+
+// openTimeout is how long Open waits for the lock on the database file, which is held by any other
+// process that has the file open.
+const openTimeout = 5 * time.Second
 
-const dbName = "simhosp.db"
 var log = logging.ForCallerPackage()
 
-// ItemSyncer implements the persist.ItemSyncer interface using a map.
-// It tracks the LoadByID requests made to the syncer in the form of an
-// internal `reqs` map for testing purposes.
-type DbItemSyncer struct {
-	m    map[string]persist.MarshallableItem
-	// delete indicates whether to delete items or not.
-	delete   bool
-	nDeletes int
-	syncType int
+// buckets maps the item types to the names of the buckets their items are stored in.
+var buckets = map[string][]byte{
+	state.MessageItemType: []byte("HL7Message"),
+	state.EventItemType:   []byte("Event"),
+	state.PatientItemType: []byte("Patient"),
 }
 
-// NewItemSyncer initializes the ItemSyncer.
-func NewItemSyncer(syncType int) *DbItemSyncer {
-	db, err := bolt.Open(dbName, 0600, nil)
+// DB is a BoltDB database with a bucket for each item type.
+// It is safe for concurrent use, and must be closed when it is no longer used.
+type DB struct {
+	db *bolt.DB
+}
+
+// Open opens the database in the given file, creating the file and the buckets if they don't
+// exist. Only one process can have the file open at any given time.
+func Open(file string) (*DB, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: openTimeout})
 	if err != nil {
-		log.Fatal(err)
+		return nil, errors.Wrapf(err, "cannot open database file %s; is it in use by another process?", file)
 	}
-	defer db.Close()
-	db.Update(func(tx *bolt.Tx) error {
-		_, err1 := tx.CreateBucket([]byte("HL7Message"))
-		if err1 != nil {
-			log.Errorf("create bucket: %s", err1)
-		} 
-		_, err2 := tx.CreateBucket([]byte("Event"))
-		if err2 != nil {
-			log.Errorf("create bucket: %s", err2)
-		} 
-		_, err3 := tx.CreateBucket([]byte("Patient"))
-		if err3 != nil {
-			log.Errorf("create bucket: %s", err3)
-		} 
-		
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return errors.Wrapf(err, "cannot create bucket %s", b)
+			}
+		}
 		return nil
 	})
-
-	return &DbItemSyncer{m: map[string]persist.MarshallableItem{}, syncType: syncType}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.WithField("file", file).Info("Opened database")
+	return &DB{db: db}, nil
 }
 
-// NewItemSyncerWithDelete initializes the ItemSyncer with a value for whether to delete items or not.
-func NewItemSyncerWithDelete(syncType int, delete bool) *DbItemSyncer {
-	return &DbItemSyncer{m: map[string]persist.MarshallableItem{}, delete: delete, syncType: syncType}
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
 }
 
-func (s *DbItemSyncer) getSyncType() string {
-	var str string 
-	str = "unknown"
-
-	switch s.syncType {
-	case PatientSyncer:
-		str = "Patient" 
-	case EventSyncer:
-		str = "Event" 
-	case MessageSyncer:
-		str = "HL7Message" 
-		default:
+// NewItemSyncer returns a syncer of items of the given type, which must be one of
+// state.MessageItemType, state.EventItemType or state.PatientItemType, that persists them in a
+// bucket of the given database.
+func NewItemSyncer(db *DB, itemType string, delete bool) (*persist.Syncer, error) {
+	name, ok := buckets[itemType]
+	if !ok {
+		return nil, errors.Errorf("unsupported item type %q", itemType)
 	}
-
-	return str
+	return state.NewItemSyncer(&bucket{db: db, name: name}, itemType, delete)
 }
 
-//func storeItem(s *DbItemSyncer, )
-
-// Write writes an item to the map.
-func (s *DbItemSyncer) Write(item persist.MarshallableItem) error {
-	b,_ := json.Marshal(item)
-	str := s.getSyncType()
-	id, err := item.ID()
-	if err != nil {
-		return errors.Wrap(err, "cannot get ID")
-	}
-	log.Infof("PersistDB: WRITE - %s - %s",str,id)
-	// s.m[id] = item
-	db, err := bolt.Open(dbName, 0600, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-	db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(str))
-		err := bucket.Put([]byte(id), []byte(b))
-		return err
+// NewItemSyncers returns the syncers of all the item types, keyed by item type, that persist them
+// in the given database; see state.NewItemSyncers.
+func NewItemSyncers(db *DB) map[string]persist.ItemSyncer {
+	return state.NewItemSyncers(func(itemType string) persist.RawStore {
+		return &bucket{db: db, name: buckets[itemType]}
 	})
-	return nil
 }
 
-// Delete deletes an item from the map.
-func (s *DbItemSyncer) Delete(item persist.MarshallableItem) error {
-	if !s.delete {
-		return nil
-	}
-	str := s.getSyncType()
-	s.nDeletes++
-	id, err := item.ID()
-	if err != nil {
-		return errors.Wrap(err, "cannot get ID")
-	}
-	log.Infof("PersistDB: DELETE - %s - %s",str,id)
-	db, err := bolt.Open(dbName, 0600, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
+// bucket implements persist.RawStore with a bucket of a DB.
+type bucket struct {
+	db   *DB
+	name []byte
+}
 
-	db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(str))
-		err := bucket.Delete([]byte(id))
-		return err
+func (b *bucket) String() string {
+	return "bucket " + string(b.name)
+}
+
+// WriteRaw writes an item to the bucket, replacing the item with the same ID if there is one.
+func (b *bucket) WriteRaw(id string, data []byte, _ persist.MarshallableItem) error {
+	return b.db.db.Update(func(tx *bolt.Tx) error {
+		return errors.Wrapf(tx.Bucket(b.name).Put([]byte(id), data), "cannot write item %q to bucket %s", id, b.name)
 	})
-	return nil
 }
 
+// DeleteRaw deletes the item with the given ID from the bucket.
+func (b *bucket) DeleteRaw(id string) error {
+	return b.db.db.Update(func(tx *bolt.Tx) error {
+		return errors.Wrapf(tx.Bucket(b.name).Delete([]byte(id)), "cannot delete item %q from bucket %s", id, b.name)
+	})
+}
 
-func GetAllDataFromBucket[T persist.MarshallableItem](s *DbItemSyncer) ([]persist.MarshallableItem, error) {
-	str := s.getSyncType()
-	log.Infof("LOADALL - %s", str)
-	db, err := bolt.Open(dbName, 0600, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-
-	data := make(map[string]T)
-	db.View(func(tx *bolt.Tx) error {
-		// Assume bucket exists and has keys
-		b := tx.Bucket([]byte(str))
-	
-		b.ForEach(func(k, v []byte) error {
-			var dat T
-			jerr := json.Unmarshal(v, &dat)
-			if jerr == nil {
-				data[string(k)] = dat
-			} else {
-				//fmt.Println(jerr)
-			}
+// LoadAllRaw returns the marshalled form of all the items in the bucket, sorted by id.
+func (b *bucket) LoadAllRaw() ([][]byte, error) {
+	var raw [][]byte
+	err := b.db.db.View(func(tx *bolt.Tx) error {
+		// Keys are iterated in byte-sorted order.
+		return tx.Bucket(b.name).ForEach(func(_, v []byte) error {
+			// v is only valid during the transaction.
+			raw = append(raw, append([]byte(nil), v...))
 			return nil
 		})
-		return nil
 	})
-
-	keys := make([]string, 0)
-	for id := range data {
-		keys = append(keys, id)
-	}
-	sort.Strings(keys)
-
-	sorted := make([]persist.MarshallableItem, len(keys))
-	for i, k := range keys {
-		sorted[i] = data[k]
-	}
-	return sorted, nil
-
-}
-
-// LoadAll returns a slice of all the items in the map, sorted by id.
-func (s *DbItemSyncer) LoadAll() ([]persist.MarshallableItem, error) {
-	str := s.getSyncType()
-	if (str == "HL7Message") {
-        data, _ := GetAllDataFromBucket[state.HL7Message](s)
-		return data, nil
-	} else if (str == "Event") {
-        data, _ := GetAllDataFromBucket[state.Event](s)
-		return data, nil
-	} else {
-        data, _ := GetAllDataFromBucket[state.Patient](s)
-		return data, nil
+	if err != nil {
+		return nil, err
 	}
+	return raw, nil
 }
 
-// LoadByID returns an item in the map with the provided id, if it exists.
-func (s *DbItemSyncer) LoadByID(id string) (persist.MarshallableItem, error) {
-	str := s.getSyncType()
-	log.Infof("PersistDB: LOADBYID - %s - %s",str,id)
-	db, err := bolt.Open(dbName, 0600, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-	
-	var dat state.Patient
-	db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(str))
-		v := b.Get([]byte(id))
-		if jerr := json.Unmarshal(v, &dat); err != nil {
-			panic(jerr)
+// LoadRawByID returns the marshalled form of the item in the bucket with the provided id, or nil if
+// it doesn't exist.
+func (b *bucket) LoadRawByID(id string) ([]byte, error) {
+	var raw []byte
+	err := b.db.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(b.name).Get([]byte(id)); v != nil {
+			// v is only valid during the transaction.
+			raw = append([]byte(nil), v...)
 		}
 		return nil
 	})
-	return dat, nil
-}
-
-// Count returns number of elements in the syncer for testing.
-func (s *DbItemSyncer) Count() int {
-	//str := s.getSyncType()
-    //fmt.Println("PersistDB: COUNT " + str)
-	return len(s.m)
-}
-
-// CountDeletes returns the number of deletions requested.
-func (s *DbItemSyncer) CountDeletes() int {
-	//str := s.getSyncType()
-    //fmt.Println("PersistDB: COUNTDELETES " + str)
-	return s.nDeletes
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
 }
-
@@ -0,0 +1,241 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistdb_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	. "github.com/Arend-melissant/simhospital/pkg/state/persistdb"
+)
+
+func open(t *testing.T, file string) *DB {
+	t.Helper()
+	db, err := Open(file)
+	if err != nil {
+		t.Fatalf("Open(%q) failed with %v", file, err)
+	}
+	return db
+}
+
+func TestItemSyncers(t *testing.T) {
+	now := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		itemType   string
+		items      []persist.MarshallableItem
+		wantDelete bool
+	}{{
+		itemType: state.EventItemType,
+		items: []persist.MarshallableItem{
+			state.Event{EventTime: now, PathwayName: "pathway1", PatientMRN: "1"},
+			state.Event{EventTime: now.Add(time.Hour), PathwayName: "pathway2", PatientMRN: "2"},
+		},
+		wantDelete: true,
+	}, {
+		itemType: state.MessageItemType,
+		items: []persist.MarshallableItem{
+			state.HL7Message{Name: "ADT^A01", MessageTime: now, PathwayName: "pathway1"},
+			state.HL7Message{Name: "ADT^A03", MessageTime: now.Add(time.Hour), PathwayName: "pathway1"},
+		},
+		wantDelete: true,
+	}, {
+		itemType: state.PatientItemType,
+		items: []persist.MarshallableItem{
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1", FirstName: "Jane"}}},
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "2", FirstName: "John"}}},
+		},
+		wantDelete: false,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.itemType, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "test.db")
+			db := open(t, file)
+			s := NewItemSyncers(db)[tc.itemType]
+			if s == nil {
+				t.Fatalf("NewItemSyncers()[%q] got nil, want a syncer", tc.itemType)
+			}
+			ids := make([]string, len(tc.items))
+			for i, item := range tc.items {
+				if err := s.Write(item); err != nil {
+					t.Fatalf("Write(%v) failed with %v", item, err)
+				}
+				ids[i], _ = item.ID()
+			}
+
+			// The items survive closing and opening the database again.
+			if err := db.Close(); err != nil {
+				t.Fatalf("Close() failed with %v", err)
+			}
+			db = open(t, file)
+			defer db.Close()
+			s = NewItemSyncers(db)[tc.itemType]
+
+			for i, item := range tc.items {
+				got, err := s.LoadByID(ids[i])
+				if err != nil {
+					t.Fatalf("LoadByID(%q) failed with %v", ids[i], err)
+				}
+				if diff := cmp.Diff(item, got, cmpopts.IgnoreUnexported(pathway.Step{})); diff != "" {
+					t.Errorf("LoadByID(%q) diff (-want, +got):\n%s", ids[i], diff)
+				}
+			}
+			if got, err := s.LoadByID("unknown"); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+			}
+
+			all, err := s.LoadAll()
+			if err != nil {
+				t.Fatalf("LoadAll() failed with %v", err)
+			}
+			if got, want := len(all), len(tc.items); got != want {
+				t.Errorf("len(LoadAll()) got %d, want %d", got, want)
+			}
+
+			if err := s.Delete(tc.items[0]); err != nil {
+				t.Fatalf("Delete(%v) failed with %v", tc.items[0], err)
+			}
+			got, err := s.LoadByID(ids[0])
+			if err != nil {
+				t.Fatalf("LoadByID(%q) failed with %v", ids[0], err)
+			}
+			if deleted := got == nil; deleted != tc.wantDelete {
+				t.Errorf("LoadByID(%q) after Delete() got %v, want deleted=%t", ids[0], got, tc.wantDelete)
+			}
+
+			// Purge deletes the item even if Delete doesn't.
+			if err := s.(persist.Purger).Purge(tc.items[0]); err != nil {
+				t.Fatalf("Purge(%v) failed with %v", tc.items[0], err)
+			}
+			if got, err := s.LoadByID(ids[0]); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) after Purge() got (%v, %v), want (nil, nil)", ids[0], got, err)
+			}
+		})
+	}
+}
+
+func TestItemSyncer_LoadAllSortedByID(t *testing.T) {
+	db := open(t, filepath.Join(t.TempDir(), "test.db"))
+	defer db.Close()
+	s, err := NewItemSyncer(db, state.PatientItemType, false)
+	if err != nil {
+		t.Fatalf("NewItemSyncer() failed with %v", err)
+	}
+	for _, mrn := range []string{"3", "1", "2"} {
+		p := state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: mrn}}}
+		if err := s.Write(p); err != nil {
+			t.Fatalf("Write(%v) failed with %v", p, err)
+		}
+	}
+	items, err := s.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll() failed with %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		id, _ := item.ID()
+		got = append(got, id)
+	}
+	if diff := cmp.Diff([]string{"1", "2", "3"}, got); diff != "" {
+		t.Errorf("LoadAll() IDs diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestItemSyncer_Concurrent(t *testing.T) {
+	db := open(t, filepath.Join(t.TempDir(), "test.db"))
+	defer db.Close()
+	syncers := NewItemSyncers(db)
+	now := time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e := state.Event{EventTime: now, PatientMRN: fmt.Sprint(i)}
+			if err := syncers[state.EventItemType].Write(e); err != nil {
+				t.Errorf("Write(%v) failed with %v", e, err)
+			}
+			p := state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: fmt.Sprint(i)}}}
+			if err := syncers[state.PatientItemType].Write(p); err != nil {
+				t.Errorf("Write(%v) failed with %v", p, err)
+			}
+			if _, err := syncers[state.PatientItemType].LoadAll(); err != nil {
+				t.Errorf("LoadAll() failed with %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, itemType := range []string{state.EventItemType, state.PatientItemType} {
+		items, err := syncers[itemType].LoadAll()
+		if err != nil {
+			t.Fatalf("LoadAll() failed with %v", err)
+		}
+		if got := len(items); got != n {
+			t.Errorf("len(LoadAll()) for %s got %d, want %d", itemType, got, n)
+		}
+	}
+}
+
+func TestNewItemSyncer_UnknownType(t *testing.T) {
+	db := open(t, filepath.Join(t.TempDir(), "test.db"))
+	defer db.Close()
+	if _, err := NewItemSyncer(db, "unknown", true); err == nil {
+		t.Error("NewItemSyncer(unknown) got nil error, want non nil")
+	}
+}
+
+func TestItemSyncer_RawItems(t *testing.T) {
+	db := open(t, filepath.Join(t.TempDir(), "test.db"))
+	defer db.Close()
+	s, err := NewItemSyncer(db, state.PatientItemType, false)
+	if err != nil {
+		t.Fatalf("NewItemSyncer() failed with %v", err)
+	}
+	items := []persist.RawItem{{ItemID: "2", Data: []byte("two")}, {ItemID: "1", Data: []byte("one")}}
+	for _, item := range items {
+		if err := s.Write(item); err != nil {
+			t.Fatalf("Write(%v) failed with %v", item, err)
+		}
+	}
+	got, err := s.LoadRawByID("2")
+	if err != nil {
+		t.Fatalf("LoadRawByID(%q) failed with %v", "2", err)
+	}
+	if diff := cmp.Diff([]byte("two"), got); diff != "" {
+		t.Errorf("LoadRawByID(%q) diff (-want, +got):\n%s", "2", diff)
+	}
+	if got, err := s.LoadRawByID("unknown"); err != nil || got != nil {
+		t.Errorf("LoadRawByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+	}
+	all, err := s.LoadAllRaw()
+	if err != nil {
+		t.Fatalf("LoadAllRaw() failed with %v", err)
+	}
+	if diff := cmp.Diff([][]byte{[]byte("one"), []byte("two")}, all); diff != "" {
+		t.Errorf("LoadAllRaw() diff (-want, +got):\n%s", diff)
+	}
+}
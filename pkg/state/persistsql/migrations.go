@@ -0,0 +1,136 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistsql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// migration is a change to the schema of the database. Migrations are applied in order, and each
+// one is applied only once.
+type migration struct {
+	// version is the version of the schema after the migration. Versions start at 1 and are consecutive.
+	version     int
+	description string
+	// statements returns the statements of the migration in the given dialect.
+	statements func(d dialect) []string
+}
+
+// migrations are all the migrations of the schema, in order. To change the schema, append a new
+// migration: the migrations that have already been released must not be changed.
+var migrations = []migration{{
+	version:     1,
+	description: "create the patients, events and messages tables",
+	statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE patients (
+				id TEXT PRIMARY KEY,
+				mrn TEXT NOT NULL,
+				nhs TEXT NOT NULL,
+				first_name TEXT NOT NULL,
+				surname TEXT NOT NULL,
+				gender TEXT NOT NULL,
+				birth_date ` + d.timestamp + `,
+				class TEXT NOT NULL,
+				location TEXT NOT NULL,
+				admission_date ` + d.timestamp + `,
+				discharge_date ` + d.timestamp + `,
+				start_time ` + d.timestamp + ` NOT NULL,
+				end_time ` + d.timestamp + ` NOT NULL,
+				data ` + d.blob + ` NOT NULL
+			)`,
+			`CREATE INDEX patients_surname ON patients (surname)`,
+			`CREATE TABLE events (
+				id TEXT PRIMARY KEY,
+				pathway_name TEXT NOT NULL,
+				patient_mrn TEXT NOT NULL,
+				step_type TEXT NOT NULL,
+				step_index INTEGER NOT NULL,
+				event_time ` + d.timestamp + ` NOT NULL,
+				message_time ` + d.timestamp + ` NOT NULL,
+				is_historical BOOLEAN NOT NULL,
+				data ` + d.blob + ` NOT NULL
+			)`,
+			`CREATE INDEX events_patient_mrn ON events (patient_mrn)`,
+			`CREATE INDEX events_event_time ON events (event_time)`,
+			`CREATE TABLE messages (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				pathway_name TEXT NOT NULL,
+				patient_mrn TEXT NOT NULL,
+				message_type TEXT NOT NULL,
+				control_id TEXT NOT NULL,
+				message_time ` + d.timestamp + ` NOT NULL,
+				is_historical BOOLEAN NOT NULL,
+				data ` + d.blob + ` NOT NULL
+			)`,
+			`CREATE INDEX messages_patient_mrn ON messages (patient_mrn)`,
+			`CREATE INDEX messages_message_time ON messages (message_time)`,
+			`CREATE INDEX messages_control_id ON messages (control_id)`,
+		}
+	},
+}}
+
+// migrate applies the migrations that haven't been applied to the database yet, each one in its own
+// transaction. The applied migrations are recorded in the schema_migrations table.
+// It returns the version of the schema after the migrations.
+func migrate(db *sql.DB, d dialect) (int, error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at ` + d.timestamp + ` NOT NULL
+	)`); err != nil {
+		return 0, errors.Wrap(err, "cannot create the schema_migrations table")
+	}
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return 0, errors.Wrap(err, "cannot get the version of the schema")
+	}
+	if latest := migrations[len(migrations)-1].version; current > latest {
+		return 0, errors.Errorf("the version of the schema is %d, but the latest version that this binary knows about is %d", current, latest)
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := apply(db, d, m); err != nil {
+			return 0, errors.Wrapf(err, "cannot apply migration %d (%s)", m.version, m.description)
+		}
+		log.WithField("version", m.version).Infof("Applied schema migration: %s", m.description)
+		current = m.version
+	}
+	return current, nil
+}
+
+func apply(db *sql.DB, d dialect, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, s := range m.statements(d) {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES ($1, $2, $3)`,
+		m.version, m.description, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
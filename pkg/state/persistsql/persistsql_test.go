@@ -0,0 +1,300 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistsql_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/message"
+	"github.com/Arend-melissant/simhospital/pkg/pathway"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	. "github.com/Arend-melissant/simhospital/pkg/state/persistsql"
+)
+
+// postgresDSNEnv is the environment variable with the data source name of a PostgreSQL database to
+// run the tests against. If it is not set, the tests only run against SQLite.
+const postgresDSNEnv = "SIMHOSPITAL_TEST_POSTGRES_DSN"
+
+var now = time.Date(2020, 2, 12, 9, 30, 0, 0, time.UTC)
+
+func openSQLite(t *testing.T, file string) *DB {
+	t.Helper()
+	db, err := Open(SQLite, file)
+	if err != nil {
+		t.Fatalf("Open(%q, %q) failed with %v", SQLite, file, err)
+	}
+	return db
+}
+
+func TestItemSyncers(t *testing.T) {
+	t.Run(SQLite, func(t *testing.T) {
+		db := openSQLite(t, filepath.Join(t.TempDir(), "test.sqlite"))
+		defer db.Close()
+		testItemSyncers(t, db)
+	})
+	t.Run(Postgres, func(t *testing.T) {
+		dsn := os.Getenv(postgresDSNEnv)
+		if dsn == "" {
+			t.Skipf("%s is not set", postgresDSNEnv)
+		}
+		db, err := Open(Postgres, dsn)
+		if err != nil {
+			t.Fatalf("Open(%q) failed with %v", Postgres, err)
+		}
+		defer db.Close()
+		testItemSyncers(t, db)
+	})
+}
+
+func testItemSyncers(t *testing.T, db *DB) {
+	t.Helper()
+	cases := []struct {
+		itemType   string
+		items      []persist.MarshallableItem
+		wantDelete bool
+	}{{
+		itemType: state.EventItemType,
+		items: []persist.MarshallableItem{
+			state.Event{EventTime: now, PathwayName: "pathway1", PatientMRN: "1"},
+			state.Event{EventTime: now.Add(time.Hour), PathwayName: "pathway2", PatientMRN: "2"},
+		},
+		wantDelete: true,
+	}, {
+		itemType: state.MessageItemType,
+		items: []persist.MarshallableItem{
+			state.HL7Message{Name: "ADT^A01", MessageTime: now, PathwayName: "pathway1"},
+			state.HL7Message{Name: "ADT^A03", MessageTime: now.Add(time.Hour), PathwayName: "pathway1"},
+		},
+		wantDelete: true,
+	}, {
+		itemType: state.PatientItemType,
+		items: []persist.MarshallableItem{
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "1", FirstName: "Jane"}}},
+			state.Patient{PatientInfo: &ir.PatientInfo{Person: &ir.Person{MRN: "2", FirstName: "John"}}},
+		},
+		wantDelete: false,
+	}}
+
+	syncers := NewItemSyncers(db)
+	for _, tc := range cases {
+		t.Run(tc.itemType, func(t *testing.T) {
+			s := syncers[tc.itemType]
+			ids := make([]string, len(tc.items))
+			for i, item := range tc.items {
+				// Writing twice replaces the item.
+				for j := 0; j < 2; j++ {
+					if err := s.Write(item); err != nil {
+						t.Fatalf("Write(%v) failed with %v", item, err)
+					}
+				}
+				ids[i], _ = item.ID()
+			}
+
+			for i, item := range tc.items {
+				got, err := s.LoadByID(ids[i])
+				if err != nil {
+					t.Fatalf("LoadByID(%q) failed with %v", ids[i], err)
+				}
+				if diff := cmp.Diff(item, got, cmpopts.IgnoreUnexported(pathway.Step{})); diff != "" {
+					t.Errorf("LoadByID(%q) diff (-want, +got):\n%s", ids[i], diff)
+				}
+			}
+			if got, err := s.LoadByID("unknown"); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+			}
+
+			all, err := s.LoadAll()
+			if err != nil {
+				t.Fatalf("LoadAll() failed with %v", err)
+			}
+			if got, want := len(all), len(tc.items); got != want {
+				t.Errorf("len(LoadAll()) got %d, want %d", got, want)
+			}
+
+			if err := s.Delete(tc.items[0]); err != nil {
+				t.Fatalf("Delete(%v) failed with %v", tc.items[0], err)
+			}
+			got, err := s.LoadByID(ids[0])
+			if err != nil {
+				t.Fatalf("LoadByID(%q) failed with %v", ids[0], err)
+			}
+			if deleted := got == nil; deleted != tc.wantDelete {
+				t.Errorf("LoadByID(%q) after Delete() got %v, want deleted=%t", ids[0], got, tc.wantDelete)
+			}
+
+			// Purge deletes the item even if Delete doesn't.
+			if err := s.(persist.Purger).Purge(tc.items[0]); err != nil {
+				t.Fatalf("Purge(%v) failed with %v", tc.items[0], err)
+			}
+			if got, err := s.LoadByID(ids[0]); err != nil || got != nil {
+				t.Errorf("LoadByID(%q) after Purge() got (%v, %v), want (nil, nil)", ids[0], got, err)
+			}
+		})
+	}
+}
+
+func TestItemSyncers_QueryableColumns(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.sqlite")
+	db := openSQLite(t, file)
+	defer db.Close()
+	syncers := NewItemSyncers(db)
+
+	p := state.Patient{PatientInfo: &ir.PatientInfo{
+		Person: &ir.Person{
+			MRN:       "1234",
+			FirstName: "Jane",
+			Surname:   "Doe",
+			Gender:    "F",
+			Birth:     ir.NewMidnightTime(time.Date(1980, 3, 4, 0, 0, 0, 0, time.UTC)),
+		},
+		Class:    "INPATIENT",
+		Location: &ir.PatientLocation{Poc: "Renal", Bed: "Bed 1"},
+	}}
+	m := state.HL7Message{
+		Name:        "ADT^A01-1234",
+		PathwayName: "pathway1",
+		MessageTime: now,
+		Message: &message.HL7Message{
+			Type:    &message.Type{MessageType: "ADT", TriggerEvent: "A01"},
+			Message: "MSH|^~\\&|app|facility|rapp|rfacility|20200212093000||ADT^A01|control-1|T|2.3\rPID|1||1234^^^^MRN~5678^^^NHSNMBR",
+		},
+		// Messages don't always have an event; the patient's MRN is taken from PID-3 instead.
+	}
+	if err := syncers[state.PatientItemType].Write(p); err != nil {
+		t.Fatalf("Write(%v) failed with %v", p, err)
+	}
+	if err := syncers[state.MessageItemType].Write(m); err != nil {
+		t.Fatalf("Write(%v) failed with %v", m, err)
+	}
+
+	conn, err := sql.Open(SQLite, file)
+	if err != nil {
+		t.Fatalf("sql.Open(%q) failed with %v", file, err)
+	}
+	defer conn.Close()
+
+	var mrn, name, location string
+	var birth time.Time
+	if err := conn.QueryRow(`SELECT mrn, first_name || ' ' || surname, location, birth_date FROM patients WHERE surname = 'Doe'`).
+		Scan(&mrn, &name, &location, &birth); err != nil {
+		t.Fatalf("querying patients failed with %v", err)
+	}
+	if got, want := []string{mrn, name, location}, []string{"1234", "Jane Doe", "Bed 1, Renal"}; !cmp.Equal(got, want) {
+		t.Errorf("patient columns got %v, want %v", got, want)
+	}
+	if want := time.Date(1980, 3, 4, 0, 0, 0, 0, time.UTC); !birth.Equal(want) {
+		t.Errorf("birth_date got %v, want %v", birth, want)
+	}
+
+	var messageType, controlID, patientMRN string
+	if err := conn.QueryRow(`SELECT message_type, control_id, patient_mrn FROM messages WHERE pathway_name = 'pathway1'`).
+		Scan(&messageType, &controlID, &patientMRN); err != nil {
+		t.Fatalf("querying messages failed with %v", err)
+	}
+	if got, want := []string{messageType, controlID, patientMRN}, []string{"ADT^A01", "control-1", "1234"}; !cmp.Equal(got, want) {
+		t.Errorf("message columns got %v, want %v", got, want)
+	}
+}
+
+func TestOpen_Migrations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.sqlite")
+	// Opening the database again doesn't apply the migrations again.
+	for i := 0; i < 2; i++ {
+		openSQLite(t, file).Close()
+	}
+
+	conn, err := sql.Open(SQLite, file)
+	if err != nil {
+		t.Fatalf("sql.Open(%q) failed with %v", file, err)
+	}
+	defer conn.Close()
+	var n int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n); err != nil {
+		t.Fatalf("querying schema_migrations failed with %v", err)
+	}
+	if n != 1 {
+		t.Errorf("number of applied migrations got %d, want 1", n)
+	}
+
+	// A database with a newer schema cannot be opened.
+	if _, err := conn.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (1000, 'future', $1)`, now); err != nil {
+		t.Fatalf("inserting migration failed with %v", err)
+	}
+	if db, err := Open(SQLite, file); err == nil {
+		db.Close()
+		t.Errorf("Open(%q) with a newer schema got nil error, want non nil", file)
+	}
+}
+
+func TestOpen_UnsupportedDriver(t *testing.T) {
+	if _, err := Open("mysql", "dsn"); err == nil {
+		t.Error("Open(mysql) got nil error, want non nil")
+	}
+}
+
+func TestItemSyncers_RawItems(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.sqlite")
+	db := openSQLite(t, file)
+	defer db.Close()
+	conn, err := sql.Open(SQLite, file)
+	if err != nil {
+		t.Fatalf("sql.Open(%q) failed with %v", file, err)
+	}
+	defer conn.Close()
+
+	for itemType, s := range NewItemSyncers(db) {
+		t.Run(itemType, func(t *testing.T) {
+			item := persist.RawItem{ItemID: "1", StartTime: now, EndTime: now, Data: []byte(`{"opaque":true}`)}
+			if err := s.Write(item); err != nil {
+				t.Fatalf("Write(%v) failed with %v", item, err)
+			}
+			l := s.(persist.RawLoader)
+			got, err := l.LoadRawByID("1")
+			if err != nil {
+				t.Fatalf("LoadRawByID(%q) failed with %v", "1", err)
+			}
+			if diff := cmp.Diff(item.Data, got); diff != "" {
+				t.Errorf("LoadRawByID(%q) diff (-want, +got):\n%s", "1", diff)
+			}
+			if got, err := l.LoadRawByID("unknown"); err != nil || got != nil {
+				t.Errorf("LoadRawByID(%q) got (%v, %v), want (nil, nil)", "unknown", got, err)
+			}
+			all, err := l.LoadAllRaw()
+			if err != nil {
+				t.Fatalf("LoadAllRaw() failed with %v", err)
+			}
+			if diff := cmp.Diff([][]byte{item.Data}, all); diff != "" {
+				t.Errorf("LoadAllRaw() diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The queryable columns of raw items are empty, so they don't reveal anything about the items.
+	var mrn, surname string
+	if err := conn.QueryRow(`SELECT mrn, surname FROM patients WHERE id = '1'`).Scan(&mrn, &surname); err != nil {
+		t.Fatalf("querying patients failed with %v", err)
+	}
+	if mrn != "" || surname != "" {
+		t.Errorf("patient columns got mrn=%q, surname=%q, want empty", mrn, surname)
+	}
+}
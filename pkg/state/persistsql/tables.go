@@ -0,0 +1,190 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistsql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/Arend-melissant/simhospital/pkg/ir"
+	"github.com/Arend-melissant/simhospital/pkg/state"
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+)
+
+// table is the table in which the items of one type are stored. Apart from the columns listed here,
+// every table has an "id" column with the ID of the item and a "data" column with the marshalled item.
+type table struct {
+	name string
+	// columns are the columns that can be queried.
+	columns []string
+	// values returns the values of the columns for the given item, in the same order as columns.
+	values func(item persist.MarshallableItem) ([]interface{}, error)
+	// rawValues are the values of the columns for persist.RawItems, which are zero values so that
+	// they don't reveal anything about the items.
+	rawValues []interface{}
+}
+
+// tables maps the item types to the tables their items are stored in.
+var tables = map[string]table{
+	state.PatientItemType: {
+		name: "patients",
+		columns: []string{"mrn", "nhs", "first_name", "surname", "gender", "birth_date", "class", "location",
+			"admission_date", "discharge_date", "start_time", "end_time"},
+		values: patientValues,
+		rawValues: []interface{}{"", "", "", "", "", sql.NullTime{}, "", "", sql.NullTime{}, sql.NullTime{},
+			time.Time{}, time.Time{}},
+	},
+	state.EventItemType: {
+		name:      "events",
+		columns:   []string{"pathway_name", "patient_mrn", "step_type", "step_index", "event_time", "message_time", "is_historical"},
+		values:    eventValues,
+		rawValues: []interface{}{"", "", "", 0, time.Time{}, time.Time{}, false},
+	},
+	state.MessageItemType: {
+		name:      "messages",
+		columns:   []string{"name", "pathway_name", "patient_mrn", "message_type", "control_id", "message_time", "is_historical"},
+		values:    messageValues,
+		rawValues: []interface{}{"", "", "", "", "", time.Time{}, false},
+	},
+}
+
+// columnValues returns the values of the columns for the given item, in the same order as columns.
+func (t table) columnValues(item persist.MarshallableItem) ([]interface{}, error) {
+	if _, ok := item.(persist.RawItem); ok {
+		return t.rawValues, nil
+	}
+	return t.values(item)
+}
+
+func patientValues(item persist.MarshallableItem) ([]interface{}, error) {
+	p, ok := item.(state.Patient)
+	if !ok {
+		return nil, errors.Errorf("got item of type %T, want state.Patient", item)
+	}
+	if p.PatientInfo == nil || p.PatientInfo.Person == nil {
+		return nil, errors.New("the patient has no PatientInfo or PatientInfo.Person")
+	}
+	info := p.PatientInfo
+	person := info.Person
+	var location string
+	if info.Location != nil {
+		location = info.Location.Name()
+	}
+	return []interface{}{
+		person.MRN, person.NHS, person.FirstName, person.Surname, person.Gender, nullTime(person.Birth),
+		info.Class, location, nullTime(info.AdmissionDate), nullTime(info.DischargeDate),
+		p.Start().UTC(), p.End().UTC(),
+	}, nil
+}
+
+func eventValues(item persist.MarshallableItem) ([]interface{}, error) {
+	e, ok := item.(state.Event)
+	if !ok {
+		return nil, errors.Errorf("got item of type %T, want state.Event", item)
+	}
+	return []interface{}{
+		e.PathwayName, e.PatientMRN, e.Step.StepType(), e.Index, e.EventTime.UTC(), e.MessageTime.UTC(), e.IsHistorical,
+	}, nil
+}
+
+func messageValues(item persist.MarshallableItem) ([]interface{}, error) {
+	m, ok := item.(state.HL7Message)
+	if !ok {
+		return nil, errors.Errorf("got item of type %T, want state.HL7Message", item)
+	}
+	var mrn, messageType, controlID string
+	if m.Event != nil {
+		mrn = m.Event.PatientMRN
+	}
+	if m.Message != nil {
+		if mrn == "" {
+			mrn = messageMRN(m.Message.Message)
+		}
+		if m.Message.Type != nil {
+			messageType = fmt.Sprintf("%s^%s", m.Message.Type.MessageType, m.Message.Type.TriggerEvent)
+		}
+		controlID = messageControlID(m.Message.Message)
+	}
+	return []interface{}{
+		m.Name, m.PathwayName, mrn, messageType, controlID, m.MessageTime.UTC(), m.IsHistorical,
+	}, nil
+}
+
+// messageControlID returns the Message Control ID, MSH-10, of the given HL7 message, or an empty
+// string if the message doesn't have one.
+func messageControlID(msg string) string {
+	// The field separator is MSH-1, so the fields after splitting are "MSH", MSH-2, MSH-3, etc.
+	return segmentField(msg, "MSH", 9)
+}
+
+// messageMRN returns the first identifier in the Patient Identifier List, PID-3, of the given HL7
+// message, which is the MRN of the patient, or an empty string if the message doesn't have one.
+func messageMRN(msg string) string {
+	id := segmentField(msg, "PID", 3)
+	// The ID Number is the first component of the first repetition.
+	if i := strings.IndexAny(id, "~^"); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// segmentField returns the i-th field of the first segment with the given name in the HL7 message,
+// where the name of the segment is field 0, or an empty string if there is no such field.
+func segmentField(msg string, segment string, i int) string {
+	if !strings.HasPrefix(msg, "MSH") || len(msg) < 4 {
+		return ""
+	}
+	sep := msg[3:4]
+	for _, s := range strings.FieldsFunc(msg, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if !strings.HasPrefix(s, segment+sep) {
+			continue
+		}
+		fields := strings.Split(s, sep)
+		if len(fields) <= i {
+			return ""
+		}
+		return fields[i]
+	}
+	return ""
+}
+
+func nullTime(t ir.NullTime) sql.NullTime {
+	return sql.NullTime{Time: t.Time.UTC(), Valid: t.Valid}
+}
+
+// placeholders returns the placeholders for n arguments, starting at $1.
+func placeholders(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(p, ", ")
+}
+
+// upsertQuery returns the statement that inserts an item in the table, or replaces it if an item
+// with the same ID exists. The arguments are the ID, the values of the columns and the data.
+func (t table) upsertQuery() string {
+	columns := append(append([]string{"id"}, t.columns...), "data")
+	updates := make([]string, 0, len(columns)-1)
+	for _, c := range columns[1:] {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", c, c))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s",
+		t.name, strings.Join(columns, ", "), placeholders(len(columns)), strings.Join(updates, ", "))
+}
+
@@ -94,6 +94,8 @@ func (q *WrappedQueue) LoadFromSyncer() error {
 	for i, item := range items {
 		queueItems[i] = item.(MarshallableQueueItem)
 	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
 	// Send a "nil" syncer so that the items aren't persisted: they have been persisted already.
 	return q.put(nil, queueItems...)
 }
@@ -153,6 +155,28 @@ func (q *WrappedQueue) addToInternalMap(i MarshallableQueueItem) error {
 func (q *WrappedQueue) Get() (*MarshallableQueueItem, error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
+	return q.get()
+}
+
+// GetIf retrieves the next item from the queue if match returns true for it, removing it from all
+// internal data structures and the syncer. Unlike calling Peek and then Get, checking the item and
+// removing it is atomic, so the item that is removed is always the one that was checked.
+// GetIf returns a nil item if the queue is empty or match returns false.
+func (q *WrappedQueue) GetIf(match func(MarshallableQueueItem) bool) (MarshallableQueueItem, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	next, ok := q.q.Peek().(MarshallableQueueItem)
+	if !ok || !match(next) {
+		return nil, nil
+	}
+	item, err := q.get()
+	if err != nil {
+		return nil, err
+	}
+	return *item, nil
+}
+
+func (q *WrappedQueue) get() (*MarshallableQueueItem, error) {
 	i, err := q.q.Get(1)
 	if err != nil {
 		return nil, err
@@ -184,6 +208,201 @@ func (q *WrappedQueue) Get() (*MarshallableQueueItem, error) {
 	return &item, nil
 }
 
+// Remove removes the first item, in priority order, for which match returns true, from all internal
+// data structures and the syncer, and returns it.
+// Remove returns a nil item if no item matches.
+func (q *WrappedQueue) Remove(match func(MarshallableQueueItem) bool) (MarshallableQueueItem, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.q.Empty() {
+		return nil, nil
+	}
+	// The priority queue does not support removing arbitrary items, so we drain it and put back
+	// all the items that don't match.
+	all, err := q.q.Get(q.q.Len())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume items in queue")
+	}
+	var removed MarshallableQueueItem
+	var rest []queue.Item
+	for _, i := range all {
+		item := i.(MarshallableQueueItem)
+		if removed == nil && match(item) {
+			removed = item
+			continue
+		}
+		rest = append(rest, i)
+	}
+	if len(rest) > 0 {
+		if err := q.q.Put(rest...); err != nil {
+			return nil, errors.Wrap(err, "failed to put back items in queue")
+		}
+	}
+	if removed == nil {
+		return nil, nil
+	}
+	if q.syncer != nil {
+		q.syncer.Delete(removed)
+	}
+	id, err := removed.ID()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get item ID")
+	}
+	if _, ok := q.m[id]; !ok {
+		log.WithField("item_id", id).Warning("Elements out of sync: asked to remove an item that wasn't present")
+	} else {
+		delete(q.m, id)
+		counters.SimulatedHospital.PendingItem.With(prometheus.Labels{
+			"item_type": q.itemType,
+		}).Dec()
+	}
+	if q.q.Len() != len(q.m) && q.consistent {
+		log.Warningf("Elements out of sync after Remove method: #priority queue: %d, #wrapped map: %d", q.q.Len(), len(q.m))
+		q.consistent = false
+	}
+	return removed, nil
+}
+
+// RemoveAll removes all the items for which match returns true from all internal data structures and
+// the syncer, and returns them in priority order.
+func (q *WrappedQueue) RemoveAll(match func(MarshallableQueueItem) bool) ([]MarshallableQueueItem, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.removeAll(match)
+}
+
+// Update removes all the items for which match returns true, and puts back the items returned by
+// update instead, atomically. update is called once with the matching items in priority order, and
+// can return them changed, return fewer items or return more items. If update returns an error, the
+// matching items are put back unchanged.
+// Update returns the items that were put back.
+func (q *WrappedQueue) Update(match func(MarshallableQueueItem) bool, update func([]MarshallableQueueItem) ([]MarshallableQueueItem, error)) ([]MarshallableQueueItem, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	removed, err := q.removeAll(match)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := update(removed)
+	if err != nil {
+		if putErr := q.put(q.syncer, removed...); putErr != nil {
+			return nil, errors.Wrap(putErr, "failed to put back items in queue")
+		}
+		return nil, err
+	}
+	if err := q.put(q.syncer, updated...); err != nil {
+		return nil, errors.Wrap(err, "failed to put updated items in queue")
+	}
+	return updated, nil
+}
+
+func (q *WrappedQueue) removeAll(match func(MarshallableQueueItem) bool) ([]MarshallableQueueItem, error) {
+	if q.q.Empty() {
+		return nil, nil
+	}
+	// The priority queue does not support removing arbitrary items, so we drain it and put back
+	// all the items that don't match.
+	all, err := q.q.Get(q.q.Len())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume items in queue")
+	}
+	var removed []MarshallableQueueItem
+	var rest []queue.Item
+	for _, i := range all {
+		item := i.(MarshallableQueueItem)
+		if match(item) {
+			removed = append(removed, item)
+			continue
+		}
+		rest = append(rest, i)
+	}
+	if len(rest) > 0 {
+		if err := q.q.Put(rest...); err != nil {
+			return nil, errors.Wrap(err, "failed to put back items in queue")
+		}
+	}
+	for _, item := range removed {
+		if q.syncer != nil {
+			q.syncer.Delete(item)
+		}
+		id, err := item.ID()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get item ID")
+		}
+		if _, ok := q.m[id]; !ok {
+			log.WithField("item_id", id).Warning("Elements out of sync: asked to remove an item that wasn't present")
+			continue
+		}
+		delete(q.m, id)
+		counters.SimulatedHospital.PendingItem.With(prometheus.Labels{
+			"item_type": q.itemType,
+		}).Dec()
+	}
+	if q.q.Len() != len(q.m) && q.consistent {
+		log.Warningf("Elements out of sync after RemoveAll method: #priority queue: %d, #wrapped map: %d", q.q.Len(), len(q.m))
+		q.consistent = false
+	}
+	return removed, nil
+}
+
+// Find returns the items for which match returns true, in no particular order.
+// The items are not removed from the queue.
+func (q *WrappedQueue) Find(match func(MarshallableQueueItem) bool) []MarshallableQueueItem {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var found []MarshallableQueueItem
+	for _, i := range q.m {
+		if match(i) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// Items returns all the items in the queue, in priority order, without removing them.
+func (q *WrappedQueue) Items() ([]MarshallableQueueItem, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.q.Empty() {
+		return nil, nil
+	}
+	// The priority queue does not support iterating over its items, so we drain it and put all the
+	// items back. Items with the same priority keep their order.
+	all, err := q.q.Get(q.q.Len())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume items in queue")
+	}
+	if err := q.q.Put(all...); err != nil {
+		return nil, errors.Wrap(err, "failed to put back items in queue")
+	}
+	items := make([]MarshallableQueueItem, len(all))
+	for i, item := range all {
+		items[i] = item.(MarshallableQueueItem)
+	}
+	return items, nil
+}
+
+// Clear removes all the items from all internal data structures and the syncer.
+func (q *WrappedQueue) Clear() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.q.Empty() {
+		if _, err := q.q.Get(q.q.Len()); err != nil {
+			return errors.Wrap(err, "failed to consume items in queue")
+		}
+	}
+	for id, item := range q.m {
+		if q.syncer != nil {
+			q.syncer.Delete(item)
+		}
+		delete(q.m, id)
+		counters.SimulatedHospital.PendingItem.With(prometheus.Labels{
+			"item_type": q.itemType,
+		}).Dec()
+	}
+	return nil
+}
+
 // Peek returns the next item in the queue without removing it from the queue.
 func (q *WrappedQueue) Peek() queue.Item {
 	q.mutex.Lock()
@@ -200,5 +419,7 @@ func (q *WrappedQueue) Empty() bool {
 
 // IsConsistent returns whether the queue and mapping of MarshallableQueueItems have ever fallen out of sync.
 func (q *WrappedQueue) IsConsistent() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
 	return q.consistent
 }
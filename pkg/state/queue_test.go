@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	"github.com/Arend-melissant/simhospital/pkg/state/persist"
 	"github.com/Arend-melissant/simhospital/pkg/test/teststate"
 )
@@ -209,6 +210,327 @@ func TestWrappedQueue_Peek(t *testing.T) {
 	}
 }
 
+func TestWrappedQueue_Remove(t *testing.T) {
+	tests := []struct {
+		name   string
+		syncer persist.ItemSyncer
+	}{
+		{name: "no syncer", syncer: nil},
+		{name: "with syncer", syncer: teststate.NewItemSyncerWithDelete(true)},
+	}
+	item3 := teststate.NewItem("3")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wq, err := NewWrappedQueue(teststate.Type, tt.syncer)
+			if err != nil {
+				t.Fatalf("NewWrappedQueue(%s, %v) failed with %v", teststate.Type, tt.syncer, err)
+			}
+			wq.Put(teststate.Item1, teststate.Item2, item3)
+
+			isItem2 := func(i MarshallableQueueItem) bool {
+				id, _ := i.ID()
+				return id == "2"
+			}
+			got, err := wq.Remove(isItem2)
+			if err != nil {
+				t.Fatalf("wq.Remove() failed with %v", err)
+			}
+			if !cmp.Equal(got, MarshallableQueueItem(teststate.Item2)) {
+				t.Errorf("wq.Remove() = %v, want: %v", got, teststate.Item2)
+			}
+			if got, want := wq.Len(), 2; got != want {
+				t.Errorf("wq.Len() = %d, want: %d", got, want)
+			}
+			if wq.syncer != nil {
+				if got, _ := wq.syncer.LoadByID("2"); got != nil {
+					t.Errorf("syncer LoadByID(%q) = %v, want: nil", "2", got)
+				}
+			}
+
+			// Removing an item that is not in the queue is a no-op.
+			got, err = wq.Remove(isItem2)
+			if err != nil {
+				t.Fatalf("wq.Remove() failed with %v", err)
+			}
+			if got != nil {
+				t.Errorf("wq.Remove() = %v, want: nil", got)
+			}
+
+			// The remaining items keep their order.
+			for _, wantItem := range []MarshallableQueueItem{teststate.Item1, item3} {
+				if got, _ := wq.Get(); !cmp.Equal(wantItem, *got) {
+					t.Errorf("wq.Get() = %v, want: %v", *got, wantItem)
+				}
+			}
+			if !wq.IsConsistent() {
+				t.Error("wq.IsConsistent() = false, want: true")
+			}
+		})
+	}
+}
+
+func TestWrappedQueue_GetIf(t *testing.T) {
+	tests := []struct {
+		name   string
+		syncer persist.ItemSyncer
+	}{
+		{name: "no syncer", syncer: nil},
+		{name: "with syncer", syncer: teststate.NewItemSyncerWithDelete(true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wq, err := NewWrappedQueue(teststate.Type, tt.syncer)
+			if err != nil {
+				t.Fatalf("NewWrappedQueue(%s, %v) failed with %v", teststate.Type, tt.syncer, err)
+			}
+			isItem := func(want string) func(MarshallableQueueItem) bool {
+				return func(i MarshallableQueueItem) bool {
+					id, _ := i.ID()
+					return id == want
+				}
+			}
+
+			// An empty queue has no item to get.
+			got, err := wq.GetIf(isItem("1"))
+			if err != nil {
+				t.Fatalf("wq.GetIf() failed with %v", err)
+			}
+			if got != nil {
+				t.Errorf("wq.GetIf() = %v, want: nil", got)
+			}
+
+			wq.Put(teststate.Item1, teststate.Item2)
+
+			// Only the next item can be got.
+			got, err = wq.GetIf(isItem("2"))
+			if err != nil {
+				t.Fatalf("wq.GetIf() failed with %v", err)
+			}
+			if got != nil {
+				t.Errorf("wq.GetIf() = %v, want: nil", got)
+			}
+			if got, want := wq.Len(), 2; got != want {
+				t.Errorf("wq.Len() = %d, want: %d", got, want)
+			}
+
+			got, err = wq.GetIf(isItem("1"))
+			if err != nil {
+				t.Fatalf("wq.GetIf() failed with %v", err)
+			}
+			if !cmp.Equal(got, MarshallableQueueItem(teststate.Item1)) {
+				t.Errorf("wq.GetIf() = %v, want: %v", got, teststate.Item1)
+			}
+			if got, want := wq.Len(), 1; got != want {
+				t.Errorf("wq.Len() = %d, want: %d", got, want)
+			}
+			if wq.syncer != nil {
+				if got, _ := wq.syncer.LoadByID("1"); got != nil {
+					t.Errorf("syncer LoadByID(%q) = %v, want: nil", "1", got)
+				}
+			}
+			if !wq.IsConsistent() {
+				t.Error("wq.IsConsistent() = false, want: true")
+			}
+		})
+	}
+}
+
+func TestWrappedQueue_RemoveAll(t *testing.T) {
+	tests := []struct {
+		name   string
+		syncer persist.ItemSyncer
+	}{
+		{name: "no syncer", syncer: nil},
+		{name: "with syncer", syncer: teststate.NewItemSyncerWithDelete(true)},
+	}
+	item3 := teststate.NewItem("3")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wq, err := NewWrappedQueue(teststate.Type, tt.syncer)
+			if err != nil {
+				t.Fatalf("NewWrappedQueue(%s, %v) failed with %v", teststate.Type, tt.syncer, err)
+			}
+			wq.Put(teststate.Item1, teststate.Item2, item3)
+
+			notItem2 := func(i MarshallableQueueItem) bool {
+				id, _ := i.ID()
+				return id != "2"
+			}
+			got, err := wq.RemoveAll(notItem2)
+			if err != nil {
+				t.Fatalf("wq.RemoveAll() failed with %v", err)
+			}
+			if want := []MarshallableQueueItem{teststate.Item1, item3}; !cmp.Equal(got, want) {
+				t.Errorf("wq.RemoveAll() = %v, want: %v", got, want)
+			}
+			if got, want := wq.Len(), 1; got != want {
+				t.Errorf("wq.Len() = %d, want: %d", got, want)
+			}
+			if wq.syncer != nil {
+				for _, id := range []string{"1", "3"} {
+					if got, _ := wq.syncer.LoadByID(id); got != nil {
+						t.Errorf("syncer LoadByID(%q) = %v, want: nil", id, got)
+					}
+				}
+			}
+
+			// Removing items that are not in the queue is a no-op.
+			got, err = wq.RemoveAll(notItem2)
+			if err != nil {
+				t.Fatalf("wq.RemoveAll() failed with %v", err)
+			}
+			if len(got) != 0 {
+				t.Errorf("wq.RemoveAll() = %v, want: no items", got)
+			}
+			if got, _ := wq.Get(); !cmp.Equal(MarshallableQueueItem(teststate.Item2), *got) {
+				t.Errorf("wq.Get() = %v, want: %v", *got, teststate.Item2)
+			}
+			if !wq.IsConsistent() {
+				t.Error("wq.IsConsistent() = false, want: true")
+			}
+		})
+	}
+}
+
+func TestWrappedQueue_Update(t *testing.T) {
+	tests := []struct {
+		name   string
+		syncer persist.ItemSyncer
+	}{
+		{name: "no syncer", syncer: nil},
+		{name: "with syncer", syncer: teststate.NewItemSyncerWithDelete(true)},
+	}
+	item3 := teststate.NewItem("3")
+	item4 := teststate.NewItem("4")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wq, err := NewWrappedQueue(teststate.Type, tt.syncer)
+			if err != nil {
+				t.Fatalf("NewWrappedQueue(%s, %v) failed with %v", teststate.Type, tt.syncer, err)
+			}
+			wq.Put(teststate.Item1, teststate.Item2, item3)
+
+			notItem2 := func(i MarshallableQueueItem) bool {
+				id, _ := i.ID()
+				return id != "2"
+			}
+
+			// If update fails, the queue doesn't change.
+			_, err = wq.Update(notItem2, func([]MarshallableQueueItem) ([]MarshallableQueueItem, error) {
+				return nil, errors.New("update failed")
+			})
+			if err == nil {
+				t.Error("wq.Update() got nil error, want error")
+			}
+			if got, want := wq.Len(), 3; got != want {
+				t.Errorf("wq.Len() = %d, want: %d", got, want)
+			}
+
+			var gotMatched []MarshallableQueueItem
+			got, err := wq.Update(notItem2, func(matched []MarshallableQueueItem) ([]MarshallableQueueItem, error) {
+				gotMatched = matched
+				return []MarshallableQueueItem{item4}, nil
+			})
+			if err != nil {
+				t.Fatalf("wq.Update() failed with %v", err)
+			}
+			if want := []MarshallableQueueItem{teststate.Item1, item3}; !cmp.Equal(gotMatched, want) {
+				t.Errorf("wq.Update() called update with %v, want: %v", gotMatched, want)
+			}
+			if want := []MarshallableQueueItem{item4}; !cmp.Equal(got, want) {
+				t.Errorf("wq.Update() = %v, want: %v", got, want)
+			}
+			if got, want := wq.Len(), 2; got != want {
+				t.Errorf("wq.Len() = %d, want: %d", got, want)
+			}
+			if wq.syncer != nil {
+				for id, wantInSyncer := range map[string]bool{"1": false, "3": false, "4": true} {
+					if got, _ := wq.syncer.LoadByID(id); (got != nil) != wantInSyncer {
+						t.Errorf("syncer LoadByID(%q) = %v, want in syncer: %t", id, got, wantInSyncer)
+					}
+				}
+			}
+			if !wq.IsConsistent() {
+				t.Error("wq.IsConsistent() = false, want: true")
+			}
+		})
+	}
+}
+
+func TestWrappedQueue_Find(t *testing.T) {
+	wq, err := NewWrappedQueue(teststate.Type, nil)
+	if err != nil {
+		t.Fatalf("NewWrappedQueue(%s, nil) failed with %v", teststate.Type, err)
+	}
+	wq.Put(teststate.Item1, teststate.Item2)
+
+	isItem2 := func(i MarshallableQueueItem) bool {
+		id, _ := i.ID()
+		return id == "2"
+	}
+	got := wq.Find(isItem2)
+	if diff := cmp.Diff([]MarshallableQueueItem{teststate.Item2}, got); diff != "" {
+		t.Errorf("wq.Find() got diff (-want, +got):\n%s", diff)
+	}
+	if got, want := wq.Len(), 2; got != want {
+		t.Errorf("wq.Len() = %d, want: %d", got, want)
+	}
+
+	none := func(i MarshallableQueueItem) bool { return false }
+	if got := wq.Find(none); len(got) != 0 {
+		t.Errorf("wq.Find() = %v, want: no items", got)
+	}
+}
+
+func TestWrappedQueue_Items(t *testing.T) {
+	wq, err := NewWrappedQueue(teststate.Type, nil)
+	if err != nil {
+		t.Fatalf("NewWrappedQueue(%s, nil) failed with %v", teststate.Type, err)
+	}
+	item3 := teststate.NewItem("3")
+	wq.Put(item3, teststate.Item2, teststate.Item1)
+
+	got, err := wq.Items()
+	if err != nil {
+		t.Fatalf("wq.Items() failed with %v", err)
+	}
+	want := []MarshallableQueueItem{teststate.Item1, teststate.Item2, item3}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wq.Items() got diff (-want, +got):\n%s", diff)
+	}
+	// The items are still in the queue, in the same order.
+	for _, wantItem := range want {
+		if got, _ := wq.Get(); !cmp.Equal(wantItem, *got) {
+			t.Errorf("wq.Get() = %v, want: %v", *got, wantItem)
+		}
+	}
+}
+
+func TestWrappedQueue_Clear(t *testing.T) {
+	syncer := teststate.NewItemSyncerWithDelete(true)
+	wq, err := NewWrappedQueue(teststate.Type, syncer)
+	if err != nil {
+		t.Fatalf("NewWrappedQueue(%s, %v) failed with %v", teststate.Type, syncer, err)
+	}
+	wq.Put(teststate.Item1, teststate.Item2)
+
+	if err := wq.Clear(); err != nil {
+		t.Fatalf("wq.Clear() failed with %v", err)
+	}
+	if !wq.Empty() {
+		t.Error("wq.Empty() = false, want: true")
+	}
+	if got, want := wq.Len(), 0; got != want {
+		t.Errorf("wq.Len() = %d, want: %d", got, want)
+	}
+	if got, _ := syncer.LoadAll(); len(got) != 0 {
+		t.Errorf("syncer.LoadAll() = %v, want: no items", got)
+	}
+	if !wq.IsConsistent() {
+		t.Error("wq.IsConsistent() = false, want: true")
+	}
+}
+
 func TestWrappedQueue_Len(t *testing.T) {
 	wq, err := NewWrappedQueue(teststate.Type, nil)
 	if err != nil {
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"github.com/Arend-melissant/simhospital/pkg/state/persist"
+	"github.com/pkg/errors"
+)
+
+// unmarshallers maps the item types to the unmarshallers of their items.
+var unmarshallers = map[string]persist.Unmarshaller{
+	MessageItemType: MessageUnmarshaller{},
+	EventItemType:   EventUnmarshaller{},
+	PatientItemType: &PatientUnmarshaller{},
+}
+
+// UnmarshallerFor returns the unmarshaller of the items of the given type, which must be one of
+// MessageItemType, EventItemType or PatientItemType.
+func UnmarshallerFor(itemType string) (persist.Unmarshaller, error) {
+	u, ok := unmarshallers[itemType]
+	if !ok {
+		return nil, errors.Errorf("unsupported item type %q", itemType)
+	}
+	return u, nil
+}
+
+// NewItemSyncer returns a syncer of items of the given type, which must be one of MessageItemType,
+// EventItemType or PatientItemType, that persists them in the given store.
+// If delete is false, the syncer only deletes items when they are purged.
+func NewItemSyncer(store persist.RawStore, itemType string, delete bool) (*persist.Syncer, error) {
+	u, err := UnmarshallerFor(itemType)
+	if err != nil {
+		return nil, err
+	}
+	return persist.NewSyncer(store, u, delete), nil
+}
+
+// NewItemSyncers returns the syncers of messages, events and patients, keyed by item type, that
+// persist the items of each type in the store returned by newStore. Messages and events are deleted
+// once they are processed; patients are never deleted, so that they can be used again after a
+// restart.
+func NewItemSyncers(newStore func(itemType string) persist.RawStore) map[string]persist.ItemSyncer {
+	syncers := map[string]persist.ItemSyncer{}
+	for itemType, u := range unmarshallers {
+		syncers[itemType] = persist.NewSyncer(newStore(itemType), u, itemType != PatientItemType)
+	}
+	return syncers
+}
@@ -110,10 +110,18 @@ var (
 	ClinicalNoteTypesConfigProd = path.Join(prodConfigDir, "hl7_messages", "third_party", "note_types.txt")
 	// LocationsConfigProd is the path to the prod locations config file.
 	LocationsConfigProd = path.Join(prodConfigDir, "hl7_messages", "locations.yml")
+	// RateProfileConfigProd is the path to the prod example rate profile file.
+	RateProfileConfigProd = path.Join(prodConfigDir, "rate_profile.yml")
+	// RateGroupsConfigProd is the path to the prod example rate groups file.
+	RateGroupsConfigProd = path.Join(prodConfigDir, "rate_groups.yml")
+	// CalendarConfigProd is the path to the prod calendar config file.
+	CalendarConfigProd = path.Join(prodConfigDir, "hl7_messages", "calendar.yml")
 	// PathwaysDirProd is the path to the directory with prod pathways.
 	PathwaysDirProd = path.Join(prodConfigDir, "pathways")
 	// HardcodedMessagesDirProd is the path to the prod directory with hardcoded messages.
 	HardcodedMessagesDirProd = path.Join(prodConfigDir, "hardcoded_messages")
+	// SchemaDirProd is the path to the directory with the JSON Schemas of the configuration files.
+	SchemaDirProd = path.Join(prodConfigDir, "schema")
 
 	// DataFiles contains sets of data files for testing.
 	DataFiles = map[ConfigType]config.DataFiles{
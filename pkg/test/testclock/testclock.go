@@ -16,11 +16,13 @@
 package testclock
 
 import (
+	"sync"
 	"time"
 )
 
-// Clock is a clock used for testing.
+// Clock is a clock used for testing. It is safe for concurrent use.
 type Clock struct {
+	mu   sync.Mutex
 	now  time.Time
 	tick time.Duration
 }
@@ -39,12 +41,20 @@ func WithTick(now time.Time, tick time.Duration) *Clock {
 // Now returns the current time as seen by the Clock and advances the time
 // the duration of the tick.
 func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	nowToReturn := c.now
-	c.Advance(c.tick)
+	c.advance(c.tick)
 	return nowToReturn
 }
 
 // Advance advances the clock the specified duration.
 func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(d)
+}
+
+func (c *Clock) advance(d time.Duration) {
 	c.now = c.now.Add(d)
 }
@@ -238,6 +238,18 @@ func AllAL1(t *testing.T, message string) []*hl7.AL1 {
 	return al1
 }
 
+// AllNK1 returns all NK1 segments.
+func AllNK1(t *testing.T, message string) []*hl7.NK1 {
+	t.Helper()
+	m := Parse(t, message)
+
+	nk1, err := m.AllNK1()
+	if err != nil {
+		t.Fatalf("AllNK1() failed with %v", err)
+	}
+	return nk1
+}
+
 // AllOBX returns all OBX segments.
 func AllOBX(t *testing.T, message string) []*hl7.OBX {
 	t.Helper()
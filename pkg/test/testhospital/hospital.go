@@ -89,6 +89,9 @@ func WithTime(ctx context.Context, t *testing.T, cfg Config, now time.Time) *Hos
 	cfg.Arguments.MessageControlGenerator = cfg.Config.MessageControlGenerator
 	cfg.Arguments.Clock = clock
 	cfg.Arguments.DeletePatientsFromMemory = cfg.Config.DeletePatientsFromMemory
+	cfg.Arguments.FullWardPolicy = cfg.Config.FullWardPolicy
+	cfg.Arguments.ReturningPatients = cfg.Config.ReturningPatients
+	cfg.Arguments.Retention = cfg.Config.Retention
 	if cfg.Config.DataFiles != (config.DataFiles{}) {
 		cfg.Arguments.DataFiles = &cfg.Config.DataFiles
 	}
@@ -118,6 +121,9 @@ func WithTime(ctx context.Context, t *testing.T, cfg Config, now time.Time) *Hos
 	if cfg.PathwayManager != nil {
 		c.PathwayManager = cfg.PathwayManager
 	}
+	if cfg.Calendar != nil {
+		c.Calendar = cfg.Calendar
+	}
 	if cfg.Sender != nil {
 		c.Sender = cfg.Sender
 	} else {
@@ -150,6 +156,11 @@ func WithTime(ctx context.Context, t *testing.T, cfg Config, now time.Time) *Hos
 	}
 }
 
+// AdvanceClock advances the clock of the hospital by the given duration.
+func (h *Hospital) AdvanceClock(d time.Duration) {
+	h.clock.Advance(d)
+}
+
 // ConsumeQueues consumes all events and messages and returns the number of events that were run
 // and the messages that were sent.
 // ConsumeQueues fails if processing a message or an event fails.
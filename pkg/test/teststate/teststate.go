@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/golang-collections/go-datastructures/queue"
 	"github.com/pkg/errors"
@@ -58,6 +59,16 @@ func (i Item) Marshal() ([]byte, error) {
 	return nil, errors.New("unsuccessful marshal")
 }
 
+// Start returns the item's start time, which is always the zero time.
+func (i Item) Start() time.Time {
+	return time.Time{}
+}
+
+// End returns the item's end time, which is always the zero time.
+func (i Item) End() time.Time {
+	return time.Time{}
+}
+
 // Compare compares two items in a priority queue.
 func (i Item) Compare(other queue.Item) int {
 	o, ok := other.(Item)
@@ -123,6 +134,17 @@ func (s *ItemSyncer) Delete(item persist.MarshallableItem) error {
 	return nil
 }
 
+// Purge deletes an item from the map, even if the syncer doesn't delete items.
+// Purges are not counted as deletions.
+func (s *ItemSyncer) Purge(item persist.MarshallableItem) error {
+	id, err := item.ID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get ID")
+	}
+	delete(s.m, id)
+	return nil
+}
+
 // LoadAll returns a slice of all the items in the map, sorted by id.
 func (s *ItemSyncer) LoadAll() ([]persist.MarshallableItem, error) {
 	keys := make([]string, 0)
@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trigger contains functionality to resume pathways waiting for external events.
+package trigger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Arend-melissant/simhospital/pkg/hl7"
+	"github.com/Arend-melissant/simhospital/pkg/logging"
+)
+
+var log = logging.ForCallerPackage()
+
+// Triggerer resumes pathways that are waiting for external events.
+type Triggerer interface {
+	// TriggerEvent triggers the external event with the given name for the patient with the given MRN.
+	TriggerEvent(mrn string, eventName string) error
+	// TriggerEventFromMessage triggers the external event represented by the given inbound HL7 message.
+	TriggerEventFromMessage(b []byte) error
+}
+
+// Controller handles requests that trigger external events.
+type Controller struct {
+	Triggerer Triggerer
+}
+
+// NewController creates a new Controller.
+func NewController(t Triggerer) *Controller {
+	return &Controller{Triggerer: t}
+}
+
+// ServeHTTP handles the requests to trigger external events.
+// Use a POST request with one of the following bodies:
+//   - A form-encoded request naming the patient and the event, e.g. "mrn=1234&event=lab_callback"
+//   - One inbound HL7v2 message where every segment is in a different line (separated by \n or \r).
+//     The message type and trigger event, e.g. "ORU^R01", is used as the event name, and the first
+//     identifier in PID-3 as the patient's MRN.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		c.handlePost(w, r)
+	case "GET", "PUT", "DELETE":
+		http.Error(w, fmt.Sprintf("Method %q not implemented", r.Method), http.StatusInternalServerError)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown method: %q", r.Method), http.StatusInternalServerError)
+	}
+}
+
+func (c *Controller) handlePost(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	errStr := "Failed to trigger external event"
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	sbody := strings.TrimSpace(string(body))
+	if strings.HasPrefix(sbody, "MSH") {
+		m := strings.Replace(sbody, "\n", hl7.SegmentTerminatorStr, -1)
+		if err := c.Triggerer.TriggerEventFromMessage([]byte(m)); err != nil {
+			log.WithError(err).Warning(errStr)
+			http.Error(w, fmt.Sprintf("%s: %v", errStr, err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	values, err := url.ParseQuery(sbody)
+	if err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+	mrn, event := values.Get("mrn"), values.Get("event")
+	if mrn == "" || event == "" {
+		log.Warningf("%s: missing mrn or event in request body: %q", errStr, sbody)
+		http.Error(w, `Error extracting values: the request must be in the format "mrn=X&event=Y"`, http.StatusBadRequest)
+		return
+	}
+	if err := c.Triggerer.TriggerEvent(mrn, event); err != nil {
+		log.WithError(err).Warning(errStr)
+		http.Error(w, fmt.Sprintf("%s: %v", errStr, err), http.StatusNotFound)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trigger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type fakeTriggerer struct {
+	mrn     string
+	event   string
+	message string
+	err     error
+}
+
+func (f *fakeTriggerer) TriggerEvent(mrn string, eventName string) error {
+	f.mrn = mrn
+	f.event = eventName
+	return f.err
+}
+
+func (f *fakeTriggerer) TriggerEventFromMessage(b []byte) error {
+	f.message = string(b)
+	return f.err
+}
+
+func TestTriggerHandlerPOST(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        string
+		err         error
+		wantStatus  int
+		wantMRN     string
+		wantEvent   string
+		wantMessage string
+	}{{
+		name:       "mrn and event",
+		body:       "mrn=1234&event=lab_callback",
+		wantStatus: http.StatusOK,
+		wantMRN:    "1234",
+		wantEvent:  "lab_callback",
+	}, {
+		name:        "HL7 message",
+		body:        "MSH|^~\\&|\nPID|1||1234",
+		wantStatus:  http.StatusOK,
+		wantMessage: "MSH|^~\\&|\rPID|1||1234",
+	}, {
+		name:       "missing event",
+		body:       "mrn=1234",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "missing mrn",
+		body:       "event=lab_callback",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "nothing waiting",
+		body:       "mrn=1234&event=lab_callback",
+		err:        errors.New("no pathway is waiting"),
+		wantStatus: http.StatusNotFound,
+		wantMRN:    "1234",
+		wantEvent:  "lab_callback",
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeTriggerer{err: tc.err}
+			ts := httptest.NewServer(NewController(f))
+			defer ts.Close()
+
+			res, err := http.Post(ts.URL, "text/plain", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("http.Post(%q) failed with %v", tc.body, err)
+			}
+			defer res.Body.Close()
+			if got, want := res.StatusCode, tc.wantStatus; got != want {
+				t.Errorf("StatusCode got %d, want %d", got, want)
+			}
+			if got, want := f.mrn, tc.wantMRN; got != want {
+				t.Errorf("TriggerEvent() mrn got %q, want %q", got, want)
+			}
+			if got, want := f.event, tc.wantEvent; got != want {
+				t.Errorf("TriggerEvent() event got %q, want %q", got, want)
+			}
+			if got, want := f.message, tc.wantMessage; got != want {
+				t.Errorf("TriggerEventFromMessage() message got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTriggerHandlerGET(t *testing.T) {
+	ts := httptest.NewServer(NewController(&fakeTriggerer{}))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("http.Get() failed with %v", err)
+	}
+	defer res.Body.Close()
+	if got, want := res.StatusCode, http.StatusInternalServerError; got != want {
+		t.Errorf("StatusCode got %d, want %d", got, want)
+	}
+}
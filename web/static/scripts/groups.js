@@ -0,0 +1,115 @@
+/**
+ * Copyright 2020 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+const PATHWAY_GROUPS_CONTAINER_ID = 'pathway-groups';
+const PATHWAY_GROUPS_SECTION_ID = 'pathway-groups-section';
+
+/**
+ * Returns the path of the endpoint with the given suffix for the given group.
+ * @param {string} group
+ * @param {string} suffix
+ * @return {string}
+ */
+function groupPath(group, suffix) {
+  const path =
+      document.getElementById(PATHWAY_GROUPS_CONTAINER_ID).dataset.path;
+  return `${path}/${encodeURIComponent(group)}/${suffix}`;
+}
+
+/**
+ * Sends a value to the endpoint with the given suffix for the given group,
+ * and reloads the groups if it succeeds.
+ * @param {string} group
+ * @param {string} suffix
+ * @param {string} value
+ */
+function postGroupValue(group, suffix, value) {
+  d3.request(groupPath(group, suffix))
+      .header('X-Requested-With', 'XMLHttpRequest')
+      .header('Content-Type', 'application/x-www-form-urlencoded')
+      .post(`value=${value}`, function(error, data) {
+        if (error) {
+          setGroupsStatus(error.target.responseText);
+          return;
+        }
+        loadGroups();
+      });
+}
+
+/**
+ * Shows the given status below the groups.
+ * @param {string} status
+ */
+function setGroupsStatus(status) {
+  d3.select(`#${PATHWAY_GROUPS_CONTAINER_ID} .group-status`).text(status);
+}
+
+/**
+ * Appends a row for each group, with the group's rate and buttons to change
+ * the rate and to pause or resume the group.
+ * @param {!Array<{name: string, rate: number, paused: boolean}>} groups
+ */
+function showGroups(groups) {
+  const container = d3.select(`#${PATHWAY_GROUPS_CONTAINER_ID}`);
+  container.selectAll('*').remove();
+  const rows = container.append('table')
+      .attr(HTML_ATTRIBUTES.class, 'group-table')
+      .selectAll('tr')
+      .data(groups)
+      .enter()
+      .append('tr');
+  rows.append('td').attr(HTML_ATTRIBUTES.class, 'group-name')
+      .text((g) => g.paused ? `${g.name} (paused)` : g.name);
+  rows.append('td').append('input')
+      .attr(HTML_ATTRIBUTES.type, 'number')
+      .attr(HTML_ATTRIBUTES.class, 'group-rate')
+      .attr('min', 0)
+      .attr('step', 'any')
+      .property(HTML_ATTRIBUTES.value, (g) => g.rate);
+  rows.append('td').append('input')
+      .attr(HTML_ATTRIBUTES.type, 'submit')
+      .attr(HTML_ATTRIBUTES.class, 'group-button')
+      .attr(HTML_ATTRIBUTES.value, 'Set rate')
+      .on('click', function(g) {
+        const rate = this.parentNode.parentNode.querySelector('.group-rate');
+        postGroupValue(g.name, 'rate', rate.value);
+      });
+  rows.append('td').append('input')
+      .attr(HTML_ATTRIBUTES.type, 'submit')
+      .attr(HTML_ATTRIBUTES.class, 'group-button')
+      .attr(HTML_ATTRIBUTES.value, (g) => g.paused ? 'Resume' : 'Pause')
+      .on('click', function(g) {
+        postGroupValue(g.name, 'paused', !g.paused);
+      });
+  container.append('text').attr(HTML_ATTRIBUTES.class, 'group-status');
+}
+
+/**
+ * Loads the groups from the backend and shows them. The section is only shown
+ * if there are groups.
+ */
+function loadGroups() {
+  d3.json(document.getElementById(PATHWAY_GROUPS_CONTAINER_ID).dataset.path,
+      function(error, groups) {
+        if (error || groups.length === 0) {
+          return;
+        }
+        document.getElementById(PATHWAY_GROUPS_SECTION_ID).style.display = '';
+        showGroups(groups);
+      });
+}
+
+loadGroups();
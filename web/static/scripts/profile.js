@@ -0,0 +1,82 @@
+/**
+ * Copyright 2020 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+const RATE_PROFILE_CONTAINER_ID = 'rate-profile';
+
+/**
+ * Appends the profile textarea, the save button and the status text to the
+ * rate profile container.
+ * @param {!Element} rateProfileContainer
+ */
+function appendProfileElements(rateProfileContainer) {
+  rateProfileContainer.append('textarea')
+      .attr(HTML_ATTRIBUTES.class, 'profile-text')
+      .attr(HTML_ATTRIBUTES.placeholder, 'Enter the rate profile in YML');
+  rateProfileContainer.append('input')
+      .attr(HTML_ATTRIBUTES.type, 'submit')
+      .attr(HTML_ATTRIBUTES.value, 'Save')
+      .attr(HTML_ATTRIBUTES.class, 'profile-button');
+  rateProfileContainer.append('text').attr(
+      HTML_ATTRIBUTES.class, 'profile-status');
+}
+
+/** Loads the current profile from the backend into the textarea. */
+function loadProfile() {
+  d3.request(document.getElementById(RATE_PROFILE_CONTAINER_ID).dataset.path)
+      .get(function(error, data) {
+        if (error) {
+          setProfileStatus(`Cannot load the profile: ${error.target.responseText}`);
+          return;
+        }
+        document.getElementsByClassName('profile-text')[0].value = data.response;
+      });
+}
+
+/**
+ * Sends the specified profile to the backend.
+ * @param {string} profile
+ */
+function saveProfile(profile) {
+  d3.request(document.getElementById(RATE_PROFILE_CONTAINER_ID).dataset.path)
+      .header('X-Requested-With', 'XMLHttpRequest')
+      .header('Content-Type', 'text/plain')
+      .post(profile, function(error, data) {
+        if (error) {
+          setProfileStatus(error.target.responseText);
+          return;
+        }
+        setProfileStatus(
+            profile.trim() === '' ? 'Profile removed.' : 'Profile saved.');
+      });
+}
+
+/**
+ * Shows the given status below the profile.
+ * @param {string} status
+ */
+function setProfileStatus(status) {
+  d3.select(`#${RATE_PROFILE_CONTAINER_ID} .profile-status`).text(status);
+}
+
+// Select the rate profile container, attach the elements and load the profile.
+const rateProfileContainer = d3.select(`#${RATE_PROFILE_CONTAINER_ID}`);
+appendProfileElements(rateProfileContainer);
+loadProfile();
+
+// Attach a click handler to the save button.
+d3.select('.profile-button').on('click', function() {
+  saveProfile(document.getElementsByClassName('profile-text')[0].value);
+});